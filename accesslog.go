@@ -0,0 +1,216 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+const l5dHeaderDstPrefix = "l5d-dst-"
+
+// A LogFormat determines how AccessLog formats each log entry.
+type LogFormat int
+
+// Log formats.
+const (
+	// LogJSON formats each entry as a single line JSON object.
+	LogJSON LogFormat = iota
+
+	// LogCombined formats each entry per the Apache combined log format,
+	// followed by space separated key=value trace and linkerd fields.
+	LogCombined
+)
+
+// An accessLogEntry is a single entry in an access log.
+type accessLogEntry struct {
+	Time      time.Time         `json:"time"`
+	Remote    string            `json:"remote"`
+	Method    string            `json:"method"`
+	URI       string            `json:"uri"`
+	Proto     string            `json:"proto"`
+	Status    int               `json:"status"`
+	Bytes     int64             `json:"bytes"`
+	Duration  float64           `json:"duration_seconds"`
+	Referer   string            `json:"referer,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	TraceID   string            `json:"trace_id,omitempty"`
+	SpanID    string            `json:"span_id,omitempty"`
+	Sampled   bool              `json:"sampled"`
+	Dst       map[string]string `json:"dst,omitempty"`
+}
+
+// AccessLog is an http.Handler that writes an access log entry for each request
+// it serves. Each entry includes the trace ID, span ID, and sampling state of
+// the span in the request's context, and the linkerd destination (l5d-dst-*)
+// headers of the request. Trace IDs are hex encoded as they are reported to
+// Zipkin. AccessLog must be wrapped by an ochttp.Handler.
+type AccessLog struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Writer is the writer to which log entries are written. Entries are
+	// discarded if Writer is nil.
+	Writer io.Writer
+
+	// Format determines how each log entry is formatted.
+	Format LogFormat
+
+	mx sync.Mutex
+}
+
+// ServeHTTP serves the request, then logs it.
+func (l *AccessLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	l.Handler.ServeHTTP(sw, r)
+	if l.Writer == nil {
+		return
+	}
+
+	e := accessLogEntry{
+		Time:      start,
+		Remote:    r.RemoteAddr,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Status:    sw.status(),
+		Bytes:     sw.bytes,
+		Duration:  time.Since(start).Seconds(),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+	if e.URI == "" {
+		e.URI = r.URL.RequestURI()
+	}
+	if span := trace.FromContext(r.Context()); span != nil {
+		sc := span.SpanContext()
+		e.TraceID = traceIDHex(sc.TraceID)
+		e.SpanID = sc.SpanID.String()
+		e.Sampled = sc.IsSampled()
+	}
+	for k := range r.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, l5dHeaderDstPrefix) {
+			if e.Dst == nil {
+				e.Dst = map[string]string{}
+			}
+			e.Dst[strings.TrimPrefix(lk, l5dHeaderDstPrefix)] = r.Header.Get(k)
+		}
+	}
+
+	l.write(e)
+}
+
+func (l *AccessLog) write(e accessLogEntry) {
+	var line []byte
+	switch l.Format {
+	case LogCombined:
+		line = []byte(combined(e))
+	default:
+		var err error
+		if line, err = json.Marshal(e); err != nil {
+			return
+		}
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.Writer.Write(append(line, '\n'))
+}
+
+func combined(e accessLogEntry) string {
+	host, _, err := net.SplitHostPort(e.Remote)
+	if err != nil {
+		host = e.Remote
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s - - [%s] %q %d %d %q %q trace_id=%s span_id=%s sampled=%t",
+		dash(host), e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method+" "+e.URI+" "+e.Proto,
+		e.Status, e.Bytes, dash(e.Referer), dash(e.UserAgent), dash(e.TraceID), dash(e.SpanID), e.Sampled)
+
+	keys := make([]string, 0, len(e.Dst))
+	for k := range e.Dst {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, " %s%s=%q", l5dHeaderDstPrefix, k, e.Dst[k])
+	}
+	return b.String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// statusWriter is an http.ResponseWriter that records the status code and
+// number of bytes written.
+type statusWriter struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot hijack %T", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
+
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
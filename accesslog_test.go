@@ -0,0 +1,123 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestAccessLog(t *testing.T) {
+	cases := []struct {
+		name   string
+		format LogFormat
+		header string
+		check  func(t *testing.T, line []byte, sc trace.SpanContext)
+	}{
+		{
+			name:   "JSON",
+			format: LogJSON,
+			check: func(t *testing.T, line []byte, sc trace.SpanContext) {
+				got := accessLogEntry{}
+				if err := json.Unmarshal(line, &got); err != nil {
+					t.Fatalf("json.Unmarshal(%s): %v", line, err)
+				}
+				want := accessLogEntry{
+					Remote:   "192.0.2.1:1234",
+					Method:   "GET",
+					URI:      "/coolpath?a=b",
+					Proto:    "HTTP/1.1",
+					Status:   http.StatusTeapot,
+					Bytes:    5,
+					TraceID:  traceIDHex(sc.TraceID),
+					SpanID:   sc.SpanID.String(),
+					Sampled:  true,
+					Dst:      map[string]string{"service": "/svc/example", "client": "/#/io.l5d.k8s/default/http/example"},
+					Duration: got.Duration,
+					Time:     got.Time,
+				}
+				if !equalEntries(want, got) {
+					t.Errorf("l.ServeHTTP(): want entry %+v, got %+v", want, got)
+				}
+			},
+		},
+		{
+			name:   "Combined",
+			format: LogCombined,
+			check: func(t *testing.T, line []byte, sc trace.SpanContext) {
+				want := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /coolpath\?a=b HTTP/1\.1" 418 5 "-" "-" ` +
+					`trace_id=` + traceIDHex(sc.TraceID) + ` span_id=` + sc.SpanID.String() + ` sampled=true ` +
+					`l5d-dst-client="/#/io.l5d.k8s/default/http/example" l5d-dst-service="/svc/example"\n$`)
+				if !want.Match(line) {
+					t.Errorf("l.ServeHTTP(): want entry matching %s, got %s", want, line)
+				}
+			},
+		},
+		{
+			name:   "Combined64BitTrace",
+			format: LogCombined,
+			header: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			check: func(t *testing.T, line []byte, sc trace.SpanContext) {
+				if want := []byte(" trace_id=32a4db20f5d592e7 "); !bytes.Contains(line, want) {
+					t.Errorf("l.ServeHTTP(): want entry containing %q, got %s", want, line)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			var sc trace.SpanContext
+			h := &ochttp.Handler{
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+				Handler: &AccessLog{
+					Writer: b,
+					Format: tc.format,
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						sc = trace.FromContext(r.Context()).SpanContext()
+						w.WriteHeader(http.StatusTeapot)
+						w.Write([]byte("teapo"))
+					}),
+				},
+			}
+			r := httptest.NewRequest("GET", "/coolpath?a=b", nil)
+			r.Header.Set("l5d-dst-service", "/svc/example")
+			r.Header.Set("l5d-dst-client", "/#/io.l5d.k8s/default/http/example")
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			tc.check(t, b.Bytes(), sc)
+		})
+	}
+}
+
+func equalEntries(a, b accessLogEntry) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package admin provides a client for the subset of linkerd's admin API that
+// is relevant to tracing, i.e. linkerd's version, its routers, and the
+// sampling configuration of its telemeters.
+// https://linkerd.io/1/administration/
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/planetlabs/linkin"
+)
+
+// TelemeterZipkin is the kind of linkerd's Zipkin telemeter.
+const TelemeterZipkin = "io.l5d.zipkin"
+
+// A Client of linkerd's admin API.
+type Client struct {
+	// URL is the base URL of linkerd's admin API, e.g. http://linkerd:9990.
+	URL string
+
+	// HTTPClient is used to send requests to linkerd. http.DefaultClient is
+	// used if HTTPClient is nil.
+	HTTPClient *http.Client
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// get decodes the JSON document at the supplied admin API path into v.
+func (c *Client) get(ctx context.Context, path, what string, v interface{}) error {
+	r, err := http.NewRequest("GET", strings.TrimRight(c.URL, "/")+path, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create %s request: %v", what, err)
+	}
+	r.Header.Set("Accept", "application/json")
+
+	rsp, err := c.client().Do(r.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("cannot get %s: %v", what, err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot get %s: linkerd returned %s", what, rsp.Status)
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(v); err != nil {
+		return fmt.Errorf("cannot decode %s: %v", what, err)
+	}
+	return nil
+}
+
+// ServerInfo describes a running linkerd.
+type ServerInfo struct {
+	// Version is the linkerd version, e.g. 1.3.6.
+	Version string `json:"version"`
+
+	// Build is the linkerd build revision.
+	Build string `json:"build"`
+}
+
+// ServerInfo returns information about the running linkerd.
+func (c *Client) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	i := ServerInfo{}
+	err := c.get(ctx, "/admin/server_info", "server info", &i)
+	return i, err
+}
+
+// Capabilities returns the capabilities of the running linkerd, per its
+// version. The result may be used to create a linkin.Detector.
+func (c *Client) Capabilities(ctx context.Context) (linkin.Capabilities, error) {
+	i, err := c.ServerInfo(ctx)
+	if err != nil {
+		return linkin.Capabilities{}, err
+	}
+	return linkin.CapabilitiesForVersion(i.Version)
+}
+
+// Config is the subset of linkerd's configuration that is relevant to tracing.
+type Config struct {
+	// Routers are linkerd's routers.
+	Routers []Router `json:"routers"`
+
+	// Telemeters are linkerd's telemeters.
+	Telemeters []Telemeter `json:"telemeters"`
+}
+
+// A Router is a linkerd router.
+type Router struct {
+	// Label is the router's label. Routers are labelled by their protocol if
+	// no label is configured.
+	Label string `json:"label"`
+
+	// Protocol is the router's protocol, e.g. http.
+	Protocol string `json:"protocol"`
+
+	// Dtab is the router's base dtab.
+	Dtab string `json:"dtab"`
+
+	// Servers are the servers on which the router listens.
+	Servers []Server `json:"servers"`
+}
+
+// Name returns the router's label, or its protocol if it has no label.
+func (r Router) Name() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return r.Protocol
+}
+
+// A Server is an address on which a router listens.
+type Server struct {
+	// IP is the IP address on which the server listens. linkerd listens on
+	// the loopback address if IP is empty.
+	IP string `json:"ip"`
+
+	// Port is the port on which the server listens.
+	Port int `json:"port"`
+}
+
+// A Telemeter exports linkerd's telemetry, e.g. traces.
+type Telemeter struct {
+	// Kind is the kind of telemeter, e.g. io.l5d.zipkin.
+	Kind string `json:"kind"`
+
+	// SampleRate is the fraction of traces sampled by the telemeter, if it
+	// samples traces.
+	SampleRate *float64 `json:"sampleRate,omitempty"`
+}
+
+// SampleRate returns the sample rate of linkerd's Zipkin telemeter. It returns
+// false if linkerd has no Zipkin telemeter, or its sample rate is not set.
+func (c Config) SampleRate() (float64, bool) {
+	for _, t := range c.Telemeters {
+		if t.Kind == TelemeterZipkin && t.SampleRate != nil {
+			return *t.SampleRate, true
+		}
+	}
+	return 0, false
+}
+
+// Router returns the router with the supplied name. See Router.Name.
+func (c Config) Router(name string) (Router, bool) {
+	for _, r := range c.Routers {
+		if r.Name() == name {
+			return r, true
+		}
+	}
+	return Router{}, false
+}
+
+// Config returns the running linkerd's configuration.
+func (c *Client) Config(ctx context.Context) (Config, error) {
+	cfg := Config{}
+	err := c.get(ctx, "/config.json", "config", &cfg)
+	return cfg, err
+}
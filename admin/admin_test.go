@@ -0,0 +1,156 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+)
+
+func linkerd(version string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/server_info":
+			w.Write([]byte(`{"name":"linkerd","version":"` + version + `","build":"f2ad5b1"}`))
+		case "/config.json":
+			w.Write([]byte(`{
+				"admin": {"port": 9990},
+				"routers": [
+					{"protocol": "http", "dtab": "/svc => /#/io.l5d.k8s/default/http", "servers": [{"ip": "0.0.0.0", "port": 4140}]},
+					{"protocol": "http", "label": "incoming", "servers": [{"port": 4141}]}
+				],
+				"telemeters": [
+					{"kind": "io.l5d.recentRequests", "sampleRate": 1.0},
+					{"kind": "io.l5d.zipkin", "host": "zipkin", "port": 9410, "sampleRate": 0.02}
+				]
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestServerInfo(t *testing.T) {
+	cases := []struct {
+		name    string
+		version string
+		want    linkin.Capabilities
+		wantErr bool
+	}{
+		{
+			name:    "TraceID128",
+			version: "1.3.6",
+			want:    linkin.Capabilities{TraceID128: true},
+		},
+		{
+			name:    "TraceID64",
+			version: "1.2.1",
+			want:    linkin.Capabilities{},
+		},
+		{
+			name:    "InvalidVersion",
+			version: "unknown",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := linkerd(tc.version)
+			defer s.Close()
+
+			c := &Client{URL: s.URL}
+			i, err := c.ServerInfo(context.Background())
+			if err != nil {
+				t.Fatalf("c.ServerInfo(): %v", err)
+			}
+			if i.Version != tc.version {
+				t.Errorf("c.ServerInfo(): want version %s, got %s", tc.version, i.Version)
+			}
+
+			got, err := c.Capabilities(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("c.Capabilities(): want error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("c.Capabilities(): want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	s := linkerd("1.3.6")
+	defer s.Close()
+
+	c := &Client{URL: s.URL}
+	cfg, err := c.Config(context.Background())
+	if err != nil {
+		t.Fatalf("c.Config(): %v", err)
+	}
+
+	if got, ok := cfg.SampleRate(); !ok || got != 0.02 {
+		t.Errorf("cfg.SampleRate(): want 0.02, got %v (%t)", got, ok)
+	}
+
+	cases := []struct {
+		name string
+		want Router
+		ok   bool
+	}{
+		{
+			name: "http",
+			want: Router{Protocol: "http", Dtab: "/svc => /#/io.l5d.k8s/default/http", Servers: []Server{{IP: "0.0.0.0", Port: 4140}}},
+			ok:   true,
+		},
+		{
+			name: "incoming",
+			want: Router{Protocol: "http", Label: "incoming", Servers: []Server{{Port: 4141}}},
+			ok:   true,
+		},
+		{
+			name: "h2",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := cfg.Router(tc.name)
+			if ok != tc.ok {
+				t.Fatalf("cfg.Router(%q): want ok %t, got %t", tc.name, tc.ok, ok)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("cfg.Router(%q):\ngot:  %+v\nwant: %+v", tc.name, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+
+	c := &Client{URL: s.URL}
+	if _, err := c.Config(context.Background()); err == nil {
+		t.Errorf("c.Config(): want error, got nil")
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// A Disagreement describes how the span contexts propagated by a request's
+// linkerd and B3 headers disagree.
+type Disagreement struct {
+	// Linkerd is the span context decoded from the l5d-ctx-trace header.
+	Linkerd trace.SpanContext
+
+	// B3 is the span context decoded from the B3 headers.
+	B3 trace.SpanContext
+
+	// TraceID is true if the trace IDs disagree.
+	TraceID bool
+
+	// Sampled is true if the sampling decisions disagree. Sampling decisions
+	// are only compared when both formats carry one.
+	Sampled bool
+}
+
+// Fields returns the names of the fields in disagreement, as recorded by the
+// KeyDisagreement tag.
+func (d Disagreement) Fields() []string {
+	f := []string{}
+	if d.TraceID {
+		f = append(f, "trace_id")
+	}
+	if d.Sampled {
+		f = append(f, "sampled")
+	}
+	return f
+}
+
+// String describes the disagreement.
+func (d Disagreement) String() string {
+	return fmt.Sprintf("disagree=%s l5d_trace_id=%s l5d_sampled=%t b3_trace_id=%s b3_sampled=%t",
+		strings.Join(d.Fields(), ","), d.Linkerd.TraceID, d.Linkerd.IsSampled(), d.B3.TraceID, d.B3.IsSampled())
+}
+
+// Differences returns all fields in which the linkerd and B3 span contexts
+// differ, including those (e.g. the span ID) that are not reported as a
+// disagreement.
+func (d Disagreement) Differences() []Difference {
+	return DiffSpanContexts(d.Linkerd, d.B3)
+}
+
+// CheckAgreement decodes both the linkerd and B3 headers of the supplied
+// request, and reports whether they disagree. It returns false if the request
+// does not carry valid span context in both formats, or if they agree. Mesh
+// components that translate between formats should always produce agreeing
+// headers; disagreements indicate a broken translator.
+func CheckAgreement(r *http.Request) (Disagreement, bool) {
+	id, ok := TraceIDFromRequest(r)
+	if !ok {
+		return Disagreement{}, false
+	}
+	b, ok := (&B3Format{}).SpanContextFromRequest(r)
+	if !ok {
+		return Disagreement{}, false
+	}
+
+	d := Disagreement{Linkerd: id.SpanContext(), B3: b}
+	d.TraceID = d.Linkerd.TraceID != d.B3.TraceID
+
+	l5dKnown := id.Flags&(wire.FlagSamplingKnown|wire.FlagDebug) != 0
+	b3Known := r.Header.Get(b3.SampledHeader) != "" || r.Header.Get(b3HeaderFlags) != ""
+	d.Sampled = l5dKnown && b3Known && d.Linkerd.IsSampled() != d.B3.IsSampled()
+
+	return d, d.TraceID || d.Sampled
+}
+
+// AgreementHandler is an http.Handler that reports requests whose linkerd and
+// B3 headers disagree, pinpointing misconfigured translators in the request
+// path. Each disagreement is recorded by the Disagreements measure, once for
+// each field in disagreement. See CheckAgreement.
+type AgreementHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Report is called with each request whose headers disagree, before the
+	// request is served. Disagreements are not reported if Report is nil.
+	Report func(r *http.Request, d Disagreement)
+}
+
+// ServeHTTP records and reports any disagreement, then serves the request.
+func (h *AgreementHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if d, ok := CheckAgreement(r); ok {
+		for _, f := range d.Fields() {
+			stats.RecordWithTags(r.Context(), []tag.Mutator{tag.Upsert(KeyDisagreement, f)}, Disagreements.M(1))
+		}
+		if h.Report != nil {
+			h.Report(r, d)
+		}
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// LogDisagreements returns a function suitable for use as the Report function
+// of an AgreementHandler that logs each disagreement to the supplied writer, one
+// line per request, including the address from which the request was received
+// and any linkerd destination (l5d-dst-*) headers, to help locate the
+// translator at fault.
+func LogDisagreements(w io.Writer) func(r *http.Request, d Disagreement) {
+	mx := &sync.Mutex{}
+	return func(r *http.Request, d Disagreement) {
+		b := &strings.Builder{}
+		fmt.Fprintf(b, "%s %s %s %s", r.RemoteAddr, r.Method, r.URL.RequestURI(), d)
+		dst := []string{}
+		for k := range r.Header {
+			if lk := strings.ToLower(k); strings.HasPrefix(lk, l5dHeaderDstPrefix) {
+				dst = append(dst, lk)
+			}
+		}
+		sort.Strings(dst)
+		for _, k := range dst {
+			fmt.Fprintf(b, " %s=%q", k, r.Header.Get(k))
+		}
+		mx.Lock()
+		defer mx.Unlock()
+		fmt.Fprintln(w, b.String())
+	}
+}
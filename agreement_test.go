@@ -0,0 +1,135 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestCheckAgreement(t *testing.T) {
+	// A sampled l5d-ctx-trace header with trace ID 32a4db20f5d592e7.
+	const l5d = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+
+	cases := []struct {
+		name    string
+		header  map[string]string
+		want    bool
+		traceID bool
+		sampled bool
+	}{
+		{
+			name:   "Agree",
+			header: map[string]string{l5dHeaderTrace: l5d, "X-B3-Traceid": "32a4db20f5d592e7", "X-B3-Spanid": "f4141d5dc0c935d0", "X-B3-Sampled": "1"},
+		},
+		{
+			name:   "AgreeWithoutB3SamplingDecision",
+			header: map[string]string{l5dHeaderTrace: l5d, "X-B3-Traceid": "32a4db20f5d592e7", "X-B3-Spanid": "f4141d5dc0c935d0"},
+		},
+		{
+			name:    "TraceIDsDisagree",
+			header:  map[string]string{l5dHeaderTrace: l5d, "X-B3-Traceid": "463ac35c9f6413ad", "X-B3-Spanid": "f4141d5dc0c935d0", "X-B3-Sampled": "1"},
+			want:    true,
+			traceID: true,
+		},
+		{
+			name:    "SamplingDisagrees",
+			header:  map[string]string{l5dHeaderTrace: l5d, "X-B3-Traceid": "32a4db20f5d592e7", "X-B3-Spanid": "f4141d5dc0c935d0", "X-B3-Sampled": "0"},
+			want:    true,
+			sampled: true,
+		},
+		{
+			name:   "OnlyLinkerd",
+			header: map[string]string{l5dHeaderTrace: l5d},
+		},
+		{
+			name:   "OnlyB3",
+			header: map[string]string{"X-B3-Traceid": "463ac35c9f6413ad", "X-B3-Spanid": "f4141d5dc0c935d0"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := view.Register(DisagreementsView); err != nil {
+				t.Fatalf("view.Register(): %v", err)
+			}
+			defer view.Unregister(DisagreementsView)
+
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+
+			var reported bool
+			h := &AgreementHandler{
+				Handler: http.NotFoundHandler(),
+				Report: func(_ *http.Request, d Disagreement) {
+					reported = true
+					if d.TraceID != tc.traceID || d.Sampled != tc.sampled {
+						t.Errorf("h.ServeHTTP(): want disagreement on trace ID %t and sampling %t, got %+v", tc.traceID, tc.sampled, d)
+					}
+				},
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if reported != tc.want {
+				t.Errorf("h.ServeHTTP(): want reported %t, got %t", tc.want, reported)
+			}
+
+			rows, err := view.RetrieveData(DisagreementsView.Name)
+			if err != nil {
+				t.Fatalf("view.RetrieveData(): %v", err)
+			}
+			want := map[string]bool{"trace_id": tc.traceID, "sampled": tc.sampled}
+			got := map[string]bool{"trace_id": false, "sampled": false}
+			for _, row := range rows {
+				for _, tg := range row.Tags {
+					if tg.Key == KeyDisagreement {
+						got[tg.Value] = true
+					}
+				}
+			}
+			if got["trace_id"] != want["trace_id"] || got["sampled"] != want["sampled"] {
+				t.Errorf("view.RetrieveData(): want rows tagged %v, got %v", want, rows)
+			}
+		})
+	}
+}
+
+func TestLogDisagreements(t *testing.T) {
+	b := &bytes.Buffer{}
+	log := LogDisagreements(b)
+
+	r := httptest.NewRequest("GET", "http://example.org/users?id=1", nil)
+	r.Header.Set("l5d-dst-service", "/svc/users")
+	d := Disagreement{TraceID: true, Sampled: true}
+	log(r, d)
+
+	want := []string{"192.0.2.1:1234 GET /users?id=1", "disagree=trace_id,sampled", `l5d-dst-service="/svc/users"`}
+	for _, w := range want {
+		if !strings.Contains(b.String(), w) {
+			t.Errorf("LogDisagreements(): want log containing %q, got %q", w, b.String())
+		}
+	}
+	if strings.Count(b.String(), "\n") != 1 {
+		t.Errorf("LogDisagreements(): want one line, got %q", b.String())
+	}
+}
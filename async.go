@@ -0,0 +1,67 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"time"
+)
+
+// detached is a context that carries the values of its parent, including its
+// span, but is never cancelled and has no deadline.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// Detach returns a context that carries the values of the supplied context,
+// including its span, tags, and baggage, but that is not cancelled when the
+// supplied context is and has no deadline. An incoming request's context is
+// cancelled when the request's ServeHTTP method returns, so work that outlives
+// the request must run with a detached context.
+func Detach(ctx context.Context) context.Context {
+	return detached{parent: ctx}
+}
+
+// Go runs fn in a new goroutine, with a context detached from the supplied
+// context (see Detach). Spans started by fn are children of the span in the
+// supplied context, but fn is not cancelled when the supplied context is, e.g.
+// when the request that spawned it completes.
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	go fn(Detach(ctx))
+}
+
+// GoWithDeadline is like Go, except that fn's context keeps the deadline of the
+// supplied context, if any, so that fn honors any deadline propagated with the
+// request that spawned it. fn's context is cancelled when the deadline passes,
+// but not when the supplied context is otherwise cancelled.
+func GoWithDeadline(ctx context.Context, fn func(ctx context.Context)) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		Go(ctx, fn)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithDeadline(Detach(ctx), d)
+		defer cancel()
+		fn(ctx)
+	}()
+}
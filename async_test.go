@@ -0,0 +1,92 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestGo(t *testing.T) {
+	cases := []struct {
+		name         string
+		fn           func(context.Context, func(context.Context))
+		deadline     bool
+		wantDeadline bool
+	}{
+		{name: "Go", fn: Go},
+		{name: "GoWithoutDeadline", fn: Go, deadline: true},
+		{name: "GoWithDeadline", fn: GoWithDeadline, deadline: true, wantDeadline: true},
+		{name: "GoWithDeadlineUnset", fn: GoWithDeadline},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, span := trace.StartSpan(context.Background(), "request")
+			defer span.End()
+
+			var cancel context.CancelFunc
+			if tc.deadline {
+				ctx, cancel = context.WithTimeout(ctx, time.Hour)
+			} else {
+				ctx, cancel = context.WithCancel(ctx)
+			}
+
+			type result struct {
+				err      error
+				span     *trace.Span
+				deadline bool
+			}
+			started, done := make(chan struct{}), make(chan result)
+			tc.fn(ctx, func(ctx context.Context) {
+				<-started
+				_, deadline := ctx.Deadline()
+				done <- result{err: ctx.Err(), span: trace.FromContext(ctx), deadline: deadline}
+			})
+			cancel()
+			close(started)
+			got := <-done
+
+			if got.err != nil {
+				t.Errorf("fn context: want not cancelled, got %v", got.err)
+			}
+			if got.span != span {
+				t.Errorf("fn context: want span %v, got %v", span, got.span)
+			}
+			if got.deadline != tc.wantDeadline {
+				t.Errorf("fn context: want deadline %t, got %t", tc.wantDeadline, got.deadline)
+			}
+		})
+	}
+}
+
+func TestGoWithDeadlineExpires(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	GoWithDeadline(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Errorf("fn context: want %v, got %v", context.DeadlineExceeded, err)
+	}
+}
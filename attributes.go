@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opencensus.io/trace"
+)
+
+// Keys of the span attributes recorded by this package. Dashboards and queries
+// may rely on these names remaining stable.
+const (
+	// Linkerd request metadata. See AnnotateLinkerd.
+	DstServiceAttribute  = "l5d.dst.service"
+	DstClientAttribute   = "l5d.dst.client"
+	DstResidualAttribute = "l5d.dst.residual"
+	SampleRateAttribute  = "l5d.sample.rate"
+	FlagsAttribute       = "l5d.flags"
+
+	// Trace structure and sampling. See AnnotateRoot, SamplingHandler,
+	// ErrorSamplingHandler, and LatencySamplingHandler.
+	RootAttribute           = "l5d.root"
+	SamplingReasonAttribute = "l5d.sampling.reason"
+	ForcedSampleAttribute   = "l5d.sampling.forced"
+	SlowAttribute           = "l5d.slow"
+
+	// Mesh metadata. See MeshExporter.
+	ServiceAttribute = "l5d.service"
+	RouterAttribute  = "l5d.router"
+	NodeAttribute    = "l5d.node"
+
+	// Attempts. See HedgeTransport and RetryTransport.
+	AttemptAttribute        = "l5d.attempt"
+	HedgeCancelledAttribute = "l5d.hedge.cancelled"
+	BackoffAttribute        = "l5d.backoff_ms"
+
+	// Shadow traffic. See ShadowTransport.
+	ShadowAttribute = "l5d.shadow"
+
+	// Requests. See RequestID, IdempotencyHandler, and Recover.
+	RequestIDAttribute      = "http.request_id"
+	IdempotencyKeyAttribute = "http.idempotency_key"
+	ErrorAttribute          = "error"
+	StackAttribute          = "stack"
+)
+
+// linkerd destination headers, as set by linkerd on requests it routes.
+const (
+	l5dHeaderDstService  = "l5d-dst-service"
+	l5dHeaderDstClient   = "l5d-dst-client"
+	l5dHeaderDstResidual = "l5d-dst-residual"
+)
+
+// AnnotateLinkerd wraps the supplied handler, adding attributes describing the
+// linkerd metadata of each request to the span in its context: the request's
+// destination (l5d-dst-*) headers, the rate requested by its l5d-sample header,
+// and the Finagle flags of its l5d-ctx-trace header. Absent or invalid metadata
+// is not recorded. AnnotateLinkerd must be wrapped by an ochttp.Handler.
+func AnnotateLinkerd(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := trace.FromContext(r.Context()); span != nil {
+			span.AddAttributes(linkerdAttributes(r)...)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func linkerdAttributes(r *http.Request) []trace.Attribute {
+	attrs := []trace.Attribute{}
+	for k, a := range map[string]string{
+		l5dHeaderDstService:  DstServiceAttribute,
+		l5dHeaderDstClient:   DstClientAttribute,
+		l5dHeaderDstResidual: DstResidualAttribute,
+	} {
+		if v := headerValue(r.Header, k); v != "" {
+			attrs = append(attrs, trace.StringAttribute(a, v))
+		}
+	}
+	if rate, err := strconv.ParseFloat(headerValue(r.Header, l5dHeaderSample), 64); err == nil {
+		attrs = append(attrs, trace.Float64Attribute(SampleRateAttribute, rate))
+	}
+	if id, ok := TraceIDFromRequest(r); ok {
+		attrs = append(attrs, trace.Int64Attribute(FlagsAttribute, int64(id.Flags)))
+	}
+	return attrs
+}
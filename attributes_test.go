@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestAnnotateLinkerd(t *testing.T) {
+	cases := []struct {
+		name   string
+		header map[string]string
+		want   map[string]interface{}
+	}{
+		{
+			name: "AllMetadata",
+			header: map[string]string{
+				l5dHeaderTrace:       "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+				l5dHeaderSample:      "0.5",
+				l5dHeaderDstService:  "/svc/users",
+				l5dHeaderDstClient:   "/#/io.l5d.k8s/default/http/users",
+				l5dHeaderDstResidual: "/v1",
+			},
+			want: map[string]interface{}{
+				DstServiceAttribute:  "/svc/users",
+				DstClientAttribute:   "/#/io.l5d.k8s/default/http/users",
+				DstResidualAttribute: "/v1",
+				SampleRateAttribute:  0.5,
+				FlagsAttribute:       int64(6),
+			},
+		},
+		{
+			name: "InvalidMetadata",
+			header: map[string]string{
+				l5dHeaderTrace:  "invalid",
+				l5dHeaderSample: "often",
+			},
+			want: map[string]interface{}{},
+		},
+		{
+			name:   "NoMetadata",
+			header: map[string]string{},
+			want:   map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+			r := httptest.NewRequest("GET", "http://example.org", nil).WithContext(ctx)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			AnnotateLinkerd(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+			span.End()
+
+			spans := e.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("want 1 exported span, got %d", len(spans))
+			}
+			got := spans[0].Attributes
+			if got == nil {
+				got = map[string]interface{}{}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("AnnotateLinkerd(): want attributes %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
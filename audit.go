@@ -0,0 +1,96 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+// An AuditEvent describes a request to be audited.
+type AuditEvent struct {
+	// Method is the request method.
+	Method string
+
+	// URL is the request URL.
+	URL string
+
+	// Propagated is true if the request included a valid l5d-ctx-trace header,
+	// in which case TraceID is the decoded header.
+	Propagated bool
+	TraceID    TraceID
+
+	// SpanContext is the span context of the span representing the request,
+	// i.e. the server span started by ochttp.Handler.
+	SpanContext trace.SpanContext
+
+	// Header contains the selected headers of the request.
+	Header http.Header
+}
+
+// An Auditor audits requests; e.g. by writing them to a security or audit
+// pipeline.
+type Auditor interface {
+	Audit(ctx context.Context, e AuditEvent)
+}
+
+// An AuditorFunc is a function that satisfies Auditor.
+type AuditorFunc func(ctx context.Context, e AuditEvent)
+
+// Audit calls fn(ctx, e).
+func (fn AuditorFunc) Audit(ctx context.Context, e AuditEvent) {
+	fn(ctx, e)
+}
+
+// AuditHandler is an http.Handler that invokes an Auditor for each request
+// before serving it, allowing audited actions to be tied back to distributed
+// traces. AuditHandler must be wrapped by an ochttp.Handler.
+type AuditHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Auditor audits each request. Requests are not audited if Auditor is nil.
+	Auditor Auditor
+
+	// Headers are the names of the request headers to include in each
+	// AuditEvent.
+	Headers []string
+}
+
+// ServeHTTP audits the request, then serves it.
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Auditor != nil {
+		h.Auditor.Audit(r.Context(), h.event(r))
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+func (h *AuditHandler) event(r *http.Request) AuditEvent {
+	e := AuditEvent{Method: r.Method, URL: r.URL.String(), Header: http.Header{}}
+	e.TraceID, e.Propagated = TraceIDFromRequest(r)
+	if span := trace.FromContext(r.Context()); span != nil {
+		e.SpanContext = span.SpanContext()
+	}
+	for _, k := range h.Headers {
+		if vs, ok := r.Header[http.CanonicalHeaderKey(k)]; ok {
+			e.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+		}
+	}
+	return e
+}
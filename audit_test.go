@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestAuditHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		header     string
+		propagated bool
+	}{
+		{
+			name:       "Propagated",
+			header:     "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			propagated: true,
+		},
+		{
+			name: "NotPropagated",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got AuditEvent
+			var sc trace.SpanContext
+			h := &ochttp.Handler{
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+				Handler: &AuditHandler{
+					Headers: []string{"x-user", "X-Missing"},
+					Auditor: AuditorFunc(func(_ context.Context, e AuditEvent) { got = e }),
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						sc = trace.FromContext(r.Context()).SpanContext()
+					}),
+				},
+			}
+			r := httptest.NewRequest("DELETE", "http://example.org/things/1", nil)
+			r.Header.Set("X-User", "negz")
+			r.Header.Set("X-Secret", "hunter2")
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			want := AuditEvent{
+				Method:      "DELETE",
+				URL:         "http://example.org/things/1",
+				Propagated:  tc.propagated,
+				SpanContext: sc,
+				Header:      http.Header{"X-User": []string{"negz"}},
+			}
+			if tc.propagated {
+				want.TraceID, _ = ParseTraceID(tc.header)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("h.ServeHTTP(): want audit event %+v, got %+v", want, got)
+			}
+		})
+	}
+}
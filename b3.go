@@ -0,0 +1,59 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+)
+
+const b3HeaderFlags = "X-B3-Flags"
+
+// B3Format implements propagation.HTTPFormat to propagate traces in HTTP
+// headers in B3 propagation format. It extends OpenCensus's B3 format with
+// support for the X-B3-Flags header, bridging B3's debug flag to and from
+// Finagle's, so that requests forced to be traced remain so when crossing
+// between linkerd and B3 propagation formats. See IsDebug.
+type B3Format struct {
+	b3 b3.HTTPFormat
+}
+
+// SpanContextFromRequest extracts B3 span context from incoming requests.
+func (f *B3Format) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	sc, ok := f.b3.SpanContextFromRequest(r)
+	if !ok {
+		return sc, false
+	}
+	// Debug implies an accept (i.e. sample) decision.
+	if r.Header.Get(b3HeaderFlags) == "1" {
+		sc.TraceOptions |= ocShouldSample | ocDebug
+	}
+	return sc, true
+}
+
+// SpanContextToRequest modifies the given request to include B3 headers
+// derived from the given SpanContext.
+func (f *B3Format) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	f.b3.SpanContextToRequest(sc, r)
+	if IsDebug(sc) {
+		// Debug implies an accept decision, so X-B3-Sampled is redundant.
+		r.Header.Set(b3HeaderFlags, "1")
+		r.Header.Del(b3.SampledHeader)
+	}
+}
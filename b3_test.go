@@ -0,0 +1,109 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestB3FormatSatisfiesHTTPFormat(t *testing.T) {
+	var _ propagation.HTTPFormat = (*B3Format)(nil)
+}
+
+func TestB3Format(t *testing.T) {
+	cases := []struct {
+		name    string
+		sc      trace.SpanContext
+		flags   string
+		sampled string
+	}{
+		{
+			name: "Debug",
+			sc: trace.SpanContext{
+				TraceID:      trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 50, 164, 219, 32, 245, 213, 146, 231},
+				SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+				TraceOptions: ocShouldSample | ocDebug,
+			},
+			flags: "1",
+		},
+		{
+			name: "Sampled",
+			sc: trace.SpanContext{
+				TraceID:      trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 50, 164, 219, 32, 245, 213, 146, 231},
+				SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+				TraceOptions: ocShouldSample,
+			},
+			sampled: "1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &B3Format{}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(tc.sc, r)
+			if got := r.Header.Get(b3HeaderFlags); got != tc.flags {
+				t.Errorf("f.SpanContextToRequest(): want %s %q, got %q", b3HeaderFlags, tc.flags, got)
+			}
+			if got := r.Header.Get(b3.SampledHeader); got != tc.sampled {
+				t.Errorf("f.SpanContextToRequest(): want %s %q, got %q", b3.SampledHeader, tc.sampled, got)
+			}
+
+			got, ok := f.SpanContextFromRequest(r)
+			if !ok {
+				t.Fatalf("f.SpanContextFromRequest(): want ok true, got false")
+			}
+			if got != tc.sc {
+				t.Errorf("f.SpanContextFromRequest():\ngot:  %+v\nwant: %+v\n", got, tc.sc)
+			}
+		})
+	}
+}
+
+func TestDebugBridging(t *testing.T) {
+	// A span context extracted from a linkerd header with the debug flag set
+	// should be injected into B3 headers with the debug flag set, and vice
+	// versa.
+	l5d, b := &HTTPFormat{}, &B3Format{}
+	in := requestWithHeader("9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAEAAAAAAAAAAA==")
+	sc, ok := l5d.SpanContextFromRequest(in)
+	if !ok {
+		t.Fatalf("l5d.SpanContextFromRequest(): want ok true, got false")
+	}
+
+	out, _ := http.NewRequest("GET", "http://example.org", nil)
+	b.SpanContextToRequest(sc, out)
+	if got := out.Header.Get(b3HeaderFlags); got != "1" {
+		t.Errorf("b.SpanContextToRequest(): want %s 1, got %q", b3HeaderFlags, got)
+	}
+
+	sc, ok = b.SpanContextFromRequest(out)
+	if !ok {
+		t.Fatalf("b.SpanContextFromRequest(): want ok true, got false")
+	}
+	back, _ := http.NewRequest("GET", "http://example.org", nil)
+	l5d.SpanContextToRequest(sc, back)
+	if got, _ := decode(context.Background(), back.Header.Get(l5dHeaderTrace)); !IsDebug(got) {
+		t.Errorf("l5d.SpanContextToRequest(): want debug flag set")
+	}
+}
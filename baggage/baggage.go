@@ -0,0 +1,420 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package baggage propagates arbitrary key value pairs alongside linkerd trace
+// context. Baggage is propagated in the l5d-ctx-baggage header. linkerd
+// forwards all l5d-ctx-* headers, so baggage survives meshed hops just like
+// the trace context.
+//
+// Unlike OpenCensus tags, whose values must be short printable ASCII strings,
+// baggage values may contain arbitrary bytes. Baggage is encoded as a comma
+// separated list of key=value pairs. Keys and valid UTF-8 values are percent
+// encoded; all bytes other than RFC 3986 unreserved characters are escaped.
+// Values that are not valid UTF-8 are base64 encoded and marked with the
+// base64 property. Entries whose propagation is limited to a number of hops
+// are marked with the hops property, e.g.:
+//
+//  l5d-ctx-baggage: tenant=acme,name=J%C3%BCrgen,token=3q2-7w%3D%3D;base64,debug=1;hops=2
+package baggage
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Header is the HTTP header in which baggage is propagated.
+const Header = "l5d-ctx-baggage"
+
+// Properties of encoded values.
+const (
+	// propBase64 marks base64 encoded values.
+	propBase64 = "base64"
+
+	// propHops limits the number of hops over which a value propagates.
+	propHops = "hops"
+)
+
+// Baggage is a set of key value pairs propagated alongside trace context.
+type Baggage map[string]string
+
+// contents are the baggage in a context, and the remaining hops of any
+// entries whose propagation is limited.
+type contents struct {
+	values Baggage
+	hops   map[string]int
+}
+
+type baggageKey struct{}
+
+func fromContext(ctx context.Context) contents {
+	c, _ := ctx.Value(baggageKey{}).(contents)
+	return c
+}
+
+// FromContext returns the baggage in the supplied context. The returned
+// baggage must not be modified; use With or NewContext instead.
+func FromContext(ctx context.Context) Baggage {
+	return fromContext(ctx).values
+}
+
+// NewContext returns a copy of the supplied context that carries the supplied
+// baggage, replacing any existing baggage. The new baggage propagates
+// indefinitely.
+func NewContext(ctx context.Context, b Baggage) context.Context {
+	return context.WithValue(ctx, baggageKey{}, contents{values: b})
+}
+
+// With returns a copy of the supplied context in which the supplied key is set
+// to the supplied value, in addition to any existing baggage. The entry
+// propagates indefinitely.
+func With(ctx context.Context, key, value string) context.Context {
+	return with(ctx, key, value, -1)
+}
+
+// WithHops returns a copy of the supplied context in which the supplied key is
+// set to the supplied value, in addition to any existing baggage. The entry is
+// propagated over at most the supplied number of hops, so that diagnostic
+// metadata added near the edge of a call graph does not propagate throughout
+// it. An entry with zero hops is not propagated at all.
+func WithHops(ctx context.Context, key, value string, hops int) context.Context {
+	if hops < 0 {
+		hops = 0
+	}
+	return with(ctx, key, value, hops)
+}
+
+// with sets the supplied entry. A negative number of hops is unlimited.
+func with(ctx context.Context, key, value string, hops int) context.Context {
+	existing := fromContext(ctx)
+	c := contents{values: make(Baggage, len(existing.values)+1)}
+	for k, v := range existing.values {
+		c.values[k] = v
+	}
+	c.values[key] = value
+	for k, n := range existing.hops {
+		if k == key {
+			continue
+		}
+		if c.hops == nil {
+			c.hops = map[string]int{}
+		}
+		c.hops[k] = n
+	}
+	if hops >= 0 {
+		if c.hops == nil {
+			c.hops = map[string]int{}
+		}
+		c.hops[key] = hops
+	}
+	return context.WithValue(ctx, baggageKey{}, c)
+}
+
+// Value returns the value of the supplied key in the supplied context's
+// baggage.
+func Value(ctx context.Context, key string) (string, bool) {
+	v, ok := FromContext(ctx)[key]
+	return v, ok
+}
+
+// Hops returns the number of further hops over which the supplied key will be
+// propagated. It returns false if the key's propagation is unlimited, or if
+// the key is not set.
+func Hops(ctx context.Context, key string) (int, bool) {
+	n, ok := fromContext(ctx).hops[key]
+	return n, ok
+}
+
+// escape percent encodes all bytes of s other than RFC 3986 unreserved
+// characters.
+func escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xF])
+	}
+	return b.String()
+}
+
+// encodeValue encodes the supplied value, base64 encoding it if it is not
+// valid UTF-8.
+func encodeValue(v string) string {
+	if utf8.ValidString(v) {
+		return escape(v)
+	}
+	return escape(base64.URLEncoding.EncodeToString([]byte(v))) + ";" + propBase64
+}
+
+// decodeValue decodes a value encoded by encodeValue.
+func decodeValue(v string) (string, error) {
+	props := strings.Split(v, ";")
+	s, err := url.PathUnescape(props[0])
+	if err != nil {
+		return "", err
+	}
+	for _, p := range props[1:] {
+		if strings.TrimSpace(p) != propBase64 {
+			continue
+		}
+		b, err := base64.URLEncoding.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		s = string(b)
+	}
+	return s, nil
+}
+
+// decodeHops returns the value of the hops property of the supplied encoded
+// value, if any.
+func decodeHops(v string) (int, bool, error) {
+	for _, p := range strings.Split(v, ";")[1:] {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) != 2 || kv[0] != propHops {
+			continue
+		}
+		n, err := strconv.Atoi(kv[1])
+		return n, true, err
+	}
+	return 0, false, nil
+}
+
+// Encode encodes the supplied baggage as per the l5d-ctx-baggage header. Keys
+// are encoded in sorted order.
+func Encode(b Baggage) string {
+	return encode(contents{values: b})
+}
+
+// encode encodes the supplied contents as per the l5d-ctx-baggage header.
+func encode(c contents) string {
+	return encodeWith(c, encodeValue)
+}
+
+// encodeWith encodes the supplied contents using the supplied value encoding,
+// omitting entries with no remaining hops and marking others with the hops
+// property.
+func encodeWith(c contents, encodeValue func(string) string) string {
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pair := escape(k) + "=" + encodeValue(c.values[k])
+		if n, ok := c.hops[k]; ok {
+			if n <= 0 {
+				continue
+			}
+			pair += ";" + propHops + "=" + strconv.Itoa(n)
+		}
+		pairs = append(pairs, pair)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// limit returns the supplied contents with at most max entries, keeping those
+// whose keys sort first. Contents are returned unmodified if max is not
+// positive.
+func limit(c contents, max int) contents {
+	if max <= 0 || len(c.values) <= max {
+		return c
+	}
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := contents{values: make(Baggage, max)}
+	for _, k := range keys[:max] {
+		out.values[k] = c.values[k]
+		if n, ok := c.hops[k]; ok {
+			if out.hops == nil {
+				out.hops = map[string]int{}
+			}
+			out.hops[k] = n
+		}
+	}
+	return out
+}
+
+// Decode decodes the supplied l5d-ctx-baggage header value.
+func Decode(h string) (Baggage, error) {
+	c, err := decode(h)
+	return c.values, err
+}
+
+// decode decodes the supplied l5d-ctx-baggage header value.
+func decode(h string) (contents, error) {
+	return decodeWith(h, decodeValue)
+}
+
+// decodeWith decodes the supplied header value using the supplied value
+// decoding. Each entry marked with the hops property has one fewer hop
+// remaining once decoded.
+func decodeWith(h string, decodeValue func(string) (string, error)) (contents, error) {
+	c := contents{values: Baggage{}}
+	for _, pair := range strings.Split(h, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return contents{}, fmt.Errorf("cannot decode baggage entry %q: missing value", pair)
+		}
+		k, err := url.PathUnescape(strings.TrimSpace(kv[0]))
+		if err != nil {
+			return contents{}, fmt.Errorf("cannot decode baggage key %q: %v", kv[0], err)
+		}
+		v, err := decodeValue(kv[1])
+		if err != nil {
+			return contents{}, fmt.Errorf("cannot decode baggage value of %q: %v", k, err)
+		}
+		c.values[k] = v
+
+		n, ok, err := decodeHops(kv[1])
+		if err != nil {
+			return contents{}, fmt.Errorf("cannot decode baggage hops of %q: %v", k, err)
+		}
+		if !ok {
+			continue
+		}
+		if c.hops == nil {
+			c.hops = map[string]int{}
+		}
+		if n--; n < 0 {
+			n = 0
+		}
+		c.hops[k] = n
+	}
+	return c, nil
+}
+
+// Handler is an http.Handler that restores baggage propagated by Transport in
+// the l5d-ctx-baggage header to the request's context.
+type Handler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// W3C additionally restores baggage propagated in the W3C baggage header,
+	// e.g. by OpenTelemetry instrumented services. Entries of the
+	// l5d-ctx-baggage header take precedence over those of the same key in
+	// the W3C baggage header.
+	W3C bool
+
+	// MaxEntries limits the number of entries restored to the request's
+	// context. Entries whose keys sort first are kept. Restored baggage is
+	// unlimited if MaxEntries is zero.
+	MaxEntries int
+}
+
+// ServeHTTP restores propagated baggage to the request's context, then serves
+// the request. Propagated baggage is ignored if it cannot be decoded.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := contents{}
+	if hdr := r.Header.Get(Header); hdr != "" {
+		if l5d, err := decode(hdr); err == nil {
+			c = l5d
+		}
+	}
+	if hdr := r.Header.Get(W3CHeader); h.W3C && hdr != "" {
+		if w3c, err := decodeW3C(hdr); err == nil {
+			c = merge(c, w3c)
+		}
+	}
+	c = limit(c, h.MaxEntries)
+	if c.values != nil {
+		r = r.WithContext(context.WithValue(r.Context(), baggageKey{}, c))
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// Transport is an http.RoundTripper that propagates the baggage in each
+// request's context in the l5d-ctx-baggage header.
+type Transport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// W3C additionally propagates baggage in the W3C baggage header, so that
+	// OpenTelemetry instrumented services receive the same key value pairs.
+	// Any existing W3C baggage header is replaced.
+	W3C bool
+
+	// Preserve causes requests that already carry an l5d-ctx-baggage header
+	// to be sent unmodified, as linkin.HTTPFormat's Preserve does for the
+	// l5d-ctx-trace header.
+	Preserve bool
+
+	// MaxEntries limits the number of entries propagated with each request.
+	// Entries whose keys sort first are propagated. Propagated baggage is
+	// unlimited if MaxEntries is zero.
+	MaxEntries int
+}
+
+// RoundTrip adds the l5d-ctx-baggage header to the supplied request, then
+// sends it. Requests without baggage to propagate are sent unmodified.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.Preserve && r.Header.Get(Header) != "" {
+		return t.base().RoundTrip(r)
+	}
+	c := limit(fromContext(r.Context()), t.MaxEntries)
+	if hdr := encode(c); hdr != "" {
+		// RoundTrippers must not modify the request they're given.
+		out := r.WithContext(r.Context())
+		out.Header = make(http.Header, len(r.Header)+2)
+		for k, vs := range r.Header {
+			out.Header[k] = vs
+		}
+		out.Header.Set(Header, hdr)
+		if t.W3C {
+			out.Header.Set(W3CHeader, encodeW3C(c))
+		}
+		r = out
+	}
+	return t.base().RoundTrip(r)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *Transport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
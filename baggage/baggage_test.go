@@ -0,0 +1,241 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package baggage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	cases := []struct {
+		name    string
+		baggage Baggage
+		want    string
+	}{
+		{
+			name:    "ASCII",
+			baggage: Baggage{"tenant": "acme", "shard": "7"},
+			want:    "shard=7,tenant=acme",
+		},
+		{
+			name:    "Reserved",
+			baggage: Baggage{"a=b": "c,d;e f%"},
+			want:    "a%3Db=c%2Cd%3Be%20f%25",
+		},
+		{
+			name:    "Unicode",
+			baggage: Baggage{"name": "Jürgen 🙂"},
+			want:    "name=J%C3%BCrgen%20%F0%9F%99%82",
+		},
+		{
+			name:    "Binary",
+			baggage: Baggage{"token": "\xde\xad\xbe\xef"},
+			want:    "token=3q2-7w%3D%3D;base64",
+		},
+		{
+			name:    "Empty",
+			baggage: Baggage{},
+			want:    "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := Encode(tc.baggage)
+			if h != tc.want {
+				t.Errorf("Encode(): want %q, got %q", tc.want, h)
+			}
+			got, err := Decode(h)
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", h, err)
+			}
+			if !reflect.DeepEqual(got, tc.baggage) {
+				t.Errorf("Decode(%q): want %v, got %v", h, tc.baggage, got)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	cases := []string{
+		"novalue",
+		"k=%zz",
+		"%zz=v",
+		"k=notbase64!;base64",
+		"k=v;hops=many",
+	}
+	for _, h := range cases {
+		t.Run(h, func(t *testing.T) {
+			if _, err := Decode(h); err == nil {
+				t.Errorf("Decode(%q): want error, got nil", h)
+			}
+		})
+	}
+}
+
+type recordingTransport struct {
+	r *http.Request
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.r = r
+	return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+}
+
+func TestPropagation(t *testing.T) {
+	want := Baggage{"tenant": "acme", "token": "\xde\xad\xbe\xef"}
+
+	ctx := With(context.Background(), "tenant", "acme")
+	ctx = With(ctx, "token", "\xde\xad\xbe\xef")
+	if _, ok := Value(context.Background(), "tenant"); ok {
+		t.Errorf("With(): modified parent context")
+	}
+
+	rt := &recordingTransport{}
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	if _, err := (&Transport{Base: rt}).RoundTrip(r.WithContext(ctx)); err != nil {
+		t.Fatalf("t.RoundTrip(): %v", err)
+	}
+	if r.Header.Get(Header) != "" {
+		t.Errorf("t.RoundTrip(): original request was modified")
+	}
+
+	var got Baggage
+	h := &Handler{Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	})}
+	in := httptest.NewRequest("GET", "http://example.org", nil)
+	in.Header.Set(Header, rt.r.Header.Get(Header))
+	h.ServeHTTP(httptest.NewRecorder(), in)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("h.ServeHTTP(): want baggage %v, got %v", want, got)
+	}
+}
+
+func TestHops(t *testing.T) {
+	ctx := With(context.Background(), "tenant", "acme")
+	ctx = WithHops(ctx, "debug", "1", 2)
+	ctx = WithHops(ctx, "local", "1", 0)
+
+	// Each iteration is one hop: a request sent by Transport and received by
+	// Handler.
+	want := []Baggage{
+		{"tenant": "acme", "debug": "1"},
+		{"tenant": "acme", "debug": "1"},
+		{"tenant": "acme"},
+		{"tenant": "acme"},
+	}
+	for i, w := range want {
+		rt := &recordingTransport{}
+		r, _ := http.NewRequest("GET", "http://example.org", nil)
+		if _, err := (&Transport{Base: rt}).RoundTrip(r.WithContext(ctx)); err != nil {
+			t.Fatalf("t.RoundTrip(): %v", err)
+		}
+
+		h := &Handler{Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			ctx = r.Context()
+		})}
+		in := httptest.NewRequest("GET", "http://example.org", nil)
+		in.Header.Set(Header, rt.r.Header.Get(Header))
+		h.ServeHTTP(httptest.NewRecorder(), in)
+
+		if got := FromContext(ctx); !reflect.DeepEqual(got, w) {
+			t.Errorf("hop %d: want baggage %v, got %v", i+1, w, got)
+		}
+	}
+}
+
+func TestWithHopsOverride(t *testing.T) {
+	ctx := WithHops(context.Background(), "debug", "1", 1)
+	if n, ok := Hops(ctx, "debug"); !ok || n != 1 {
+		t.Errorf("Hops(): want 1, got %d (%t)", n, ok)
+	}
+	ctx = With(ctx, "debug", "2")
+	if _, ok := Hops(ctx, "debug"); ok {
+		t.Errorf("Hops(): want unlimited hops once overridden by With")
+	}
+	if got := encode(fromContext(ctx)); got != "debug=2" {
+		t.Errorf("encode(): want %q, got %q", "debug=2", got)
+	}
+}
+
+func TestTransportPreserve(t *testing.T) {
+	const existing = "tenant=initech"
+	ctx := With(context.Background(), "tenant", "acme")
+
+	cases := []struct {
+		name     string
+		preserve bool
+		want     string
+	}{
+		{
+			name:     "Preserved",
+			preserve: true,
+			want:     existing,
+		},
+		{
+			name: "Overwritten",
+			want: "tenant=acme",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &recordingTransport{}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			r.Header.Set(Header, existing)
+			if _, err := (&Transport{Base: rt, Preserve: tc.preserve}).RoundTrip(r.WithContext(ctx)); err != nil {
+				t.Fatalf("t.RoundTrip(): %v", err)
+			}
+			if got := rt.r.Header.Get(Header); got != tc.want {
+				t.Errorf("t.RoundTrip(): want %s header %q, got %q", Header, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	ctx := With(context.Background(), "tenant", "acme")
+	ctx = WithHops(ctx, "debug", "1", 2)
+	ctx = With(ctx, "user", "jürgen")
+
+	rt := &recordingTransport{}
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	if _, err := (&Transport{Base: rt, MaxEntries: 2}).RoundTrip(r.WithContext(ctx)); err != nil {
+		t.Fatalf("t.RoundTrip(): %v", err)
+	}
+	if got, want := rt.r.Header.Get(Header), "debug=1;hops=2,tenant=acme"; got != want {
+		t.Errorf("t.RoundTrip(): want %s %q, got %q", Header, want, got)
+	}
+
+	var got Baggage
+	h := &Handler{MaxEntries: 1, Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	})}
+	in := httptest.NewRequest("GET", "http://example.org", nil)
+	in.Header.Set(Header, rt.r.Header.Get(Header))
+	h.ServeHTTP(httptest.NewRecorder(), in)
+
+	if want := (Baggage{"debug": "1"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("h.ServeHTTP(): want baggage %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package baggage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// A Codec encodes and decodes typed baggage values.
+type Codec[T any] interface {
+	// Encode encodes the supplied value.
+	Encode(v T) (string, error)
+
+	// Decode decodes the supplied encoded value.
+	Decode(s string) (T, error)
+}
+
+// CodecFuncs is a Codec implemented by a pair of functions.
+type CodecFuncs[T any] struct {
+	EncodeFunc func(v T) (string, error)
+	DecodeFunc func(s string) (T, error)
+}
+
+// Encode calls c.EncodeFunc(v).
+func (c CodecFuncs[T]) Encode(v T) (string, error) {
+	return c.EncodeFunc(v)
+}
+
+// Decode calls c.DecodeFunc(s).
+func (c CodecFuncs[T]) Decode(s string) (T, error) {
+	return c.DecodeFunc(s)
+}
+
+// Codecs for common value types.
+var (
+	// String encodes strings as themselves.
+	String Codec[string] = CodecFuncs[string]{
+		EncodeFunc: func(v string) (string, error) { return v, nil },
+		DecodeFunc: func(s string) (string, error) { return s, nil },
+	}
+
+	// Bytes encodes byte slices as strings. Baggage values may contain
+	// arbitrary bytes.
+	Bytes Codec[[]byte] = CodecFuncs[[]byte]{
+		EncodeFunc: func(v []byte) (string, error) { return string(v), nil },
+		DecodeFunc: func(s string) ([]byte, error) { return []byte(s), nil },
+	}
+
+	// Int64 encodes 64 bit integers in base 10.
+	Int64 Codec[int64] = CodecFuncs[int64]{
+		EncodeFunc: func(v int64) (string, error) { return strconv.FormatInt(v, 10), nil },
+		DecodeFunc: func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) },
+	}
+
+	// Uint64 encodes unsigned 64 bit integers, e.g. IDs, in base 10.
+	Uint64 Codec[uint64] = CodecFuncs[uint64]{
+		EncodeFunc: func(v uint64) (string, error) { return strconv.FormatUint(v, 10), nil },
+		DecodeFunc: func(s string) (uint64, error) { return strconv.ParseUint(s, 10, 64) },
+	}
+
+	// Bool encodes booleans as true or false.
+	Bool Codec[bool] = CodecFuncs[bool]{
+		EncodeFunc: func(v bool) (string, error) { return strconv.FormatBool(v), nil },
+		DecodeFunc: strconv.ParseBool,
+	}
+)
+
+// JSON returns a Codec that encodes values of any type as JSON.
+func JSON[T any]() Codec[T] {
+	return CodecFuncs[T]{
+		EncodeFunc: func(v T) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		DecodeFunc: func(s string) (T, error) {
+			var v T
+			err := json.Unmarshal([]byte(s), &v)
+			return v, err
+		},
+	}
+}
+
+// Enum returns a Codec that encodes only the supplied values of a string type.
+// Values outside the enumeration cannot be encoded or decoded.
+func Enum[T ~string](values ...T) Codec[T] {
+	valid := make(map[T]bool, len(values))
+	for _, v := range values {
+		valid[v] = true
+	}
+	return CodecFuncs[T]{
+		EncodeFunc: func(v T) (string, error) {
+			if !valid[v] {
+				return "", fmt.Errorf("invalid enum value %q", v)
+			}
+			return string(v), nil
+		},
+		DecodeFunc: func(s string) (T, error) {
+			if !valid[T(s)] {
+				return "", fmt.Errorf("invalid enum value %q", s)
+			}
+			return T(s), nil
+		},
+	}
+}
+
+// A Key identifies a typed baggage entry. Keys are typically declared once, as
+// package level variables shared by the services that exchange the entry:
+//
+//  var KeyTenantID = baggage.NewKey("tenant_id", baggage.Uint64)
+type Key[T any] struct {
+	name  string
+	codec Codec[T]
+}
+
+// NewKey returns a new Key with the supplied name, whose values are encoded by
+// the supplied codec.
+func NewKey[T any](name string, c Codec[T]) Key[T] {
+	return Key[T]{name: name, codec: c}
+}
+
+// Name returns the name of the key, i.e. the key of its baggage entry.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// With returns a copy of the supplied context in which the key is set to the
+// supplied value. The entry propagates indefinitely.
+func (k Key[T]) With(ctx context.Context, v T) (context.Context, error) {
+	s, err := k.codec.Encode(v)
+	if err != nil {
+		return ctx, fmt.Errorf("cannot encode baggage value of %q: %v", k.name, err)
+	}
+	return With(ctx, k.name, s), nil
+}
+
+// WithHops returns a copy of the supplied context in which the key is set to
+// the supplied value. The entry propagates over at most the supplied number of
+// hops. See WithHops.
+func (k Key[T]) WithHops(ctx context.Context, v T, hops int) (context.Context, error) {
+	s, err := k.codec.Encode(v)
+	if err != nil {
+		return ctx, fmt.Errorf("cannot encode baggage value of %q: %v", k.name, err)
+	}
+	return WithHops(ctx, k.name, s, hops), nil
+}
+
+// Value returns the key's value in the supplied context's baggage. It returns
+// false if the key is not set, or if its value cannot be decoded; propagated
+// baggage may have been set by a service using a different codec.
+func (k Key[T]) Value(ctx context.Context) (T, bool) {
+	var zero T
+	s, ok := Value(ctx, k.name)
+	if !ok {
+		return zero, false
+	}
+	v, err := k.codec.Decode(s)
+	if err != nil {
+		return zero, false
+	}
+	return v, true
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package baggage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type tier string
+
+type order struct {
+	ID    uint64   `json:"id"`
+	Items []string `json:"items"`
+}
+
+func TestKey(t *testing.T) {
+	var (
+		keyTenant = NewKey("tenant_id", Uint64)
+		keyTier   = NewKey("tier", Enum[tier]("free", "pro"))
+		keyDebug  = NewKey("debug", Bool)
+		keyOrder  = NewKey("order", JSON[order]())
+		keyToken  = NewKey("token", Bytes)
+	)
+
+	ctx := context.Background()
+	var err error
+	if ctx, err = keyTenant.With(ctx, 42); err != nil {
+		t.Fatalf("keyTenant.With(): %v", err)
+	}
+	if ctx, err = keyTier.With(ctx, "pro"); err != nil {
+		t.Fatalf("keyTier.With(): %v", err)
+	}
+	if ctx, err = keyDebug.WithHops(ctx, true, 1); err != nil {
+		t.Fatalf("keyDebug.WithHops(): %v", err)
+	}
+	if ctx, err = keyOrder.With(ctx, order{ID: 7, Items: []string{"a,b", "c"}}); err != nil {
+		t.Fatalf("keyOrder.With(): %v", err)
+	}
+	if ctx, err = keyToken.With(ctx, []byte{0xde, 0xad}); err != nil {
+		t.Fatalf("keyToken.With(): %v", err)
+	}
+	if _, err := keyTier.With(ctx, "enterprise"); err == nil {
+		t.Errorf("keyTier.With(%q): want error, got nil", "enterprise")
+	}
+
+	// Round trip the baggage through its header encoding.
+	c, err := decode(encode(fromContext(ctx)))
+	if err != nil {
+		t.Fatalf("decode(): %v", err)
+	}
+	ctx = context.WithValue(context.Background(), baggageKey{}, c)
+
+	if got, ok := keyTenant.Value(ctx); !ok || got != 42 {
+		t.Errorf("keyTenant.Value(): want 42, got %v (%t)", got, ok)
+	}
+	if got, ok := keyTier.Value(ctx); !ok || got != "pro" {
+		t.Errorf("keyTier.Value(): want pro, got %v (%t)", got, ok)
+	}
+	if got, ok := keyDebug.Value(ctx); !ok || !got {
+		t.Errorf("keyDebug.Value(): want true, got %v (%t)", got, ok)
+	}
+	if got, ok := keyOrder.Value(ctx); !ok || !reflect.DeepEqual(got, order{ID: 7, Items: []string{"a,b", "c"}}) {
+		t.Errorf("keyOrder.Value(): want order 7, got %+v (%t)", got, ok)
+	}
+	if got, ok := keyToken.Value(ctx); !ok || !reflect.DeepEqual(got, []byte{0xde, 0xad}) {
+		t.Errorf("keyToken.Value(): want 0xdead, got %x (%t)", got, ok)
+	}
+}
+
+func TestKeyValueInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		ctx   context.Context
+		value func(ctx context.Context) bool
+	}{
+		{
+			name:  "Unset",
+			ctx:   context.Background(),
+			value: func(ctx context.Context) bool { _, ok := NewKey("tenant_id", Uint64).Value(ctx); return ok },
+		},
+		{
+			name:  "NotAnInteger",
+			ctx:   With(context.Background(), "tenant_id", "acme"),
+			value: func(ctx context.Context) bool { _, ok := NewKey("tenant_id", Uint64).Value(ctx); return ok },
+		},
+		{
+			name: "NotInEnum",
+			ctx:  With(context.Background(), "tier", "enterprise"),
+			value: func(ctx context.Context) bool {
+				_, ok := NewKey("tier", Enum[tier]("free", "pro")).Value(ctx)
+				return ok
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.value(tc.ctx) {
+				t.Errorf("k.Value(): want false, got true")
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package baggage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// W3CHeader is the W3C baggage header, in which OpenTelemetry propagates
+// baggage. https://www.w3.org/TR/baggage/
+const W3CHeader = "baggage"
+
+// The W3C baggage format is a superset of the l5d-ctx-baggage format, except
+// that values are percent encoded regardless of whether they are valid UTF-8;
+// the base64 property is not used. Limited hops are propagated using the hops
+// property, which W3C baggage consumers that do not understand it ignore.
+
+// EncodeW3C encodes the supplied baggage as per the W3C baggage header. Keys
+// are encoded in sorted order.
+func EncodeW3C(b Baggage) string {
+	return encodeW3C(contents{values: b})
+}
+
+func encodeW3C(c contents) string {
+	return encodeWith(c, escape)
+}
+
+// DecodeW3C decodes the supplied W3C baggage header value. Properties other
+// than hops are ignored.
+func DecodeW3C(h string) (Baggage, error) {
+	c, err := decodeW3C(h)
+	return c.values, err
+}
+
+func decodeW3C(h string) (contents, error) {
+	return decodeWith(h, func(v string) (string, error) {
+		s, err := url.PathUnescape(strings.TrimSpace(strings.SplitN(v, ";", 2)[0]))
+		if err != nil {
+			return "", fmt.Errorf("cannot unescape value: %v", err)
+		}
+		return s, nil
+	})
+}
+
+// merge returns the entries of a, and those entries of b whose keys are not
+// in a.
+func merge(a, b contents) contents {
+	c := contents{values: make(Baggage, len(a.values)+len(b.values))}
+	for _, from := range []contents{b, a} {
+		for k, v := range from.values {
+			c.values[k] = v
+			delete(c.hops, k)
+			if n, ok := from.hops[k]; ok {
+				if c.hops == nil {
+					c.hops = map[string]int{}
+				}
+				c.hops[k] = n
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package baggage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeW3C(t *testing.T) {
+	cases := []struct {
+		name    string
+		baggage Baggage
+		want    string
+	}{
+		{
+			name:    "ASCII",
+			baggage: Baggage{"tenant": "acme", "shard": "7"},
+			want:    "shard=7,tenant=acme",
+		},
+		{
+			name:    "Unicode",
+			baggage: Baggage{"name": "Jürgen"},
+			want:    "name=J%C3%BCrgen",
+		},
+		{
+			name:    "Binary",
+			baggage: Baggage{"token": "\xde\xad\xbe\xef"},
+			want:    "token=%DE%AD%BE%EF",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := EncodeW3C(tc.baggage)
+			if got != tc.want {
+				t.Errorf("EncodeW3C(): want %q, got %q", tc.want, got)
+			}
+			b, err := DecodeW3C(got)
+			if err != nil {
+				t.Fatalf("DecodeW3C(): %v", err)
+			}
+			if !reflect.DeepEqual(b, tc.baggage) {
+				t.Errorf("DecodeW3C(): want %v, got %v", tc.baggage, b)
+			}
+		})
+	}
+}
+
+func TestDecodeW3CProperties(t *testing.T) {
+	b, err := DecodeW3C("userId=alice;meta=1, serverNode = DF%2028 ,isProduction=false")
+	if err != nil {
+		t.Fatalf("DecodeW3C(): %v", err)
+	}
+	want := Baggage{"userId": "alice", "serverNode": "DF 28", "isProduction": "false"}
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("DecodeW3C(): want %v, got %v", want, b)
+	}
+}
+
+func TestW3CBridge(t *testing.T) {
+	cases := []struct {
+		name   string
+		l5d    string
+		w3c    string
+		want   Baggage
+		hops   map[string]int
+		header string
+	}{
+		{
+			name:   "W3COnly",
+			w3c:    "tenant=acme",
+			want:   Baggage{"tenant": "acme"},
+			header: "tenant=acme",
+		},
+		{
+			name:   "L5dTakesPrecedence",
+			l5d:    "tenant=acme",
+			w3c:    "tenant=initech,user=alice",
+			want:   Baggage{"tenant": "acme", "user": "alice"},
+			header: "tenant=acme,user=alice",
+		},
+		{
+			name:   "Hops",
+			l5d:    "debug=1;hops=2",
+			w3c:    "debug=0;hops=5",
+			want:   Baggage{"debug": "1"},
+			hops:   map[string]int{"debug": 1},
+			header: "debug=1;hops=1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ctx context.Context
+			h := &Handler{W3C: true, Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				ctx = r.Context()
+			})}
+			in := httptest.NewRequest("GET", "http://example.org", nil)
+			if tc.l5d != "" {
+				in.Header.Set(Header, tc.l5d)
+			}
+			in.Header.Set(W3CHeader, tc.w3c)
+			h.ServeHTTP(httptest.NewRecorder(), in)
+
+			if got := FromContext(ctx); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("h.ServeHTTP(): want baggage %v, got %v", tc.want, got)
+			}
+			for k, want := range tc.hops {
+				if got, _ := Hops(ctx, k); got != want {
+					t.Errorf("Hops(%q): want %d, got %d", k, want, got)
+				}
+			}
+
+			rt := &recordingTransport{}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			if _, err := (&Transport{Base: rt, W3C: true}).RoundTrip(r.WithContext(ctx)); err != nil {
+				t.Fatalf("t.RoundTrip(): %v", err)
+			}
+			for _, k := range []string{Header, W3CHeader} {
+				if got := rt.r.Header.Get(k); got != tc.header {
+					t.Errorf("t.RoundTrip(): want %s header %q, got %q", k, tc.header, got)
+				}
+			}
+		})
+	}
+}
+
+func TestW3CDisabled(t *testing.T) {
+	var got Baggage
+	h := &Handler{Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	})}
+	in := httptest.NewRequest("GET", "http://example.org", nil)
+	in.Header.Set(W3CHeader, "tenant=acme")
+	h.ServeHTTP(httptest.NewRecorder(), in)
+	if got != nil {
+		t.Errorf("h.ServeHTTP(): want no baggage, got %v", got)
+	}
+
+	rt := &recordingTransport{}
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	ctx := With(context.Background(), "tenant", "acme")
+	if _, err := (&Transport{Base: rt}).RoundTrip(r.WithContext(ctx)); err != nil {
+		t.Fatalf("t.RoundTrip(): %v", err)
+	}
+	if v := rt.r.Header.Get(W3CHeader); v != "" {
+		t.Errorf("t.RoundTrip(): want no %s header, got %q", W3CHeader, v)
+	}
+}
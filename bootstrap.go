@@ -0,0 +1,59 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+// BootstrapHandler is an http.Handler that returns an encoded l5d-ctx-trace
+// header to JavaScript clients, allowing requests sent by single page
+// applications to carry a trace context that the mesh will recognise. The
+// header is returned as a JSON object of header names to values, e.g.:
+//
+//  {"l5d-ctx-trace": "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="}
+//
+// The returned header propagates the span in the request's context, if any.
+// Wrap BootstrapHandler in an ochttp.Handler to ensure requests sent with the
+// returned header are children of the span that served it. A new root span
+// context is minted for requests without a span in their context.
+type BootstrapHandler struct {
+	// Sampler decides whether minted root span contexts are sampled. Minted
+	// contexts are never sampled if Sampler is nil.
+	Sampler trace.Sampler
+
+	// IDGenerator generates the IDs of minted root span contexts. Random IDs
+	// are generated if IDGenerator is nil.
+	IDGenerator IDGenerator
+}
+
+// ServeHTTP returns an encoded l5d-ctx-trace header.
+func (h *BootstrapHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var sc trace.SpanContext
+	if s := trace.FromContext(r.Context()); s != nil {
+		sc = s.SpanContext()
+	} else {
+		sc = newSpanContext(h.IDGenerator, h.Sampler)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{l5dHeaderTrace: encode(sc)})
+}
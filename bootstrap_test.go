@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type fixedIDs struct{}
+
+func (g fixedIDs) NewTraceID() [16]byte { return [16]byte{15: 1} }
+func (g fixedIDs) NewSpanID() [8]byte   { return [8]byte{7: 2} }
+
+func TestBootstrapHandler(t *testing.T) {
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		sampler trace.Sampler
+		ids     IDGenerator
+		want    func(trace.SpanContext) bool
+	}{
+		{
+			name: "CurrentSpan",
+			ctx:  ctx,
+			want: func(sc trace.SpanContext) bool {
+				// The l5d-ctx-trace header does not propagate tracestate.
+				want := span.SpanContext()
+				want.Tracestate = nil
+				return sc == want
+			},
+		},
+		{
+			name:    "MintedSampled",
+			ctx:     context.Background(),
+			sampler: trace.AlwaysSample(),
+			want: func(sc trace.SpanContext) bool {
+				return sc.IsSampled() && sc.TraceID != trace.TraceID{} && sc.SpanID != trace.SpanID{}
+			},
+		},
+		{
+			name: "MintedWithIDGenerator",
+			ctx:  context.Background(),
+			ids:  fixedIDs{},
+			want: func(sc trace.SpanContext) bool {
+				return sc == trace.SpanContext{TraceID: trace.TraceID{15: 1}, SpanID: trace.SpanID{7: 2}}
+			},
+		},
+		{
+			name: "MintedUnsampled",
+			ctx:  context.Background(),
+			want: func(sc trace.SpanContext) bool {
+				return !sc.IsSampled() && sc.TraceID != trace.TraceID{} && sc.SpanID != trace.SpanID{}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &BootstrapHandler{Sampler: tc.sampler, IDGenerator: tc.ids}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.org", nil).WithContext(tc.ctx))
+
+			body := map[string]string{}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("json.Decode(): %v", err)
+			}
+			sc, ok := decode(context.Background(), body[l5dHeaderTrace])
+			if !ok {
+				t.Fatalf("h.ServeHTTP(): invalid header %q", body[l5dHeaderTrace])
+			}
+			if !tc.want(sc) {
+				t.Errorf("h.ServeHTTP(): unexpected span context %+v", sc)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+)
+
+// Branch returns a function suitable for running one branch of a fan-out, for
+// example via an errgroup.Group's Go method. The returned function starts a
+// child of the span in the supplied context, calls fn with the child span in
+// its context, then ends the child span, recording any error fn returns. The
+// supplied context should be that shared by all branches of the fan-out (e.g.
+// the context returned by errgroup.WithContext), so that each branch is
+// cancelled when another fails. Branches that fail due to cancellation are
+// recorded as cancelled rather than failed.
+func Branch(ctx context.Context, name string, fn func(ctx context.Context) error) func() error {
+	return func() error {
+		ctx, span := trace.StartSpan(ctx, name)
+		defer span.End()
+
+		err := fn(ctx)
+		switch {
+		case err == nil:
+		case ctx.Err() == context.Canceled:
+			span.SetStatus(trace.Status{Code: trace.StatusCodeCancelled, Message: err.Error()})
+		case ctx.Err() == context.DeadlineExceeded:
+			span.SetStatus(trace.Status{Code: trace.StatusCodeDeadlineExceeded, Message: err.Error()})
+		default:
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		}
+		return err
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestBranch(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	failed := make(chan struct{})
+
+	// A minimal errgroup: the first error cancels all branches.
+	branches := map[string]func() error{
+		"succeeds": Branch(ctx, "succeeds", func(ctx context.Context) error { return nil }),
+		"fails": Branch(ctx, "fails", func(ctx context.Context) error {
+			defer close(failed)
+			return errors.New("boom")
+		}),
+		"cancelled": Branch(ctx, "cancelled", func(ctx context.Context) error {
+			<-failed
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	}
+	wg := &sync.WaitGroup{}
+	for _, fn := range branches {
+		wg.Add(1)
+		go func(fn func() error) {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				cancel()
+			}
+		}(fn)
+	}
+	wg.Wait()
+	parent.End()
+
+	want := map[string]int32{
+		"succeeds":  trace.StatusCodeOK,
+		"fails":     trace.StatusCodeUnknown,
+		"cancelled": trace.StatusCodeCancelled,
+		"parent":    trace.StatusCodeOK,
+	}
+	spans := e.Spans()
+	if len(spans) != len(want) {
+		t.Fatalf("Branch(): want %d exported spans, got %d", len(want), len(spans))
+	}
+	for _, s := range spans {
+		if got := s.Status.Code; got != want[s.Name] {
+			t.Errorf("Branch(): want %s status code %d, got %d", s.Name, want[s.Name], got)
+		}
+		if s.Name != "parent" && s.ParentSpanID != parent.SpanContext().SpanID {
+			t.Errorf("Branch(): want %s to be child of %v, got parent %v", s.Name, parent.SpanContext().SpanID, s.ParentSpanID)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// Canary results.
+const (
+	CanaryHonored = "honored"
+	CanaryIgnored = "ignored"
+)
+
+// CanaryTransport is an http.RoundTripper that de-risks migrations between
+// propagation formats. It injects span context in a secondary (canary) format
+// into a fraction of requests, in addition to the primary format injected by
+// ochttp.Transport, and records whether each response indicates that the
+// canary was honored to the CanaryResponses measure.
+//
+// CanaryTransport must be the Base of an ochttp.Transport, so that it sees the
+// client span in each request's context.
+type CanaryTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Canary is the canary propagation format.
+	Canary propagation.HTTPFormat
+
+	// Fraction is the fraction of traces, between 0 and 1, whose requests
+	// carry the canary format. Traces are selected deterministically by trace
+	// ID.
+	Fraction float64
+
+	// Honored returns true if the supplied response indicates that the
+	// supplied request's canary span context was honored. By default a canary
+	// is considered honored if the response carries span context in the canary
+	// format with the same trace ID as the request.
+	Honored func(r *http.Request, rsp *http.Response) bool
+}
+
+// RoundTrip injects canary span context into the supplied request if it is
+// selected, then sends it.
+func (t *CanaryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	span := trace.FromContext(r.Context())
+	if t.Canary == nil || span == nil || !sampledAt(span.SpanContext().TraceID, t.Fraction) {
+		return t.base().RoundTrip(r)
+	}
+
+	// RoundTrippers must not modify the request they're given.
+	r = withHeaderCopy(r)
+	t.Canary.SpanContextToRequest(span.SpanContext(), r)
+
+	rsp, err := t.base().RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	result := CanaryIgnored
+	if t.honored(r, rsp) {
+		result = CanaryHonored
+	}
+	stats.RecordWithTags(r.Context(), []tag.Mutator{tag.Upsert(KeyCanaryResult, result)}, CanaryResponses.M(1))
+	return rsp, nil
+}
+
+func (t *CanaryTransport) honored(r *http.Request, rsp *http.Response) bool {
+	if t.Honored != nil {
+		return t.Honored(r, rsp)
+	}
+	echo := (&http.Request{Header: rsp.Header}).WithContext(r.Context())
+	sc, ok := t.Canary.SpanContextFromRequest(echo)
+	return ok && sc.TraceID == trace.FromContext(r.Context()).SpanContext().TraceID
+}
+
+func (t *CanaryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *CanaryTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
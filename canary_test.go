@@ -0,0 +1,114 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// echoTransport responds with the B3 headers of each request if echo is true.
+type echoTransport struct {
+	echo bool
+	r    *http.Request
+}
+
+func (t *echoTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.r = r
+	rsp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Request: r}
+	if t.echo {
+		for _, k := range []string{"X-B3-Traceid", "X-B3-Spanid", "X-B3-Sampled"} {
+			rsp.Header.Set(k, r.Header.Get(k))
+		}
+	}
+	return rsp, nil
+}
+
+func TestCanaryTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		fraction float64
+		echo     bool
+		want     string
+	}{
+		{
+			name:     "Honored",
+			fraction: 1,
+			echo:     true,
+			want:     CanaryHonored,
+		},
+		{
+			name:     "Ignored",
+			fraction: 1,
+			want:     CanaryIgnored,
+		},
+		{
+			name:     "NotSelected",
+			fraction: 0,
+			echo:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := view.Register(CanaryResponsesView); err != nil {
+				t.Fatalf("view.Register(): %v", err)
+			}
+			defer view.Unregister(CanaryResponsesView)
+
+			et := &echoTransport{echo: tc.echo}
+			client := &http.Client{Transport: &ochttp.Transport{
+				Base:         &CanaryTransport{Base: et, Canary: &B3Format{}, Fraction: tc.fraction},
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+			}}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			if _, err := client.Do(r.WithContext(context.Background())); err != nil {
+				t.Fatalf("client.Do(): %v", err)
+			}
+
+			if et.r.Header.Get(l5dHeaderTrace) == "" {
+				t.Errorf("client.Do(): want primary %s header, got %v", l5dHeaderTrace, et.r.Header)
+			}
+			if got := et.r.Header.Get("X-B3-Traceid") != ""; got != (tc.want != "") {
+				t.Errorf("client.Do(): want canary header %t, got %t", tc.want != "", got)
+			}
+
+			rows, err := view.RetrieveData(CanaryResponsesView.Name)
+			if err != nil {
+				t.Fatalf("view.RetrieveData(): %v", err)
+			}
+			if tc.want == "" {
+				if len(rows) != 0 {
+					t.Errorf("view.RetrieveData(): want no rows, got %v", rows)
+				}
+				return
+			}
+			want := []tag.Tag{{Key: KeyCanaryResult, Value: tc.want}}
+			if len(rows) != 1 || !reflect.DeepEqual(rows[0].Tags, want) {
+				t.Errorf("view.RetrieveData(): want one row tagged %v, got %v", want, rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/planetlabs/linkin/wire"
+)
+
+// minVersion128 is the first linkerd release to propagate 128 bit trace IDs.
+var minVersion128 = [3]int{1, 3, 0}
+
+// Capabilities describes the features of the linkerd to which requests are
+// sent, and thus which headers it is safe to emit.
+type Capabilities struct {
+	// TraceID128 is true if linkerd accepts 40 byte l5d-ctx-trace headers,
+	// i.e. those with 128 bit trace IDs. Older linkerds reject such headers,
+	// breaking the trace.
+	TraceID128 bool
+}
+
+// CapabilitiesForVersion returns the capabilities of the supplied linkerd
+// version, e.g. "1.3.6" or "v1.4.0-rc1".
+func CapabilitiesForVersion(v string) (Capabilities, error) {
+	ver, err := parseVersion(v)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	return Capabilities{TraceID128: !less(ver, minVersion128)}, nil
+}
+
+func parseVersion(v string) ([3]int, error) {
+	ver := [3]int{}
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return ver, fmt.Errorf("cannot parse linkerd version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return ver, fmt.Errorf("cannot parse linkerd version %q", v)
+		}
+		ver[i] = n
+	}
+	return ver, nil
+}
+
+func less(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// A Detector tracks the capabilities of the linkerd to which requests are
+// sent. It begins with the capabilities it is supplied (e.g. those of a
+// configured linkerd version) and upgrades them as it observes evidence of
+// further features in the headers of incoming requests, on the assumption that
+// incoming and outgoing requests traverse the same linkerd, and in the headers
+// of responses to outgoing requests observed by DetectorTransport. A Detector
+// is safe for concurrent use.
+type Detector struct {
+	mu sync.RWMutex
+	c  Capabilities
+}
+
+// NewDetector returns a Detector that initially assumes the supplied
+// capabilities.
+func NewDetector(c Capabilities) *Detector {
+	return &Detector{c: c}
+}
+
+// Capabilities returns the currently detected capabilities.
+func (d *Detector) Capabilities() Capabilities {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.c
+}
+
+// Observe inspects the supplied headers for evidence of linkerd capabilities.
+// A 40 byte l5d-ctx-trace header indicates linkerd supports 128 bit trace IDs.
+func (d *Detector) Observe(h http.Header) {
+	if d.Capabilities().TraceID128 {
+		return
+	}
+	v := strings.TrimRight(headerValue(h, l5dHeaderTrace), "=")
+	if base64.RawStdEncoding.DecodedLen(len(v)) != 40 {
+		return
+	}
+	if _, err := wire.Parse(v); err != nil {
+		return
+	}
+	d.mu.Lock()
+	d.c.TraceID128 = true
+	d.mu.Unlock()
+}
+
+// DetectorTransport is an http.RoundTripper that observes the headers of the
+// responses to the requests it sends, allowing a Detector to upgrade the
+// capabilities it assumes based on evidence from the linkerd to which requests
+// are sent, rather than only from the linkerd from which requests are received.
+type DetectorTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Detector observes the headers of each response.
+	Detector *Detector
+}
+
+// RoundTrip sends the supplied request, then observes the headers of its
+// response.
+func (t *DetectorTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rsp, err := t.base().RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if t.Detector != nil {
+		t.Detector.Observe(rsp.Header)
+	}
+	return rsp, nil
+}
+
+func (t *DetectorTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *DetectorTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/trace"
+)
+
+func TestCapabilitiesForVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		want    Capabilities
+		wantErr bool
+	}{
+		{version: "1.2.1", want: Capabilities{}},
+		{version: "1.3", want: Capabilities{TraceID128: true}},
+		{version: "1.3.0", want: Capabilities{TraceID128: true}},
+		{version: "v1.4.6-rc1", want: Capabilities{TraceID128: true}},
+		{version: "0.9.1", want: Capabilities{}},
+		{version: "latest", wantErr: true},
+		{version: "1.2.3.4", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := CapabilitiesForVersion(tc.version)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("CapabilitiesForVersion(%q): want error, got nil", tc.version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CapabilitiesForVersion(%q): %v", tc.version, err)
+			}
+			if got != tc.want {
+				t.Errorf("CapabilitiesForVersion(%q): want %+v, got %+v", tc.version, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAdaptiveEmission(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x32, 0xa4, 0xdb, 0x20, 0xf5, 0xd5, 0x92, 0xe7},
+		SpanID:       trace.SpanID{0xf4, 0x14, 0x1d, 0x5d, 0xc0, 0xc9, 0x35, 0xd0},
+		TraceOptions: ocShouldSample,
+	}
+	short := "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAY="
+	long := traceIDFromSpanContext(sc).String()
+
+	cases := []struct {
+		name    string
+		linkerd *Detector
+		observe string
+		want    string
+	}{
+		{
+			name: "NoDetector",
+			want: long,
+		},
+		{
+			name:    "TraceID128",
+			linkerd: NewDetector(Capabilities{TraceID128: true}),
+			want:    long,
+		},
+		{
+			name:    "TraceID64",
+			linkerd: NewDetector(Capabilities{}),
+			want:    short,
+		},
+		{
+			name:    "ObservedTraceID64",
+			linkerd: NewDetector(Capabilities{}),
+			observe: short,
+			want:    short,
+		},
+		{
+			name:    "ObservedTraceID128",
+			linkerd: NewDetector(Capabilities{}),
+			observe: long,
+			want:    long,
+		},
+		{
+			name:    "ObservedTraceID128RawURL",
+			linkerd: NewDetector(Capabilities{}),
+			observe: wire.TraceID(traceIDFromSpanContext(sc)).Encode(base64.RawURLEncoding),
+			want:    long,
+		},
+		{
+			name:    "ObservedInvalid",
+			linkerd: NewDetector(Capabilities{}),
+			observe: strings.Repeat("!", 54),
+			want:    short,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &HTTPFormat{Linkerd: tc.linkerd}
+			if tc.observe != "" {
+				r := httptest.NewRequest("GET", "http://example.org", nil)
+				r.Header.Set(l5dHeaderTrace, tc.observe)
+				f.SpanContextFromRequest(r)
+			}
+
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(sc, r)
+			if got := r.Header.Get(l5dHeaderTrace); got != tc.want {
+				t.Errorf("f.SpanContextToRequest(): want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// respondingTransport records the requests it sends, and responds to each with
+// the supplied headers.
+type respondingTransport struct {
+	header http.Header
+	reqs   []*http.Request
+}
+
+func (t *respondingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.reqs = append(t.reqs, r)
+	return &http.Response{StatusCode: http.StatusOK, Header: t.header, Request: r}, nil
+}
+
+func TestDetectorTransport(t *testing.T) {
+	id := wire.TraceID{Trace: [16]byte{0: 1, 15: 1}, Span: [8]byte{7: 1}}
+	long, short := id.String(), id.String32()
+
+	cases := []struct {
+		name   string
+		header string
+		want   Capabilities
+	}{
+		{name: "ObservedTraceID128", header: long, want: Capabilities{TraceID128: true}},
+		{name: "ObservedTraceID64", header: short, want: Capabilities{}},
+		{name: "NotObserved", want: Capabilities{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			if tc.header != "" {
+				h.Set(l5dHeaderTrace, tc.header)
+			}
+			d := NewDetector(Capabilities{})
+			dt := &DetectorTransport{Base: &respondingTransport{header: h}, Detector: d}
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			if _, err := dt.RoundTrip(r); err != nil {
+				t.Fatalf("dt.RoundTrip(): %v", err)
+			}
+			if got := d.Capabilities(); got != tc.want {
+				t.Errorf("d.Capabilities(): want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A Carrier carries span context in string key value pairs, such as the headers
+// of a queued message. Keys are lowercase when set by this package.
+type Carrier interface {
+	// Get returns the value of the supplied key, or the empty string.
+	Get(key string) string
+
+	// Set sets the supplied key to the supplied value.
+	Set(key, value string)
+
+	// Keys returns all keys in the carrier.
+	Keys() []string
+}
+
+// A MapCarrier is a Carrier backed by a map.
+type MapCarrier map[string]string
+
+// Get returns the value of the supplied key, or the empty string.
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+// Set sets the supplied key to the supplied value.
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys returns all keys in the carrier.
+func (c MapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// SpanContextFromCarrier extracts span context from the supplied carrier using
+// the supplied HTTP propagation format. The format sees the carrier's key value
+// pairs as HTTP headers of a request with an empty URL. &HTTPFormat{} is used
+// if the format is nil.
+func SpanContextFromCarrier(ctx context.Context, f propagation.HTTPFormat, c Carrier) (trace.SpanContext, bool) {
+	if f == nil {
+		f = &HTTPFormat{}
+	}
+	r := (&http.Request{URL: &url.URL{}, Header: http.Header{}}).WithContext(ctx)
+	for _, k := range c.Keys() {
+		r.Header.Add(k, c.Get(k))
+	}
+	return f.SpanContextFromRequest(r)
+}
+
+// SpanContextToCarrier injects the supplied span context into the supplied
+// carrier using the supplied HTTP propagation format. &HTTPFormat{} is used if
+// the format is nil.
+func SpanContextToCarrier(ctx context.Context, f propagation.HTTPFormat, sc trace.SpanContext, c Carrier) {
+	if f == nil {
+		f = &HTTPFormat{}
+	}
+	r := (&http.Request{URL: &url.URL{}, Header: http.Header{}}).WithContext(ctx)
+	f.SpanContextToRequest(sc, r)
+	for k := range r.Header {
+		c.Set(strings.ToLower(k), r.Header.Get(k))
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// urlFormat is an HTTPFormat that reads the URL of the requests it handles.
+type urlFormat struct{ HTTPFormat }
+
+func (f *urlFormat) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	_ = r.URL.Path
+	return f.HTTPFormat.SpanContextFromRequest(r)
+}
+
+func (f *urlFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	_ = r.URL.Path
+	f.HTTPFormat.SpanContextToRequest(sc, r)
+}
+
+func TestCarrier(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 1, 50, 164, 219, 32, 245, 213, 146, 231},
+		SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+		TraceOptions: ocShouldSample,
+	}
+
+	cases := []struct {
+		name string
+		f    propagation.HTTPFormat
+		key  string
+	}{
+		{
+			name: "Default",
+			key:  l5dHeaderTrace,
+		},
+		{
+			name: "B3",
+			f:    &B3Format{},
+			key:  "x-b3-traceid",
+		},
+		{
+			name: "ReadsURL",
+			f:    &urlFormat{},
+			key:  l5dHeaderTrace,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := MapCarrier{"unrelated": "value"}
+			SpanContextToCarrier(context.Background(), tc.f, sc, c)
+			if c.Get(tc.key) == "" {
+				t.Errorf("SpanContextToCarrier(): want key %q, got %v", tc.key, c)
+			}
+
+			got, ok := SpanContextFromCarrier(context.Background(), tc.f, c)
+			if !ok {
+				t.Fatalf("SpanContextFromCarrier(): want ok, got invalid span context")
+			}
+			if got != sc {
+				t.Errorf("SpanContextFromCarrier():\ngot:  %+v\nwant: %+v\n", got, sc)
+			}
+		})
+	}
+}
+
+func TestSpanContextFromEmptyCarrier(t *testing.T) {
+	if _, ok := SpanContextFromCarrier(context.Background(), nil, MapCarrier{}); ok {
+		t.Errorf("SpanContextFromCarrier(): want invalid span context, got ok")
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+)
+
+// A Chain assembles the middleware of this package around a handler in the
+// order each piece requires. Most pieces must be wrapped by an ochttp.Handler,
+// some must wrap it, and misordering them produces subtle propagation bugs, so
+// prefer a Chain to wrapping handlers by hand. Outermost first, a Chain:
+//
+//  1. Memoizes decoded l5d-ctx-trace headers. See Memoize.
+//  2. Sanitizes the request, before any span context is extracted.
+//  3. Extracts span context and starts a server span. See Stack.Handler.
+//  4. Adds any seeded service attributes to the span. See AnnotateService.
+//  5. Records the request ID. See RequestID.
+//  6. Writes an access log entry. See AccessLog.
+//  7. Records latency with exemplars. See ExemplarHandler.
+//  8. Recovers from panics, so they are logged and recorded as 500s. See
+//     Recover.
+//  9. Applies the request deadline.
+//
+// Pieces that are not configured are omitted. The zero value only extracts
+// span context using the l5d format and annotates seeded service attributes.
+type Chain struct {
+	// Stack configures trace propagation. Span context is extracted by an
+	// ochttp.Handler that uses the l5d format if Stack is nil.
+	Stack *Stack
+
+	// Sanitize, if non-nil, returns a sanitized copy of each incoming request
+	// before span context is extracted from it, e.g. to drop trace headers
+	// sent by untrusted clients.
+	Sanitize func(r *http.Request) *http.Request
+
+	// RequestID enables request ID correlation. See RequestID.
+	RequestID bool
+
+	// AccessLog is the writer to which access logs are written, in the
+	// AccessLogFormat. Access logs are not written if AccessLog is nil.
+	AccessLog       io.Writer
+	AccessLogFormat LogFormat
+
+	// Exemplars enables recording latency with trace exemplars. See
+	// ExemplarHandler.
+	Exemplars bool
+
+	// Recover enables recovering from panics. See Recover.
+	Recover bool
+
+	// Timeout is the deadline for handling each request. Requests are handled
+	// without a deadline if Timeout is zero.
+	Timeout time.Duration
+}
+
+// Then wraps the supplied handler in the configured middleware.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if c.Timeout > 0 {
+		h = withTimeout(h, c.Timeout)
+	}
+	if c.Recover {
+		h = Recover(h)
+	}
+	if c.Exemplars {
+		h = &ExemplarHandler{Handler: h}
+	}
+	if c.AccessLog != nil {
+		h = &AccessLog{Handler: h, Writer: c.AccessLog, Format: c.AccessLogFormat}
+	}
+	if c.RequestID {
+		h = RequestID(h)
+	}
+	h = AnnotateService(h)
+	if c.Stack != nil {
+		h = c.Stack.Handler(h)
+	} else {
+		h = &ochttp.Handler{Handler: h, Propagation: &HTTPFormat{}}
+	}
+	if c.Sanitize != nil {
+		h = sanitize(h, c.Sanitize)
+	}
+	return Memoize(h)
+}
+
+func withTimeout(h http.Handler, d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func sanitize(h http.Handler, fn func(r *http.Request) *http.Request) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, fn(r))
+	})
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestChain(t *testing.T) {
+	parent := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	cases := []struct {
+		name         string
+		chain        Chain
+		handler      http.HandlerFunc
+		wantParent   bool
+		wantStatus   int
+		wantLog      string
+		wantDeadline bool
+	}{
+		{
+			name:       "Default",
+			handler:    ok,
+			wantParent: true,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "Sanitized",
+			chain: Chain{Sanitize: func(r *http.Request) *http.Request {
+				r = r.WithContext(r.Context())
+				r.Header = http.Header{}
+				return r
+			}},
+			handler:    ok,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "LoggedPanic",
+			chain:      Chain{AccessLog: &bytes.Buffer{}, Recover: true, Exemplars: true, RequestID: true},
+			handler:    func(_ http.ResponseWriter, _ *http.Request) { panic("boom") },
+			wantParent: true,
+			wantStatus: http.StatusInternalServerError,
+			wantLog:    `"status":500`,
+		},
+		{
+			name:         "Timeout",
+			chain:        Chain{Timeout: time.Minute},
+			handler:      ok,
+			wantParent:   true,
+			wantStatus:   http.StatusNoContent,
+			wantDeadline: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got trace.SpanContext
+			var deadline bool
+			h := tc.chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = trace.FromContext(r.Context()).SpanContext()
+				_, deadline = r.Context().Deadline()
+				tc.handler(w, r)
+			}))
+
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			r.Header.Set(l5dHeaderTrace, encode(parent))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("h.ServeHTTP(): want status %d, got %d", tc.wantStatus, w.Code)
+			}
+			if (got.TraceID == parent.TraceID) != tc.wantParent {
+				t.Errorf("h.ServeHTTP(): want child of %v %t, got span context %v", parent, tc.wantParent, got)
+			}
+			if deadline != tc.wantDeadline {
+				t.Errorf("h.ServeHTTP(): want deadline %t, got %t", tc.wantDeadline, deadline)
+			}
+			if tc.wantLog == "" {
+				return
+			}
+			log := tc.chain.AccessLog.(*bytes.Buffer).String()
+			if !strings.Contains(log, tc.wantLog) || !strings.Contains(log, parent.TraceID.String()) {
+				t.Errorf("h.ServeHTTP(): want access log containing %s and trace ID %s, got %s", tc.wantLog, parent.TraceID, log)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Command linkin-corpus converts captured traffic into a Go fuzz corpus for the
+// l5d-ctx-trace codec, so that real-world malformed headers become permanent
+// regression test cases. It reads either HAR files, as exported by browsers
+// and proxies, or captured header logs as read by linkin-tree, containing one
+// JSON object per line:
+//
+//  {"time": "2018-06-01T12:00:00Z", "duration_seconds": 0.02, "method": "GET", "uri": "/", "header": {"L5d-Ctx-Trace": ["..."]}}
+//
+// Each distinct value of the selected headers is written to the corpus
+// directory of the wire package's FuzzParse target, where go test runs it as a
+// seed input:
+//
+//  linkin-corpus --malformed capture.har
+//  go test ./wire
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/planetlabs/linkin/wire"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Input formats.
+const (
+	formatAuto    = "auto"
+	formatHAR     = "har"
+	formatHeaders = "headers"
+)
+
+// DefaultCorpus is the corpus directory of the wire package's FuzzParse target,
+// relative to the root of the repository.
+const DefaultCorpus = "wire/testdata/fuzz/FuzzParse"
+
+// A har is the subset of the HTTP Archive format read by this command.
+// http://www.softwareishard.com/blog/har-12-spec/
+type har struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// A capturedRequest is a line of a captured header log.
+type capturedRequest struct {
+	Header http.Header `json:"header"`
+}
+
+// readHAR returns the values of the supplied headers of each request in the
+// supplied HAR.
+func readHAR(b []byte, headers map[string]bool) ([]string, error) {
+	h := har{}
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("cannot decode HAR: %v", err)
+	}
+	values := []string{}
+	for _, e := range h.Log.Entries {
+		for _, hdr := range e.Request.Headers {
+			if headers[strings.ToLower(hdr.Name)] {
+				values = append(values, hdr.Value)
+			}
+		}
+	}
+	return values, nil
+}
+
+// readHeaders returns the values of the supplied headers of each request in
+// the supplied captured header log.
+func readHeaders(b []byte, headers map[string]bool) ([]string, error) {
+	values := []string{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		c := capturedRequest{}
+		if err := json.Unmarshal(s.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("cannot decode line %d: %v", n, err)
+		}
+		for k, v := range c.Header {
+			if headers[strings.ToLower(k)] {
+				values = append(values, v...)
+			}
+		}
+	}
+	return values, s.Err()
+}
+
+// read returns the values of the supplied headers read in the supplied format.
+// Input that is a single JSON object with a log key is read as a HAR if the
+// format is formatAuto.
+func read(r io.Reader, format string, headers map[string]bool) ([]string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatAuto {
+		format = formatHeaders
+		probe := map[string]json.RawMessage{}
+		if json.Unmarshal(b, &probe) == nil && probe["log"] != nil {
+			format = formatHAR
+		}
+	}
+	if format == formatHAR {
+		return readHAR(b, headers)
+	}
+	return readHeaders(b, headers)
+}
+
+// corpusFile returns the name and content of the Go fuzz corpus file that
+// represents the supplied string input. Files are named for the hash of their
+// content, as go test names the inputs it finds.
+func corpusFile(v string) (string, []byte) {
+	b := []byte("go test fuzz v1\nstring(" + strconv.Quote(v) + ")\n")
+	return fmt.Sprintf("%x", sha256.Sum256(b))[:16], b
+}
+
+// write writes each distinct value to the supplied corpus directory, returning
+// the number of files written. Values already in the corpus are skipped.
+func write(dir string, values []string, malformed bool) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("cannot create corpus directory: %v", err)
+	}
+	sort.Strings(values)
+	written := 0
+	for i, v := range values {
+		if i > 0 && v == values[i-1] {
+			continue
+		}
+		if _, err := wire.Parse(v); malformed && err == nil {
+			continue
+		}
+		name, b := corpusFile(v)
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			return written, fmt.Errorf("cannot write corpus file: %v", err)
+		}
+		written++
+	}
+	return written, nil
+}
+
+func main() {
+	var (
+		app       = kingpin.New(filepath.Base(os.Args[0]), "Converts captured traffic into a fuzz corpus for the l5d-ctx-trace codec.").DefaultEnvars()
+		format    = app.Flag("format", "Format of the input.").Default(formatAuto).Enum(formatAuto, formatHAR, formatHeaders)
+		headers   = app.Flag("header", "Header whose values are imported. May be repeated.").Default(wire.Header).Strings()
+		out       = app.Flag("out", "Corpus directory to which inputs are written.").Default(DefaultCorpus).String()
+		malformed = app.Flag("malformed", "Import only values that cannot be parsed.").Bool()
+		files     = app.Arg("files", "Files from which to read traffic. Standard input is read if none are supplied.").ExistingFiles()
+	)
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	hdrs := map[string]bool{}
+	for _, h := range *headers {
+		hdrs[strings.ToLower(h)] = true
+	}
+
+	values := []string{}
+	if len(*files) == 0 {
+		v, err := read(os.Stdin, *format, hdrs)
+		kingpin.FatalIfError(err, "cannot read standard input")
+		values = append(values, v...)
+	}
+	for _, name := range *files {
+		f, err := os.Open(name)
+		kingpin.FatalIfError(err, "cannot open %s", name)
+		v, err := read(f, *format, hdrs)
+		f.Close()
+		kingpin.FatalIfError(err, "cannot read %s", name)
+		values = append(values, v...)
+	}
+
+	n, err := write(*out, values, *malformed)
+	kingpin.FatalIfError(err, "cannot write corpus")
+	fmt.Printf("wrote %d new inputs to %s\n", n, *out)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const harLog = `{"log": {"version": "1.2", "entries": [
+  {"request": {"method": "GET", "url": "http://example.org/", "headers": [
+    {"name": "l5d-ctx-trace", "value": "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY="},
+    {"name": "Accept", "value": "*/*"}
+  ]}},
+  {"request": {"method": "GET", "url": "http://example.org/users", "headers": [
+    {"name": "L5d-Ctx-Trace", "value": "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLn AAAAAAAAAAY="}
+  ]}}
+]}}`
+
+const headerLog = `
+{"time": "2018-06-01T12:00:00Z", "duration_seconds": 0.02, "method": "GET", "uri": "/", "header": {"L5d-Ctx-Trace": ["9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY="]}}
+{"time": "2018-06-01T12:00:01Z", "duration_seconds": 0.02, "method": "GET", "uri": "/", "header": {"L5d-Ctx-Trace": ["bmVlZWVyZA=="], "X-B3-Traceid": ["1"]}}
+`
+
+func TestRead(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		format  string
+		headers map[string]bool
+		want    []string
+	}{
+		{
+			name:    "AutoHAR",
+			input:   harLog,
+			format:  formatAuto,
+			headers: map[string]bool{"l5d-ctx-trace": true},
+			want:    []string{"9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=", "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLn AAAAAAAAAAY="},
+		},
+		{
+			name:    "AutoHeaders",
+			input:   headerLog,
+			format:  formatAuto,
+			headers: map[string]bool{"l5d-ctx-trace": true},
+			want:    []string{"9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=", "bmVlZWVyZA=="},
+		},
+		{
+			name:    "OtherHeaders",
+			input:   headerLog,
+			format:  formatHeaders,
+			headers: map[string]bool{"x-b3-traceid": true},
+			want:    []string{"1"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := read(strings.NewReader(tc.input), tc.format, tc.headers)
+			if err != nil {
+				t.Fatalf("read(): %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("read(): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	values := []string{
+		"9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+		"bmVlZWVyZA==",
+		"bmVlZWVyZA==",
+		"\"quoted\"\x00",
+	}
+
+	cases := []struct {
+		name      string
+		malformed bool
+		want      int
+	}{
+		{name: "All", want: 3},
+		{name: "Malformed", malformed: true, want: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "linkin-corpus")
+			if err != nil {
+				t.Fatalf("ioutil.TempDir(): %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			n, err := write(dir, values, tc.malformed)
+			if err != nil {
+				t.Fatalf("write(): %v", err)
+			}
+			if n != tc.want {
+				t.Errorf("write(): want %d files written, got %d", tc.want, n)
+			}
+
+			// Writing the same values again is a no-op.
+			if n, _ := write(dir, values, tc.malformed); n != 0 {
+				t.Errorf("write(): want existing inputs skipped, got %d files written", n)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*"))
+			sort.Strings(files)
+			for _, f := range files {
+				b, _ := ioutil.ReadFile(f)
+				if !strings.HasPrefix(string(b), "go test fuzz v1\nstring(") {
+					t.Errorf("write(): want Go fuzz corpus file, got %q", b)
+				}
+			}
+		})
+	}
+}
+
+func TestCorpusFile(t *testing.T) {
+	name, b := corpusFile("a\x00\"b")
+	if want := "go test fuzz v1\nstring(\"a\\x00\\\"b\")\n"; string(b) != want {
+		t.Errorf("corpusFile(): want %q, got %q", want, b)
+	}
+	if len(name) != 16 {
+		t.Errorf("corpusFile(): want 16 character name, got %q", name)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Command linkin-loadgen issues traced HTTP requests against a target service
+// at a configurable rate, trace depth, and sample rate, for validating tracing
+// pipelines and the capacity of Zipkin. Each request is sent as the leaf of a
+// trace that is --depth spans deep, with its span context propagated in the
+// configured format.
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/zipkin"
+	openzipkin "github.com/openzipkin/zipkin-go"
+	openzipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// A generator sends traced requests.
+type generator struct {
+	client  *http.Client
+	target  string
+	depth   int
+	sampler trace.Sampler
+	sample  string
+}
+
+// send sends one traced request, reporting whether its trace was sampled.
+func (g *generator) send(ctx context.Context) (bool, error) {
+	ctx, root := trace.StartSpan(ctx, "loadgen", trace.WithSampler(g.sampler))
+	defer root.End()
+	for i := 1; i < g.depth; i++ {
+		var span *trace.Span
+		ctx, span = trace.StartSpan(ctx, "loadgen.hop."+strconv.Itoa(i))
+		defer span.End()
+	}
+	sampled := root.SpanContext().IsSampled()
+
+	r, err := http.NewRequest("GET", g.target, nil)
+	if err != nil {
+		return sampled, fmt.Errorf("cannot create request: %v", err)
+	}
+	if g.sample != "" {
+		r.Header.Set("l5d-sample", g.sample)
+	}
+	rsp, err := g.client.Do(r.WithContext(ctx))
+	if err != nil {
+		return sampled, fmt.Errorf("cannot send request: %v", err)
+	}
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusInternalServerError {
+		return sampled, fmt.Errorf("target returned %s", rsp.Status)
+	}
+	return sampled, nil
+}
+
+// results summarizes the requests sent by a generator.
+type results struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	sampled   int
+}
+
+func (r *results) record(d time.Duration, sampled bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, d)
+	if err != nil {
+		r.errors++
+	}
+	if sampled {
+		r.sampled++
+	}
+}
+
+// percentile returns the supplied percentile of the recorded latencies.
+func (r *results) percentile(p float64) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	l := append([]time.Duration{}, r.latencies...)
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	i := int(p / 100 * float64(len(l)))
+	if i >= len(l) {
+		i = len(l) - 1
+	}
+	return l[i]
+}
+
+func (r *results) String() string {
+	p50, p90, p99 := r.percentile(50), r.percentile(90), r.percentile(99)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprintf("requests=%d errors=%d sampled=%d p50=%s p90=%s p99=%s",
+		len(r.latencies), r.errors, r.sampled, p50, p90, p99)
+}
+
+// run sends requests at the supplied rate using the supplied number of
+// concurrent workers until the supplied context is done, then waits for any
+// requests in flight. Requests that cannot be sent on schedule because all
+// workers are busy are skipped.
+func (g *generator) run(ctx context.Context, qps float64, workers int) *results {
+	res := &results{}
+	work := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range work {
+				start := time.Now()
+				sampled, err := g.send(context.Background())
+				res.record(time.Since(start), sampled, err)
+			}
+		}()
+	}
+
+	t := time.NewTicker(time.Duration(float64(time.Second) / qps))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			close(work)
+			wg.Wait()
+			return res
+		case <-t.C:
+			select {
+			case work <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func main() {
+	var (
+		app        = kingpin.New(filepath.Base(os.Args[0]), "Generates traced load against a target service.").DefaultEnvars()
+		qps        = app.Flag("qps", "Requests to send per second.").Default("10").Float64()
+		duration   = app.Flag("duration", "Duration for which to send requests.").Default("1m").Duration()
+		workers    = app.Flag("concurrency", "Maximum number of concurrent requests.").Default("16").Int()
+		depth      = app.Flag("depth", "Depth of each trace, in spans, before the request is sent.").Default("1").Int()
+		sampleRate = app.Flag("sample-rate", "Fraction of traces to sample, between 0 and 1.").Default("1").Float64()
+		l5dSample  = app.Flag("l5d-sample", "Value of the l5d-sample header to send with each request.").String()
+		formats    = app.Flag("format", "Propagation formats in which to send span context.").Default(linkin.FormatLinkerd).Strings()
+		prefix     = app.Flag("header-prefix", "Tenant specific prefix to which l5d-ctx-* headers are mapped.").String()
+		timeout    = app.Flag("timeout", "Timeout for each request.").Default("10s").Duration()
+		zipkinURL  = app.Flag("zipkin", "Address at which Zipkin listens. Generated spans are not exported if unset.").String()
+		target     = app.Arg("target", "URL of the target service.").Required().String()
+	)
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+	if *qps <= 0 || *workers < 1 || *depth < 1 {
+		kingpin.Fatalf("--qps, --concurrency, and --depth must be positive")
+	}
+
+	if *zipkinURL != "" {
+		endpoint, err := openzipkin.NewEndpoint("linkin-loadgen", "")
+		kingpin.FatalIfError(err, "cannot set Zipkin endpoint")
+		reporter := openzipkinhttp.NewReporter(*zipkinURL)
+		defer reporter.Close()
+		trace.RegisterExporter(zipkin.NewExporter(reporter, endpoint))
+	}
+
+	s, err := linkin.Config{Formats: *formats, SampleRate: sampleRate, HeaderPrefix: *prefix}.Build()
+	kingpin.FatalIfError(err, "cannot configure propagation")
+
+	g := &generator{
+		client:  &http.Client{Transport: s.Transport(nil), Timeout: *timeout},
+		target:  *target,
+		depth:   *depth,
+		sampler: s.StartOptions.Sampler,
+		sample:  *l5dSample,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	fmt.Println(g.run(ctx, *qps, *workers))
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+)
+
+func TestPercentile(t *testing.T) {
+	r := &results{}
+	for i := 1; i <= 100; i++ {
+		r.record(time.Duration(i)*time.Millisecond, false, nil)
+	}
+	cases := []struct {
+		name string
+		p    float64
+		want time.Duration
+	}{
+		{name: "Zero", p: 0, want: 1 * time.Millisecond},
+		{name: "Median", p: 50, want: 51 * time.Millisecond},
+		{name: "P99", p: 99, want: 100 * time.Millisecond},
+		{name: "Max", p: 100, want: 100 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.percentile(tc.p); got != tc.want {
+				t.Errorf("r.percentile(%v): want %s, got %s", tc.p, tc.want, got)
+			}
+		})
+	}
+	if got := (&results{}).percentile(50); got != 0 {
+		t.Errorf("r.percentile(50): want 0 for no results, got %s", got)
+	}
+}
+
+func TestSend(t *testing.T) {
+	cases := []struct {
+		name        string
+		depth       int
+		sampler     trace.Sampler
+		sample      string
+		status      int
+		wantSampled bool
+		wantErr     bool
+	}{
+		{
+			name:        "Sampled",
+			depth:       3,
+			sampler:     trace.AlwaysSample(),
+			wantSampled: true,
+		},
+		{
+			name:    "NotSampled",
+			depth:   1,
+			sampler: trace.NeverSample(),
+		},
+		{
+			name:        "L5dSample",
+			depth:       1,
+			sampler:     trace.AlwaysSample(),
+			sample:      "0.5",
+			wantSampled: true,
+		},
+		{
+			name:    "ServerError",
+			depth:   1,
+			sampler: trace.NeverSample(),
+			status:  http.StatusInternalServerError,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *http.Request
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+			}))
+			defer srv.Close()
+
+			s, err := linkin.Config{}.Build()
+			if err != nil {
+				t.Fatalf("linkin.Config{}.Build(): %v", err)
+			}
+			g := &generator{
+				client:  &http.Client{Transport: s.Transport(nil)},
+				target:  srv.URL,
+				depth:   tc.depth,
+				sampler: tc.sampler,
+				sample:  tc.sample,
+			}
+			sampled, err := g.send(context.Background())
+			if tc.wantErr != (err != nil) {
+				t.Errorf("g.send(): want error %t, got %v", tc.wantErr, err)
+			}
+			if sampled != tc.wantSampled {
+				t.Errorf("g.send(): want sampled %t, got %t", tc.wantSampled, sampled)
+			}
+			if got.Header.Get("l5d-ctx-trace") == "" {
+				t.Errorf("g.send(): want l5d-ctx-trace header, got %v", got.Header)
+			}
+			if v := got.Header.Get("l5d-sample"); v != tc.sample {
+				t.Errorf("g.send(): want l5d-sample %q, got %q", tc.sample, v)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	mu := &sync.Mutex{}
+	traces := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		traces[r.Header.Get("l5d-ctx-trace")] = true
+	}))
+	defer srv.Close()
+
+	s, err := linkin.Config{}.Build()
+	if err != nil {
+		t.Fatalf("linkin.Config{}.Build(): %v", err)
+	}
+	g := &generator{client: &http.Client{Transport: s.Transport(nil)}, target: srv.URL, depth: 2, sampler: trace.AlwaysSample()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r := g.run(ctx, 100, 4)
+
+	if len(r.latencies) == 0 {
+		t.Fatalf("g.run(): want requests, got none")
+	}
+	if r.errors != 0 {
+		t.Errorf("g.run(): want no errors, got %d", r.errors)
+	}
+	if r.sampled != len(r.latencies) {
+		t.Errorf("g.run(): want %d sampled, got %d", len(r.latencies), r.sampled)
+	}
+	if len(traces) != len(r.latencies) {
+		t.Errorf("g.run(): want %d distinct traces, got %d", len(r.latencies), len(traces))
+	}
+}
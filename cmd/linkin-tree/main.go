@@ -0,0 +1,353 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Command linkin-tree prints traces as ASCII trees with timing, without the
+// need for a tracing backend. It reads either Zipkin v2 JSON, as returned by
+// Zipkin's /api/v2/trace and /api/v2/traces APIs, or captured header logs.
+//
+// A captured header log contains one JSON object per line, each describing a
+// request by its start time, duration, method, URI, and headers:
+//
+//  {"time": "2018-06-01T12:00:00Z", "duration_seconds": 0.02, "method": "GET", "uri": "/", "header": {"L5d-Ctx-Trace": ["..."]}}
+//
+// Each request's span is decoded from its l5d-ctx-trace header, or from its B3
+// headers. Note that l5d-ctx-trace headers carry a parent span ID only when the
+// sending service recorded one (see linkin.ParentTransport); spans without a
+// known parent are printed as roots of their trace.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/planetlabs/linkin/wire"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// Input formats.
+const (
+	formatAuto    = "auto"
+	formatZipkin  = "zipkin"
+	formatHeaders = "headers"
+)
+
+// A span is a node in a trace tree.
+type span struct {
+	TraceID  string
+	ID       string
+	ParentID string
+	Name     string
+	Service  string
+	Start    time.Time
+	Duration time.Duration
+
+	// Shared is true for the server side of a span whose ID is shared with
+	// the client side, as recorded by Zipkin.
+	Shared bool
+
+	children []*span
+}
+
+// A zipkinSpan is a span in Zipkin's v2 JSON format.
+type zipkinSpan struct {
+	TraceID       string `json:"traceId"`
+	ID            string `json:"id"`
+	ParentID      string `json:"parentId"`
+	Name          string `json:"name"`
+	Kind          string `json:"kind"`
+	Timestamp     int64  `json:"timestamp"`
+	Duration      int64  `json:"duration"`
+	Shared        bool   `json:"shared"`
+	LocalEndpoint struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"localEndpoint"`
+}
+
+// readZipkin reads spans from Zipkin v2 JSON; either a list of spans or a list
+// of traces, each a list of spans.
+func readZipkin(r io.Reader) ([]*span, error) {
+	raw := []json.RawMessage{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("cannot decode Zipkin JSON: %v", err)
+	}
+	zs := []zipkinSpan{}
+	for _, m := range raw {
+		if strings.HasPrefix(strings.TrimSpace(string(m)), "[") {
+			t := []zipkinSpan{}
+			if err := json.Unmarshal(m, &t); err != nil {
+				return nil, fmt.Errorf("cannot decode Zipkin trace: %v", err)
+			}
+			zs = append(zs, t...)
+			continue
+		}
+		z := zipkinSpan{}
+		if err := json.Unmarshal(m, &z); err != nil {
+			return nil, fmt.Errorf("cannot decode Zipkin span: %v", err)
+		}
+		zs = append(zs, z)
+	}
+
+	spans := make([]*span, 0, len(zs))
+	for _, z := range zs {
+		name := z.Name
+		if z.Kind != "" {
+			name = strings.ToLower(z.Kind) + " " + name
+		}
+		spans = append(spans, &span{
+			TraceID:  pad(z.TraceID),
+			ID:       pad16(z.ID),
+			ParentID: pad16(z.ParentID),
+			Name:     name,
+			Service:  z.LocalEndpoint.ServiceName,
+			Start:    time.Unix(0, z.Timestamp*int64(time.Microsecond)),
+			Duration: time.Duration(z.Duration) * time.Microsecond,
+			Shared:   z.Shared,
+		})
+	}
+	return spans, nil
+}
+
+// A capturedRequest is a line of a captured header log.
+type capturedRequest struct {
+	Time     time.Time   `json:"time"`
+	Duration float64     `json:"duration_seconds"`
+	Method   string      `json:"method"`
+	URI      string      `json:"uri"`
+	Header   http.Header `json:"header"`
+}
+
+// readHeaders reads spans from a captured header log. Requests that carry no
+// decodable span context are skipped.
+func readHeaders(r io.Reader) ([]*span, error) {
+	spans := []*span{}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		c := capturedRequest{}
+		if err := json.Unmarshal(s.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("cannot decode line %d: %v", n, err)
+		}
+		sp, ok := spanFromHeader(c.Header)
+		if !ok {
+			continue
+		}
+		sp.Name = strings.TrimSpace(c.Method + " " + c.URI)
+		sp.Start = c.Time
+		sp.Duration = time.Duration(c.Duration * float64(time.Second))
+		spans = append(spans, sp)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read header log: %v", err)
+	}
+	return spans, nil
+}
+
+// spanFromHeader decodes a span from the supplied l5d-ctx-trace header, or from
+// the supplied B3 headers.
+func spanFromHeader(h http.Header) (*span, bool) {
+	if v := h.Get("l5d-ctx-trace"); v != "" {
+		id, err := wire.Parse(v)
+		if err != nil {
+			return nil, false
+		}
+		sp := &span{TraceID: hex.EncodeToString(id.Trace[:]), ID: hex.EncodeToString(id.Span[:])}
+		if !id.IsRoot() && id.Parent != [8]byte{} {
+			sp.ParentID = hex.EncodeToString(id.Parent[:])
+		}
+		return sp, true
+	}
+	if h.Get("X-B3-TraceId") != "" && h.Get("X-B3-SpanId") != "" {
+		return &span{
+			TraceID:  pad(h.Get("X-B3-TraceId")),
+			ID:       pad16(h.Get("X-B3-SpanId")),
+			ParentID: pad16(h.Get("X-B3-ParentSpanId")),
+		}, true
+	}
+	return nil, false
+}
+
+// pad left pads the supplied hex trace ID to 128 bits.
+func pad(id string) string {
+	if id == "" || len(id) >= 32 {
+		return strings.ToLower(id)
+	}
+	return strings.Repeat("0", 32-len(id)) + strings.ToLower(id)
+}
+
+// pad16 left pads the supplied hex span ID to 64 bits.
+func pad16(id string) string {
+	if id == "" || len(id) >= 16 {
+		return strings.ToLower(id)
+	}
+	return strings.Repeat("0", 16-len(id)) + strings.ToLower(id)
+}
+
+// A tree is a trace assembled from its spans.
+type tree struct {
+	TraceID string
+	Roots   []*span
+	Start   time.Time
+	End     time.Time
+	Spans   int
+}
+
+// assemble groups the supplied spans into traces, ordered by start time. Spans
+// whose parent is unknown are roots of their trace.
+func assemble(spans []*span) []*tree {
+	byTrace := map[string][]*span{}
+	for _, sp := range spans {
+		byTrace[sp.TraceID] = append(byTrace[sp.TraceID], sp)
+	}
+
+	trees := make([]*tree, 0, len(byTrace))
+	for id, spans := range byTrace {
+		t := &tree{TraceID: id, Spans: len(spans)}
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+
+		// The client side of a shared span is the parent of its server side.
+		client := map[string]*span{}
+		for _, sp := range spans {
+			if !sp.Shared {
+				client[sp.ID] = sp
+			}
+		}
+		for _, sp := range spans {
+			p, ok := client[sp.ParentID]
+			if sp.Shared {
+				if c, found := client[sp.ID]; found {
+					p, ok = c, true
+				}
+			}
+			if ok && p != sp {
+				p.children = append(p.children, sp)
+			} else {
+				t.Roots = append(t.Roots, sp)
+			}
+			if t.Start.IsZero() || sp.Start.Before(t.Start) {
+				t.Start = sp.Start
+			}
+			if end := sp.Start.Add(sp.Duration); end.After(t.End) {
+				t.End = end
+			}
+		}
+		trees = append(trees, t)
+	}
+	sort.Slice(trees, func(i, j int) bool {
+		if !trees[i].Start.Equal(trees[j].Start) {
+			return trees[i].Start.Before(trees[j].Start)
+		}
+		return trees[i].TraceID < trees[j].TraceID
+	})
+	return trees
+}
+
+// print writes the supplied trace as an ASCII tree. Each span is annotated with
+// its start offset from the beginning of the trace and its duration.
+func (t *tree) print(w io.Writer) {
+	fmt.Fprintf(w, "trace %s (%d spans, %s)\n", t.TraceID, t.Spans, t.End.Sub(t.Start))
+	for i, sp := range t.Roots {
+		t.printSpan(w, sp, "", i == len(t.Roots)-1)
+	}
+}
+
+func (t *tree) printSpan(w io.Writer, sp *span, indent string, last bool) {
+	branch, next := "|-- ", "|   "
+	if last {
+		branch, next = "`-- ", "    "
+	}
+	label := sp.Name
+	if sp.Service != "" {
+		label = sp.Service + ": " + label
+	}
+	if label == "" {
+		label = sp.ID
+	}
+	fmt.Fprintf(w, "%s%s%s [+%s %s]\n", indent, branch, label, sp.Start.Sub(t.Start), sp.Duration)
+	for i, c := range sp.children {
+		t.printSpan(w, c, indent+next, i == len(sp.children)-1)
+	}
+}
+
+// read reads spans in the supplied format. Input beginning with "[" is read as
+// Zipkin JSON if the format is formatAuto.
+func read(r io.Reader, format string) ([]*span, error) {
+	br := bufio.NewReader(r)
+	if format == formatAuto {
+		format = formatHeaders
+		for {
+			b, err := br.ReadByte()
+			if err != nil {
+				break
+			}
+			if strings.TrimSpace(string(b)) == "" {
+				continue
+			}
+			if b == '[' {
+				format = formatZipkin
+			}
+			br.UnreadByte()
+			break
+		}
+	}
+	if format == formatZipkin {
+		return readZipkin(br)
+	}
+	return readHeaders(br)
+}
+
+func main() {
+	var (
+		app     = kingpin.New(filepath.Base(os.Args[0]), "Prints traces as ASCII trees.").DefaultEnvars()
+		format  = app.Flag("format", "Format of the input.").Default(formatAuto).Enum(formatAuto, formatZipkin, formatHeaders)
+		traceID = app.Flag("trace-id", "Print only the trace with this hex encoded ID.").String()
+		files   = app.Arg("files", "Files from which to read traces. Standard input is read if none are supplied.").ExistingFiles()
+	)
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	spans := []*span{}
+	if len(*files) == 0 {
+		s, err := read(os.Stdin, *format)
+		kingpin.FatalIfError(err, "cannot read standard input")
+		spans = append(spans, s...)
+	}
+	for _, name := range *files {
+		f, err := os.Open(name)
+		kingpin.FatalIfError(err, "cannot open %s", name)
+		s, err := read(f, *format)
+		f.Close()
+		kingpin.FatalIfError(err, "cannot read %s", name)
+		spans = append(spans, s...)
+	}
+
+	for _, t := range assemble(spans) {
+		if *traceID != "" && t.TraceID != pad(*traceID) {
+			continue
+		}
+		t.print(os.Stdout)
+	}
+}
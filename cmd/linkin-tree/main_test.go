@@ -0,0 +1,111 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const zipkinTrace = `[
+  {"traceId": "a", "id": "a", "name": "get /", "kind": "SERVER", "timestamp": 1000000, "duration": 20000, "localEndpoint": {"serviceName": "frontend"}},
+  {"traceId": "a", "id": "b", "parentId": "a", "name": "get /users", "kind": "CLIENT", "timestamp": 1002000, "duration": 15000, "localEndpoint": {"serviceName": "frontend"}},
+  {"traceId": "a", "id": "b", "parentId": "a", "name": "get /users", "kind": "SERVER", "timestamp": 1003000, "duration": 12000, "shared": true, "localEndpoint": {"serviceName": "users"}}
+]`
+
+const headerLog = `
+{"time": "2018-06-01T12:00:00Z", "duration_seconds": 0.02, "method": "GET", "uri": "/", "header": {"L5d-Ctx-Trace": ["AAAAAAAAAAoAAAAAAAAAAAAAAAAAAAAKAAAAAAAAAAYAAAAAAAAAAA=="]}}
+{"time": "2018-06-01T12:00:00.005Z", "duration_seconds": 0.01, "method": "GET", "uri": "/users", "header": {"L5d-Ctx-Trace": ["AAAAAAAAAAsAAAAAAAAACgAAAAAAAAAKAAAAAAAAAAYAAAAAAAAAAA=="]}}
+{"time": "2018-06-01T12:00:00.010Z", "duration_seconds": 0.001, "method": "GET", "uri": "/orphan", "header": {"L5d-Ctx-Trace": ["AAAAAAAAAAwAAAAAAAAADQAAAAAAAAAKAAAAAAAAAAYAAAAAAAAAAA=="]}}
+{"time": "2018-06-01T12:00:01Z", "duration_seconds": 0.002, "method": "POST", "uri": "/b3", "header": {"X-B3-Traceid": ["1"], "X-B3-Spanid": ["2"]}}
+{"time": "2018-06-01T12:00:02Z", "duration_seconds": 0.002, "method": "GET", "uri": "/untraced", "header": {}}
+`
+
+func TestPrint(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Zipkin",
+			input:  zipkinTrace,
+			format: formatZipkin,
+			want: "trace 0000000000000000000000000000000a (3 spans, 20ms)\n" +
+				"`-- frontend: server get / [+0s 20ms]\n" +
+				"    `-- frontend: client get /users [+2ms 15ms]\n" +
+				"        `-- users: server get /users [+3ms 12ms]\n",
+		},
+		{
+			name:   "ZipkinTraces",
+			input:  "[" + zipkinTrace + "]",
+			format: formatAuto,
+			want: "trace 0000000000000000000000000000000a (3 spans, 20ms)\n" +
+				"`-- frontend: server get / [+0s 20ms]\n" +
+				"    `-- frontend: client get /users [+2ms 15ms]\n" +
+				"        `-- users: server get /users [+3ms 12ms]\n",
+		},
+		{
+			name:   "Headers",
+			input:  headerLog,
+			format: formatAuto,
+			want: "trace 0000000000000000000000000000000a (3 spans, 20ms)\n" +
+				"|-- GET / [+0s 20ms]\n" +
+				"|   `-- GET /users [+5ms 10ms]\n" +
+				"`-- GET /orphan [+10ms 1ms]\n" +
+				"trace 00000000000000000000000000000001 (1 spans, 2ms)\n" +
+				"`-- POST /b3 [+0s 2ms]\n",
+		},
+		{
+			name:    "InvalidZipkin",
+			input:   `{"traceId": "a"}`,
+			format:  formatZipkin,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidHeaders",
+			input:   `[`,
+			format:  formatHeaders,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			spans, err := read(strings.NewReader(tc.input), tc.format)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("read(): want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read(): %v", err)
+			}
+			b := &bytes.Buffer{}
+			for _, tr := range assemble(spans) {
+				tr.print(b)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("print(): want\n%s\ngot\n%s", tc.want, got)
+			}
+		})
+	}
+}
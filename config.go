@@ -0,0 +1,373 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/planetlabs/linkin/baggage"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// Config configures a propagation stack. It may be decoded from JSON or YAML,
+// allowing tracing configuration to be standardized across many services.
+type Config struct {
+	// Formats are the names of registered propagation formats (see Register)
+	// used to extract span context, in priority order. The l5d format is used
+	// if Formats is empty.
+	Formats []string `json:"formats,omitempty" yaml:"formats,omitempty"`
+
+	// Inject are the names of registered propagation formats used to inject
+	// span context. Naming several formats enables dual injection. All Formats
+	// are used if Inject is empty.
+	Inject []string `json:"inject,omitempty" yaml:"inject,omitempty"`
+
+	// ForceSample configures the local sampler to sample every span,
+	// regardless of the upstream sampling decision. Span contexts sampled
+	// upstream are never unsampled. ForceSample takes precedence over
+	// SampleRate.
+	ForceSample bool `json:"forceSample,omitempty" yaml:"forceSample,omitempty"`
+
+	// CookieName configures the l5d format to extract span context from the
+	// named cookie. See HTTPFormat.
+	CookieName string `json:"cookieName,omitempty" yaml:"cookieName,omitempty"`
+
+	// SampleRate is the fraction of root spans to sample, between 0 and 1. The
+	// default OpenCensus sampler is used if SampleRate is nil.
+	SampleRate *float64 `json:"sampleRate,omitempty" yaml:"sampleRate,omitempty"`
+
+	// SampleByTraceID configures SampleRate to sample purely by trace ID,
+	// ignoring upstream sampling decisions, so that every service with the
+	// same SampleRate makes the same decision. See TraceIDSampler.
+	SampleByTraceID bool `json:"sampleByTraceID,omitempty" yaml:"sampleByTraceID,omitempty"`
+
+	// HeaderPrefix is a tenant specific prefix to which the l5d-ctx-* headers
+	// are mapped. See PrefixHandler and PrefixTransport.
+	HeaderPrefix string `json:"headerPrefix,omitempty" yaml:"headerPrefix,omitempty"`
+
+	// MaxContextBytes limits the total size of the context headers sent with
+	// each request. See HeaderLimitTransport.
+	MaxContextBytes int `json:"maxContextBytes,omitempty" yaml:"maxContextBytes,omitempty"`
+
+	// DropAll configures requests exceeding MaxContextBytes to drop all
+	// context headers, rather than the largest headers first.
+	DropAll bool `json:"dropAll,omitempty" yaml:"dropAll,omitempty"`
+
+	// LinkerdVersion is the version of the linkerd to which requests are
+	// sent. It configures the l5d format to emit only headers that version
+	// understands, until evidence of further capabilities is observed in
+	// incoming requests or in responses to outgoing requests. See HTTPFormat,
+	// Detector, and DetectorTransport. The l5d format assumes the latest
+	// linkerd if LinkerdVersion is empty.
+	LinkerdVersion string `json:"linkerdVersion,omitempty" yaml:"linkerdVersion,omitempty"`
+
+	// Encoding is the base64 encoding of outgoing l5d-ctx-trace headers: std,
+	// raw (unpadded), url (URL safe), or rawurl. See HTTPFormat. Headers are
+	// encoded per std if Encoding is empty.
+	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+
+	// Strict configures the l5d format to refuse to inject invalid span
+	// contexts. See HTTPFormat.
+	Strict bool `json:"strict,omitempty" yaml:"strict,omitempty"`
+
+	// Preserve configures the l5d format to leave l5d-ctx-trace headers
+	// already present on outgoing requests untouched. See HTTPFormat.
+	Preserve bool `json:"preserve,omitempty" yaml:"preserve,omitempty"`
+
+	// Suppress match outgoing requests that must not carry trace headers. See
+	// SuppressTransport.
+	Suppress []SuppressRule `json:"suppress,omitempty" yaml:"suppress,omitempty"`
+
+	// Allow, if non-empty, match the only outgoing requests that may carry
+	// trace headers. See SuppressTransport.
+	Allow []SuppressRule `json:"allow,omitempty" yaml:"allow,omitempty"`
+
+	// Baggage configures handlers and transports to propagate baggage in the
+	// l5d-ctx-baggage header. See the baggage package.
+	Baggage bool `json:"baggage,omitempty" yaml:"baggage,omitempty"`
+
+	// MaxBaggageEntries limits the number of baggage entries restored from
+	// each incoming request and propagated with each outgoing request, if
+	// Baggage is true. Baggage is unlimited if MaxBaggageEntries is zero.
+	MaxBaggageEntries int `json:"maxBaggageEntries,omitempty" yaml:"maxBaggageEntries,omitempty"`
+
+	// TraceUI, if non-nil, configures handlers to link to the trace of each
+	// sampled request in a response header. Set it only in non-production
+	// configs. See TraceUIHandler.
+	TraceUI *TraceUI `json:"traceUI,omitempty" yaml:"traceUI,omitempty"`
+}
+
+// encodings are the base64 encodings that may be configured by name.
+var encodings = map[string]*base64.Encoding{
+	"":       nil,
+	"std":    base64.StdEncoding,
+	"raw":    base64.RawStdEncoding,
+	"url":    base64.URLEncoding,
+	"rawurl": base64.RawURLEncoding,
+}
+
+// A Stack is a propagation stack built from a Config.
+type Stack struct {
+	// Propagation is the configured propagation format.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are the configured span start options.
+	StartOptions trace.StartOptions
+
+	config   Config
+	detector *Detector
+}
+
+// Build builds a propagation stack from the Config.
+func (c Config) Build() (*Stack, error) {
+	names := c.Formats
+	if len(names) == 0 {
+		names = []string{FormatLinkerd}
+	}
+	// The extracting and injecting l5d formats share a Detector, so that
+	// capabilities observed on incoming requests inform outgoing requests.
+	var d *Detector
+	if c.LinkerdVersion != "" {
+		caps, err := CapabilitiesForVersion(c.LinkerdVersion)
+		if err != nil {
+			return nil, fmt.Errorf("cannot build propagation: %v", err)
+		}
+		d = NewDetector(caps)
+	}
+	enc, ok := encodings[c.Encoding]
+	if !ok {
+		return nil, fmt.Errorf("cannot build propagation: unknown encoding %q", c.Encoding)
+	}
+	extract, err := c.formats(names, d, enc)
+	if err != nil {
+		return nil, err
+	}
+	inject, err := c.formats(c.Inject, d, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stack{config: c, detector: d}
+	switch {
+	case len(extract) == 1 && len(inject) == 0:
+		s.Propagation = extract[0]
+	default:
+		s.Propagation = &MultiFormat{Extract: extract, Inject: inject}
+	}
+	if c.SampleRate != nil {
+		if *c.SampleRate < 0 || *c.SampleRate > 1 {
+			return nil, fmt.Errorf("cannot build propagation: invalid sample rate %v", *c.SampleRate)
+		}
+		s.StartOptions.Sampler = probabilitySampler(*c.SampleRate)
+		if c.SampleByTraceID {
+			s.StartOptions.Sampler = TraceIDSampler(*c.SampleRate)
+		}
+	}
+	if c.ForceSample {
+		s.StartOptions.Sampler = trace.AlwaysSample()
+	}
+	if c.MaxBaggageEntries < 0 {
+		return nil, fmt.Errorf("cannot build propagation: invalid max baggage entries %d", c.MaxBaggageEntries)
+	}
+	return s, nil
+}
+
+func (c Config) formats(names []string, d *Detector, enc *base64.Encoding) ([]propagation.HTTPFormat, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	formats := make([]propagation.HTTPFormat, 0, len(names))
+	for _, name := range names {
+		if name == FormatLinkerd {
+			formats = append(formats, &HTTPFormat{CookieName: c.CookieName, Linkerd: d, Encoding: enc, Strict: c.Strict, Preserve: c.Preserve})
+			continue
+		}
+		f, ok := Get(name)
+		if !ok {
+			return nil, fmt.Errorf("cannot build propagation: unknown format %q", name)
+		}
+		formats = append(formats, f)
+	}
+	return formats, nil
+}
+
+// Config returns the Config from which the stack was built.
+func (s *Stack) Config() Config {
+	return s.config
+}
+
+// Handler wraps the supplied handler in an ochttp.Handler that uses the
+// configured propagation format and start options.
+func (s *Stack) Handler(h http.Handler) http.Handler {
+	if s.config.TraceUI != nil {
+		h = &TraceUIHandler{Handler: h, UI: s.config.TraceUI}
+	}
+	if s.config.Baggage {
+		h = &baggage.Handler{Handler: h, MaxEntries: s.config.MaxBaggageEntries}
+	}
+	h = &ochttp.Handler{Handler: h, Propagation: s.Propagation, StartOptions: s.StartOptions}
+	if s.config.HeaderPrefix != "" {
+		h = &PrefixHandler{Handler: h, Prefix: s.config.HeaderPrefix}
+	}
+	return h
+}
+
+// Transport wraps the supplied transport in an ochttp.Transport that uses the
+// configured propagation format and start options. http.DefaultTransport is
+// wrapped if the supplied transport is nil.
+func (s *Stack) Transport(base http.RoundTripper) http.RoundTripper {
+	if s.config.HeaderPrefix != "" {
+		base = &PrefixTransport{Base: base, Prefix: s.config.HeaderPrefix}
+	}
+	if len(s.config.Suppress) > 0 || len(s.config.Allow) > 0 {
+		base = &SuppressTransport{Base: base, Rules: s.config.Suppress, Allow: s.config.Allow}
+	}
+	if s.config.MaxContextBytes > 0 {
+		p := DropLargest
+		if s.config.DropAll {
+			p = DropAll
+		}
+		base = &HeaderLimitTransport{Base: base, MaxBytes: s.config.MaxContextBytes, Policy: p}
+	}
+	if s.config.Baggage {
+		base = &baggage.Transport{Base: base, MaxEntries: s.config.MaxBaggageEntries}
+	}
+	if s.detector != nil {
+		base = &DetectorTransport{Base: base, Detector: s.detector}
+	}
+	return &ochttp.Transport{Base: base, Propagation: s.Propagation, StartOptions: s.StartOptions}
+}
+
+// Environment variables from which ConfigFromEnv reads configuration. Lists
+// are comma separated.
+const (
+	EnvFormats           = "LINKIN_FORMATS"
+	EnvInject            = "LINKIN_INJECT"
+	EnvForceSample       = "LINKIN_FORCE_SAMPLE"
+	EnvCookieName        = "LINKIN_COOKIE_NAME"
+	EnvSampleRate        = "LINKIN_SAMPLE_RATE"
+	EnvSampleByTraceID   = "LINKIN_SAMPLE_BY_TRACE_ID"
+	EnvHeaderPrefix      = "LINKIN_HEADER_PREFIX"
+	EnvMaxContextBytes   = "LINKIN_MAX_CONTEXT_BYTES"
+	EnvBaggage           = "LINKIN_BAGGAGE"
+	EnvMaxBaggageEntries = "LINKIN_MAX_BAGGAGE_ENTRIES"
+	EnvLinkerdVersion    = "LINKIN_LINKERD_VERSION"
+	EnvEncoding          = "LINKIN_ENCODING"
+	EnvStrict            = "LINKIN_STRICT"
+	EnvPreserve          = "LINKIN_PRESERVE"
+	EnvTraceUIURL        = "LINKIN_TRACE_UI_URL"
+	EnvTraceUIKind       = "LINKIN_TRACE_UI_KIND"
+)
+
+// ConfigFromEnv reads a Config from the LINKIN_* environment variables.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		Formats:        list(os.Getenv(EnvFormats)),
+		Inject:         list(os.Getenv(EnvInject)),
+		CookieName:     os.Getenv(EnvCookieName),
+		HeaderPrefix:   os.Getenv(EnvHeaderPrefix),
+		LinkerdVersion: os.Getenv(EnvLinkerdVersion),
+		Encoding:       os.Getenv(EnvEncoding),
+	}
+	if v := os.Getenv(EnvForceSample); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvForceSample, err)
+		}
+		c.ForceSample = b
+	}
+	if v := os.Getenv(EnvStrict); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvStrict, err)
+		}
+		c.Strict = b
+	}
+	if v := os.Getenv(EnvPreserve); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvPreserve, err)
+		}
+		c.Preserve = b
+	}
+	if v := os.Getenv(EnvSampleRate); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvSampleRate, err)
+		}
+		c.SampleRate = &f
+	}
+	if v := os.Getenv(EnvSampleByTraceID); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvSampleByTraceID, err)
+		}
+		c.SampleByTraceID = b
+	}
+	if v := os.Getenv(EnvMaxContextBytes); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvMaxContextBytes, err)
+		}
+		c.MaxContextBytes = i
+	}
+	if v := os.Getenv(EnvBaggage); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvBaggage, err)
+		}
+		c.Baggage = b
+	}
+	if v := os.Getenv(EnvMaxBaggageEntries); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("cannot parse %s: %v", EnvMaxBaggageEntries, err)
+		}
+		c.MaxBaggageEntries = i
+	}
+	if v := os.Getenv(EnvTraceUIURL); v != "" {
+		c.TraceUI = &TraceUI{URL: v, Kind: os.Getenv(EnvTraceUIKind)}
+	}
+	return c, nil
+}
+
+// FromEnv builds a propagation stack configured by the LINKIN_* environment
+// variables, for twelve-factor deployments.
+func FromEnv() (*Stack, error) {
+	c, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return c.Build()
+}
+
+func list(v string) []string {
+	var l []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			l = append(l, s)
+		}
+	}
+	return l
+}
@@ -0,0 +1,424 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/planetlabs/linkin/baggage"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestConfigBuild(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		want    propagation.HTTPFormat
+		sampler bool
+		wantErr bool
+	}{
+		{
+			name:   "Default",
+			config: `{}`,
+			want:   &HTTPFormat{},
+		},
+		{
+			name:    "ConfiguredLinkerd",
+			config:  `{"formats": ["l5d"], "forceSample": true, "cookieName": "l5d"}`,
+			want:    &HTTPFormat{CookieName: "l5d"},
+			sampler: true,
+		},
+		{
+			name:   "DualInjection",
+			config: `{"formats": ["l5d", "b3"], "inject": ["l5d", "b3"]}`,
+			want: &MultiFormat{
+				Extract: []propagation.HTTPFormat{&HTTPFormat{}, &B3Format{}},
+				Inject:  []propagation.HTTPFormat{&HTTPFormat{}, &B3Format{}},
+			},
+		},
+		{
+			name:    "SampleRate",
+			config:  `{"sampleRate": 0.5}`,
+			want:    &HTTPFormat{},
+			sampler: true,
+		},
+		{
+			name:   "Encoding",
+			config: `{"encoding": "rawurl"}`,
+			want:   &HTTPFormat{Encoding: base64.RawURLEncoding},
+		},
+		{
+			name:   "Strict",
+			config: `{"strict": true}`,
+			want:   &HTTPFormat{Strict: true},
+		},
+		{
+			name:   "Preserve",
+			config: `{"preserve": true}`,
+			want:   &HTTPFormat{Preserve: true},
+		},
+		{
+			name:    "UnknownEncoding",
+			config:  `{"encoding": "base32"}`,
+			wantErr: true,
+		},
+		{
+			name:    "SampleByTraceID",
+			config:  `{"sampleRate": 0.5, "sampleByTraceID": true}`,
+			want:    &HTTPFormat{},
+			sampler: true,
+		},
+		{
+			name:   "LinkerdVersion",
+			config: `{"linkerdVersion": "1.2.1"}`,
+			want:   &HTTPFormat{Linkerd: NewDetector(Capabilities{})},
+		},
+		{
+			name:    "InvalidLinkerdVersion",
+			config:  `{"linkerdVersion": "latest"}`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidSampleRate",
+			config:  `{"sampleRate": 2}`,
+			wantErr: true,
+		},
+		{
+			name:   "Baggage",
+			config: `{"baggage": true, "maxBaggageEntries": 8}`,
+			want:   &HTTPFormat{},
+		},
+		{
+			name:    "InvalidMaxBaggageEntries",
+			config:  `{"baggage": true, "maxBaggageEntries": -1}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownFormat",
+			config:  `{"formats": ["jaeger"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "UnknownInjectFormat",
+			config:  `{"inject": ["jaeger"]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{}
+			if err := json.Unmarshal([]byte(tc.config), &c); err != nil {
+				t.Fatalf("json.Unmarshal(): %v", err)
+			}
+			s, err := c.Build()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("c.Build(): want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("c.Build(): %v", err)
+			}
+			if !reflect.DeepEqual(s.Propagation, tc.want) {
+				t.Errorf("c.Build(): want propagation %#v, got %#v", tc.want, s.Propagation)
+			}
+			if got := s.StartOptions.Sampler != nil; got != tc.sampler {
+				t.Errorf("c.Build(): want sampler %t, got %t", tc.sampler, got)
+			}
+			if got := s.Config(); !reflect.DeepEqual(got, c) {
+				t.Errorf("s.Config(): want %+v, got %+v", c, got)
+			}
+		})
+	}
+}
+
+func TestStack(t *testing.T) {
+	c := Config{Formats: []string{FormatLinkerd, FormatB3}, HeaderPrefix: "acme-ctx-", MaxContextBytes: 1024}
+	s, err := c.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	rt := &recordingTransport{}
+	client := &http.Client{Transport: s.Transport(rt)}
+
+	var server trace.SpanContext
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server = trace.FromContext(r.Context()).SpanContext()
+		out, _ := http.NewRequest("GET", "http://example.net", nil)
+		if _, err := client.Do(out.WithContext(r.Context())); err != nil {
+			t.Fatalf("client.Do(): %v", err)
+		}
+	}))
+
+	parent := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+	r := httptest.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set("acme-ctx-trace", encode(parent))
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(context.Background()))
+
+	if server.TraceID != parent.TraceID {
+		t.Errorf("s.Handler(): want trace ID %v, got %v", parent.TraceID, server.TraceID)
+	}
+	for _, k := range []string{"Acme-Ctx-Trace", "X-B3-Traceid"} {
+		if rt.r.Header.Get(k) == "" {
+			t.Errorf("s.Transport(): want header %s, got %v", k, rt.r.Header)
+		}
+	}
+	if rt.r.Header.Get(l5dHeaderTrace) != "" {
+		t.Errorf("s.Transport(): want no %s header, got %v", l5dHeaderTrace, rt.r.Header)
+	}
+}
+
+func TestStackBaggage(t *testing.T) {
+	c := Config{Baggage: true, MaxBaggageEntries: 1}
+	s, err := c.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	rt := &recordingTransport{}
+	client := &http.Client{Transport: s.Transport(rt)}
+
+	var got baggage.Baggage
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = baggage.FromContext(r.Context())
+		ctx := baggage.With(r.Context(), "user", "jurgen")
+		out, _ := http.NewRequest("GET", "http://example.net", nil)
+		if _, err := client.Do(out.WithContext(ctx)); err != nil {
+			t.Fatalf("client.Do(): %v", err)
+		}
+	}))
+
+	r := httptest.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set(baggage.Header, "debug=1,tenant=acme")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if want := (baggage.Baggage{"debug": "1"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("s.Handler(): want baggage %v, got %v", want, got)
+	}
+	if got, want := rt.r.Header.Get(baggage.Header), "debug=1"; got != want {
+		t.Errorf("s.Transport(): want %s %q, got %q", baggage.Header, want, got)
+	}
+}
+
+func TestStackDetector(t *testing.T) {
+	s, err := Config{LinkerdVersion: "1.2.1"}.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	sc := trace.SpanContext{TraceID: trace.TraceID{0: 1, 15: 1}, SpanID: trace.SpanID{7: 1}, TraceOptions: ocShouldSample}
+	id := traceIDFromSpanContext(sc)
+	rt := &respondingTransport{header: http.Header{}}
+	rt.header.Set(l5dHeaderTrace, id.String())
+	client := &http.Client{Transport: s.Transport(rt)}
+
+	// The first response reveals that linkerd supports 128 bit trace IDs.
+	ctx, span := trace.StartSpanWithRemoteParent(context.Background(), "test", sc)
+	defer span.End()
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "http://example.net", nil)
+		if _, err := client.Do(r.WithContext(ctx)); err != nil {
+			t.Fatalf("client.Do(): %v", err)
+		}
+	}
+	for i, want := range []int{32, 40} {
+		h, err := base64.StdEncoding.DecodeString(rt.reqs[i].Header.Get(l5dHeaderTrace))
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if len(h) != want {
+			t.Errorf("request %d: want %d byte %s header, got %d bytes", i, want, l5dHeaderTrace, len(h))
+		}
+	}
+}
+
+func TestStackSuppress(t *testing.T) {
+	c := Config{Formats: []string{FormatLinkerd, FormatB3}, HeaderPrefix: "acme-ctx-", Suppress: []SuppressRule{{Host: ".example.net"}}}
+	s, err := c.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	rt := &recordingTransport{}
+	client := &http.Client{Transport: s.Transport(rt)}
+	if _, err := client.Get("http://api.example.net"); err != nil {
+		t.Fatalf("client.Get(): %v", err)
+	}
+	for k := range rt.r.Header {
+		if isTraceHeader(k) || strings.HasPrefix(k, "Acme-Ctx-") {
+			t.Errorf("s.Transport(): want no trace headers, got %v", rt.r.Header)
+		}
+	}
+}
+
+func TestStackAllow(t *testing.T) {
+	c := Config{Allow: []SuppressRule{{Host: ".svc.cluster.local"}}}
+	s, err := c.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	rt := &recordingTransport{}
+	client := &http.Client{Transport: s.Transport(rt)}
+	if _, err := client.Get("http://web.default.svc.cluster.local"); err != nil {
+		t.Fatalf("client.Get(): %v", err)
+	}
+	if rt.r.Header.Get(l5dHeaderTrace) == "" {
+		t.Errorf("s.Transport(): want header %s, got %v", l5dHeaderTrace, rt.r.Header)
+	}
+	if _, err := client.Get("http://api.example.net"); err != nil {
+		t.Fatalf("client.Get(): %v", err)
+	}
+	for k := range rt.r.Header {
+		if isTraceHeader(k) {
+			t.Errorf("s.Transport(): want no trace headers, got %v", rt.r.Header)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	unset := map[string]string{
+		EnvFormats: "", EnvInject: "", EnvForceSample: "", EnvCookieName: "",
+		EnvSampleRate: "", EnvSampleByTraceID: "", EnvHeaderPrefix: "", EnvMaxContextBytes: "",
+		EnvBaggage: "", EnvMaxBaggageEntries: "",
+		EnvLinkerdVersion: "", EnvEncoding: "", EnvStrict: "", EnvPreserve: "", EnvTraceUIURL: "", EnvTraceUIKind: "",
+	}
+	half := 0.5
+
+	cases := []struct {
+		name    string
+		env     map[string]string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "Unset",
+			env:  unset,
+			want: Config{},
+		},
+		{
+			name: "AllSet",
+			env: map[string]string{
+				EnvFormats:           "l5d, b3",
+				EnvInject:            "b3",
+				EnvForceSample:       "true",
+				EnvCookieName:        "l5d",
+				EnvSampleRate:        "0.5",
+				EnvSampleByTraceID:   "true",
+				EnvHeaderPrefix:      "acme-ctx-",
+				EnvMaxContextBytes:   "1024",
+				EnvBaggage:           "true",
+				EnvMaxBaggageEntries: "8",
+				EnvLinkerdVersion:    "1.2.1",
+				EnvEncoding:          "raw",
+				EnvStrict:            "true",
+				EnvPreserve:          "true",
+				EnvTraceUIURL:        "http://jaeger:16686",
+				EnvTraceUIKind:       "jaeger",
+			},
+			want: Config{
+				Formats:           []string{"l5d", "b3"},
+				Inject:            []string{"b3"},
+				ForceSample:       true,
+				CookieName:        "l5d",
+				SampleRate:        &half,
+				SampleByTraceID:   true,
+				HeaderPrefix:      "acme-ctx-",
+				MaxContextBytes:   1024,
+				Baggage:           true,
+				MaxBaggageEntries: 8,
+				LinkerdVersion:    "1.2.1",
+				Encoding:          "raw",
+				Strict:            true,
+				Preserve:          true,
+				TraceUI:           &TraceUI{URL: "http://jaeger:16686", Kind: "jaeger"},
+			},
+		},
+		{
+			name:    "InvalidForceSample",
+			env:     map[string]string{EnvForceSample: "always"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidSampleRate",
+			env:     map[string]string{EnvSampleRate: "half"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidStrict",
+			env:     map[string]string{EnvStrict: "very"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidPreserve",
+			env:     map[string]string{EnvPreserve: "maybe"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidSampleByTraceID",
+			env:     map[string]string{EnvSampleByTraceID: "yes please"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidBaggage",
+			env:     map[string]string{EnvBaggage: "please"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidMaxBaggageEntries",
+			env:     map[string]string{EnvMaxBaggageEntries: "lots"},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidMaxContextBytes",
+			env:     map[string]string{EnvMaxContextBytes: "1KB"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setenv(t, unset)()
+			defer setenv(t, tc.env)()
+
+			got, err := ConfigFromEnv()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ConfigFromEnv(): want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigFromEnv(): %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ConfigFromEnv(): want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
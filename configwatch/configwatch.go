@@ -0,0 +1,184 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package configwatch applies linkin configuration from a file live, as linkerd
+// itself is reconfigured in-cluster. The file is typically a key of a
+// Kubernetes ConfigMap mounted as a volume, which the kubelet updates in place
+// when the ConfigMap changes:
+//
+//  w := &configwatch.Watcher{Path: "/etc/linkin/config.json"}
+//  go w.Run(ctx)
+//  http.ListenAndServe(":8080", w.Handler(mux))
+//
+// The file contains a JSON encoded linkin.Config.
+package configwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/planetlabs/linkin"
+)
+
+// DefaultInterval is the default interval at which a Watcher reads its file.
+const DefaultInterval = 10 * time.Second
+
+// A Watcher periodically reads a linkin.Config from a file, rebuilding its
+// propagation stack when the file changes. Handlers and transports returned by
+// a Watcher always use its most recently built stack, which is the stack built
+// from the zero linkin.Config until the file is first read successfully.
+type Watcher struct {
+	// Path is the path of the file from which to read configuration.
+	Path string
+
+	// Interval at which the file is read. DefaultInterval is used if Interval
+	// is zero.
+	Interval time.Duration
+
+	// ErrorHandler is called with any error encountered while reading the
+	// file or building a stack. The most recently built stack continues to be
+	// used until the file is read successfully. Errors are ignored if
+	// ErrorHandler is nil.
+	ErrorHandler func(error)
+
+	// loaded holds a *loaded describing the most recently built stack.
+	loaded atomic.Value
+}
+
+// loaded is a stack and the file from which it was built.
+type loaded struct {
+	raw   []byte
+	stack *linkin.Stack
+}
+
+// Run reads the file until the supplied context is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	i := w.Interval
+	if i == 0 {
+		i = DefaultInterval
+	}
+	t := time.NewTicker(i)
+	defer t.Stop()
+
+	for {
+		if err := w.Load(); err != nil && w.ErrorHandler != nil {
+			w.ErrorHandler(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Load reads the file, rebuilding the propagation stack if it has changed.
+func (w *Watcher) Load() error {
+	raw, err := os.ReadFile(w.Path)
+	if err != nil {
+		return fmt.Errorf("cannot read config: %v", err)
+	}
+	if l, ok := w.loaded.Load().(*loaded); ok && bytes.Equal(raw, l.raw) {
+		return nil
+	}
+
+	c := linkin.Config{}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return fmt.Errorf("cannot decode config %s: %v", w.Path, err)
+	}
+	s, err := c.Build()
+	if err != nil {
+		return err
+	}
+	w.loaded.Store(&loaded{raw: raw, stack: s})
+	return nil
+}
+
+// Stack returns the most recently built propagation stack.
+func (w *Watcher) Stack() *linkin.Stack {
+	if l, ok := w.loaded.Load().(*loaded); ok {
+		return l.stack
+	}
+	return defaultStack
+}
+
+// defaultStack is built from the zero linkin.Config, which cannot fail.
+var defaultStack, _ = linkin.Config{}.Build()
+
+// Handler wraps the supplied handler per the most recently built stack. See
+// linkin.Stack.Handler.
+func (w *Watcher) Handler(h http.Handler) http.Handler {
+	return &handler{w: w, h: h}
+}
+
+// Transport wraps the supplied transport per the most recently built stack.
+// See linkin.Stack.Transport.
+func (w *Watcher) Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{w: w, base: base}
+}
+
+type handler struct {
+	w *Watcher
+	h http.Handler
+
+	// built holds a *builtHandler wrapping h per the most recently used stack.
+	built atomic.Value
+}
+
+type builtHandler struct {
+	stack *linkin.Stack
+	h     http.Handler
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.w.Stack()
+	b, ok := h.built.Load().(*builtHandler)
+	if !ok || b.stack != s {
+		b = &builtHandler{stack: s, h: s.Handler(h.h)}
+		h.built.Store(b)
+	}
+	b.h.ServeHTTP(w, r)
+}
+
+type transport struct {
+	w    *Watcher
+	base http.RoundTripper
+
+	// built holds a *builtTransport wrapping base per the most recently used
+	// stack.
+	built atomic.Value
+}
+
+type builtTransport struct {
+	stack *linkin.Stack
+	rt    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	s := t.w.Stack()
+	b, ok := t.built.Load().(*builtTransport)
+	if !ok || b.stack != s {
+		b = &builtTransport{stack: s, rt: s.Transport(t.base)}
+		t.built.Store(b)
+	}
+	return b.rt.RoundTrip(r)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package configwatch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name       string
+		config     string
+		wantErr    bool
+		wantPrefix string
+	}{
+		{
+			name:       "Valid",
+			config:     `{"headerPrefix": "acme-ctx-"}`,
+			wantPrefix: "Acme-Ctx-Trace",
+		},
+		{
+			name:       "Undecodable",
+			config:     `nope`,
+			wantErr:    true,
+			wantPrefix: "L5d-Ctx-Trace",
+		},
+		{
+			name:       "Unbuildable",
+			config:     `{"formats": ["jaeger"]}`,
+			wantErr:    true,
+			wantPrefix: "L5d-Ctx-Trace",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tc.config), 0o600); err != nil {
+				t.Fatalf("os.WriteFile(): %v", err)
+			}
+			w := &Watcher{Path: path}
+			if err := w.Load(); (err != nil) != tc.wantErr {
+				t.Fatalf("w.Load(): want error %t, got %v", tc.wantErr, err)
+			}
+			if got := inject(t, w); got[tc.wantPrefix] == nil {
+				t.Errorf("w.Transport(): want header %s, got %v", tc.wantPrefix, got)
+			}
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	w := &Watcher{Path: filepath.Join(t.TempDir(), "missing.json")}
+	if err := w.Load(); err == nil {
+		t.Errorf("w.Load(): want error, got nil")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	w := &Watcher{Path: path}
+
+	for _, step := range []struct {
+		config string
+		want   string
+	}{
+		{config: `{"headerPrefix": "acme-ctx-"}`, want: "Acme-Ctx-Trace"},
+		{config: `{"headerPrefix": "acme-ctx-"}`, want: "Acme-Ctx-Trace"},
+		{config: `{}`, want: "L5d-Ctx-Trace"},
+		{config: `nope`, want: "L5d-Ctx-Trace"},
+	} {
+		if err := os.WriteFile(path, []byte(step.config), 0o600); err != nil {
+			t.Fatalf("os.WriteFile(): %v", err)
+		}
+		w.Load()
+		if got := inject(t, w); got[step.want] == nil {
+			t.Errorf("w.Transport() with config %s: want header %s, got %v", step.config, step.want, got)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"headerPrefix": "acme-ctx-"}`), 0o600); err != nil {
+		t.Fatalf("os.WriteFile(): %v", err)
+	}
+	w := &Watcher{Path: path}
+	if err := w.Load(); err != nil {
+		t.Fatalf("w.Load(): %v", err)
+	}
+
+	var got trace.SpanContext
+	h := w.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = trace.FromContext(r.Context()).SpanContext()
+	}))
+	want := trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231}
+	r := httptest.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set("Acme-Ctx-Trace", "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got.TraceID != want {
+		t.Errorf("w.Handler(): want trace ID %v, got %v", want, got.TraceID)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var errs int
+	w := &Watcher{Path: filepath.Join(t.TempDir(), "missing.json"), ErrorHandler: func(error) { errs++ }}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.Run(ctx); err != context.Canceled {
+		t.Errorf("w.Run(): want %v, got %v", context.Canceled, err)
+	}
+	if errs != 1 {
+		t.Errorf("w.Run(): want 1 error handled, got %d", errs)
+	}
+}
+
+// inject returns the headers sent by the watcher's transport for a request made
+// in the context of a sampled span.
+func inject(t *testing.T, w *Watcher) http.Header {
+	t.Helper()
+	var got http.Header
+	rt := w.Transport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	}))
+	ctx, span := trace.StartSpan(context.Background(), "test")
+	defer span.End()
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	if _, err := rt.RoundTrip(r.WithContext(ctx)); err != nil {
+		t.Fatalf("rt.RoundTrip(): %v", err)
+	}
+	return got
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
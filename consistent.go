@@ -0,0 +1,184 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+// otTracestateKey is the key of the OpenTelemetry entry of a W3C tracestate.
+// Its value carries the p-value and r-value of consistent probability sampling,
+// e.g. ot=p:2;r:5.
+const otTracestateKey = "ot"
+
+// consistentNever is the p-value representing a sampling probability of zero.
+const consistentNever = 63
+
+// otState is the decoded OpenTelemetry entry of a W3C tracestate. Absent
+// values are negative. Subkeys other than p and r are preserved.
+type otState struct {
+	p, r  int
+	other []string
+}
+
+func otStateFrom(ts *tracestate.Tracestate) otState {
+	s := otState{p: -1, r: -1}
+	for _, e := range ts.Entries() {
+		if e.Key != otTracestateKey {
+			continue
+		}
+		for _, kv := range strings.Split(e.Value, ";") {
+			k, v := kv, ""
+			if i := strings.Index(kv, ":"); i >= 0 {
+				k, v = kv[:i], kv[i+1:]
+			}
+			switch k {
+			case "p":
+				if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= consistentNever {
+					s.p = n
+				}
+			case "r":
+				if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 62 {
+					s.r = n
+				}
+			default:
+				if kv != "" {
+					s.other = append(s.other, kv)
+				}
+			}
+		}
+	}
+	return s
+}
+
+func (s otState) String() string {
+	var values []string
+	if s.p >= 0 {
+		values = append(values, "p:"+strconv.Itoa(s.p))
+	}
+	if s.r >= 0 {
+		values = append(values, "r:"+strconv.Itoa(s.r))
+	}
+	return strings.Join(append(values, s.other...), ";")
+}
+
+// with returns the supplied tracestate with its OpenTelemetry entry replaced by
+// this one. The supplied tracestate is returned unchanged if it cannot be.
+func (s otState) with(ts *tracestate.Tracestate) *tracestate.Tracestate {
+	entries := []tracestate.Entry{{Key: otTracestateKey, Value: s.String()}}
+	for _, e := range ts.Entries() {
+		if e.Key != otTracestateKey {
+			entries = append(entries, e)
+		}
+	}
+	out, err := tracestate.New(nil, entries...)
+	if err != nil {
+		return ts
+	}
+	return out
+}
+
+// consistentP returns the p-value of the supplied sampling fraction, i.e. the
+// p for which 2^-p is the greatest power of two no greater than the fraction.
+func consistentP(fraction float64) int {
+	if fraction >= 1 {
+		return 0
+	}
+	if !(fraction > 0) {
+		return consistentNever
+	}
+	p := int(math.Ceil(-math.Log2(fraction)))
+	if p > 62 {
+		return consistentNever
+	}
+	return p
+}
+
+// consistentR derives an r-value from the supplied trace ID, i.e. the number of
+// leading zeros of the low 62 bits of its low 64 bits. Deriving r-values from
+// trace IDs, rather than at random, allows span contexts that carry no r-value
+// (e.g. those propagated via linkerd) to be sampled consistently too.
+func consistentR(id trace.TraceID) int {
+	return bits.LeadingZeros64(binary.BigEndian.Uint64(id[8:16])&(1<<62-1)) - 2
+}
+
+// ConsistentSampler returns a trace.Sampler that implements OpenTelemetry's
+// consistent probability sampling, so that sampling decisions interoperate with
+// OpenTelemetry SDKs during migration. The supplied fraction is rounded down to
+// the nearest power of two, 2^-p. A span is sampled if p is no greater than the
+// r-value of its trace: that of the OpenTelemetry entry of its parent's W3C
+// tracestate, or one derived from its trace ID. Spans with a debug parent are
+// always sampled. ConsistentSampler is intended for use with ConsistentFormat.
+func ConsistentSampler(fraction float64) trace.Sampler {
+	p := consistentP(fraction)
+	return func(sp trace.SamplingParameters) trace.SamplingDecision {
+		if IsDebug(sp.ParentContext) {
+			return trace.SamplingDecision{Sample: true}
+		}
+		r := otStateFrom(sp.ParentContext.Tracestate).r
+		if r < 0 {
+			r = consistentR(sp.TraceID)
+		}
+		return trace.SamplingDecision{Sample: p <= r}
+	}
+}
+
+// ConsistentFormat is a W3C trace context propagation format that propagates
+// the p-value and r-value of OpenTelemetry's consistent probability sampling in
+// the OpenTelemetry entry of the tracestate header. The r-value of a trace is
+// propagated unchanged if it was extracted, or derived from its trace ID. The
+// p-value of a sampled span is that of the Fraction, if the span would have
+// been sampled at the Fraction, or that extracted with it otherwise. Unsampled
+// spans propagate no p-value.
+type ConsistentFormat struct {
+	// Fraction is the sampling fraction of the ConsistentSampler with which
+	// spans are started.
+	Fraction float64
+
+	w3c TraceContextFormat
+}
+
+// SpanContextFromRequest extracts a span context, including its consistent
+// probability sampling values, from the supplied request.
+func (f *ConsistentFormat) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	return f.w3c.SpanContextFromRequest(r)
+}
+
+// SpanContextToRequest injects the supplied span context, including its
+// consistent probability sampling values, into the supplied request.
+func (f *ConsistentFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	s := otStateFrom(sc.Tracestate)
+	if s.r < 0 {
+		s.r = consistentR(sc.TraceID)
+	}
+	switch p := consistentP(f.Fraction); {
+	case !sc.IsSampled():
+		s.p = -1
+	case p <= s.r:
+		s.p = p
+	}
+	sc.Tracestate = s.with(sc.Tracestate)
+	f.w3c.SpanContextToRequest(sc, r)
+}
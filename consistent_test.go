@@ -0,0 +1,203 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"go.opencensus.io/trace/tracestate"
+)
+
+func TestConsistentFormatSatisfiesHTTPFormat(t *testing.T) {
+	var _ propagation.HTTPFormat = (*ConsistentFormat)(nil)
+}
+
+func TestConsistentP(t *testing.T) {
+	cases := map[float64]int{
+		1:      0,
+		2:      0,
+		0.5:    1,
+		0.3:    2,
+		0.25:   2,
+		1e-4:   14,
+		0:      consistentNever,
+		-1:     consistentNever,
+		1e-300: consistentNever,
+	}
+	for fraction, want := range cases {
+		if got := consistentP(fraction); got != want {
+			t.Errorf("consistentP(%v): want %d, got %d", fraction, want, got)
+		}
+	}
+}
+
+func TestConsistentR(t *testing.T) {
+	cases := []struct {
+		name string
+		id   trace.TraceID
+		want int
+	}{
+		{name: "NoLeadingZeros", id: trace.TraceID{8: 0x20}, want: 0},
+		{name: "IgnoresTopTwoBits", id: trace.TraceID{8: 0xd0}, want: 1},
+		{name: "FiveLeadingZeros", id: trace.TraceID{8: 0x01}, want: 5},
+		{name: "AllZeros", id: trace.TraceID{0: 0xff}, want: 62},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := consistentR(tc.id); got != tc.want {
+				t.Errorf("consistentR(): want %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOTState(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  otState
+		str   string
+	}{
+		{name: "PAndR", value: "p:2;r:5", want: otState{p: 2, r: 5}, str: "p:2;r:5"},
+		{name: "ROnly", value: "r:10", want: otState{p: -1, r: 10}, str: "r:10"},
+		{name: "OtherSubkeys", value: "r:10;th:8;p:1", want: otState{p: 1, r: 10, other: []string{"th:8"}}, str: "p:1;r:10;th:8"},
+		{name: "InvalidValues", value: "p:64;r:x", want: otState{p: -1, r: -1}, str: ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, err := tracestate.New(nil, tracestate.Entry{Key: otTracestateKey, Value: tc.value})
+			if err != nil {
+				t.Fatalf("tracestate.New(): %v", err)
+			}
+			got := otStateFrom(ts)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("otStateFrom(): want %+v, got %+v", tc.want, got)
+			}
+			if got.String() != tc.str {
+				t.Errorf("s.String(): want %q, got %q", tc.str, got.String())
+			}
+		})
+	}
+}
+
+func TestConsistentSampler(t *testing.T) {
+	// consistentR of these trace IDs is 0 and 5 respectively.
+	r0 := trace.TraceID{8: 0x20}
+	r5 := trace.TraceID{8: 0x01}
+	withR := func(r string) *tracestate.Tracestate {
+		ts, _ := tracestate.New(nil, tracestate.Entry{Key: "vendor", Value: "x"}, tracestate.Entry{Key: otTracestateKey, Value: "r:" + r})
+		return ts
+	}
+
+	cases := []struct {
+		name     string
+		fraction float64
+		id       trace.TraceID
+		parent   trace.SpanContext
+		want     bool
+	}{
+		{name: "DerivedRAtOrAboveP", fraction: 0.25, id: r5, want: true},
+		{name: "DerivedRBelowP", fraction: 0.25, id: r0},
+		{name: "PropagatedRAtOrAboveP", fraction: 0.25, id: r0, parent: trace.SpanContext{TraceID: r0, Tracestate: withR("2")}, want: true},
+		{name: "PropagatedRBelowP", fraction: 0.25, id: r5, parent: trace.SpanContext{TraceID: r5, Tracestate: withR("1"), TraceOptions: ocShouldSample}},
+		{name: "DebugParent", fraction: 0, id: r0, parent: trace.SpanContext{TraceID: r0, TraceOptions: ocShouldSample | ocDebug}, want: true},
+		{name: "AlwaysSample", fraction: 1, id: r0, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ConsistentSampler(tc.fraction)(trace.SamplingParameters{ParentContext: tc.parent, TraceID: tc.id})
+			if got.Sample != tc.want {
+				t.Errorf("ConsistentSampler(%v): want sample %t, got %t", tc.fraction, tc.want, got.Sample)
+			}
+		})
+	}
+}
+
+func TestConsistentFormat(t *testing.T) {
+	r5 := trace.TraceID{8: 0x01}
+	withOT := func(v string) *tracestate.Tracestate {
+		ts, _ := tracestate.New(nil, tracestate.Entry{Key: "vendor", Value: "x"}, tracestate.Entry{Key: otTracestateKey, Value: v})
+		return ts
+	}
+
+	cases := []struct {
+		name     string
+		fraction float64
+		sc       trace.SpanContext
+		want     string
+	}{
+		{
+			name:     "SampledRoot",
+			fraction: 0.25,
+			sc:       trace.SpanContext{TraceID: r5, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample},
+			want:     "ot=p:2;r:5",
+		},
+		{
+			name:     "UnsampledRoot",
+			fraction: 0.01,
+			sc:       trace.SpanContext{TraceID: r5, SpanID: trace.SpanID{1}},
+			want:     "ot=r:5",
+		},
+		{
+			name:     "SampledAtFraction",
+			fraction: 0.5,
+			sc:       trace.SpanContext{TraceID: r5, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample, Tracestate: withOT("p:3;r:3")},
+			want:     "ot=p:1;r:3,vendor=x",
+		},
+		{
+			name:     "SampledUpstream",
+			fraction: 0.01,
+			sc:       trace.SpanContext{TraceID: r5, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample, Tracestate: withOT("p:3;r:3")},
+			want:     "ot=p:3;r:3,vendor=x",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &ConsistentFormat{Fraction: tc.fraction}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(tc.sc, r)
+			if got := r.Header.Get("tracestate"); got != tc.want {
+				t.Errorf("f.SpanContextToRequest(): want tracestate %q, got %q", tc.want, got)
+			}
+
+			sc, ok := f.SpanContextFromRequest(r)
+			if !ok {
+				t.Fatalf("f.SpanContextFromRequest(): want span context")
+			}
+			if got, want := otStateFrom(sc.Tracestate), otStateFrom(tracestateOf(t, tc.want)); !reflect.DeepEqual(got, want) {
+				t.Errorf("f.SpanContextFromRequest(): want %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func tracestateOf(t *testing.T, h string) *tracestate.Tracestate {
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set("traceparent", "00-00000000000000000100000000000000-0100000000000000-01")
+	r.Header.Set("tracestate", h)
+	sc, ok := (&ConsistentFormat{}).SpanContextFromRequest(r)
+	if !ok {
+		t.Fatalf("cannot parse tracestate %q", h)
+	}
+	return sc.Tracestate
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package consoleexporter provides an OpenCensus trace exporter that prints a
+// nested, colorized view of each trace as its local root span finishes, so that
+// developers running a service locally may see the structure of its traces
+// without running a tracing backend.
+package consoleexporter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"go.opencensus.io/trace"
+)
+
+// ANSI escape sequences used to colorize output.
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	dim    = "\x1b[2m"
+	red    = "\x1b[31m"
+	yellow = "\x1b[33m"
+	cyan   = "\x1b[36m"
+)
+
+// maxPending is the number of traces for which an Exporter buffers spans while
+// waiting for their local root span to finish. All buffered spans are printed
+// when it is exceeded, so spans whose root never finishes are not held forever.
+const maxPending = 1000
+
+// Exporter is a trace.Exporter that prints traces to a terminal. Spans are
+// buffered until the local root span of their trace, i.e. a span with a remote
+// parent or no parent, finishes. The trace is then printed as a tree, with each
+// span annotated by its start offset from the root span and its duration.
+// Exporter must not be copied after first use.
+type Exporter struct {
+	// Writer is the writer to which traces are printed. os.Stderr is used if
+	// Writer is nil.
+	Writer io.Writer
+
+	// NoColor disables colorized output, e.g. when Writer is not a terminal.
+	NoColor bool
+
+	mu      sync.Mutex
+	pending map[trace.TraceID][]*trace.SpanData
+}
+
+// ExportSpan buffers the supplied span, printing its trace if it is a local
+// root span.
+func (e *Exporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.pending == nil {
+		e.pending = make(map[trace.TraceID][]*trace.SpanData)
+	}
+	e.pending[s.TraceID] = append(e.pending[s.TraceID], s)
+
+	if s.HasRemoteParent || s.ParentSpanID == (trace.SpanID{}) {
+		e.print(s.TraceID)
+		return
+	}
+	if len(e.pending) > maxPending {
+		for id := range e.pending {
+			e.print(id)
+		}
+	}
+}
+
+// Flush prints all buffered spans, even those whose local root span has not
+// finished. It is typically called before a program exits.
+func (e *Exporter) Flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id := range e.pending {
+		e.print(id)
+	}
+}
+
+func (e *Exporter) writer() io.Writer {
+	if e.Writer == nil {
+		return os.Stderr
+	}
+	return e.Writer
+}
+
+func (e *Exporter) color(c, s string) string {
+	if e.NoColor {
+		return s
+	}
+	return c + s + reset
+}
+
+// print prints and forgets the buffered spans of the supplied trace. Spans
+// whose parent is not buffered are printed as roots.
+func (e *Exporter) print(id trace.TraceID) {
+	spans := e.pending[id]
+	delete(e.pending, id)
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].StartTime.Before(spans[j].StartTime) })
+	buffered := make(map[trace.SpanID]bool, len(spans))
+	for _, s := range spans {
+		buffered[s.SpanID] = true
+	}
+	children := make(map[trace.SpanID][]*trace.SpanData)
+	var roots []*trace.SpanData
+	for _, s := range spans {
+		if buffered[s.ParentSpanID] && s.ParentSpanID != s.SpanID {
+			children[s.ParentSpanID] = append(children[s.ParentSpanID], s)
+			continue
+		}
+		roots = append(roots, s)
+	}
+
+	w := e.writer()
+	fmt.Fprintf(w, "%s\n", e.color(bold, fmt.Sprintf("trace %s (%d spans)", id, len(spans))))
+	for i, s := range roots {
+		e.printSpan(w, children, roots[0], s, "", i == len(roots)-1)
+	}
+}
+
+func (e *Exporter) printSpan(w io.Writer, children map[trace.SpanID][]*trace.SpanData, root, s *trace.SpanData, indent string, last bool) {
+	branch, next := "|-- ", "|   "
+	if last {
+		branch, next = "`-- ", "    "
+	}
+	name := e.color(cyan, s.Name)
+	if s.Code != trace.StatusCodeOK {
+		name = e.color(red, s.Name) + " " + e.color(red, fmt.Sprintf("(%d %s)", s.Code, s.Message))
+	}
+	timing := e.color(dim, fmt.Sprintf("[+%s %s]", s.StartTime.Sub(root.StartTime), s.EndTime.Sub(s.StartTime)))
+	fmt.Fprintf(w, "%s%s%s%s %s\n", indent, branch, e.kind(s.SpanKind), name, timing)
+	for i, c := range children[s.SpanID] {
+		e.printSpan(w, children, root, c, indent+next, i == len(children[s.SpanID])-1)
+	}
+}
+
+func (e *Exporter) kind(k int) string {
+	switch k {
+	case trace.SpanKindServer:
+		return e.color(yellow, "server") + " "
+	case trace.SpanKindClient:
+		return e.color(yellow, "client") + " "
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package consoleexporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestExporterSatisfiesExporter(t *testing.T) {
+	var _ trace.Exporter = (*Exporter)(nil)
+}
+
+func span(id, parent byte, name string, kind int, start, duration time.Duration) *trace.SpanData {
+	epoch := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := &trace.SpanData{
+		SpanContext: trace.SpanContext{TraceID: trace.TraceID{15: 1}, SpanID: trace.SpanID{7: id}},
+		SpanKind:    kind,
+		Name:        name,
+		StartTime:   epoch.Add(start),
+		EndTime:     epoch.Add(start + duration),
+	}
+	if parent != 0 {
+		s.ParentSpanID = trace.SpanID{7: parent}
+	}
+	return s
+}
+
+func TestExportSpan(t *testing.T) {
+	failed := span(4, 1, "cache", 0, 40*time.Millisecond, 5*time.Millisecond)
+	failed.Status = trace.Status{Code: trace.StatusCodeUnavailable, Message: "Unavailable"}
+	remote := span(1, 9, "/users", trace.SpanKindServer, 0, 100*time.Millisecond)
+	remote.HasRemoteParent = true
+
+	cases := []struct {
+		name  string
+		spans []*trace.SpanData
+		want  string
+	}{
+		{
+			name: "Nested",
+			spans: []*trace.SpanData{
+				span(3, 2, "SELECT", 0, 15*time.Millisecond, 10*time.Millisecond),
+				span(2, 1, "GET /db", trace.SpanKindClient, 10*time.Millisecond, 20*time.Millisecond),
+				failed,
+				span(1, 0, "/users", trace.SpanKindServer, 0, 100*time.Millisecond),
+			},
+			want: "trace 00000000000000000000000000000001 (4 spans)\n" +
+				"`-- server /users [+0s 100ms]\n" +
+				"    |-- client GET /db [+10ms 20ms]\n" +
+				"    |   `-- SELECT [+15ms 10ms]\n" +
+				"    `-- cache (14 Unavailable) [+40ms 5ms]\n",
+		},
+		{
+			name:  "RemoteParent",
+			spans: []*trace.SpanData{remote},
+			want: "trace 00000000000000000000000000000001 (1 spans)\n" +
+				"`-- server /users [+0s 100ms]\n",
+		},
+		{
+			name:  "RootNotFinished",
+			spans: []*trace.SpanData{span(2, 1, "GET /db", trace.SpanKindClient, 10*time.Millisecond, 20*time.Millisecond)},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			e := &Exporter{Writer: b, NoColor: true}
+			for _, s := range tc.spans {
+				e.ExportSpan(s)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("e.ExportSpan(): want\n%s\ngot\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFlush(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := &Exporter{Writer: b, NoColor: true}
+	e.ExportSpan(span(2, 1, "GET /db", trace.SpanKindClient, 10*time.Millisecond, 20*time.Millisecond))
+	e.Flush()
+
+	want := "trace 00000000000000000000000000000001 (1 spans)\n" +
+		"`-- client GET /db [+0s 20ms]\n"
+	if got := b.String(); got != want {
+		t.Errorf("e.Flush(): want\n%s\ngot\n%s", want, got)
+	}
+
+	b.Reset()
+	e.Flush()
+	if b.Len() != 0 {
+		t.Errorf("e.Flush(): want flushed spans to be forgotten, got\n%s", b.String())
+	}
+}
+
+func TestColor(t *testing.T) {
+	b := &bytes.Buffer{}
+	e := &Exporter{Writer: b}
+	e.ExportSpan(span(1, 0, "/users", trace.SpanKindServer, 0, 100*time.Millisecond))
+
+	if want := cyan + "/users" + reset; !strings.Contains(b.String(), want) {
+		t.Errorf("e.ExportSpan(): want colorized span name %q, got %q", want, b.String())
+	}
+}
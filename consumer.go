@@ -0,0 +1,125 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A Receiver receives messages from a queue. Each message is a Carrier that may
+// carry the span context of its producer.
+type Receiver interface {
+	Receive(ctx context.Context) (Carrier, error)
+}
+
+// A ReceiverFunc is a function that satisfies Receiver.
+type ReceiverFunc func(ctx context.Context) (Carrier, error)
+
+// Receive calls fn(ctx).
+func (fn ReceiverFunc) Receive(ctx context.Context) (Carrier, error) {
+	return fn(ctx)
+}
+
+// A MessageHandler handles a message received from a queue.
+type MessageHandler func(ctx context.Context, m Carrier) error
+
+// DefaultConsumerSpanName is the name of the span started for each consumed
+// message when no name is configured.
+const DefaultConsumerSpanName = "consume"
+
+// Consumer is a queue consumer loop that starts a span for each message it
+// handles. Each message's span is a child of the span context extracted from
+// the message, if any, or a new root span otherwise. Message spans are never
+// children of any span in the consumer loop's context.
+type Consumer struct {
+	// Propagation defines how traces are propagated. &HTTPFormat{} is used if
+	// Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each message.
+	StartOptions trace.StartOptions
+
+	// Name is the name of the span started for each message. The
+	// DefaultConsumerSpanName is used if Name is empty.
+	Name string
+
+	// Timeout is the deadline for handling each message. Messages are handled
+	// without a deadline if Timeout is zero.
+	Timeout time.Duration
+
+	// ErrorHandler is called with any error returned by the MessageHandler.
+	// Errors are ignored if ErrorHandler is nil.
+	ErrorHandler func(error)
+}
+
+// Run receives and handles messages until the supplied context is done or the
+// receiver returns an error, which is returned.
+func (c *Consumer) Run(ctx context.Context, r Receiver, h MessageHandler) error {
+	for {
+		m, err := r.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if err := c.Handle(ctx, m, h); err != nil && c.ErrorHandler != nil {
+			c.ErrorHandler(err)
+		}
+	}
+}
+
+// Handle handles a single message, starting a span that ends when the handler
+// returns. The span is detached from any span in the supplied context, but the
+// handler is cancelled when the supplied context is.
+func (c *Consumer) Handle(ctx context.Context, m Carrier, h MessageHandler) error {
+	name := c.Name
+	if name == "" {
+		name = DefaultConsumerSpanName
+	}
+
+	// Detach from the consumer loop's span, if any.
+	ctx = trace.NewContext(ctx, nil)
+
+	var span *trace.Span
+	if sc, ok := SpanContextFromCarrier(ctx, c.Propagation, m); ok {
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, name, sc,
+			trace.WithSampler(c.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	} else {
+		ctx, span = trace.StartSpan(ctx, name,
+			trace.WithSampler(c.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	}
+	defer span.End()
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	err := h(ctx, m)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeDeadlineExceeded, Message: ctx.Err().Error()})
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestConsumer(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	// The producer's span.
+	_, producer := trace.StartSpan(context.Background(), "produce", trace.WithSampler(trace.AlwaysSample()))
+	producer.End()
+	propagated := MapCarrier{}
+	SpanContextToCarrier(context.Background(), nil, producer.SpanContext(), propagated)
+
+	messages := []Carrier{propagated, MapCarrier{}, MapCarrier{"fail": "true"}, MapCarrier{"slow": "true"}}
+
+	// The consumer loop's span, which message spans must not be children of.
+	ctx, loop := trace.StartSpan(context.Background(), "loop", trace.WithSampler(trace.AlwaysSample()))
+	defer loop.End()
+
+	errs := []error{}
+	c := &Consumer{
+		Name:         "consume",
+		StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+		Timeout:      10 * time.Millisecond,
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	}
+	r := ReceiverFunc(func(ctx context.Context) (Carrier, error) {
+		if len(messages) == 0 {
+			return nil, io.EOF
+		}
+		m := messages[0]
+		messages = messages[1:]
+		return m, nil
+	})
+	h := func(ctx context.Context, m Carrier) error {
+		if m.Get("fail") != "" {
+			return errors.New("boom")
+		}
+		if m.Get("slow") != "" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	if err := c.Run(ctx, r, h); err != io.EOF {
+		t.Errorf("c.Run(): want %v, got %v", io.EOF, err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("c.Run(): want 2 handler errors, got %v", errs)
+	}
+
+	spans := e.Spans()
+	if len(spans) != 5 {
+		t.Fatalf("c.Run(): want 5 exported spans, got %d", len(spans))
+	}
+	// spans[0] is the producer's span.
+	if spans[1].ParentSpanID != producer.SpanContext().SpanID {
+		t.Errorf("c.Run(): want child of producer %v, got parent %v", producer.SpanContext().SpanID, spans[1].ParentSpanID)
+	}
+	for i, s := range spans[2:] {
+		if s.ParentSpanID != (trace.SpanID{}) {
+			t.Errorf("c.Run(): want root span for message %d, got parent %v", i+1, s.ParentSpanID)
+		}
+	}
+	if got := spans[4].Status.Code; got != trace.StatusCodeDeadlineExceeded {
+		t.Errorf("c.Run(): want status code %d for slow message, got %d", trace.StatusCodeDeadlineExceeded, got)
+	}
+}
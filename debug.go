@@ -0,0 +1,130 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/planetlabs/linkin/wire"
+)
+
+// DebugHandler is an http.Handler that forces individual requests to be traced
+// by setting Finagle's debug flag on their l5d-ctx-trace header, making it
+// feasible to trace an exact call in production. The debug flag causes the
+// request to be sampled at every hop. A request may ask to be traced using a
+// header or query parameter, but is only traced if it is allowed to be.
+//
+// A new trace is started for allowed requests without a valid l5d-ctx-trace
+// header. The minted header has no span ID, causing the ochttp.Handler to start
+// a root span in the minted trace. DebugHandler must wrap an ochttp.Handler
+// that uses HTTPFormat, and whose sampler (if any) samples spans with sampled
+// parents, as the default OpenCensus sampler does.
+type DebugHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Header is the name of a request header that asks for a request to be
+	// traced when its value is true, per strconv.ParseBool. No header is
+	// honored if Header is empty.
+	Header string
+
+	// Query is the name of a URL query parameter that asks for a request to be
+	// traced when its value is true, per strconv.ParseBool. No query parameter
+	// is honored if Query is empty.
+	Query string
+
+	// Allow returns true if the supplied request is allowed to ask to be
+	// traced. No requests are allowed if Allow is nil.
+	Allow func(r *http.Request) bool
+
+	// IDGenerator generates the IDs of minted traces, of which only the low 64
+	// bits are used, per Finagle's convention. Random IDs are generated if
+	// IDGenerator is nil.
+	IDGenerator IDGenerator
+}
+
+// ServeHTTP forces the request to be traced if it asks to be and is allowed to
+// be, then serves it.
+func (h *DebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.asked(r) && h.Allow != nil && h.Allow(r) {
+		r = h.debug(r)
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+func (h *DebugHandler) asked(r *http.Request) bool {
+	if h.Header != "" {
+		if v, err := strconv.ParseBool(r.Header.Get(h.Header)); err == nil && v {
+			return true
+		}
+	}
+	if h.Query != "" {
+		if v, err := strconv.ParseBool(r.URL.Query().Get(h.Query)); err == nil && v {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *DebugHandler) debug(r *http.Request) *http.Request {
+	id, ok := TraceIDFromRequest(r)
+	if !ok {
+		g := h.IDGenerator
+		if g == nil {
+			g = defaultIDs
+		}
+		id = TraceID{Trace: newRootTraceID(g)}
+	}
+	id.Flags |= wire.FlagSamplingKnown | wire.FlagSampled | wire.FlagDebug
+
+	out := withHeaderCopy(r)
+	out.Header.Set(l5dHeaderTrace, id.String())
+	return out
+}
+
+// AllowNetworks returns a function suitable for use as DebugHandler's Allow
+// function that allows requests whose remote address is within any of the
+// supplied networks, specified in CIDR notation.
+func AllowNetworks(cidrs ...string) (func(r *http.Request) bool, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse network %q: %v", c, err)
+		}
+		nets = append(nets, n)
+	}
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestDebugHandler(t *testing.T) {
+	allow, err := AllowNetworks("10.0.0.0/8", "2001:db8::/32")
+	if err != nil {
+		t.Fatalf("AllowNetworks(): %v", err)
+	}
+
+	// An unsampled l5d-ctx-trace header.
+	unsampled := "laEAbScFR/gDfE/j8FV/8P8jOugI0dtmAAAAAAAAAAA="
+
+	cases := []struct {
+		name   string
+		target string
+		remote string
+		header map[string]string
+		want   bool
+	}{
+		{
+			name:   "NotAsked",
+			remote: "10.0.0.1:1234",
+			want:   false,
+		},
+		{
+			name:   "AskedByHeader",
+			remote: "10.0.0.1:1234",
+			header: map[string]string{"X-Debug": "true"},
+			want:   true,
+		},
+		{
+			name:   "AskedByQuery",
+			target: "/?debug=1",
+			remote: "[2001:db8::1]:1234",
+			want:   true,
+		},
+		{
+			name:   "AskedWithUnsampledTrace",
+			remote: "10.0.0.1:1234",
+			header: map[string]string{"X-Debug": "true", l5dHeaderTrace: unsampled},
+			want:   true,
+		},
+		{
+			name:   "AskedButNotAllowed",
+			remote: "192.0.2.1:1234",
+			header: map[string]string{"X-Debug": "true"},
+			want:   false,
+		},
+		{
+			name:   "AskedByUnsupportedQuery",
+			target: "/?debug=please",
+			remote: "10.0.0.1:1234",
+			want:   false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			var sc trace.SpanContext
+			h := &DebugHandler{
+				Header:      "X-Debug",
+				Query:       "debug",
+				Allow:       allow,
+				IDGenerator: wideIDs{},
+				Handler: &ochttp.Handler{
+					Propagation:  &HTTPFormat{},
+					StartOptions: trace.StartOptions{Sampler: probabilitySampler(0)},
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						sc = trace.FromContext(r.Context()).SpanContext()
+					}),
+				},
+			}
+			target := tc.target
+			if target == "" {
+				target = "/"
+			}
+			r := httptest.NewRequest("GET", target, nil)
+			r.RemoteAddr = tc.remote
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got := IsDebug(sc) && sc.IsSampled(); got != tc.want {
+				t.Errorf("h.ServeHTTP(): want debug span %t, got %t", tc.want, got)
+			}
+			spans := e.Spans()
+			if got := len(spans) == 1; got != tc.want {
+				t.Fatalf("h.ServeHTTP(): want exported span %t, got %t", tc.want, got)
+			}
+			if tc.want && tc.header[l5dHeaderTrace] == "" && spans[0].ParentSpanID != (trace.SpanID{}) {
+				t.Errorf("h.ServeHTTP(): want root span, got parent %v", spans[0].ParentSpanID)
+			}
+			if want := (trace.TraceID{15: 1}); tc.want && tc.header[l5dHeaderTrace] == "" && spans[0].TraceID != want {
+				t.Errorf("h.ServeHTTP(): want minted trace ID %v, got %v", want, spans[0].TraceID)
+			}
+		})
+	}
+}
+
+func TestAllowNetworksInvalid(t *testing.T) {
+	if _, err := AllowNetworks("10.0.0.0/33"); err == nil {
+		t.Errorf("AllowNetworks(): want error, got nil")
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package diagnostics mounts a single local diagnostics surface for a traced
+// service: OpenCensus' zpages, which list recent spans (tracez) and RPC stats
+// (rpcz), alongside a page that explains how the service propagates traces
+// (propagationz):
+//
+//  mux := http.NewServeMux()
+//  diagnostics.Handle(mux, "/debug", stack)
+//  go http.ListenAndServe("localhost:8081", mux)
+//
+// Diagnostics reveal the trace headers of requests, and should not be exposed
+// outside the host or pod.
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/zpages"
+)
+
+// Handle mounts the zpages and the propagation page of the supplied stack at
+// the supplied path prefix of the supplied mux. http.DefaultServeMux is used if
+// the mux is nil.
+func Handle(mux *http.ServeMux, prefix string, s *linkin.Stack) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+	zpages.Handle(mux, prefix)
+	mux.Handle(path.Join(prefix, "propagationz"), PropagationHandler(s))
+}
+
+// A propagation explains how a stack propagates traces.
+type propagation struct {
+	Config    linkin.Config `json:"config"`
+	Extracted *extracted    `json:"extracted,omitempty"`
+	Header    http.Header   `json:"header"`
+}
+
+// An extracted span context.
+type extracted struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+	Sampled bool   `json:"sampled"`
+}
+
+// scrubber keeps trace headers, but hashes baggage and redacts sensitive
+// headers such as Authorization.
+var scrubber = &linkin.Scrubber{Trace: linkin.MaskKeep, Baggage: linkin.MaskHash}
+
+// PropagationHandler returns an http.Handler that responds with a JSON document
+// explaining how the supplied stack propagates traces: the stack's
+// configuration, the span context it extracts from the request, if any, and the
+// request's (scrubbed) headers. Request a page with the trace headers in
+// question to debug their propagation. A stack that uses the l5d format is
+// explained if the supplied stack is nil.
+func PropagationHandler(s *linkin.Stack) http.Handler {
+	if s == nil {
+		s, _ = linkin.Config{}.Build()
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := propagation{Config: s.Config(), Header: scrubber.Header(r.Header)}
+		if sc, ok := s.Propagation.SpanContextFromRequest(r); ok {
+			p.Extracted = &extracted{TraceID: sc.TraceID.String(), SpanID: sc.SpanID.String(), Sampled: sc.IsSampled()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	})
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+)
+
+func TestHandle(t *testing.T) {
+	s, err := linkin.Config{}.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+	mux := http.NewServeMux()
+	Handle(mux, "/debug", s)
+
+	cases := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "Tracez",
+			path:       "/debug/tracez",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Rpcz",
+			path:       "/debug/rpcz",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Propagationz",
+			path:       "/debug/propagationz",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+			if w.Code != tc.wantStatus {
+				t.Errorf("mux.ServeHTTP(%s): want status %d, got %d", tc.path, tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestPropagationHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		stack     func() *linkin.Stack
+		header    map[string]string
+		wantTrace string
+	}{
+		{
+			name:      "Default",
+			stack:     func() *linkin.Stack { return nil },
+			header:    map[string]string{"l5d-ctx-trace": "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="},
+			wantTrace: "000000000000000032a4db20f5d592e7",
+		},
+		{
+			name: "B3",
+			stack: func() *linkin.Stack {
+				s, _ := linkin.Config{Formats: []string{linkin.FormatB3}}.Build()
+				return s
+			},
+			header:    map[string]string{"X-B3-TraceId": "463ac35c9f6413ad", "X-B3-SpanId": "f4141d5dc0c935d0"},
+			wantTrace: "0000000000000000463ac35c9f6413ad",
+		},
+		{
+			name:   "NoContext",
+			stack:  func() *linkin.Stack { return nil },
+			header: map[string]string{"Authorization": "Bearer secret"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/propagationz", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			PropagationHandler(tc.stack()).ServeHTTP(w, r)
+
+			got := propagation{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("json.Decode(): %v", err)
+			}
+			switch {
+			case tc.wantTrace == "" && got.Extracted != nil:
+				t.Errorf("PropagationHandler(): want no extracted span context, got %+v", got.Extracted)
+			case tc.wantTrace != "" && (got.Extracted == nil || got.Extracted.TraceID != tc.wantTrace):
+				t.Errorf("PropagationHandler(): want trace ID %s, got %+v", tc.wantTrace, got.Extracted)
+			}
+			if v := got.Header.Get("Authorization"); v != "" && v != "[REDACTED]" {
+				t.Errorf("PropagationHandler(): want redacted Authorization header, got %s", v)
+			}
+		})
+	}
+}
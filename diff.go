@@ -0,0 +1,109 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+// A Difference describes a field in which two span contexts differ.
+type Difference struct {
+	// Field is the name of the field, e.g. trace_id.
+	Field string
+
+	// A and B are the field's values in each span context.
+	A, B string
+}
+
+// String describes the difference, e.g. "sampled: true != false".
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Field, d.A, d.B)
+}
+
+// DiffSpanContexts returns the fields in which the supplied span contexts
+// differ: trace_id, span_id, sampled, debug, and tracestate. It returns no
+// differences if the span contexts are equal.
+func DiffSpanContexts(a, b trace.SpanContext) []Difference {
+	diff := []Difference{}
+	if a.TraceID != b.TraceID {
+		diff = append(diff, Difference{Field: "trace_id", A: a.TraceID.String(), B: b.TraceID.String()})
+	}
+	if a.SpanID != b.SpanID {
+		diff = append(diff, Difference{Field: "span_id", A: a.SpanID.String(), B: b.SpanID.String()})
+	}
+	if a.IsSampled() != b.IsSampled() {
+		diff = append(diff, Difference{Field: "sampled", A: strconv.FormatBool(a.IsSampled()), B: strconv.FormatBool(b.IsSampled())})
+	}
+	if IsDebug(a) != IsDebug(b) {
+		diff = append(diff, Difference{Field: "debug", A: strconv.FormatBool(IsDebug(a)), B: strconv.FormatBool(IsDebug(b))})
+	}
+	if ta, tb := tracestateString(a.Tracestate), tracestateString(b.Tracestate); ta != tb {
+		diff = append(diff, Difference{Field: "tracestate", A: ta, B: tb})
+	}
+	return diff
+}
+
+// EqualSpanContexts returns true if the supplied span contexts are equal. Unlike
+// the == operator it compares tracestates by value.
+func EqualSpanContexts(a, b trace.SpanContext) bool {
+	return len(DiffSpanContexts(a, b)) == 0
+}
+
+// DiffTraceIDs returns the fields in which the supplied TraceIDs differ:
+// trace_id, span_id, parent_span_id, and flags. It returns no differences if
+// the TraceIDs are equal.
+func DiffTraceIDs(a, b TraceID) []Difference {
+	diff := []Difference{}
+	if a.Trace != b.Trace {
+		diff = append(diff, Difference{Field: "trace_id", A: hex.EncodeToString(a.Trace[:]), B: hex.EncodeToString(b.Trace[:])})
+	}
+	if a.Span != b.Span {
+		diff = append(diff, Difference{Field: "span_id", A: hex.EncodeToString(a.Span[:]), B: hex.EncodeToString(b.Span[:])})
+	}
+	if a.Parent != b.Parent {
+		diff = append(diff, Difference{Field: "parent_span_id", A: hex.EncodeToString(a.Parent[:]), B: hex.EncodeToString(b.Parent[:])})
+	}
+	if a.Flags != b.Flags {
+		diff = append(diff, Difference{Field: "flags", A: "0b" + strconv.FormatUint(a.Flags, 2), B: "0b" + strconv.FormatUint(b.Flags, 2)})
+	}
+	return diff
+}
+
+// FormatDiff returns the supplied differences as a human-readable string, one
+// difference per line, e.g. for use in test failure messages.
+func FormatDiff(diff []Difference) string {
+	lines := make([]string, 0, len(diff))
+	for _, d := range diff {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tracestateString(ts *tracestate.Tracestate) string {
+	entries := ts.Entries()
+	kv := make([]string, 0, len(entries))
+	for _, e := range entries {
+		kv = append(kv, e.Key+"="+e.Value)
+	}
+	return strings.Join(kv, ",")
+}
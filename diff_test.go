@@ -0,0 +1,91 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+func TestDiffSpanContexts(t *testing.T) {
+	ts1, _ := tracestate.New(nil, tracestate.Entry{Key: "ot", Value: "r:5"})
+	ts2, _ := tracestate.New(nil, tracestate.Entry{Key: "ot", Value: "r:5"})
+	ts3, _ := tracestate.New(nil, tracestate.Entry{Key: "ot", Value: "r:6"})
+	sc := trace.SpanContext{TraceID: trace.TraceID{15: 1}, SpanID: trace.SpanID{7: 1}, TraceOptions: ocShouldSample, Tracestate: ts1}
+
+	cases := []struct {
+		name string
+		a, b trace.SpanContext
+		want []Difference
+	}{
+		{
+			name: "Equal",
+			a:    sc,
+			b:    trace.SpanContext{TraceID: sc.TraceID, SpanID: sc.SpanID, TraceOptions: sc.TraceOptions, Tracestate: ts2},
+			want: []Difference{},
+		},
+		{
+			name: "AllFields",
+			a:    sc,
+			b:    trace.SpanContext{TraceID: trace.TraceID{15: 2}, SpanID: trace.SpanID{7: 2}, TraceOptions: ocDebug, Tracestate: ts3},
+			want: []Difference{
+				{Field: "trace_id", A: "00000000000000000000000000000001", B: "00000000000000000000000000000002"},
+				{Field: "span_id", A: "0000000000000001", B: "0000000000000002"},
+				{Field: "sampled", A: "true", B: "false"},
+				{Field: "debug", A: "false", B: "true"},
+				{Field: "tracestate", A: "ot=r:5", B: "ot=r:6"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DiffSpanContexts(tc.a, tc.b)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("DiffSpanContexts():\nwant: %v\ngot:  %v", tc.want, got)
+			}
+			if eq := EqualSpanContexts(tc.a, tc.b); eq != (len(tc.want) == 0) {
+				t.Errorf("EqualSpanContexts(): want %t, got %t", len(tc.want) == 0, eq)
+			}
+		})
+	}
+}
+
+func TestDiffTraceIDs(t *testing.T) {
+	a := TraceID{Span: [8]byte{7: 1}, Parent: [8]byte{7: 2}, Trace: [16]byte{15: 3}, Flags: 6}
+	b := TraceID{Span: [8]byte{7: 1}, Parent: [8]byte{7: 4}, Trace: [16]byte{15: 3}, Flags: 7}
+
+	want := []Difference{
+		{Field: "parent_span_id", A: "0000000000000002", B: "0000000000000004"},
+		{Field: "flags", A: "0b110", B: "0b111"},
+	}
+	got := DiffTraceIDs(a, b)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffTraceIDs():\nwant: %v\ngot:  %v", want, got)
+	}
+	if got := DiffTraceIDs(a, a); len(got) != 0 {
+		t.Errorf("DiffTraceIDs(): want no differences, got %v", got)
+	}
+
+	wantText := "parent_span_id: 0000000000000002 != 0000000000000004\nflags: 0b110 != 0b111"
+	if text := FormatDiff(got); text != wantText {
+		t.Errorf("FormatDiff():\nwant: %s\ngot:  %s", wantText, text)
+	}
+}
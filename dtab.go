@@ -0,0 +1,240 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const l5dHeaderDtab = "l5d-dtab"
+
+// A Dentry is a single delegation table rule. It rewrites names beginning with
+// Prefix to begin with Dst instead.
+type Dentry struct {
+	Prefix string `json:"prefix"`
+	Dst    string `json:"dst"`
+}
+
+// String returns the Dentry in linkerd's textual dtab format.
+func (d Dentry) String() string {
+	return d.Prefix + "=>" + d.Dst
+}
+
+// Validate returns an error if the Dentry cannot be represented in linkerd's
+// textual dtab format, e.g. in an l5d-dtab header.
+func (d Dentry) Validate() error {
+	switch {
+	case !strings.HasPrefix(d.Prefix, "/"):
+		return fmt.Errorf("invalid dentry %q: prefix must begin with /", d)
+	case d.Dst == "":
+		return fmt.Errorf("invalid dentry %q: empty destination", d)
+	case strings.Contains(d.Prefix+d.Dst, ";"), strings.Contains(d.Prefix+d.Dst, "=>"):
+		return fmt.Errorf("invalid dentry %q: prefix and destination must not contain ; or =>", d)
+	}
+	return nil
+}
+
+// A Dtab is a delegation table, used by linkerd and namerd to route requests.
+// Later dentries take precedence over earlier dentries.
+// https://linkerd.io/1/advanced/dtabs/
+type Dtab []Dentry
+
+// String returns the Dtab in linkerd's textual dtab format.
+func (d Dtab) String() string {
+	s := make([]string, len(d))
+	for i, e := range d {
+		s[i] = e.String()
+	}
+	return strings.Join(s, ";")
+}
+
+// ParseDtab parses a Dtab in linkerd's textual dtab format, e.g.
+// "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary".
+func ParseDtab(s string) (Dtab, error) {
+	d := Dtab{}
+	for _, e := range strings.Split(s, ";") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		parts := strings.SplitN(e, "=>", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid dentry %q: missing =>", e)
+		}
+		de := Dentry{Prefix: strings.TrimSpace(parts[0]), Dst: strings.TrimSpace(parts[1])}
+		if err := de.Validate(); err != nil {
+			return nil, err
+		}
+		d = append(d, de)
+	}
+	return d, nil
+}
+
+// Validate returns an error if any of the Dtab's dentries are invalid.
+func (d Dtab) Validate() error {
+	for _, e := range d {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Append returns a new Dtab consisting of the Dtab's dentries followed by the
+// supplied dentries, which take precedence.
+func (d Dtab) Append(o Dtab) Dtab {
+	out := make(Dtab, 0, len(d)+len(o))
+	return append(append(out, d...), o...)
+}
+
+// Override returns a new Dtab consisting of the Dtab's dentries followed by
+// the supplied dentries, omitting the Dtab's dentries whose prefixes the
+// supplied dentries override. Unlike Append's result, linkerd cannot fall back
+// to an omitted dentry when a supplied dentry's destination fails to resolve.
+func (d Dtab) Override(o Dtab) Dtab {
+	overridden := make(map[string]bool, len(o))
+	for _, e := range o {
+		overridden[e.Prefix] = true
+	}
+	out := make(Dtab, 0, len(d)+len(o))
+	for _, e := range d {
+		if !overridden[e.Prefix] {
+			out = append(out, e)
+		}
+	}
+	return append(out, o...)
+}
+
+// A DtabConflict is returned by ComposeDtabs when two dtabs delegate the same
+// prefix to different destinations.
+type DtabConflict struct {
+	Prefix string
+	Dst    string
+	Other  string
+}
+
+// Error describes the conflict.
+func (c *DtabConflict) Error() string {
+	return fmt.Sprintf("conflicting dentries for %s: %s and %s", c.Prefix, c.Dst, c.Other)
+}
+
+// ComposeDtabs composes dtabs from multiple sources, e.g. a service's default
+// routing overrides and those requested by a caller, into a single Dtab that
+// may be sent in an l5d-dtab header. Dtabs are composed in precedence order, as
+// if by Append. Composition fails if any dentry is invalid, or if two of the
+// supplied dtabs delegate the same prefix to different destinations, in which
+// case a *DtabConflict is returned; one source would silently override the
+// other. Only identical prefixes conflict; dentries whose prefixes overlap,
+// e.g. /svc and /svc/web, are composed as if by Append. Dentries repeated
+// across dtabs appear once in the result, at their last occurrence.
+func ComposeDtabs(ds ...Dtab) (Dtab, error) {
+	out := Dtab{}
+	owner := map[string]int{}
+	for i, d := range ds {
+		if err := d.Validate(); err != nil {
+			return nil, err
+		}
+		for _, e := range d {
+			if j, ok := owner[e.Prefix]; ok && j != i {
+				if dst := dstOf(ds[j], e.Prefix); dst != e.Dst {
+					return nil, &DtabConflict{Prefix: e.Prefix, Dst: dst, Other: e.Dst}
+				}
+				out = without(out, e)
+			}
+			owner[e.Prefix] = i
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// without returns the supplied Dtab, modified in place to omit the supplied
+// dentry.
+func without(d Dtab, e Dentry) Dtab {
+	out := d[:0]
+	for _, o := range d {
+		if o != e {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// dstOf returns the destination to which the supplied Dtab delegates the
+// supplied prefix.
+func dstOf(d Dtab, prefix string) string {
+	dst := ""
+	for _, e := range d {
+		if e.Prefix == prefix {
+			dst = e.Dst
+		}
+	}
+	return dst
+}
+
+// DtabTransport is an http.RoundTripper that applies per-request routing
+// overrides by adding dentries to the l5d-dtab header of outgoing requests.
+// linkerd applies the l5d-dtab header to the request it is sent with, and
+// propagates it to any downstream requests via the l5d-ctx-dtab header.
+type DtabTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Dtab returns the dentries to apply to the supplied request. They take
+	// precedence over any dentries already present in its l5d-dtab header.
+	// Requests are sent unmodified if Dtab is nil or returns no dentries.
+	Dtab func(*http.Request) Dtab
+}
+
+// RoundTrip adds dentries to the supplied request's l5d-dtab header, then
+// sends the request.
+func (t *DtabTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.Dtab == nil {
+		return t.base().RoundTrip(r)
+	}
+	d := t.Dtab(r)
+	if len(d) == 0 {
+		return t.base().RoundTrip(r)
+	}
+
+	out := withHeaderCopy(r)
+	v := d.String()
+	if existing := r.Header.Get(l5dHeaderDtab); existing != "" {
+		v = existing + ";" + v
+	}
+	out.Header.Set(l5dHeaderDtab, v)
+	return t.base().RoundTrip(out)
+}
+
+func (t *DtabTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *DtabTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
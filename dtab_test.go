@@ -0,0 +1,238 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseDtab(t *testing.T) {
+	cases := []struct {
+		name    string
+		s       string
+		want    Dtab
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			s:    "/svc=>/#/io.l5d.k8s/default/http; /svc/web => /svc/web-canary ;",
+			want: Dtab{
+				{Prefix: "/svc", Dst: "/#/io.l5d.k8s/default/http"},
+				{Prefix: "/svc/web", Dst: "/svc/web-canary"},
+			},
+		},
+		{
+			name: "Empty",
+			s:    "",
+			want: Dtab{},
+		},
+		{
+			name:    "MissingArrow",
+			s:       "/svc/#/io.l5d.k8s",
+			wantErr: true,
+		},
+		{
+			name:    "RelativePrefix",
+			s:       "svc=>/#/io.l5d.k8s",
+			wantErr: true,
+		},
+		{
+			name:    "EmptyDestination",
+			s:       "/svc=>",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseDtab(tc.s)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseDtab(%q): want error %v, got %v", tc.s, tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseDtab(%q):\ngot:  %v\nwant: %v", tc.s, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDtabString(t *testing.T) {
+	d := Dtab{
+		{Prefix: "/svc", Dst: "/#/io.l5d.k8s/default/http"},
+		{Prefix: "/svc/web", Dst: "/svc/web-canary"},
+	}
+	want := "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary"
+	if got := d.String(); got != want {
+		t.Errorf("d.String(): want %q, got %q", want, got)
+	}
+}
+
+func TestDtabTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing string
+		dtab     Dtab
+		want     string
+	}{
+		{
+			name: "NoOverrides",
+			want: "",
+		},
+		{
+			name: "Overrides",
+			dtab: Dtab{{Prefix: "/svc/web", Dst: "/svc/web-canary"}},
+			want: "/svc/web=>/svc/web-canary",
+		},
+		{
+			name:     "AppendsToExisting",
+			existing: "/svc=>/#/io.l5d.k8s/default/http",
+			dtab:     Dtab{{Prefix: "/svc/web", Dst: "/svc/web-canary"}},
+			want:     "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &recordingTransport{}
+			dt := &DtabTransport{Base: rt, Dtab: func(_ *http.Request) Dtab { return tc.dtab }}
+
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			if tc.existing != "" {
+				r.Header.Set(l5dHeaderDtab, tc.existing)
+			}
+			if _, err := dt.RoundTrip(r); err != nil {
+				t.Fatalf("dt.RoundTrip(): %v", err)
+			}
+			if got := rt.r.Header.Get(l5dHeaderDtab); got != tc.want {
+				t.Errorf("dt.RoundTrip(): want l5d-dtab %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDentryValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		d       Dentry
+		wantErr bool
+	}{
+		{name: "Valid", d: Dentry{Prefix: "/svc/web", Dst: "/svc/web-canary"}},
+		{name: "RelativePrefix", d: Dentry{Prefix: "svc", Dst: "/svc/web"}, wantErr: true},
+		{name: "EmptyDestination", d: Dentry{Prefix: "/svc"}, wantErr: true},
+		{name: "Separator", d: Dentry{Prefix: "/svc", Dst: "/svc/a;/svc=>/svc/b"}, wantErr: true},
+		{name: "Arrow", d: Dentry{Prefix: "/svc=>/a", Dst: "/svc/b"}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.d.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("d.Validate(): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDtabAppendOverride(t *testing.T) {
+	d := Dtab{
+		{Prefix: "/svc", Dst: "/#/io.l5d.k8s/default/http"},
+		{Prefix: "/svc/web", Dst: "/svc/web-v1"},
+	}
+	o := Dtab{{Prefix: "/svc/web", Dst: "/svc/web-canary"}}
+
+	wantAppend := "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-v1;/svc/web=>/svc/web-canary"
+	if got := d.Append(o).String(); got != wantAppend {
+		t.Errorf("d.Append():\ngot:  %s\nwant: %s", got, wantAppend)
+	}
+	wantOverride := "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary"
+	if got := d.Override(o).String(); got != wantOverride {
+		t.Errorf("d.Override():\ngot:  %s\nwant: %s", got, wantOverride)
+	}
+	if len(d) != 2 {
+		t.Errorf("d.Append(), d.Override(): want receiver unmodified, got %v", d)
+	}
+}
+
+func TestComposeDtabs(t *testing.T) {
+	base := Dtab{{Prefix: "/svc", Dst: "/#/io.l5d.k8s/default/http"}}
+	canary := Dtab{{Prefix: "/svc/web", Dst: "/svc/web-canary"}}
+
+	cases := []struct {
+		name         string
+		ds           []Dtab
+		want         string
+		wantConflict bool
+		wantErr      bool
+	}{
+		{
+			name: "Disjoint",
+			ds:   []Dtab{base, canary},
+			want: "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary",
+		},
+		{
+			name: "Repeated",
+			ds:   []Dtab{base, canary, canary},
+			want: "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary",
+		},
+		{
+			name: "RepeatedKeepsLast",
+			ds:   []Dtab{canary, base, canary},
+			want: "/svc=>/#/io.l5d.k8s/default/http;/svc/web=>/svc/web-canary",
+		},
+		{
+			name: "OverlappingPrefixes",
+			ds:   []Dtab{canary, base},
+			want: "/svc/web=>/svc/web-canary;/svc=>/#/io.l5d.k8s/default/http",
+		},
+		{
+			name: "OverrideWithinSource",
+			ds:   []Dtab{base.Append(Dtab{{Prefix: "/svc", Dst: "/$/inet/localhost/8080"}})},
+			want: "/svc=>/#/io.l5d.k8s/default/http;/svc=>/$/inet/localhost/8080",
+		},
+		{
+			name:         "Conflict",
+			ds:           []Dtab{canary, {{Prefix: "/svc/web", Dst: "/svc/web-v2"}}},
+			wantConflict: true,
+			wantErr:      true,
+		},
+		{
+			name:    "Invalid",
+			ds:      []Dtab{base, {{Prefix: "svc", Dst: "/svc/web"}}},
+			wantErr: true,
+		},
+		{
+			name: "None",
+			want: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ComposeDtabs(tc.ds...)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ComposeDtabs(): want error %t, got %v", tc.wantErr, err)
+			}
+			if _, ok := err.(*DtabConflict); ok != tc.wantConflict {
+				t.Errorf("ComposeDtabs(): want conflict %t, got %v", tc.wantConflict, err)
+			}
+			if err == nil && got.String() != tc.want {
+				t.Errorf("ComposeDtabs():\ngot:  %s\nwant: %s", got, tc.want)
+			}
+		})
+	}
+}
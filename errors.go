@@ -0,0 +1,85 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"errors"
+
+	"go.opencensus.io/trace"
+)
+
+// A TracedError is an error annotated with the span context in which it
+// occurred, so that error reports always link back to their trace.
+type TracedError struct {
+	// Err is the underlying error.
+	Err error
+
+	// SpanContext is the span context in which the error occurred.
+	SpanContext trace.SpanContext
+}
+
+// Error returns the underlying error's message, unmodified.
+func (e *TracedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *TracedError) Unwrap() error {
+	return e.Err
+}
+
+// WrapError annotates the supplied error with the span context of the span in
+// the supplied context. The error's message is unmodified. Errors that are
+// already annotated, and nil errors, are returned unmodified, as are errors
+// when there is no span in the supplied context.
+func WrapError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := SpanContextFromError(err); ok {
+		return err
+	}
+	s := trace.FromContext(ctx)
+	if s == nil {
+		return err
+	}
+	return &TracedError{Err: err, SpanContext: s.SpanContext()}
+}
+
+// SpanContextFromError returns the span context in which the supplied error
+// occurred, if it or any error it wraps was annotated by WrapError.
+func SpanContextFromError(err error) (trace.SpanContext, bool) {
+	var te *TracedError
+	if !errors.As(err, &te) {
+		return trace.SpanContext{}, false
+	}
+	return te.SpanContext, true
+}
+
+// ErrorTags returns the trace_id and span_id of the span in which the supplied
+// error occurred, suitable for use as the tags of an error report, e.g. in
+// Sentry. The trace_id is hex encoded as it is reported to Zipkin, matching the
+// X-Trace-Id header. It returns nil if the error was not annotated by
+// WrapError.
+func ErrorTags(err error) map[string]string {
+	sc, ok := SpanContextFromError(err)
+	if !ok {
+		return nil
+	}
+	return map[string]string{labelTraceID: traceIDHex(sc.TraceID), labelSpanID: sc.SpanID.String()}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestWrapError(t *testing.T) {
+	ctx, span := trace.StartSpan(context.Background(), "test")
+	defer span.End()
+	sc := span.SpanContext()
+
+	_, other := trace.StartSpan(context.Background(), "other")
+	defer other.End()
+
+	ctx64, span64 := trace.StartSpanWithRemoteParent(context.Background(), "test64",
+		trace.SpanContext{TraceID: trace.TraceID{8: 0x32, 15: 0xe7}, SpanID: trace.SpanID{1}})
+	defer span64.End()
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want map[string]string
+	}{
+		{
+			name: "Wrapped",
+			ctx:  ctx,
+			err:  io.EOF,
+			want: map[string]string{"trace_id": traceIDHex(sc.TraceID), "span_id": sc.SpanID.String()},
+		},
+		{
+			name: "AlreadyWrapped",
+			ctx:  trace.NewContext(context.Background(), other),
+			err:  fmt.Errorf("cannot read: %w", WrapError(ctx, io.EOF)),
+			want: map[string]string{"trace_id": traceIDHex(sc.TraceID), "span_id": sc.SpanID.String()},
+		},
+		{
+			name: "TraceID64",
+			ctx:  ctx64,
+			err:  io.EOF,
+			want: map[string]string{"trace_id": "32000000000000e7", "span_id": span64.SpanContext().SpanID.String()},
+		},
+		{
+			name: "NoSpan",
+			ctx:  context.Background(),
+			err:  io.EOF,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := WrapError(tc.ctx, tc.err)
+			if err.Error() != tc.err.Error() {
+				t.Errorf("WrapError(): want message %q, got %q", tc.err.Error(), err.Error())
+			}
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("WrapError(): want error that wraps %v, got %v", io.EOF, err)
+			}
+			if got := ErrorTags(err); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ErrorTags(): want %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if err := WrapError(ctx, nil); err != nil {
+		t.Errorf("WrapError(nil): want nil, got %v", err)
+	}
+}
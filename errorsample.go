@@ -0,0 +1,172 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// ErrorSamplingHandler is an http.Handler that retains the server spans of
+// failed requests even under aggressive head sampling. OpenCensus decides
+// whether to sample a span when it starts, so an unsampled span cannot be
+// upgraded once a request fails. Instead, when a request whose span was not
+// sampled receives a 5xx response, ErrorSamplingHandler exports a sampled copy
+// of its server span, with the same trace and span IDs, directly to its
+// Exporter. The copy records an l5d.sampling.forced attribute. Only the server
+// span is retained; any unsampled descendants of it are not.
+//
+// ErrorSamplingHandler must be wrapped by an ochttp.Handler, e.g.:
+//
+//  h := &ochttp.Handler{Handler: &linkin.ErrorSamplingHandler{Handler: h, Exporter: e}, Propagation: &linkin.HTTPFormat{}}
+type ErrorSamplingHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Exporter is the exporter to which the server spans of failed requests
+	// are exported. This should be the exporter (or one of the exporters)
+	// registered with trace.RegisterExporter. Spans are not exported if
+	// Exporter is nil.
+	Exporter trace.Exporter
+
+	// Propagation is the format used by the wrapping ochttp.Handler to
+	// extract span context. It is used to determine the parent of exported
+	// spans. &HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// FormatSpanName is the function used by the wrapping ochttp.Handler to
+	// name server spans. Spans are named for their request's URL path if
+	// FormatSpanName is nil, as per ochttp.Handler.
+	FormatSpanName func(r *http.Request) string
+}
+
+// ServeHTTP serves the request, exporting its server span if the span was not
+// sampled and the response status is 5xx.
+func (h *ErrorSamplingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	span := trace.FromContext(r.Context())
+	if span == nil || span.SpanContext().IsSampled() || h.Exporter == nil {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	h.Handler.ServeHTTP(sw, r)
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	if sw.code < 500 {
+		return
+	}
+	h.Exporter.ExportSpan(forcedSpanData(r, h.Propagation, h.FormatSpanName, span.SpanContext(), start, sw.code, SampledError))
+}
+
+// forcedSpanData returns a sampled copy of the supplied unsampled server span
+// context of the supplied request, which started at the supplied time and
+// received a response with the supplied status code.
+func forcedSpanData(r *http.Request, f propagation.HTTPFormat, formatSpanName func(r *http.Request) string, sc trace.SpanContext, start time.Time, code int, reason SamplingReason) *trace.SpanData {
+	sc.TraceOptions |= ocShouldSample
+	name := r.URL.Path
+	if formatSpanName != nil {
+		name = formatSpanName(r)
+	}
+	s := &trace.SpanData{
+		SpanContext: sc,
+		SpanKind:    trace.SpanKindServer,
+		Name:        name,
+		StartTime:   start,
+		EndTime:     time.Now(),
+		Attributes: map[string]interface{}{
+			ForcedSampleAttribute:      true,
+			SamplingReasonAttribute:    string(reason),
+			ochttp.MethodAttribute:     r.Method,
+			ochttp.PathAttribute:       r.URL.Path,
+			ochttp.StatusCodeAttribute: int64(code),
+		},
+		Status: ochttp.TraceStatus(code, ""),
+	}
+	if f == nil {
+		f = &HTTPFormat{}
+	}
+	if parent, ok := f.SpanContextFromRequest(r); ok && parent.TraceID == sc.TraceID {
+		s.ParentSpanID = parent.SpanID
+		s.HasRemoteParent = true
+	}
+	return s
+}
+
+// LatencySamplingHandler is an http.Handler that marks slow requests, so that
+// they are preferentially retained and easy to search. The server span of each
+// request that takes longer than the Threshold records an l5d.slow attribute.
+// If the span was not sampled a sampled copy of it is exported directly to the
+// Exporter, as per ErrorSamplingHandler.
+//
+// LatencySamplingHandler must be wrapped by an ochttp.Handler.
+type LatencySamplingHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Threshold is the duration beyond which a request is considered slow.
+	// No requests are considered slow if Threshold is zero.
+	Threshold time.Duration
+
+	// Exporter is the exporter to which the unsampled server spans of slow
+	// requests are exported. Unsampled spans are not exported if Exporter is
+	// nil.
+	Exporter trace.Exporter
+
+	// Propagation is the format used by the wrapping ochttp.Handler to
+	// extract span context. &HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// FormatSpanName is the function used by the wrapping ochttp.Handler to
+	// name server spans. Spans are named for their request's URL path if
+	// FormatSpanName is nil, as per ochttp.Handler.
+	FormatSpanName func(r *http.Request) string
+}
+
+// ServeHTTP serves the request, marking its server span if it was slow.
+func (h *LatencySamplingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	span := trace.FromContext(r.Context())
+	if span == nil || h.Threshold <= 0 {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	h.Handler.ServeHTTP(sw, r)
+	if time.Since(start) <= h.Threshold {
+		return
+	}
+	if sc := span.SpanContext(); !sc.IsSampled() {
+		if h.Exporter != nil {
+			if sw.code == 0 {
+				sw.code = http.StatusOK
+			}
+			s := forcedSpanData(r, h.Propagation, h.FormatSpanName, sc, start, sw.code, SampledSlow)
+			s.Attributes[SlowAttribute] = true
+			h.Exporter.ExportSpan(s)
+		}
+		return
+	}
+	span.AddAttributes(trace.BoolAttribute(SlowAttribute, true))
+}
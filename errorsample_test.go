@@ -0,0 +1,158 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestErrorSamplingHandler(t *testing.T) {
+	parent := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}}
+
+	cases := []struct {
+		name       string
+		sampler    trace.Sampler
+		code       int
+		withParent bool
+		want       bool
+	}{
+		{name: "UnsampledError", sampler: trace.NeverSample(), code: http.StatusServiceUnavailable, want: true},
+		{name: "UnsampledErrorWithParent", sampler: trace.NeverSample(), code: http.StatusInternalServerError, withParent: true, want: true},
+		{name: "UnsampledSuccess", sampler: trace.NeverSample(), code: http.StatusOK},
+		{name: "UnsampledImplicitSuccess", sampler: trace.NeverSample()},
+		{name: "UnsampledClientError", sampler: trace.NeverSample(), code: http.StatusNotFound},
+		{name: "SampledError", sampler: trace.AlwaysSample(), code: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			var sc trace.SpanContext
+			h := &ochttp.Handler{
+				Propagation: &HTTPFormat{},
+				Handler: &ErrorSamplingHandler{
+					Exporter: e,
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						sc = trace.FromContext(r.Context()).SpanContext()
+						if tc.code != 0 {
+							w.WriteHeader(tc.code)
+						}
+					}),
+				},
+				StartOptions: trace.StartOptions{Sampler: tc.sampler},
+			}
+
+			r := httptest.NewRequest("GET", "http://example.org/users", nil)
+			if tc.withParent {
+				r.Header.Set(l5dHeaderTrace, encode(parent))
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := e.Spans()
+			if !tc.want {
+				if len(spans) != 0 {
+					t.Errorf("h.ServeHTTP(): want no forced spans, got %v", spans)
+				}
+				return
+			}
+			if len(spans) != 1 {
+				t.Fatalf("h.ServeHTTP(): want 1 forced span, got %d", len(spans))
+			}
+			s := spans[0]
+			if s.TraceID != sc.TraceID || s.SpanID != sc.SpanID || !s.IsSampled() {
+				t.Errorf("h.ServeHTTP(): want sampled copy of span %v, got %v", sc, s.SpanContext)
+			}
+			if s.Name != "/users" || s.SpanKind != trace.SpanKindServer {
+				t.Errorf("h.ServeHTTP(): want server span named /users, got %d span named %s", s.SpanKind, s.Name)
+			}
+			if s.Attributes[ForcedSampleAttribute] != true {
+				t.Errorf("h.ServeHTTP(): want attribute %s, got %v", ForcedSampleAttribute, s.Attributes)
+			}
+			if s.Code != ochttp.TraceStatus(tc.code, "").Code {
+				t.Errorf("h.ServeHTTP(): want status %d, got %d", ochttp.TraceStatus(tc.code, "").Code, s.Code)
+			}
+			if tc.withParent && (s.ParentSpanID != parent.SpanID || !s.HasRemoteParent) {
+				t.Errorf("h.ServeHTTP(): want remote parent %v, got %v", parent.SpanID, s.ParentSpanID)
+			}
+		})
+	}
+}
+
+func TestLatencySamplingHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		sampled    bool
+		threshold  time.Duration
+		latency    time.Duration
+		wantSlow   bool
+		wantForced bool
+	}{
+		{name: "SampledSlow", sampled: true, threshold: time.Millisecond, latency: 20 * time.Millisecond, wantSlow: true},
+		{name: "SampledFast", sampled: true, threshold: time.Second},
+		{name: "UnsampledSlow", threshold: time.Millisecond, latency: 20 * time.Millisecond, wantSlow: true, wantForced: true},
+		{name: "UnsampledFast", threshold: time.Second},
+		{name: "NoThreshold", sampled: true, latency: 20 * time.Millisecond},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sampler := trace.NeverSample()
+			if tc.sampled {
+				sampler = trace.AlwaysSample()
+			}
+			forced := &recordingExporter{}
+			exported := &recordingExporter{}
+			trace.RegisterExporter(exported)
+			defer trace.UnregisterExporter(exported)
+
+			h := &ochttp.Handler{
+				Handler: &LatencySamplingHandler{
+					Threshold: tc.threshold,
+					Exporter:  forced,
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						time.Sleep(tc.latency)
+					}),
+				},
+				StartOptions: trace.StartOptions{Sampler: sampler},
+			}
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.org/users", nil))
+
+			spans := append(exported.Spans(), forced.Spans()...)
+			if len(spans) == 0 {
+				if tc.sampled || tc.wantForced {
+					t.Fatalf("h.ServeHTTP(): want exported span, got none")
+				}
+				return
+			}
+			if len(spans) != 1 {
+				t.Fatalf("h.ServeHTTP(): want 1 exported span, got %d", len(spans))
+			}
+			if got := spans[0].Attributes[SlowAttribute] == true; got != tc.wantSlow {
+				t.Errorf("h.ServeHTTP(): want slow %t, got attributes %v", tc.wantSlow, spans[0].Attributes)
+			}
+			if got := len(forced.Spans()) == 1; got != tc.wantForced {
+				t.Errorf("h.ServeHTTP(): want forced %t, got %t", tc.wantForced, got)
+			}
+		})
+	}
+}
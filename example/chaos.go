@@ -0,0 +1,88 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+)
+
+// chaos injects faults into a fraction of requests, so that this example may
+// double as a test bed for tracing dashboards and alerting. Each fault is noted
+// as an annotation on the span of the affected request.
+type chaos struct {
+	// Latency is added to the fraction of requests served that is configured
+	// by LatencyFraction.
+	Latency         time.Duration
+	LatencyFraction float64
+
+	// ErrorFraction is the fraction of requests served that fail with a 500.
+	ErrorFraction float64
+
+	// DropFraction is the fraction of downstream requests sent without trace
+	// propagation headers, breaking their traces.
+	DropFraction float64
+}
+
+func chance(fraction float64) bool {
+	return fraction > 0 && rand.Float64() < fraction
+}
+
+// Handler injects latency and errors into requests served by the supplied
+// handler, which must be wrapped by an ochttp.Handler.
+func (c *chaos) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.FromContext(r.Context())
+		if chance(c.LatencyFraction) {
+			span.Annotate([]trace.Attribute{trace.Int64Attribute("chaos.latency_ms", c.Latency.Milliseconds())}, "chaos: injected latency")
+			time.Sleep(c.Latency)
+		}
+		if chance(c.ErrorFraction) {
+			span.Annotate(nil, "chaos: injected error")
+			http.Error(w, "chaos: injected error", http.StatusInternalServerError)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Transport drops the trace propagation headers of requests sent by the
+// supplied transport. It must be the Base of an ochttp.Transport, so that it
+// runs after span context has been injected.
+func (c *chaos) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	drop := &linkin.Scrubber{Trace: linkin.MaskDrop, Baggage: linkin.MaskKeep, Sensitive: []string{}}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if chance(c.DropFraction) {
+			trace.FromContext(r.Context()).Annotate(nil, "chaos: dropped propagation")
+			r = drop.Request(r)
+		}
+		return base.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
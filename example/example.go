@@ -17,19 +17,22 @@ and limitations under the License.
 package main
 
 import (
+	"context"
 	"net/http"
-	"net/http/httputil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
+	"contrib.go.opencensus.io/exporter/prometheus"
+	"contrib.go.opencensus.io/exporter/zipkin"
 	openzipkin "github.com/openzipkin/zipkin-go"
-	openzipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	"github.com/planetlabs/linkin"
-	"go.opencensus.io/exporter/prometheus"
-	"go.opencensus.io/exporter/zipkin"
+	"github.com/planetlabs/linkin/consoleexporter"
+	"github.com/planetlabs/linkin/httpserver"
+	"github.com/planetlabs/linkin/zipkinreporter"
 	"go.opencensus.io/plugin/ochttp"
-	"go.opencensus.io/stats/view"
 	"go.opencensus.io/trace"
 	"go.uber.org/zap"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -41,42 +44,7 @@ const serviceName = "example"
 TODO(negz): Throw some useful baggage on the traces?
 */
 
-func loggingHandler(log *zap.Logger, h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if dump, err := httputil.DumpRequest(r, false); err == nil {
-			log.Info("request", zap.ByteString("in", dump))
-		}
-		h.ServeHTTP(w, r)
-	})
-}
-
-type loggingTransport struct {
-	log  *zap.Logger
-	base http.RoundTripper
-}
-
-func (t *loggingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
-	if dump, err := httputil.DumpRequest(r, false); err == nil {
-		t.log.Info("request", zap.ByteString("out", dump))
-	}
-	rsp, err := t.base.RoundTrip(r)
-	if err == nil {
-		if dump, err := httputil.DumpResponse(rsp, false); err == nil {
-			t.log.Info("response", zap.ByteString("in", dump))
-		}
-	}
-	return rsp, err
-}
-
-func (t *loggingTransport) CancelRequest(r *http.Request) {
-	if cr, ok := t.base.(interface {
-		CancelRequest(*http.Request)
-	}); ok {
-		cr.CancelRequest(r)
-	}
-}
-
-func propagateRequest(log *zap.Logger, downstreams []string) http.HandlerFunc {
+func propagateRequest(log *zap.Logger, c *chaos, downstreams []string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
@@ -99,11 +67,9 @@ func propagateRequest(log *zap.Logger, downstreams []string) http.HandlerFunc {
 		}(dbSpan)
 
 		// Create an HTTP round tripper with some Opencensus middleware. Note we
-		// use linkerd's trace propagation headers rather than Zipkin's.
-		var t http.RoundTripper = &ochttp.Transport{Propagation: &linkin.HTTPFormat{}}
-
-		// Wrap the HTTP router with some simple request logging middleware.
-		t = &loggingTransport{log: log, base: t}
+		// use linkerd's trace propagation headers rather than Zipkin's. Chaos
+		// may drop said headers from some requests.
+		t := &ochttp.Transport{Base: c.Transport(nil), Propagation: &linkin.HTTPFormat{}}
 
 		// Create an HTTP client that uses our transport.
 		client := http.Client{Transport: t}
@@ -127,11 +93,11 @@ func propagateRequest(log *zap.Logger, downstreams []string) http.HandlerFunc {
 
 				Note that the incoming request's context is canceled when the
 				client's connection closes, the request is canceled (with
-				HTTP/2), or when the ServeHTTP method returns. You'll need to
-				extract the span and stash it in a new context if this request
-				should create asynchronous spans:
+				HTTP/2), or when the ServeHTTP method returns. Use linkin.Go to
+				run work that outlives the request with a context that carries
+				its span, but that is not cancelled with it:
 
-				  ctx := trace.NewContext(context.Background(), trace.FromContext(r.Context()))
+				  linkin.Go(r.Context(), func(ctx context.Context) { ... })
 
 			*/
 			out = out.WithContext(r.Context())
@@ -152,6 +118,11 @@ func main() {
 		debug          = app.Flag("debug", "Run with debug logging.").Short('d').Bool()
 		listen         = app.Flag("listen", "Address at which to listen.").Default("0.0.0.0:10002").String()
 		zipkinEndpoint = app.Flag("zipkin", "Address at which Zipkin listens.").Default("http://zipkin.kube-system:9411/api/v2/spans").String()
+		latency        = app.Flag("chaos-latency", "Latency to inject into requests.").Default("500ms").Duration()
+		latencyFrac    = app.Flag("chaos-latency-fraction", "Fraction of requests into which to inject latency.").Default("0").Float64()
+		errorFrac      = app.Flag("chaos-error-fraction", "Fraction of requests that fail with an injected error.").Default("0").Float64()
+		dropFrac       = app.Flag("chaos-drop-fraction", "Fraction of downstream requests sent without trace propagation headers.").Default("0").Float64()
+		console        = app.Flag("console-traces", "Print traces to stderr as they finish.").Bool()
 		downstreams    = app.Arg("downstreams", "Downstream service URLs to query").Strings()
 	)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
@@ -167,33 +138,41 @@ func main() {
 	// Create and register an Opencensus Zipkin exporter.
 	endpoint, err := openzipkin.NewEndpoint(serviceName, *listen)
 	kingpin.FatalIfError(err, "cannot set Zipkin endpoint")
-	zipkinExporter := zipkin.NewExporter(openzipkinhttp.NewReporter(*zipkinEndpoint), endpoint)
+	// The reporter buffers spans and retries failed batches, so that Zipkin
+	// outages neither block requests nor lose traces.
+	zipkinReporter := zipkinreporter.NewHTTPReporter(*zipkinEndpoint, zipkinreporter.Options{})
+	defer zipkinReporter.Close()
+	zipkinExporter := zipkin.NewExporter(zipkinReporter, endpoint)
 	trace.RegisterExporter(zipkinExporter)
 
-	// Create and register an Opencensus Prometheus exporter.
+	// Print traces to the terminal when running locally.
+	if *console {
+		trace.RegisterExporter(&consoleexporter.Exporter{})
+	}
+
+	// Create an Opencensus Prometheus exporter. It reads every registered view
+	// when scraped, so it need not be registered as a view exporter.
 	prometheusExporter, err := prometheus.NewExporter(prometheus.Options{Namespace: serviceName})
 	kingpin.FatalIfError(err, "cannot create prometheus exporter")
-	view.RegisterExporter(prometheusExporter)
 
-	// Register default views (i.e. metrics) for ochttp server and clients.
-	err = view.Register(ochttp.DefaultServerViews...)
-	kingpin.FatalIfError(err, "cannot register opencensus HTTP server views")
-	err = view.Register(ochttp.DefaultClientViews...)
-	kingpin.FatalIfError(err, "cannot register opencensus HTTP client views")
+	// Register default views (i.e. metrics) for ochttp servers and clients,
+	// and for linkin.
+	kingpin.FatalIfError(httpserver.RegisterViews(), "cannot register opencensus views")
 
 	// Create an HTTP router.
 	r := http.NewServeMux()
-	r.HandleFunc("/", propagateRequest(log, *downstreams))
+	c := &chaos{Latency: *latency, LatencyFraction: *latencyFrac, ErrorFraction: *errorFrac, DropFraction: *dropFrac}
+	r.Handle("/", c.Handler(propagateRequest(log, c, *downstreams)))
 	r.Handle("/metrics", prometheusExporter)
 
-	// Wrap the HTTP router with some simple request logging middleware.
-	h := loggingHandler(log, r)
-
-	// Wrap the HTTP router with some Opencensus middleware. Note we use
-	// linkerd's trace propagation headers rather than Zipkin's.
-	h = &ochttp.Handler{Handler: h, Propagation: &linkin.HTTPFormat{}}
+	// Create an HTTP server. The server wraps the HTTP router with access
+	// logging and Opencensus middleware. Note it uses linkerd's trace
+	// propagation headers rather than Zipkin's.
+	s := &httpserver.Server{Addr: *listen, Handler: r, AccessLog: os.Stdout}
 
-	// Start listening for HTTP requests!
-	s := &http.Server{Addr: *listen, Handler: h}
-	log.Info("shutdown", zap.Error(s.ListenAndServe()))
+	// Start listening for HTTP requests! The server shuts down gracefully when
+	// the process is interrupted or terminated.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Info("shutdown", zap.Error(s.ListenAndServe(ctx)))
 }
@@ -0,0 +1,185 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/zipkin"
+	openzipkin "github.com/openzipkin/zipkin-go"
+	openzipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/planetlabs/linkin"
+	"github.com/planetlabs/linkin/l5dgrpc"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const serviceName = "example-grpc"
+
+/*
+This example stitches HTTP and gRPC requests into a single trace. An HTTP
+request to the frontend results in a gRPC health check of the backend, which in
+turn checks the health of its HTTP dependencies:
+
+  HTTP client -> frontend (HTTP) -> backend (gRPC) -> dependencies (HTTP)
+
+linkerd forwards l5d-ctx-* headers over HTTP/2, and thus gRPC metadata, so the
+trace survives every hop through the mesh.
+*/
+
+// dependencyHealth is a gRPC health service that reports a service as healthy
+// only if all of its HTTP dependencies respond successfully.
+type dependencyHealth struct {
+	grpc_health_v1.UnimplementedHealthServer
+
+	log          *zap.Logger
+	client       *http.Client
+	dependencies []string
+}
+
+func (h *dependencyHealth) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	/*
+		The l5dgrpc server interceptor injects a span representing this RPC
+		into its context. If the RPC's metadata contained linkerd trace
+		propagation headers the span will be a child of the span that sent
+		the RPC. Outgoing HTTP requests that use this context will be its
+		children in turn.
+	*/
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	for _, d := range h.dependencies {
+		out, err := http.NewRequest("GET", d, nil)
+		if err != nil {
+			h.log.Error("cannot form dependency request", zap.Error(err))
+			continue
+		}
+		rsp, err := h.client.Do(out.WithContext(ctx))
+		if err != nil {
+			h.log.Info("dependency is unhealthy", zap.String("dependency", d), zap.Error(err))
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+			continue
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode >= http.StatusInternalServerError {
+			h.log.Info("dependency is unhealthy", zap.String("dependency", d), zap.Int("status", rsp.StatusCode))
+			status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: status}, nil
+}
+
+// frontend returns an HTTP handler that reports the health of the backend.
+func frontend(log *zap.Logger, backend grpc_health_v1.HealthClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		/*
+			The ochttp.Handler middleware that wraps this handler injects a
+			span representing this request into its context. The l5dgrpc
+			client interceptor creates a child of that span to represent the
+			RPC, and propagates it in the RPC's metadata.
+		*/
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		rsp, err := backend.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			log.Error("cannot check backend health", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if rsp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprintln(w, rsp.GetStatus())
+	}
+}
+
+func main() {
+	var (
+		app            = kingpin.New(filepath.Base(os.Args[0]), "Traces stuff over gRPC, and also junk!").DefaultEnvars()
+		debug          = app.Flag("debug", "Run with debug logging.").Short('d').Bool()
+		zipkinEndpoint = app.Flag("zipkin", "Address at which Zipkin listens.").Default("http://zipkin.kube-system:9411/api/v2/spans").String()
+
+		backendCmd   = app.Command("backend", "Serve a gRPC health service that checks HTTP dependencies.")
+		backendAddr  = backendCmd.Flag("listen", "Address at which to listen.").Default("0.0.0.0:10003").String()
+		dependencies = backendCmd.Arg("dependencies", "Dependency URLs to query").Strings()
+
+		frontendCmd   = app.Command("frontend", "Serve an HTTP endpoint that reports the health of a gRPC backend.")
+		frontendAddr  = frontendCmd.Flag("listen", "Address at which to listen.").Default("0.0.0.0:10004").String()
+		backendTarget = frontendCmd.Arg("backend", "gRPC backend to query, e.g. via linkerd.").Required().String()
+	)
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	// Create a logger.
+	var log *zap.Logger
+	log, err := zap.NewProduction()
+	if *debug {
+		log, err = zap.NewDevelopment()
+	}
+	kingpin.FatalIfError(err, "cannot create log")
+
+	// Both the gRPC client and server interceptors use linkerd's trace
+	// propagation headers rather than Zipkin's.
+	i := &l5dgrpc.Interceptor{Propagation: &linkin.HTTPFormat{}}
+
+	switch cmd {
+	case backendCmd.FullCommand():
+		registerZipkin(*zipkinEndpoint, *backendAddr)
+
+		hs := &dependencyHealth{
+			log:          log,
+			client:       &http.Client{Transport: &ochttp.Transport{Propagation: &linkin.HTTPFormat{}}},
+			dependencies: *dependencies,
+		}
+		s := grpc.NewServer(grpc.UnaryInterceptor(i.UnaryServer), grpc.StreamInterceptor(i.StreamServer))
+		grpc_health_v1.RegisterHealthServer(s, hs)
+
+		l, err := net.Listen("tcp", *backendAddr)
+		kingpin.FatalIfError(err, "cannot listen")
+		log.Info("shutdown", zap.Error(s.Serve(l)))
+
+	case frontendCmd.FullCommand():
+		registerZipkin(*zipkinEndpoint, *frontendAddr)
+
+		conn, err := grpc.Dial(*backendTarget,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(i.UnaryClient),
+			grpc.WithStreamInterceptor(i.StreamClient))
+		kingpin.FatalIfError(err, "cannot dial backend")
+		defer conn.Close()
+
+		h := &ochttp.Handler{Handler: frontend(log, grpc_health_v1.NewHealthClient(conn)), Propagation: &linkin.HTTPFormat{}}
+		s := &http.Server{Addr: *frontendAddr, Handler: h}
+		log.Info("shutdown", zap.Error(s.ListenAndServe()))
+	}
+}
+
+// registerZipkin creates and registers an Opencensus Zipkin exporter.
+func registerZipkin(zipkinEndpoint, listen string) {
+	endpoint, err := openzipkin.NewEndpoint(serviceName, listen)
+	kingpin.FatalIfError(err, "cannot set Zipkin endpoint")
+	trace.RegisterExporter(zipkin.NewExporter(openzipkinhttp.NewReporter(zipkinEndpoint), endpoint))
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/zipkin"
+	openzipkin "github.com/openzipkin/zipkin-go"
+	openzipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/planetlabs/linkin"
+	"github.com/segmentio/kafka-go"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const serviceName = "example-kafka"
+
+/*
+This example propagates trace context through Kafka. The producer publishes a
+message on an interval, each in a new trace. The consumer handles each message
+in a span that is a child of the span that produced it, and sends an HTTP
+request to each of its downstreams as part of the same trace:
+
+  producer -> Kafka -> consumer -> downstreams (HTTP)
+
+Span context is carried in Kafka message headers, using the same linkerd
+l5d-ctx-trace header as HTTP requests.
+*/
+
+// carrier is a linkin.Carrier backed by the headers of a Kafka message.
+type carrier struct {
+	m *kafka.Message
+}
+
+func (c carrier) Get(key string) string {
+	for _, h := range c.m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c carrier) Set(key, value string) {
+	for i, h := range c.m.Headers {
+		if h.Key == key {
+			c.m.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.m.Headers = append(c.m.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c.m.Headers))
+	for _, h := range c.m.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+func produce(ctx context.Context, log *zap.Logger, w *kafka.Writer, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		// Start a new trace for each message, and inject its span context
+		// into the message's headers.
+		mctx, span := trace.StartSpan(ctx, "produce", trace.WithSpanKind(trace.SpanKindClient))
+		m := kafka.Message{Value: []byte(time.Now().Format(time.RFC3339))}
+		linkin.SpanContextToCarrier(mctx, &linkin.HTTPFormat{}, span.SpanContext(), carrier{&m})
+
+		if err := w.WriteMessages(mctx, m); err != nil {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnavailable, Message: err.Error()})
+			log.Error("cannot produce message", zap.Error(err))
+		}
+		span.End()
+	}
+}
+
+func consume(ctx context.Context, log *zap.Logger, r *kafka.Reader, downstreams []string) error {
+	client := &http.Client{Transport: &ochttp.Transport{Propagation: &linkin.HTTPFormat{}}}
+
+	/*
+		The linkin.Consumer starts a span for each message it receives. The
+		span is a child of the span context carried in the message's headers,
+		if any. Messages are committed only once they have been handled.
+	*/
+	c := &linkin.Consumer{
+		Propagation:  &linkin.HTTPFormat{},
+		Timeout:      10 * time.Second,
+		ErrorHandler: func(err error) { log.Error("cannot handle message", zap.Error(err)) },
+	}
+	receive := linkin.ReceiverFunc(func(ctx context.Context) (linkin.Carrier, error) {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return carrier{&m}, nil
+	})
+	handle := func(ctx context.Context, m linkin.Carrier) error {
+		for _, d := range downstreams {
+			out, err := http.NewRequest("GET", d, nil)
+			if err != nil {
+				return err
+			}
+			rsp, err := client.Do(out.WithContext(ctx))
+			if err != nil {
+				return err
+			}
+			rsp.Body.Close()
+		}
+		return r.CommitMessages(ctx, *m.(carrier).m)
+	}
+	return c.Run(ctx, receive, handle)
+}
+
+func main() {
+	var (
+		app            = kingpin.New(filepath.Base(os.Args[0]), "Traces stuff through Kafka, and also junk!").DefaultEnvars()
+		debug          = app.Flag("debug", "Run with debug logging.").Short('d').Bool()
+		zipkinEndpoint = app.Flag("zipkin", "Address at which Zipkin listens.").Default("http://zipkin.kube-system:9411/api/v2/spans").String()
+		brokers        = app.Flag("broker", "Kafka broker address.").Default("kafka:9092").Strings()
+		topic          = app.Flag("topic", "Kafka topic.").Default("example").String()
+
+		producerCmd = app.Command("producer", "Produce a traced message on an interval.")
+		interval    = producerCmd.Flag("interval", "Interval at which to produce messages.").Default("1s").Duration()
+
+		consumerCmd = app.Command("consumer", "Consume traced messages.")
+		group       = consumerCmd.Flag("group", "Kafka consumer group.").Default("example").String()
+		downstreams = consumerCmd.Arg("downstreams", "Downstream service URLs to query for each message").Strings()
+	)
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	// Create a logger.
+	var log *zap.Logger
+	log, err := zap.NewProduction()
+	if *debug {
+		log, err = zap.NewDevelopment()
+	}
+	kingpin.FatalIfError(err, "cannot create log")
+
+	// Create and register an Opencensus Zipkin exporter. Sample every trace;
+	// this example produces few of them.
+	endpoint, err := openzipkin.NewEndpoint(serviceName+"-"+cmd, "")
+	kingpin.FatalIfError(err, "cannot set Zipkin endpoint")
+	trace.RegisterExporter(zipkin.NewExporter(openzipkinhttp.NewReporter(*zipkinEndpoint), endpoint))
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch cmd {
+	case producerCmd.FullCommand():
+		w := &kafka.Writer{Addr: kafka.TCP(*brokers...), Topic: *topic}
+		defer w.Close()
+		log.Info("shutdown", zap.Error(produce(ctx, log, w, *interval)))
+
+	case consumerCmd.FullCommand():
+		r := kafka.NewReader(kafka.ReaderConfig{Brokers: *brokers, GroupID: *group, Topic: *topic})
+		defer r.Close()
+		log.Info("shutdown", zap.Error(consume(ctx, log, r, *downstreams)))
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	rtrace "runtime/trace"
+
+	"go.opencensus.io/trace"
+)
+
+// ExecutionTrace wraps the supplied handler, creating a runtime/trace task for
+// each request so that Go execution traces captured during an incident (e.g.
+// via /debug/pprof/trace) may be aligned with distributed traces. Tasks are
+// named after the endpoint served by the request; the route tagged by
+// ochttp.WithRouteTag if any, or else the URL path (i.e. the default name of
+// the ochttp server span). The trace and span IDs of the request's span are
+// logged to the task, the trace ID hex encoded as it is reported to Zipkin.
+// Use StartRegion to mark regions of interest within a request.
+// ExecutionTrace must be wrapped by an ochttp.Handler.
+func ExecutionTrace(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rtrace.IsEnabled() {
+			h.ServeHTTP(w, r)
+			return
+		}
+		ctx, task := rtrace.NewTask(r.Context(), endpoint(r))
+		defer task.End()
+		if s := trace.FromContext(ctx); s != nil {
+			sc := s.SpanContext()
+			rtrace.Log(ctx, labelTraceID, traceIDHex(sc.TraceID))
+			rtrace.Log(ctx, labelSpanID, sc.SpanID.String())
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StartRegion starts a new OpenCensus span with the supplied name, and a
+// runtime/trace region of the same name within the current execution trace
+// task, if any. Call the returned function to end both. It must be called from
+// the goroutine that started the region.
+func StartRegion(ctx context.Context, name string) (context.Context, func()) {
+	ctx, span := trace.StartSpan(ctx, name)
+	region := rtrace.StartRegion(ctx, name)
+	return ctx, func() {
+		region.End()
+		span.End()
+	}
+}
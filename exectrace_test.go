@@ -0,0 +1,65 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	rtrace "runtime/trace"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestExecutionTrace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := rtrace.Start(buf); err != nil {
+		t.Skipf("rtrace.Start(): %v", err)
+	}
+
+	var sc trace.SpanContext
+	var region trace.SpanContext
+	inner := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		sc = trace.FromContext(r.Context()).SpanContext()
+		ctx, end := StartRegion(r.Context(), "database query")
+		region = trace.FromContext(ctx).SpanContext()
+		end()
+	})
+	h := &ochttp.Handler{Handler: ExecutionTrace(inner), Propagation: &HTTPFormat{}}
+	r := httptest.NewRequest("GET", "http://example.org/checkout", nil)
+	r.Header.Set(l5dHeaderTrace, "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	rtrace.Stop()
+
+	if region.TraceID != sc.TraceID || region.SpanID == sc.SpanID {
+		t.Errorf("StartRegion(): want child span of %v, got %v", sc, region)
+	}
+
+	// The execution trace is binary, but includes task names and log messages
+	// verbatim.
+	for _, want := range []string{"/checkout", "database query", "32a4db20f5d592e7", sc.SpanID.String()} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("ExecutionTrace(): want %q in execution trace", want)
+		}
+	}
+	if long := sc.TraceID.String(); strings.Contains(buf.String(), long) {
+		t.Errorf("ExecutionTrace(): want 64 bit trace ID, got %q in execution trace", long)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// ExemplarHandler is an http.Handler that records the latency of each request
+// to the ServerLatency measure, attaching the request's span context as an
+// exemplar if the span is sampled. Exemplars link a latency histogram bucket
+// to a representative trace, allowing dashboards such as Grafana to jump from
+// a latency spike straight to the trace in Zipkin. Use TraceIDFromExemplar to
+// recover the trace ID from an exported exemplar.
+//
+// OpenCensus' own ochttp views cannot carry exemplars, so ExemplarHandler
+// records latency to its own measure. ServerLatencyView mirrors the ochttp
+// server latency view. ExemplarHandler must be wrapped by an ochttp.Handler.
+type ExemplarHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+}
+
+// ServeHTTP serves the request, then records its latency.
+func (h *ExemplarHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w}
+	h.Handler.ServeHTTP(sw, r)
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+
+	opts := []stats.Options{
+		stats.WithTags(tag.Upsert(ochttp.Method, r.Method), tag.Upsert(ochttp.StatusCode, strconv.Itoa(sw.code))),
+		stats.WithMeasurements(ServerLatency.M(float64(time.Since(start)) / float64(time.Millisecond))),
+	}
+	if s := trace.FromContext(r.Context()); s != nil && s.SpanContext().IsSampled() {
+		opts = append(opts, stats.WithAttachments(metricdata.Attachments{metricdata.AttachmentKeySpanContext: s.SpanContext()}))
+	}
+	stats.RecordWithOptions(r.Context(), opts...)
+}
+
+// TraceIDFromExemplar returns the ID of the trace attached to the supplied
+// exemplar, hex encoded as per Zipkin's API. 64 bit trace IDs are encoded as 16
+// characters, as Zipkin reports them.
+func TraceIDFromExemplar(e *metricdata.Exemplar) (string, bool) {
+	if e == nil {
+		return "", false
+	}
+	sc, ok := e.Attachments[metricdata.AttachmentKeySpanContext].(trace.SpanContext)
+	if !ok {
+		return "", false
+	}
+	return traceIDHex(sc.TraceID), true
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+)
+
+func TestExemplarHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		sampler  trace.Sampler
+		exemplar bool
+	}{
+		{name: "Sampled", sampler: trace.AlwaysSample(), exemplar: true},
+		{name: "Unsampled", sampler: trace.NeverSample(), exemplar: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := view.Register(ServerLatencyView); err != nil {
+				t.Fatalf("view.Register(): %v", err)
+			}
+			defer view.Unregister(ServerLatencyView)
+
+			var traceID string
+			h := &ochttp.Handler{
+				Handler: &ExemplarHandler{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					traceID = traceIDHex(trace.FromContext(r.Context()).SpanContext().TraceID)
+					w.WriteHeader(http.StatusTeapot)
+				})},
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: tc.sampler},
+			}
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.org", nil))
+
+			rows, err := view.RetrieveData(ServerLatencyView.Name)
+			if err != nil {
+				t.Fatalf("view.RetrieveData(): %v", err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("view.RetrieveData(): want one row, got %v", rows)
+			}
+			d, ok := rows[0].Data.(*view.DistributionData)
+			if !ok {
+				t.Fatalf("view.RetrieveData(): want distribution data, got %T", rows[0].Data)
+			}
+
+			var got []string
+			for _, e := range d.ExemplarsPerBucket {
+				if id, ok := TraceIDFromExemplar(e); ok {
+					got = append(got, id)
+				}
+			}
+			if !tc.exemplar {
+				if len(got) != 0 {
+					t.Errorf("h.ServeHTTP(): want no exemplars, got %v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0] != traceID {
+				t.Errorf("h.ServeHTTP(): want one exemplar with trace ID %s, got %v", traceID, got)
+			}
+		})
+	}
+}
+
+func TestTraceIDFromExemplar(t *testing.T) {
+	cases := []struct {
+		name   string
+		e      *metricdata.Exemplar
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "TraceID64",
+			e:      &metricdata.Exemplar{Attachments: metricdata.Attachments{metricdata.AttachmentKeySpanContext: trace.SpanContext{TraceID: trace.TraceID{8: 0x32, 15: 0xe7}}}},
+			want:   "32000000000000e7",
+			wantOK: true,
+		},
+		{
+			name:   "TraceID128",
+			e:      &metricdata.Exemplar{Attachments: metricdata.Attachments{metricdata.AttachmentKeySpanContext: trace.SpanContext{TraceID: trace.TraceID{0: 0x01, 15: 0xe7}}}},
+			want:   "010000000000000000000000000000e7",
+			wantOK: true,
+		},
+		{
+			name: "NoSpanContext",
+			e:    &metricdata.Exemplar{},
+		},
+		{
+			name: "Nil",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := TraceIDFromExemplar(tc.e)
+			if ok != tc.wantOK || got != tc.want {
+				t.Errorf("TraceIDFromExemplar(): want %q (%t), got %q (%t)", tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"os"
+
+	"go.opencensus.io/trace"
+)
+
+// Environment variables from which MeshExporterFromEnv reads mesh metadata.
+const (
+	EnvService = "LINKIN_SERVICE"
+	EnvRouter  = "LINKIN_ROUTER"
+	EnvNode    = "LINKIN_NODE"
+)
+
+// The Kubernetes node name is conventionally exposed to linkerd daemonsets via
+// this environment variable.
+const envNodeName = "NODE_NAME"
+
+// MeshExporter is a trace.Exporter that stamps each exported span with mesh
+// metadata before exporting it, so that spans may be searched by mesh
+// attributes uniformly. Attributes already present on a span are not
+// overwritten.
+type MeshExporter struct {
+	// Exporter is the exporter to which enriched spans are exported.
+	Exporter trace.Exporter
+
+	// Service is the name of the meshed service. Stamped as l5d.service.
+	Service string
+
+	// Router is the name of the linkerd router through which the service's
+	// traffic is routed. Stamped as l5d.router.
+	Router string
+
+	// Node is the name of the node on which the service, and thus its linkerd,
+	// runs. Stamped as l5d.node.
+	Node string
+}
+
+// MeshExporterFromEnv returns a MeshExporter that wraps the supplied exporter,
+// reading mesh metadata from the LINKIN_SERVICE, LINKIN_ROUTER, and LINKIN_NODE
+// environment variables. The node name is read from the NODE_NAME environment
+// variable if LINKIN_NODE is unset.
+func MeshExporterFromEnv(e trace.Exporter) *MeshExporter {
+	m := &MeshExporter{
+		Exporter: e,
+		Service:  os.Getenv(EnvService),
+		Router:   os.Getenv(EnvRouter),
+		Node:     os.Getenv(EnvNode),
+	}
+	if m.Node == "" {
+		m.Node = os.Getenv(envNodeName)
+	}
+	return m
+}
+
+// ExportSpan stamps the supplied span with mesh metadata, then exports it.
+func (m *MeshExporter) ExportSpan(s *trace.SpanData) {
+	// SpanData is shared between all registered exporters, so we must stamp a
+	// copy.
+	out := *s
+	out.Attributes = make(map[string]interface{}, len(s.Attributes)+3)
+	for k, v := range s.Attributes {
+		out.Attributes[k] = v
+	}
+	for k, v := range map[string]string{ServiceAttribute: m.Service, RouterAttribute: m.Router, NodeAttribute: m.Node} {
+		if _, ok := out.Attributes[k]; ok || v == "" {
+			continue
+		}
+		out.Attributes[k] = v
+	}
+	m.Exporter.ExportSpan(&out)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestMeshExporterSatisfiesExporter(t *testing.T) {
+	var _ trace.Exporter = (*MeshExporter)(nil)
+}
+
+func TestMeshExporterFromEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		want map[string]interface{}
+	}{
+		{
+			name: "AllMetadata",
+			env:  map[string]string{EnvService: "example", EnvRouter: "outgoing", EnvNode: "node-a", envNodeName: "node-b"},
+			want: map[string]interface{}{"existing": true, ServiceAttribute: "example", RouterAttribute: "outgoing", NodeAttribute: "node-a"},
+		},
+		{
+			name: "NodeName",
+			env:  map[string]string{EnvService: "", EnvRouter: "", EnvNode: "", envNodeName: "node-b"},
+			want: map[string]interface{}{"existing": true, NodeAttribute: "node-b"},
+		},
+		{
+			name: "NoMetadata",
+			env:  map[string]string{EnvService: "", EnvRouter: "", EnvNode: "", envNodeName: ""},
+			want: map[string]interface{}{"existing": true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setenv(t, tc.env)()
+
+			e := &recordingExporter{}
+			s := &trace.SpanData{Attributes: map[string]interface{}{"existing": true}}
+			MeshExporterFromEnv(e).ExportSpan(s)
+
+			spans := e.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("m.ExportSpan(): want 1 exported span, got %d", len(spans))
+			}
+			if !reflect.DeepEqual(spans[0].Attributes, tc.want) {
+				t.Errorf("m.ExportSpan(): want attributes %v, got %v", tc.want, spans[0].Attributes)
+			}
+			if len(s.Attributes) != 1 {
+				t.Errorf("m.ExportSpan(): modified the original span attributes: %v", s.Attributes)
+			}
+		})
+	}
+}
+
+func TestMeshExporterPreservesAttributes(t *testing.T) {
+	e := &recordingExporter{}
+	m := &MeshExporter{Exporter: e, Service: "example"}
+	m.ExportSpan(&trace.SpanData{Attributes: map[string]interface{}{ServiceAttribute: "override"}})
+
+	if got := e.Spans()[0].Attributes[ServiceAttribute]; got != "override" {
+		t.Errorf("m.ExportSpan(): want %s %q, got %v", ServiceAttribute, "override", got)
+	}
+}
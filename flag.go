@@ -0,0 +1,119 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A Flagger evaluates feature flags. It allows propagation changes, such as
+// migrations between formats, to be controlled by existing feature flagging
+// systems.
+type Flagger interface {
+	// Enabled returns true if the named flag is enabled in the supplied
+	// context, typically that of the request being propagated.
+	Enabled(ctx context.Context, flag string) bool
+}
+
+// A FlaggerFunc is a function that satisfies Flagger.
+type FlaggerFunc func(ctx context.Context, flag string) bool
+
+// Enabled calls fn(ctx, flag).
+func (fn FlaggerFunc) Enabled(ctx context.Context, flag string) bool {
+	return fn(ctx, flag)
+}
+
+type flagTraceIDKey struct{}
+
+// FlagTraceID returns the trace ID by which flags should be evaluated in the
+// supplied context: that of the span in the context, if any, or that of the
+// span context propagated by the request whose span context FlaggedFormat is
+// extracting. No span exists while span context is being extracted.
+func FlagTraceID(ctx context.Context) (trace.TraceID, bool) {
+	if span := trace.FromContext(ctx); span != nil {
+		return span.SpanContext().TraceID, true
+	}
+	id, ok := ctx.Value(flagTraceIDKey{}).(trace.TraceID)
+	return id, ok
+}
+
+// TraceFraction returns a Flagger that enables all flags for the supplied
+// fraction of traces, between 0 and 1. Flags are evaluated deterministically by
+// the trace ID returned by FlagTraceID, so a flag is enabled for every request
+// in a trace or none of them, whether span context is being extracted or
+// injected. Flags are disabled for contexts without a trace ID.
+func TraceFraction(fraction float64) Flagger {
+	return FlaggerFunc(func(ctx context.Context, _ string) bool {
+		id, ok := FlagTraceID(ctx)
+		if !ok {
+			return false
+		}
+		return sampledAt(id, fraction)
+	})
+}
+
+// FlaggedFormat implements propagation.HTTPFormat to propagate traces in one
+// of two formats, depending on whether a feature flag is enabled for each
+// request. For example, dual injection may be enabled for 5% of traffic by
+// using a MultiFormat when the flag is enabled and an HTTPFormat otherwise.
+type FlaggedFormat struct {
+	// Flag is the name of the feature flag.
+	Flag string
+
+	// Flagger evaluates the flag in the context of each request. The flag is
+	// always disabled if Flagger is nil.
+	Flagger Flagger
+
+	// Enabled is the propagation format used when the flag is enabled.
+	Enabled propagation.HTTPFormat
+
+	// Disabled is the propagation format used when the flag is disabled.
+	Disabled propagation.HTTPFormat
+}
+
+func (f *FlaggedFormat) format(ctx context.Context) propagation.HTTPFormat {
+	if f.Flagger != nil && f.Flagger.Enabled(ctx, f.Flag) {
+		return f.Enabled
+	}
+	return f.Disabled
+}
+
+// SpanContextFromRequest extracts span context from the supplied request using
+// the format selected by the feature flag. The flag is evaluated in a context
+// from which FlagTraceID returns the trace ID propagated by the request, in
+// either format, so that requests are extracted using the format with which
+// they were injected.
+func (f *FlaggedFormat) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	ctx := r.Context()
+	for _, p := range []propagation.HTTPFormat{f.Enabled, f.Disabled} {
+		if sc, ok := p.SpanContextFromRequest(r); ok {
+			ctx = context.WithValue(ctx, flagTraceIDKey{}, sc.TraceID)
+			break
+		}
+	}
+	return f.format(ctx).SpanContextFromRequest(r)
+}
+
+// SpanContextToRequest injects the supplied span context into the supplied
+// request using the format selected by the feature flag.
+func (f *FlaggedFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	f.format(r.Context()).SpanContextToRequest(sc, r)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestFlaggedFormatSatisfiesHTTPFormat(t *testing.T) {
+	var _ propagation.HTTPFormat = (*FlaggedFormat)(nil)
+}
+
+func TestFlaggedFormat(t *testing.T) {
+	sc := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+
+	cases := []struct {
+		name    string
+		flagger Flagger
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "NoFlagger",
+			want:    []string{"L5d-Ctx-Trace"},
+			notWant: []string{"X-B3-Traceid"},
+		},
+		{
+			name:    "Enabled",
+			flagger: FlaggerFunc(func(ctx context.Context, flag string) bool { return flag == "dual-injection" }),
+			want:    []string{"L5d-Ctx-Trace", "X-B3-Traceid"},
+		},
+		{
+			name:    "Disabled",
+			flagger: FlaggerFunc(func(ctx context.Context, flag string) bool { return false }),
+			want:    []string{"L5d-Ctx-Trace"},
+			notWant: []string{"X-B3-Traceid"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &FlaggedFormat{
+				Flag:     "dual-injection",
+				Flagger:  tc.flagger,
+				Enabled:  &MultiFormat{Extract: []propagation.HTTPFormat{&HTTPFormat{}, &B3Format{}}},
+				Disabled: &HTTPFormat{},
+			}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(sc, r)
+			for _, k := range tc.want {
+				if r.Header.Get(k) == "" {
+					t.Errorf("f.SpanContextToRequest(): want header %s, got %v", k, r.Header)
+				}
+			}
+			for _, k := range tc.notWant {
+				if r.Header.Get(k) != "" {
+					t.Errorf("f.SpanContextToRequest(): want no header %s, got %v", k, r.Header)
+				}
+			}
+			if got, ok := f.SpanContextFromRequest(r); !ok || got != sc {
+				t.Errorf("f.SpanContextFromRequest(): want %+v, got %+v", sc, got)
+			}
+		})
+	}
+}
+
+func TestFlaggedFormatExtractsByPropagatedTraceID(t *testing.T) {
+	sc := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+
+	cases := []struct {
+		name     string
+		fraction float64
+		want     bool
+	}{
+		{name: "Enabled", fraction: 1, want: true},
+		{name: "Disabled", fraction: 0, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &FlaggedFormat{
+				Flag:     "b3",
+				Flagger:  TraceFraction(tc.fraction),
+				Enabled:  &B3Format{},
+				Disabled: &HTTPFormat{},
+			}
+
+			// The request carries only B3 headers, as injected by a caller in
+			// the enabled cohort. No span exists while it is extracted.
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			(&B3Format{}).SpanContextToRequest(sc, r)
+			got, ok := f.SpanContextFromRequest(r)
+			if ok != tc.want {
+				t.Fatalf("f.SpanContextFromRequest(): want ok %t, got %t", tc.want, ok)
+			}
+			if ok && got != sc {
+				t.Errorf("f.SpanContextFromRequest(): want %+v, got %+v", sc, got)
+			}
+		})
+	}
+}
+
+func TestTraceFraction(t *testing.T) {
+	// The low 64 bits of this trace ID, shifted right by one, are 0.375 of the
+	// maximum value.
+	sc := trace.SpanContext{TraceID: trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 0x60}, SpanID: trace.SpanID{1}}
+	ctx, _ := trace.StartSpanWithRemoteParent(context.Background(), "test", sc)
+
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		fraction float64
+		want     bool
+	}{
+		{name: "Enabled", ctx: ctx, fraction: 0.4, want: true},
+		{name: "Disabled", ctx: ctx, fraction: 0.3, want: false},
+		{name: "NoSpan", ctx: context.Background(), fraction: 1, want: false},
+		{name: "Extracting", ctx: context.WithValue(context.Background(), flagTraceIDKey{}, sc.TraceID), fraction: 0.4, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := TraceFraction(tc.fraction).Enabled(tc.ctx, "flag"); got != tc.want {
+				t.Errorf("TraceFraction(%v).Enabled(): want %t, got %t", tc.fraction, tc.want, got)
+			}
+		})
+	}
+}
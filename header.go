@@ -0,0 +1,55 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// isContextHeader returns true if the supplied header name is that of a
+// linkerd context (l5d-ctx-*) header.
+func isContextHeader(k string) bool {
+	return strings.HasPrefix(strings.ToLower(k), l5dHeaderPrefix)
+}
+
+// withHeaderCopy returns a shallow copy of the supplied request with a copy of
+// its headers that may be safely modified.
+func withHeaderCopy(r *http.Request) *http.Request {
+	out := r.WithContext(r.Context())
+	out.Header = make(http.Header, len(r.Header))
+	for k, vs := range r.Header {
+		out.Header[k] = vs
+	}
+	return out
+}
+
+// headerValue returns the first value of the named header. Unlike
+// http.Header's Get method it tolerates header maps with non-canonical keys,
+// such as the lowercase keys produced by some HTTP/2 proxies and frameworks
+// that populate header maps directly.
+func headerValue(h http.Header, k string) string {
+	if v := h.Get(k); v != "" {
+		return v
+	}
+	for hk, vs := range h {
+		if len(vs) > 0 && strings.EqualFold(hk, k) {
+			return vs[0]
+		}
+	}
+	return ""
+}
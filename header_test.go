@@ -0,0 +1,76 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaderValue(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{
+			name:   "Canonical",
+			header: http.Header{"L5d-Ctx-Trace": []string{"canonical"}},
+			want:   "canonical",
+		},
+		{
+			name:   "Lowercase",
+			header: http.Header{"l5d-ctx-trace": []string{"lowercase"}},
+			want:   "lowercase",
+		},
+		{
+			name:   "CanonicalPreferred",
+			header: http.Header{"l5d-ctx-trace": []string{"lowercase"}, "L5d-Ctx-Trace": []string{"canonical"}},
+			want:   "canonical",
+		},
+		{
+			name:   "Missing",
+			header: http.Header{"l5d-ctx-dtab": []string{"dtab"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := headerValue(tc.header, l5dHeaderTrace); got != tc.want {
+				t.Errorf("headerValue(): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSpanContextFromNonCanonicalHeader(t *testing.T) {
+	const h = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	r.Header = http.Header{"l5d-ctx-trace": []string{h}, "l5d-sample": []string{"1"}}
+
+	f := &HTTPFormat{}
+	sc, ok := f.SpanContextFromRequest(r)
+	if !ok {
+		t.Fatalf("f.SpanContextFromRequest(): want ok, got invalid span context")
+	}
+	if !sc.IsSampled() {
+		t.Errorf("f.SpanContextFromRequest(): want sampled span context")
+	}
+	if reason, _ := f.SamplingReason(r); reason != SampledL5dSample {
+		t.Errorf("f.SamplingReason(): want %q, got %q", SampledL5dSample, reason)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.opencensus.io/stats"
+)
+
+// A DropPolicy determines which linkerd context headers are dropped from a
+// request that exceeds its header size limit.
+type DropPolicy int
+
+// Drop policies.
+const (
+	// DropLargest drops context headers largest first until the request is
+	// within its limit. The l5d-ctx-trace header is always dropped last.
+	DropLargest DropPolicy = iota
+
+	// DropAll drops every context header from a request that exceeds its
+	// limit.
+	DropAll
+)
+
+// HeaderLimitTransport is an http.RoundTripper that caps the total size of the
+// linkerd context (l5d-ctx-*) headers sent with each request. Proxies along the
+// request path commonly reject requests with oversized headers; it's better to
+// lose some context than the request itself. Each dropped header is recorded
+// to the DroppedHeaders measure.
+//
+// HeaderLimitTransport should wrap the transport that actually sends requests,
+// i.e. it should be the Base of an ochttp.Transport, so that it sees headers
+// injected by all other middleware.
+type HeaderLimitTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// MaxBytes is the maximum total size in bytes of all linkerd context
+	// headers. The size of a header is the length of its name plus the length
+	// of its value, for each of its values. Requests are not limited if
+	// MaxBytes is zero.
+	MaxBytes int
+
+	// Policy determines which headers are dropped from a request that exceeds
+	// MaxBytes.
+	Policy DropPolicy
+}
+
+// RoundTrip sends the supplied request, dropping linkerd context headers if
+// necessary to satisfy the configured size limit.
+func (t *HeaderLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.MaxBytes > 0 {
+		r = t.limit(r)
+	}
+	return t.base().RoundTrip(r)
+}
+
+func (t *HeaderLimitTransport) limit(r *http.Request) *http.Request {
+	total := 0
+	size := map[string]int{}
+	for k, vs := range r.Header {
+		if !isContextHeader(k) {
+			continue
+		}
+		for _, v := range vs {
+			size[k] += len(k) + len(v)
+		}
+		total += size[k]
+	}
+	if total <= t.MaxBytes {
+		return r
+	}
+
+	drop := t.drop(size, total)
+
+	// RoundTrippers must not modify the request they're given.
+	out := withHeaderCopy(r)
+	for _, k := range drop {
+		delete(out.Header, k)
+	}
+	stats.Record(r.Context(), DroppedHeaders.M(int64(len(drop))))
+	return out
+}
+
+func (t *HeaderLimitTransport) drop(size map[string]int, total int) []string {
+	keys := make([]string, 0, len(size))
+	for k := range size {
+		keys = append(keys, k)
+	}
+	if t.Policy == DropAll {
+		return keys
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		// The trace header sorts after all other headers.
+		ti, tj := strings.EqualFold(keys[i], l5dHeaderTrace), strings.EqualFold(keys[j], l5dHeaderTrace)
+		if ti != tj {
+			return tj
+		}
+		if size[keys[i]] != size[keys[j]] {
+			return size[keys[i]] > size[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for i, k := range keys {
+		if total <= t.MaxBytes {
+			return keys[:i]
+		}
+		total -= size[k]
+	}
+	return keys
+}
+
+func (t *HeaderLimitTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *HeaderLimitTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
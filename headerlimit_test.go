@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type recordingTransport struct {
+	r *http.Request
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.r = r
+	return &http.Response{StatusCode: http.StatusOK, Request: r}, nil
+}
+
+func TestHeaderLimitTransport(t *testing.T) {
+	// The context headers below total 98 bytes.
+	headers := map[string]string{
+		"l5d-ctx-trace":   "aaaaaaaaaaaaaaa",           // 28 bytes.
+		"l5d-ctx-dtab-aa": "bbbbbbbbbbbbbbb",           // 30 bytes.
+		"l5d-ctx-dtab-bb": "ccccccccccccccccccccccccc", // 40 bytes.
+		"X-Unrelated":     "dddddddddddddddddddddddddddddddddddddddddd",
+	}
+
+	cases := []struct {
+		name   string
+		max    int
+		policy DropPolicy
+		want   []string
+	}{
+		{
+			name: "Unlimited",
+			want: []string{"L5d-Ctx-Dtab-Aa", "L5d-Ctx-Dtab-Bb", "L5d-Ctx-Trace", "X-Unrelated"},
+		},
+		{
+			name: "WithinLimit",
+			max:  98,
+			want: []string{"L5d-Ctx-Dtab-Aa", "L5d-Ctx-Dtab-Bb", "L5d-Ctx-Trace", "X-Unrelated"},
+		},
+		{
+			name: "DropLargest",
+			max:  90,
+			want: []string{"L5d-Ctx-Dtab-Aa", "L5d-Ctx-Trace", "X-Unrelated"},
+		},
+		{
+			name: "DropLargestKeepsTrace",
+			max:  30,
+			want: []string{"L5d-Ctx-Trace", "X-Unrelated"},
+		},
+		{
+			name: "DropLargestDropsTrace",
+			max:  10,
+			want: []string{"X-Unrelated"},
+		},
+		{
+			name:   "DropAll",
+			max:    90,
+			policy: DropAll,
+			want:   []string{"X-Unrelated"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &recordingTransport{}
+			lt := &HeaderLimitTransport{Base: rt, MaxBytes: tc.max, Policy: tc.policy}
+
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			for k, v := range headers {
+				r.Header.Set(k, v)
+			}
+			if _, err := lt.RoundTrip(r); err != nil {
+				t.Fatalf("lt.RoundTrip(): %v", err)
+			}
+
+			got := make([]string, 0, len(rt.r.Header))
+			for k := range rt.r.Header {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("lt.RoundTrip(): want headers %v, got %v", tc.want, got)
+			}
+			if len(r.Header) != len(headers) {
+				t.Errorf("lt.RoundTrip(): modified the original request headers: %v", r.Header)
+			}
+		})
+	}
+}
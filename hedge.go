@@ -0,0 +1,231 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// HedgeTransport is an http.RoundTripper that mitigates tail latency by sending
+// backup (hedged) requests when a request takes longer than a threshold to
+// complete. The first successful response wins; all other attempts are
+// cancelled. Each attempt is represented by a client span, and these spans are
+// siblings; children of the span in the request's context. Each attempt's span
+// context is injected into its request.
+//
+// HedgeTransport starts its own client spans, so its Base should not be an
+// ochttp.Transport. Requests with a body are only hedged if their GetBody
+// function is set.
+type HedgeTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Propagation defines how traces are propagated. &HTTPFormat{} is used if
+	// Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// Delay is how long to wait for an attempt to complete before sending a
+	// hedged request. Requests are not hedged if Delay is zero.
+	Delay time.Duration
+
+	// MaxHedges is the maximum number of hedged requests sent in addition to
+	// the original request. One hedged request is sent if MaxHedges is zero.
+	MaxHedges int
+}
+
+type hedgeResult struct {
+	i   int
+	rsp *http.Response
+	err error
+}
+
+// A hedge tracks the attempts of a single hedged request.
+type hedge struct {
+	mu      sync.Mutex
+	winner  int
+	cancels []context.CancelFunc
+	results chan hedgeResult
+}
+
+// RoundTrip sends the supplied request, hedging it if it takes too long.
+func (t *HedgeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	max := 1 + t.MaxHedges
+	if t.MaxHedges < 1 {
+		max = 2
+	}
+	if t.Delay <= 0 || (r.Body != nil && r.Body != http.NoBody && r.GetBody == nil) {
+		max = 1
+	}
+
+	h := &hedge{winner: -1, results: make(chan hedgeResult, max)}
+	t.attempt(h, r, 0)
+	sent, failed := 1, 0
+
+	timer := time.NewTimer(t.Delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if sent < max {
+				t.attempt(h, r, sent)
+				sent++
+				timer.Reset(t.Delay)
+			}
+		case res := <-h.results:
+			if res.err == nil {
+				h.cancelLosers(res.i)
+				return res.rsp, nil
+			}
+			// Requests are hedged only when they're slow. We give up once
+			// all attempts sent so far have failed.
+			failed++
+			if failed == sent {
+				return nil, res.err
+			}
+		}
+	}
+}
+
+// attempt sends the nth attempt of the supplied request asynchronously.
+func (t *HedgeTransport) attempt(h *hedge, r *http.Request, n int) {
+	ctx, cancel := context.WithCancel(r.Context())
+	h.mu.Lock()
+	h.cancels = append(h.cancels, cancel)
+	h.mu.Unlock()
+
+	req := r.WithContext(ctx)
+	if n > 0 && r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			cancel()
+			h.results <- hedgeResult{i: n, err: err}
+			return
+		}
+		req.Body = body
+	}
+	req, span := startAttempt(req, t.propagation(), n)
+
+	go func() {
+		rsp, err := t.base().RoundTrip(req)
+		if err != nil {
+			if ctx.Err() == context.Canceled {
+				span.AddAttributes(trace.BoolAttribute(HedgeCancelledAttribute, true))
+			}
+			endAttempt(span, nil, err)
+			cancel()
+			h.results <- hedgeResult{i: n, err: err}
+			return
+		}
+
+		h.mu.Lock()
+		won := h.winner < 0
+		if won {
+			h.winner = n
+		}
+		h.mu.Unlock()
+
+		if !won {
+			// Another attempt already won the race.
+			rsp.Body.Close()
+			span.AddAttributes(trace.BoolAttribute(HedgeCancelledAttribute, true))
+			endAttempt(span, rsp, nil)
+			cancel()
+			return
+		}
+		rsp.Body = &spanBody{ReadCloser: rsp.Body, end: func() {
+			endAttempt(span, rsp, nil)
+			cancel()
+		}}
+		h.results <- hedgeResult{i: n, rsp: rsp}
+	}()
+}
+
+// cancelLosers cancels all attempts except the winner.
+func (h *hedge) cancelLosers(winner int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, cancel := range h.cancels {
+		if i != winner {
+			cancel()
+		}
+	}
+}
+
+func (t *HedgeTransport) propagation() propagation.HTTPFormat {
+	if t.Propagation == nil {
+		return &HTTPFormat{}
+	}
+	return t.Propagation
+}
+
+func (t *HedgeTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// startAttempt starts a client span representing the nth attempt to send the
+// supplied request, and returns a copy of the request with the span in its
+// context and its span context injected.
+func startAttempt(r *http.Request, f propagation.HTTPFormat, n int) (*http.Request, *trace.Span) {
+	ctx, span := trace.StartSpan(r.Context(), r.URL.Path, trace.WithSpanKind(trace.SpanKindClient))
+	span.AddAttributes(
+		trace.StringAttribute(ochttp.MethodAttribute, r.Method),
+		trace.StringAttribute(ochttp.URLAttribute, r.URL.String()),
+		trace.Int64Attribute(AttemptAttribute, int64(n)),
+	)
+	out := withHeaderCopy(r.WithContext(ctx))
+	f.SpanContextToRequest(span.SpanContext(), out)
+	return out, span
+}
+
+// endAttempt ends the supplied attempt span, recording the outcome of the
+// attempt.
+func endAttempt(span *trace.Span, rsp *http.Response, err error) {
+	switch {
+	case err != nil:
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	case rsp != nil:
+		span.AddAttributes(trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(rsp.StatusCode)))
+		span.SetStatus(ochttp.TraceStatus(rsp.StatusCode, rsp.Status))
+	}
+	span.End()
+}
+
+// spanBody is a response body that ends a span when it is closed.
+type spanBody struct {
+	io.ReadCloser
+	once sync.Once
+	end  func()
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.end)
+	return err
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// A scriptedTransport responds to the nth request it receives per the nth
+// function in its script.
+type scriptedTransport struct {
+	mu     sync.Mutex
+	script []func(r *http.Request) (*http.Response, error)
+	reqs   []*http.Request
+}
+
+func (t *scriptedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	n := len(t.reqs)
+	t.reqs = append(t.reqs, r)
+	t.mu.Unlock()
+	return t.script[n](r)
+}
+
+func (t *scriptedTransport) requests() []*http.Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*http.Request(nil), t.reqs...)
+}
+
+func respond(code int) func(r *http.Request) (*http.Response, error) {
+	return func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: code, Body: ioutil.NopCloser(strings.NewReader("")), Request: r}, nil
+	}
+}
+
+func hang(r *http.Request) (*http.Response, error) {
+	<-r.Context().Done()
+	return nil, r.Context().Err()
+}
+
+func fail(r *http.Request) (*http.Response, error) {
+	return nil, errors.New("boom")
+}
+
+func TestHedgeTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		delay    time.Duration
+		script   []func(r *http.Request) (*http.Response, error)
+		wantErr  bool
+		attempts int
+	}{
+		{
+			name:     "NotHedged",
+			delay:    time.Hour,
+			script:   []func(r *http.Request) (*http.Response, error){respond(http.StatusOK)},
+			attempts: 1,
+		},
+		{
+			name:     "Hedged",
+			delay:    time.Millisecond,
+			script:   []func(r *http.Request) (*http.Response, error){hang, respond(http.StatusOK)},
+			attempts: 2,
+		},
+		{
+			name:     "Failed",
+			delay:    time.Hour,
+			script:   []func(r *http.Request) (*http.Response, error){fail},
+			wantErr:  true,
+			attempts: 1,
+		},
+		{
+			name:     "Disabled",
+			script:   []func(r *http.Request) (*http.Response, error){respond(http.StatusOK)},
+			attempts: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			st := &scriptedTransport{script: tc.script}
+			ht := &HedgeTransport{Base: st, Delay: tc.delay}
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+			r, _ := http.NewRequest("GET", "http://example.org/hedge", nil)
+			rsp, err := ht.RoundTrip(r.WithContext(ctx))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ht.RoundTrip(): want error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("ht.RoundTrip(): %v", err)
+				}
+				rsp.Body.Close()
+			}
+			parent.End()
+
+			reqs := st.requests()
+			if len(reqs) != tc.attempts {
+				t.Fatalf("ht.RoundTrip(): want %d attempts, got %d", tc.attempts, len(reqs))
+			}
+			for i, req := range reqs {
+				if tc.wantErr && req.Context().Err() == nil {
+					t.Errorf("attempt %d: want context cancelled after all attempts failed", i)
+				}
+			}
+
+			// Wait for any cancelled attempts to end their spans.
+			deadline := time.Now().Add(time.Second)
+			for len(e.Spans()) < tc.attempts+1 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			spans := map[trace.SpanID]*trace.SpanData{}
+			for _, s := range e.Spans() {
+				spans[s.SpanID] = s
+			}
+			for i, req := range reqs {
+				id, ok := TraceIDFromRequest(req)
+				if !ok {
+					t.Fatalf("attempt %d: want valid %s header", i, l5dHeaderTrace)
+				}
+				s, ok := spans[id.Span]
+				if !ok {
+					t.Fatalf("attempt %d: want exported span %x", i, id.Span)
+				}
+				if s.ParentSpanID != parent.SpanContext().SpanID {
+					t.Errorf("attempt %d: want child of %v, got parent %v", i, parent.SpanContext().SpanID, s.ParentSpanID)
+				}
+				if got := s.Attributes[AttemptAttribute]; got != int64(i) {
+					t.Errorf("attempt %d: want %s %d, got %v", i, AttemptAttribute, i, got)
+				}
+			}
+			if tc.attempts > 1 {
+				first, _ := TraceIDFromRequest(reqs[0])
+				if got := spans[first.Span].Attributes[HedgeCancelledAttribute]; got != true {
+					t.Errorf("attempt 0: want %s true, got %v", HedgeCancelledAttribute, got)
+				}
+			}
+		})
+	}
+}
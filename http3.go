@@ -0,0 +1,71 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"io"
+	"net/http"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A ClosingRoundTripper is an http.RoundTripper that holds connections that
+// must be released by calling Close. quic-go's http3.RoundTripper is a
+// ClosingRoundTripper.
+type ClosingRoundTripper interface {
+	http.RoundTripper
+	io.Closer
+}
+
+// HTTP3Transport is an http.RoundTripper that starts a client span for each
+// request it sends over HTTP/3 and injects the span's context into the
+// request, just as an ochttp.Transport does over HTTP/1 and HTTP/2. Unlike an
+// ochttp.Transport it may be closed, releasing the QUIC connections of its
+// base RoundTripper. It does not depend on quic-go; use it to wrap quic-go's
+// http3.RoundTripper:
+//
+//  t := &linkin.HTTP3Transport{Base: &http3.RoundTripper{}}
+//  defer t.Close()
+//  client := &http.Client{Transport: t}
+type HTTP3Transport struct {
+	// Base is the HTTP/3 RoundTripper used to send requests. It is required.
+	Base ClosingRoundTripper
+
+	// Propagation defines how traces are propagated. &HTTPFormat{} is used if
+	// Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each request.
+	StartOptions trace.StartOptions
+}
+
+// RoundTrip starts a client span, injects its context into the supplied
+// request, then sends the request.
+func (t *HTTP3Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f := t.Propagation
+	if f == nil {
+		f = &HTTPFormat{}
+	}
+	return (&ochttp.Transport{Base: t.Base, Propagation: f, StartOptions: t.StartOptions}).RoundTrip(r)
+}
+
+// Close closes the base RoundTripper, releasing its connections.
+func (t *HTTP3Transport) Close() error {
+	return t.Base.Close()
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type closingTransport struct {
+	r      *http.Request
+	closed bool
+}
+
+func (t *closingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.r = r
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+}
+
+func (t *closingTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestHTTP3Transport(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	ct := &closingTransport{}
+	tr := &HTTP3Transport{Base: ct, StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}}
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	r, _ := http.NewRequest("GET", "https://example.org", nil)
+	rsp, err := (&http.Client{Transport: tr}).Do(r.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("client.Do(): %v", err)
+	}
+	rsp.Body.Close()
+	parent.End()
+
+	id, err := ParseTraceID(ct.r.Header.Get(l5dHeaderTrace))
+	if err != nil {
+		t.Fatalf("ParseTraceID(): %v", err)
+	}
+	if id.Trace != parent.SpanContext().TraceID {
+		t.Errorf("tr.RoundTrip(): want trace ID %v, got %v", parent.SpanContext().TraceID, id.Trace)
+	}
+	if id.Span == parent.SpanContext().SpanID {
+		t.Errorf("tr.RoundTrip(): want client span ID, got parent span ID %v", id.Span)
+	}
+	if len(e.Spans()) != 2 {
+		t.Errorf("tr.RoundTrip(): want 2 exported spans, got %d", len(e.Spans()))
+	}
+
+	if err := tr.Close(); err != nil {
+		t.Fatalf("tr.Close(): %v", err)
+	}
+	if !ct.closed {
+		t.Errorf("tr.Close(): want base closed")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package httpserver provides an HTTP server wired for linkerd request tracing,
+// so that services get a correctly traced server in a few lines:
+//
+//  s := &httpserver.Server{Addr: ":8080", Handler: mux, AccessLog: os.Stdout}
+//  err := s.ListenAndServe(ctx)
+//
+// Requests are traced by an ochttp.Handler that propagates linkerd trace
+// headers, panics are recovered, and the server shuts down gracefully when its
+// context is cancelled.
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+)
+
+// DefaultShutdownTimeout is the default time allowed for in-flight requests to
+// complete when a Server shuts down.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// A Server is a traced HTTP server.
+type Server struct {
+	// Addr is the TCP address on which to listen, e.g. ":8080".
+	Addr string
+
+	// Handler is the handler used to handle incoming requests.
+	Handler http.Handler
+
+	// Stack configures trace propagation. Requests are traced by an
+	// ochttp.Handler that propagates linkerd trace headers if Stack is nil.
+	Stack *linkin.Stack
+
+	// AccessLog is the writer to which access logs are written. Access logs
+	// are not written if AccessLog is nil. See linkin.AccessLog.
+	AccessLog io.Writer
+
+	// ShutdownTimeout is the time allowed for in-flight requests to complete
+	// when the server shuts down. DefaultShutdownTimeout is used if
+	// ShutdownTimeout is zero.
+	ShutdownTimeout time.Duration
+}
+
+// Traced returns the server's handler wrapped in tracing, access logging, and
+// panic recovery middleware.
+func (s *Server) Traced() http.Handler {
+	return linkin.Chain{Stack: s.Stack, AccessLog: s.AccessLog, Recover: true}.Then(s.Handler)
+}
+
+// ListenAndServe listens on the server's address and serves requests until the
+// supplied context is cancelled, at which point it shuts down gracefully. It
+// returns nil if the server shut down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", s.Addr, err)
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves requests accepted by the supplied listener until the supplied
+// context is cancelled, at which point it shuts down gracefully. It returns nil
+// if the server shut down gracefully.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{Handler: s.Traced()}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("cannot serve: %v", err)
+	case <-ctx.Done():
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return fmt.Errorf("cannot shut down gracefully: %v", err)
+	}
+	return nil
+}
+
+// RegisterViews registers the default ochttp server and client views, and the
+// default views of package linkin.
+func RegisterViews() error {
+	views := append([]*view.View{}, ochttp.DefaultServerViews...)
+	views = append(views, ochttp.DefaultClientViews...)
+	views = append(views, linkin.DefaultViews...)
+	if err := view.Register(views...); err != nil {
+		return fmt.Errorf("cannot register views: %v", err)
+	}
+	return nil
+}
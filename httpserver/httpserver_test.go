@@ -0,0 +1,155 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+)
+
+// A sampled l5d-ctx-trace header with trace ID 32a4db20f5d592e7.
+const l5dTrace = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestTraced(t *testing.T) {
+	cases := []struct {
+		name   string
+		stack  func(t *testing.T) *linkin.Stack
+		header string
+	}{
+		{
+			name:   "Default",
+			stack:  func(_ *testing.T) *linkin.Stack { return nil },
+			header: "l5d-ctx-trace",
+		},
+		{
+			name: "Stack",
+			stack: func(t *testing.T) *linkin.Stack {
+				s, err := linkin.Config{HeaderPrefix: "acme-ctx-"}.Build()
+				if err != nil {
+					t.Fatalf("c.Build(): %v", err)
+				}
+				return s
+			},
+			header: "acme-ctx-trace",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got trace.SpanContext
+			log := &syncBuffer{}
+			s := &Server{
+				Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+					got = trace.FromContext(r.Context()).SpanContext()
+					if r.URL.Path == "/panic" {
+						panic("boom")
+					}
+				}),
+				Stack:     tc.stack(t),
+				AccessLog: log,
+			}
+			h := s.Traced()
+
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			r.Header.Set(tc.header, l5dTrace)
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if got.TraceID.String() != "000000000000000032a4db20f5d592e7" {
+				t.Errorf("s.Traced(): want propagated trace ID, got %v", got.TraceID)
+			}
+			if !strings.Contains(log.String(), "32a4db20f5d592e7") {
+				t.Errorf("s.Traced(): want access log with trace ID, got %q", log.String())
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.org/panic", nil))
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("s.Traced(): want status %d after panic, got %d", http.StatusInternalServerError, w.Code)
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(): %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := &Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		ShutdownTimeout: 5 * time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, l) }()
+
+	// An in-flight request should complete despite the server shutting down.
+	rsp := make(chan *http.Response, 1)
+	go func() {
+		r, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			t.Errorf("http.Get(): %v", err)
+		}
+		rsp <- r
+	}()
+	<-started
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if r := <-rsp; r != nil && r.StatusCode != http.StatusNoContent {
+		t.Errorf("http.Get(): want status %d, got %d", http.StatusNoContent, r.StatusCode)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("s.Serve(): %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"crypto/sha256"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+// DefaultIdempotencyKeyHeader is the request header from which idempotency
+// keys are read when no header is configured.
+const DefaultIdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyHandler is an http.Handler that correlates retried deliveries of
+// the same request, as identified by their idempotency keys, across traces. It
+// records each request's idempotency key as an http.idempotency_key attribute
+// of the span in its context. IdempotencyHandler must be wrapped by an
+// ochttp.Handler. Use DeriveFromIdempotencyKey to also place retried
+// deliveries in the same trace.
+type IdempotencyHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Header is the request header from which idempotency keys are read. The
+	// DefaultIdempotencyKeyHeader is used if Header is empty.
+	Header string
+}
+
+// ServeHTTP annotates the request's span, then serves the request.
+func (h *IdempotencyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if key := r.Header.Get(idempotencyKeyHeader(h.Header)); key != "" {
+		if span := trace.FromContext(r.Context()); span != nil {
+			span.AddAttributes(trace.StringAttribute(IdempotencyKeyAttribute, key))
+		}
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// DeriveFromIdempotencyKey wraps the supplied handler, deriving the trace of
+// each request with an idempotency key (read from the supplied header, or the
+// DefaultIdempotencyKeyHeader if it is empty) and no valid l5d-ctx-trace
+// header from its key. The minted l5d-ctx-trace header's trace and span IDs
+// are a hash of the key, so every delivery of a retried request is handled by
+// a server span in the same trace, with the same parent span ID. The minted
+// header is unsampled, deferring the sampling decision to the ochttp.Handler's
+// sampler. DeriveFromIdempotencyKey must wrap an ochttp.Handler that uses
+// HTTPFormat.
+func DeriveFromIdempotencyKey(h http.Handler, header string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader(header))
+		if _, ok := TraceIDFromRequest(r); ok || key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		out := withHeaderCopy(r)
+		out.Header.Set(l5dHeaderTrace, traceIDFromIdempotencyKey(key).String())
+		h.ServeHTTP(w, out)
+	})
+}
+
+// traceIDFromIdempotencyKey derives a 64 bit trace ID and a span ID from the
+// supplied idempotency key.
+func traceIDFromIdempotencyKey(key string) TraceID {
+	sum := sha256.Sum256([]byte(key))
+	id := TraceID{}
+	copy(id.Trace[8:], sum[:8])
+	copy(id.Span[:], sum[8:16])
+	return id
+}
+
+func idempotencyKeyHeader(header string) string {
+	if header == "" {
+		return DefaultIdempotencyKeyHeader
+	}
+	return header
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestIdempotencyHandler(t *testing.T) {
+	propagated := trace.SpanContext{TraceID: trace.TraceID{8: 1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+	derived := traceIDFromIdempotencyKey("8e03978e-40d5-43e8-bc93-6894a57f9324")
+
+	cases := []struct {
+		name       string
+		header     string
+		key        string
+		trace      string
+		wantTrace  trace.TraceID
+		wantParent trace.SpanID
+		wantAttr   bool
+	}{
+		{
+			name:       "Derived",
+			key:        "8e03978e-40d5-43e8-bc93-6894a57f9324",
+			wantTrace:  derived.Trace,
+			wantParent: derived.Span,
+			wantAttr:   true,
+		},
+		{
+			name:       "CustomHeader",
+			header:     "X-Idempotency-Key",
+			key:        "8e03978e-40d5-43e8-bc93-6894a57f9324",
+			wantTrace:  derived.Trace,
+			wantParent: derived.Span,
+			wantAttr:   true,
+		},
+		{
+			name:       "Propagated",
+			key:        "8e03978e-40d5-43e8-bc93-6894a57f9324",
+			trace:      encode(propagated),
+			wantTrace:  propagated.TraceID,
+			wantParent: propagated.SpanID,
+			wantAttr:   true,
+		},
+		{
+			name: "NoKey",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			h := DeriveFromIdempotencyKey(&ochttp.Handler{
+				Handler:      &IdempotencyHandler{Handler: http.NotFoundHandler(), Header: tc.header},
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+			}, tc.header)
+
+			// Deliver the request twice, as a retrying client would.
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest("POST", "http://example.org", nil)
+				if tc.key != "" {
+					r.Header.Set(idempotencyKeyHeader(tc.header), tc.key)
+				}
+				if tc.trace != "" {
+					r.Header.Set(l5dHeaderTrace, tc.trace)
+				}
+				h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+
+			spans := e.Spans()
+			if len(spans) != 2 {
+				t.Fatalf("h.ServeHTTP(): want 2 exported spans, got %d", len(spans))
+			}
+			for _, s := range spans {
+				if _, ok := s.Attributes[IdempotencyKeyAttribute]; ok != tc.wantAttr {
+					t.Errorf("h.ServeHTTP(): want attribute %s %t, got %v", IdempotencyKeyAttribute, tc.wantAttr, s.Attributes)
+				}
+				if tc.key == "" {
+					continue
+				}
+				if s.TraceID != tc.wantTrace || s.ParentSpanID != tc.wantParent {
+					t.Errorf("h.ServeHTTP(): want span in trace %v with parent %v, got trace %v with parent %v", tc.wantTrace, tc.wantParent, s.TraceID, s.ParentSpanID)
+				}
+			}
+			if tc.key == "" && spans[0].TraceID == spans[1].TraceID {
+				t.Errorf("h.ServeHTTP(): want deliveries without a key in distinct traces, got %v", spans[0].TraceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+
+	"go.opencensus.io/trace"
+)
+
+// An IDGenerator generates trace and span IDs. A custom IDGenerator may be
+// used to embed information such as shard or region bits in the IDs of span
+// contexts minted by this package. Implementations must be safe for concurrent
+// use. The OpenCensus trace package's internal IDGenerator interface is
+// identical, so implementations may be shared with trace.ApplyConfig.
+type IDGenerator interface {
+	NewTraceID() [16]byte
+	NewSpanID() [8]byte
+}
+
+// randomIDs generates random trace and span IDs.
+type randomIDs struct {
+	once sync.Once
+	mu   sync.Mutex
+	r    *rand.Rand
+}
+
+var defaultIDs = &randomIDs{}
+
+func (g *randomIDs) init() {
+	g.once.Do(func() {
+		var seed int64
+		if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+			panic(err)
+		}
+		g.r = rand.New(rand.NewSource(seed))
+	})
+}
+
+func (g *randomIDs) NewTraceID() [16]byte {
+	g.init()
+	id := [16]byte{}
+	g.mu.Lock()
+	g.r.Read(id[:])
+	g.mu.Unlock()
+	return id
+}
+
+func (g *randomIDs) NewSpanID() [8]byte {
+	g.init()
+	id := [8]byte{}
+	g.mu.Lock()
+	g.r.Read(id[:])
+	g.mu.Unlock()
+	return id
+}
+
+// newRootTraceID mints a 64 bit trace ID, per Finagle's convention, using the
+// supplied generator. The high 64 bits of the generated trace ID are zeroed.
+func newRootTraceID(g IDGenerator) trace.TraceID {
+	id := trace.TraceID(g.NewTraceID())
+	copy(id[:8], make([]byte, 8))
+	return id
+}
+
+// newSpanContext mints a new root span context using the supplied generator, or
+// random IDs if the generator is nil. The context is sampled if the supplied
+// sampler chooses to sample it.
+func newSpanContext(g IDGenerator, s trace.Sampler) trace.SpanContext {
+	if g == nil {
+		g = defaultIDs
+	}
+	sc := trace.SpanContext{TraceID: g.NewTraceID(), SpanID: g.NewSpanID()}
+	if s != nil && s(trace.SamplingParameters{TraceID: sc.TraceID, SpanID: sc.SpanID}).Sample {
+		sc.TraceOptions = ocShouldSample
+	}
+	return sc
+}
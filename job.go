@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// A Job is a periodic task, such as a cron job, that is traced in a new root
+// span each time it runs. Like those started by RootHandler, root spans follow
+// Finagle's convention of using 64 bit trace IDs, ensuring the traces they
+// start may be joined by linkerd. HTTP calls made with the context supplied to
+// the job's Func via a Stack's Transport, or any ochttp.Transport using this
+// package's propagation, carry the root span's context.
+//
+// As with RootHandler, only the root span's trace ID follows Finagle's
+// conventions; its span ID is assigned by OpenCensus, and is not the low 64
+// bits of its trace ID.
+//
+// Job satisfies the Job interface of github.com/robfig/cron, so it may be
+// scheduled directly:
+//
+//  c := cron.New()
+//  c.AddJob("@hourly", &linkin.Job{Name: "reindex", Func: reindex})
+type Job struct {
+	// Name is the name of the root span started for each run.
+	Name string
+
+	// Func is the work done by each run.
+	Func func(ctx context.Context) error
+
+	// Context is the context from which each run's context is derived. Any
+	// span in Context is ignored. context.Background() is used if Context is
+	// nil.
+	Context context.Context
+
+	// Timeout is the deadline for each run. Runs have no deadline if Timeout
+	// is zero.
+	Timeout time.Duration
+
+	// Sampler decides whether root spans are sampled. The default sampler
+	// configured via trace.ApplyConfig is used if Sampler is nil. Note that
+	// trace.ProbabilitySampler, the OpenCensus default, considers only the
+	// high 64 bits of the trace ID. These are zero for root spans, so it
+	// samples every root span; use TraceIDSampler to sample a fraction of
+	// them.
+	Sampler trace.Sampler
+
+	// IDGenerator generates root trace IDs, of which only the low 64 bits are
+	// used. Random IDs are generated if IDGenerator is nil.
+	IDGenerator IDGenerator
+
+	// ErrorHandler is called with any error returned by Func when the job is
+	// run via Run or Every. Errors are ignored if ErrorHandler is nil.
+	ErrorHandler func(error)
+}
+
+// Run runs the job once, in a new root span.
+func (j *Job) Run() {
+	ctx := j.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := j.RunContext(ctx); err != nil && j.ErrorHandler != nil {
+		j.ErrorHandler(err)
+	}
+}
+
+// RunContext runs the job once with the supplied context, in a new root span.
+// Any span in the supplied context is ignored.
+func (j *Job) RunContext(ctx context.Context) error {
+	g := j.IDGenerator
+	if g == nil {
+		g = defaultIDs
+	}
+
+	// Detach from any span in the supplied context.
+	ctx = trace.NewContext(ctx, nil)
+	ctx, span := startRootSpan(ctx, j.Name, g, j.Sampler, trace.SpanKindUnspecified)
+	defer span.End()
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	err := j.Func(ctx)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeDeadlineExceeded, Message: ctx.Err().Error()})
+	}
+	return err
+}
+
+// Every runs the job each time the supplied interval elapses, until the
+// supplied context is done. Runs do not overlap; a run that takes longer than
+// the interval delays the next. Every returns the context's error.
+func (j *Job) Every(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if err := j.RunContext(ctx); err != nil && j.ErrorHandler != nil {
+				j.ErrorHandler(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestJob(t *testing.T) {
+	parentCtx, parent := trace.StartSpan(context.Background(), "parent")
+	defer parent.End()
+
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		timeout  time.Duration
+		err      error
+		wantCode int32
+	}{
+		{name: "Success", ctx: context.Background(), wantCode: trace.StatusCodeOK},
+		{name: "IgnoresParent", ctx: parentCtx, wantCode: trace.StatusCodeOK},
+		{name: "Error", ctx: context.Background(), err: errors.New("boom"), wantCode: trace.StatusCodeUnknown},
+		{name: "Timeout", ctx: context.Background(), timeout: time.Millisecond, wantCode: trace.StatusCodeDeadlineExceeded},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			rt := &recordingTransport{}
+			client := &http.Client{Transport: &ochttp.Transport{Base: rt, Propagation: &HTTPFormat{}}}
+
+			var errs []error
+			j := &Job{
+				Name:    "reindex",
+				Context: tc.ctx,
+				Timeout: tc.timeout,
+				Sampler: trace.AlwaysSample(),
+				Func: func(ctx context.Context) error {
+					r, _ := http.NewRequest("GET", "http://example.org", nil)
+					if _, err := client.Do(r.WithContext(ctx)); err != nil {
+						t.Fatalf("client.Do(): %v", err)
+					}
+					if tc.timeout > 0 {
+						<-ctx.Done()
+					}
+					return tc.err
+				},
+				ErrorHandler: func(err error) { errs = append(errs, err) },
+			}
+			j.Run()
+
+			if tc.err != nil && (len(errs) != 1 || errs[0] != tc.err) {
+				t.Errorf("j.Run(): want error %v, got %v", tc.err, errs)
+			}
+
+			var root *trace.SpanData
+			for _, sd := range e.spans {
+				if sd.Name == "reindex" {
+					root = sd
+				}
+			}
+			if root == nil {
+				t.Fatalf("j.Run(): want root span, got %v", e.spans)
+			}
+			if root.ParentSpanID != (trace.SpanID{}) {
+				t.Errorf("j.Run(): want root span, got parent %v", root.ParentSpanID)
+			}
+			if root.TraceID == parent.SpanContext().TraceID {
+				t.Errorf("j.Run(): want new trace, got trace ID %v", root.TraceID)
+			}
+			if hi := root.TraceID[:8]; string(hi) != string(make([]byte, 8)) {
+				t.Errorf("j.Run(): want 64 bit trace ID, got %v", root.TraceID)
+			}
+			if root.Code != tc.wantCode {
+				t.Errorf("j.Run(): want status code %d, got %d", tc.wantCode, root.Code)
+			}
+
+			sc, ok := (&HTTPFormat{}).SpanContextFromRequest(rt.r)
+			if !ok {
+				t.Fatalf("j.Run(): want %s header, got %v", l5dHeaderTrace, rt.r.Header)
+			}
+			if sc.TraceID != root.TraceID {
+				t.Errorf("j.Run(): want propagated trace ID %v, got %v", root.TraceID, sc.TraceID)
+			}
+		})
+	}
+}
+
+func TestJobDefaultSampler(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	var sampled bool
+	j := &Job{Name: "reindex", Func: func(ctx context.Context) error {
+		sampled = trace.FromContext(ctx).SpanContext().IsSampled()
+		return nil
+	}}
+	j.Run()
+	if !sampled {
+		t.Errorf("j.Run(): want root span sampled by the default sampler")
+	}
+}
+
+func TestJobEvery(t *testing.T) {
+	var runs int32
+	j := &Job{Name: "tick", Func: func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := j.Every(ctx, 5*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("j.Every(): want %v, got %v", context.DeadlineExceeded, err)
+	}
+	if atomic.LoadInt32(&runs) == 0 {
+		t.Errorf("j.Every(): want runs, got none")
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5dasynq propagates linkerd span context through asynq background
+// tasks, so that tasks enqueued by traced handlers appear as descendants of
+// those handlers' spans in the same trace. asynq tasks have no headers, so span
+// context is carried in an envelope around the task's payload:
+//
+//  t := &l5dasynq.Tracer{}
+//  task, err := t.NewTask(r.Context(), "email:welcome", payload)
+//  ...
+//  mux := asynq.NewServeMux()
+//  mux.Use(t.Middleware)
+package l5dasynq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// envelopeMagic marks a task payload as an envelope, so that Middleware does
+// not mistake JSON payloads that happen to resemble an envelope for one.
+const envelopeMagic = "linkin/v1"
+
+// An envelope wraps a task's payload with span context.
+type envelope struct {
+	Magic   string            `json:"l5d-envelope"`
+	Context linkin.MapCarrier `json:"l5d-ctx"`
+	Payload []byte            `json:"payload"`
+}
+
+type resultWriterKey struct{}
+
+// ResultWriter returns the ResultWriter of the task being handled by
+// Middleware. Tasks unwrapped by Middleware have no ResultWriter of their own,
+// so handlers that write task results must use ResultWriter instead. It
+// returns nil if the context was not supplied by Middleware, or if the task
+// has no ResultWriter.
+func ResultWriter(ctx context.Context) *asynq.ResultWriter {
+	w, _ := ctx.Value(resultWriterKey{}).(*asynq.ResultWriter)
+	return w
+}
+
+// Tracer creates tasks that carry span context, and handles them in spans that
+// are children of that span context.
+type Tracer struct {
+	// Propagation defines how traces are propagated. &linkin.HTTPFormat{} is
+	// used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each task.
+	StartOptions trace.StartOptions
+}
+
+// NewTask returns a task with the supplied type name, payload, and options,
+// carrying the span context of the span in the supplied context, if any.
+//
+// NewTask changes the task's payload format if, and only if, the supplied
+// context has a span: the payload is then a JSON envelope wrapping the
+// supplied payload. Such tasks must be handled by a handler wrapped with
+// Middleware; any other handler sees the envelope rather than the supplied
+// payload. Without a span the task is identical to one returned by
+// asynq.NewTask, so a handler without Middleware may appear to work until the
+// task is enqueued with a span.
+func (t *Tracer) NewTask(ctx context.Context, typename string, payload []byte, opts ...asynq.Option) (*asynq.Task, error) {
+	span := trace.FromContext(ctx)
+	if span == nil {
+		return asynq.NewTask(typename, payload, opts...), nil
+	}
+	e := envelope{Magic: envelopeMagic, Context: linkin.MapCarrier{}, Payload: payload}
+	linkin.SpanContextToCarrier(ctx, t.Propagation, span.SpanContext(), e.Context)
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode task payload: %v", err)
+	}
+	return asynq.NewTask(typename, b, opts...), nil
+}
+
+// Middleware is an asynq.MiddlewareFunc that starts a span named Recv.<type>
+// for each task, as a child of the span context carried by the task, if any.
+// Tasks created by NewTask are unwrapped before they are handled; the
+// unwrapped task has the original payload but no ResultWriter, which handlers
+// may retrieve using ResultWriter. Other tasks are handled unmodified, in a new
+// root span.
+func (t *Tracer) Middleware(h asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		ctx = context.WithValue(ctx, resultWriterKey{}, task.ResultWriter())
+		c := linkin.MapCarrier{}
+		e := envelope{}
+		if err := json.Unmarshal(task.Payload(), &e); err == nil && e.Magic == envelopeMagic {
+			c = e.Context
+			task = asynq.NewTask(task.Type(), e.Payload)
+		}
+		consumer := &linkin.Consumer{
+			Propagation:  t.Propagation,
+			StartOptions: t.StartOptions,
+			Name:         "Recv." + task.Type(),
+		}
+		return consumer.Handle(ctx, c, func(ctx context.Context, _ linkin.Carrier) error {
+			return h.ProcessTask(ctx, task)
+		})
+	})
+}
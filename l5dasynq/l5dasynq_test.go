@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dasynq
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"go.opencensus.io/trace"
+)
+
+func TestTracer(t *testing.T) {
+	tr := &Tracer{StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}}
+	parentCtx, parent := trace.StartSpan(context.Background(), "handler", trace.WithSampler(trace.AlwaysSample()))
+	defer parent.End()
+
+	cases := []struct {
+		name        string
+		task        func() (*asynq.Task, error)
+		err         error
+		wantPayload string
+		wantParent  bool
+	}{
+		{
+			name:       "Traced",
+			task:       func() (*asynq.Task, error) { return tr.NewTask(parentCtx, "email:welcome", []byte("hi")) },
+			wantParent: true,
+		},
+		{
+			name: "Untraced",
+			task: func() (*asynq.Task, error) { return tr.NewTask(context.Background(), "email:welcome", []byte("hi")) },
+		},
+		{
+			name: "Plain",
+			task: func() (*asynq.Task, error) { return asynq.NewTask("email:welcome", []byte("hi")), nil },
+		},
+		{
+			name: "PlainEnvelopeLike",
+			task: func() (*asynq.Task, error) {
+				return asynq.NewTask("email:welcome", []byte(`{"l5d-ctx":{},"payload":"aGk="}`)), nil
+			},
+			wantPayload: `{"l5d-ctx":{},"payload":"aGk="}`,
+		},
+		{
+			name:       "Error",
+			task:       func() (*asynq.Task, error) { return tr.NewTask(parentCtx, "email:welcome", []byte("hi")) },
+			err:        errors.New("boom"),
+			wantParent: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			task, err := tc.task()
+			if err != nil {
+				t.Fatalf("tr.NewTask(): %v", err)
+			}
+
+			var got *trace.Span
+			var payload string
+			h := tr.Middleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+				got = trace.FromContext(ctx)
+				payload = string(task.Payload())
+				return tc.err
+			}))
+			if err := h.ProcessTask(context.Background(), task); err != tc.err {
+				t.Errorf("h.ProcessTask(): want error %v, got %v", tc.err, err)
+			}
+
+			wantPayload := tc.wantPayload
+			if wantPayload == "" {
+				wantPayload = "hi"
+			}
+			if payload != wantPayload {
+				t.Errorf("h.ProcessTask(): want payload %q, got %q", wantPayload, payload)
+			}
+			if got == nil {
+				t.Fatalf("h.ProcessTask(): want span, got nil")
+			}
+			if sameTrace := got.SpanContext().TraceID == parent.SpanContext().TraceID; sameTrace != tc.wantParent {
+				t.Errorf("h.ProcessTask(): want same trace %t, got trace ID %v", tc.wantParent, got.SpanContext().TraceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dgrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+const (
+	contentTypeGRPCWeb     = "application/grpc-web"
+	contentTypeGRPCWebText = "application/grpc-web-text"
+)
+
+const (
+	// grpcWebTrailer is set in the flags of a grpc-web trailer frame.
+	grpcWebTrailer byte = 1 << 7
+
+	// maxTrailerSize is the size of the largest trailer frame that is parsed.
+	maxTrailerSize = 64 << 10
+)
+
+// browserHeaders are the trace headers emitted by browser tracing libraries.
+var browserHeaders = []string{"traceparent", "tracestate", "b3", "X-B3-TraceId", "X-B3-SpanId", "X-B3-ParentSpanId", "X-B3-Sampled", "X-B3-Flags"}
+
+// WebBridge is an http.Handler that joins browser-initiated grpc-web RPCs to
+// mesh traces at a gateway that translates grpc-web to gRPC, such as
+// improbable-eng's grpcweb.WrappedGrpcServer. Browsers cannot emit l5d headers,
+// so WebBridge extracts span context from the headers emitted by browser
+// tracing libraries, starts a server span for the RPC, and replaces those
+// headers with the l5d headers of its span before the gateway translates them
+// to gRPC metadata. Backend services using an Interceptor will join the trace,
+// as will RPCs the gateway proxies using an Interceptor, which are children of
+// the span in the request's context.
+//
+// The span's status is set per the grpc-status of the gateway's response, which
+// is read from the trailer frame at the end of the response body, or from the
+// response headers of trailers-only responses.
+//
+// Requests that are not grpc-web requests are handled unmodified. Browsers may
+// only send trace headers that the gateway allows per CORS.
+type WebBridge struct {
+	// Handler is the gateway handler used to handle grpc-web requests.
+	Handler http.Handler
+
+	// Extract defines how span context is extracted from browser requests.
+	// The W3C trace context, B3, and l5d formats are tried in order if Extract
+	// is nil.
+	Extract propagation.HTTPFormat
+
+	// Propagation defines how span context is propagated to the gateway.
+	// &linkin.HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each RPC.
+	StartOptions trace.StartOptions
+}
+
+// ServeHTTP starts a span for the grpc-web request, if it is one, then serves
+// the request.
+func (b *WebBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeGRPCWeb) {
+		b.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, span := b.startSpan(r)
+	defer span.End()
+
+	out := r.Clone(ctx)
+	for _, k := range browserHeaders {
+		out.Header.Del(k)
+	}
+	b.propagation().SpanContextToRequest(span.SpanContext(), out)
+	tw := &trailerWriter{ResponseWriter: w, text: strings.HasPrefix(r.Header.Get("Content-Type"), contentTypeGRPCWebText)}
+	b.Handler.ServeHTTP(tw, out)
+
+	status, message := tw.status, tw.message
+	if status == "" {
+		// Trailers-only responses carry their gRPC status in headers.
+		status, message = w.Header().Get("Grpc-Status"), w.Header().Get("Grpc-Message")
+	}
+	if code, err := strconv.Atoi(status); err == nil {
+		span.SetStatus(trace.Status{Code: int32(code), Message: message})
+	}
+}
+
+// startSpan starts a span representing the supplied grpc-web RPC.
+func (b *WebBridge) startSpan(r *http.Request) (context.Context, *trace.Span) {
+	name := "Recv." + spanName(r.URL.Path)
+	if sc, ok := b.extract().SpanContextFromRequest(r); ok {
+		return trace.StartSpanWithRemoteParent(r.Context(), name, sc,
+			trace.WithSampler(b.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	}
+	return trace.StartSpan(r.Context(), name,
+		trace.WithSampler(b.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindServer))
+}
+
+func (b *WebBridge) extract() propagation.HTTPFormat {
+	if b.Extract == nil {
+		return &linkin.MultiFormat{Extract: []propagation.HTTPFormat{&tracecontext.HTTPFormat{}, &linkin.B3Format{}, &linkin.HTTPFormat{}}}
+	}
+	return b.Extract
+}
+
+func (b *WebBridge) propagation() propagation.HTTPFormat {
+	if b.Propagation == nil {
+		return &linkin.HTTPFormat{}
+	}
+	return b.Propagation
+}
+
+// trailerWriter is an http.ResponseWriter that records the gRPC status of the
+// trailer frame of a grpc-web response body. Message frames are not buffered.
+type trailerWriter struct {
+	http.ResponseWriter
+
+	// text is true if the body is base64 encoded, per grpc-web-text.
+	text bool
+
+	// encoded holds base64 encoded bytes of a grpc-web-text body that are yet
+	// to be decoded.
+	encoded []byte
+
+	// frame holds the bytes of the current frame's header and, for trailer
+	// frames, its payload.
+	frame []byte
+
+	// skip is the number of bytes of the current message frame's payload that
+	// are yet to be written.
+	skip int
+
+	// invalid is true if the body could not be parsed.
+	invalid bool
+
+	status  string
+	message string
+}
+
+func (w *trailerWriter) Write(b []byte) (int, error) {
+	w.observe(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trailerWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// observe parses the supplied body bytes.
+func (w *trailerWriter) observe(b []byte) {
+	if w.invalid {
+		return
+	}
+	if !w.text {
+		w.parse(b)
+		return
+	}
+	// Each write of a grpc-web-text body may be separately base64 encoded and
+	// padded, so the body is decoded four characters at a time.
+	w.encoded = append(w.encoded, b...)
+	n := len(w.encoded) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		d, err := base64.StdEncoding.DecodeString(string(w.encoded[i : i+4]))
+		if err != nil {
+			w.invalid = true
+			return
+		}
+		w.parse(d)
+	}
+	w.encoded = append(w.encoded[:0], w.encoded[n:]...)
+}
+
+// parse parses the supplied decoded body bytes, which continue the frame
+// parsed by the previous call.
+func (w *trailerWriter) parse(b []byte) {
+	for !w.invalid {
+		if w.skip > 0 {
+			n := w.skip
+			if n > len(b) {
+				n = len(b)
+			}
+			w.skip, b = w.skip-n, b[n:]
+			if w.skip > 0 {
+				return
+			}
+		}
+
+		// Each frame begins with a byte of flags and a four byte length.
+		if len(w.frame) < 5 {
+			n := 5 - len(w.frame)
+			if n > len(b) {
+				n = len(b)
+			}
+			w.frame, b = append(w.frame, b[:n]...), b[n:]
+			if len(w.frame) < 5 {
+				return
+			}
+			if w.frame[0]&grpcWebTrailer == 0 {
+				w.skip, w.frame = int(binary.BigEndian.Uint32(w.frame[1:5])), w.frame[:0]
+				continue
+			}
+		}
+
+		size := 5 + int(binary.BigEndian.Uint32(w.frame[1:5]))
+		if size > maxTrailerSize {
+			w.invalid = true
+			return
+		}
+		n := size - len(w.frame)
+		if n > len(b) {
+			n = len(b)
+		}
+		w.frame, b = append(w.frame, b[:n]...), b[n:]
+		if len(w.frame) < size {
+			return
+		}
+		w.parseTrailer(string(w.frame[5:]))
+		w.frame = w.frame[:0]
+	}
+}
+
+// parseTrailer parses the supplied trailer frame payload, which is formatted
+// as HTTP/1 headers.
+func (w *trailerWriter) parseTrailer(t string) {
+	for _, line := range strings.Split(t, "\r\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "grpc-status":
+			w.status = strings.TrimSpace(kv[1])
+		case "grpc-message":
+			w.message = strings.TrimSpace(kv[1])
+		}
+	}
+}
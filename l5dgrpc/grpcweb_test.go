@@ -0,0 +1,169 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dgrpc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+)
+
+func TestWebBridge(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		header      map[string]string
+		status      string
+		wantTrace   string
+		wantBridged bool
+		wantStatus  int32
+	}{
+		{
+			name:        "TraceContext",
+			contentType: "application/grpc-web+proto",
+			header:      map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantTrace:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantBridged: true,
+		},
+		{
+			name:        "B3",
+			contentType: "application/grpc-web-text",
+			header:      map[string]string{"X-B3-TraceId": "463ac35c9f6413ad", "X-B3-SpanId": "f4141d5dc0c935d0", "X-B3-Sampled": "1"},
+			wantTrace:   "0000000000000000463ac35c9f6413ad",
+			wantBridged: true,
+		},
+		{
+			name:        "NoContext",
+			contentType: "application/grpc-web+proto",
+			wantBridged: true,
+		},
+		{
+			name:        "StatusInHeaders",
+			contentType: "application/grpc-web+proto",
+			header:      map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			status:      "headers",
+			wantTrace:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantBridged: true,
+			wantStatus:  5,
+		},
+		{
+			name:        "StatusInTrailer",
+			contentType: "application/grpc-web+proto",
+			header:      map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			status:      "trailer",
+			wantTrace:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantBridged: true,
+			wantStatus:  5,
+		},
+		{
+			name:        "StatusInTextTrailer",
+			contentType: "application/grpc-web-text",
+			header:      map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			status:      "trailer",
+			wantTrace:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantBridged: true,
+			wantStatus:  5,
+		},
+		{
+			name:        "NotGRPCWeb",
+			contentType: "application/json",
+			header:      map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			var got *http.Request
+			b := &WebBridge{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = r
+					switch tc.status {
+					case "headers":
+						w.Header().Set("Grpc-Status", "5")
+						w.Header().Set("Grpc-Message", "not found")
+					case "trailer":
+						for _, f := range [][]byte{
+							grpcWebFrame(0, "message"),
+							grpcWebFrame(grpcWebTrailer, "grpc-status: 5\r\ngrpc-message: not found\r\n"),
+						} {
+							if tc.contentType == contentTypeGRPCWebText {
+								f = []byte(base64.StdEncoding.EncodeToString(f))
+							}
+							// Write each frame in parts, as a streaming
+							// gateway might.
+							w.Write(f[:3])
+							w.Write(f[3:])
+						}
+					}
+				}),
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+			}
+			r := httptest.NewRequest("POST", "http://gateway/grpc.health.v1.Health/Check", nil)
+			r.Header.Set("Content-Type", tc.contentType)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			b.ServeHTTP(httptest.NewRecorder(), r)
+
+			id, ok := linkin.TraceIDFromRequest(got)
+			if ok != tc.wantBridged {
+				t.Fatalf("b.ServeHTTP(): want l5d-ctx-trace header %t, got %v", tc.wantBridged, got.Header)
+			}
+			if !ok {
+				if got.Header.Get("traceparent") == "" {
+					t.Errorf("b.ServeHTTP(): want request unmodified, got %v", got.Header)
+				}
+				return
+			}
+			if got.Header.Get("traceparent") != "" || got.Header.Get("X-B3-TraceId") != "" {
+				t.Errorf("b.ServeHTTP(): want browser trace headers removed, got %v", got.Header)
+			}
+			span := trace.FromContext(got.Context())
+			if span == nil || trace.SpanID(id.Span) != span.SpanContext().SpanID {
+				t.Errorf("b.ServeHTTP(): want l5d-ctx-trace header of the span in the request's context, got %v", got.Header)
+			}
+			if tc.wantTrace != "" && trace.TraceID(id.Trace).String() != tc.wantTrace {
+				t.Errorf("b.ServeHTTP(): want trace ID %s, got %s", tc.wantTrace, trace.TraceID(id.Trace))
+			}
+
+			if len(e.spans) != 1 {
+				t.Fatalf("b.ServeHTTP(): want 1 exported span, got %d", len(e.spans))
+			}
+			s := e.spans[0]
+			if s.Name != "Recv.grpc.health.v1.Health.Check" || s.Status.Code != tc.wantStatus {
+				t.Errorf("b.ServeHTTP(): want span Recv.grpc.health.v1.Health.Check with status %d, got %s with %+v", tc.wantStatus, s.Name, s.Status)
+			}
+		})
+	}
+}
+
+// grpcWebFrame returns a grpc-web frame with the supplied flags and payload.
+func grpcWebFrame(flags byte, payload string) []byte {
+	b := make([]byte, 5, 5+len(payload))
+	b[0] = flags
+	binary.BigEndian.PutUint32(b[1:], uint32(len(payload)))
+	return append(b, payload...)
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5dgrpc propagates linkerd span context over gRPC. linkerd forwards
+// l5d-ctx-* headers over HTTP/2, and thus gRPC metadata, just as it does over
+// HTTP/1.
+package l5dgrpc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptor provides gRPC client and server interceptors that start a span
+// for each RPC and propagate its span context in gRPC metadata. Streaming RPCs
+// record a span event for each message sent and received, and keep the span
+// attached to the stream's context for the stream's lifetime.
+//
+// Register server interceptors using grpc.UnaryInterceptor(i.UnaryServer) and
+// grpc.StreamInterceptor(i.StreamServer), and client interceptors using
+// grpc.WithUnaryInterceptor(i.UnaryClient) and
+// grpc.WithStreamInterceptor(i.StreamClient).
+type Interceptor struct {
+	// Propagation defines how traces are propagated. Propagation formats that
+	// work with HTTP headers, such as linkin.HTTPFormat and linkin.B3Format,
+	// work with gRPC metadata too; they see its lowercase keys canonicalized
+	// as HTTP header keys. &linkin.HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each RPC.
+	StartOptions trace.StartOptions
+}
+
+// extract extracts span context from the supplied context's incoming metadata.
+func (i *Interceptor) extract(ctx context.Context) (trace.SpanContext, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return linkin.SpanContextFromCarrier(ctx, i.Propagation, linkin.MetadataCarrier(md))
+}
+
+// inject returns a copy of the supplied context whose outgoing metadata
+// includes the supplied span context.
+func (i *Interceptor) inject(ctx context.Context, sc trace.SpanContext) context.Context {
+	c := linkin.MetadataCarrier{}
+	linkin.SpanContextToCarrier(ctx, i.Propagation, sc, c)
+	kv := make([]string, 0, 2*len(c))
+	for k, vs := range c {
+		for _, v := range vs {
+			kv = append(kv, k, v)
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// startServerSpan starts a span representing the supplied incoming RPC.
+func (i *Interceptor) startServerSpan(ctx context.Context, method string) (context.Context, *trace.Span) {
+	name := "Recv." + spanName(method)
+	if sc, ok := i.extract(ctx); ok {
+		return trace.StartSpanWithRemoteParent(ctx, name, sc,
+			trace.WithSampler(i.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	}
+	return trace.StartSpan(ctx, name,
+		trace.WithSampler(i.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// startClientSpan starts a span representing the supplied outgoing RPC, and
+// injects its span context into the returned context's outgoing metadata.
+func (i *Interceptor) startClientSpan(ctx context.Context, method string) (context.Context, *trace.Span) {
+	ctx, span := trace.StartSpan(ctx, "Sent."+spanName(method),
+		trace.WithSampler(i.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindClient))
+	return i.inject(ctx, span.SpanContext()), span
+}
+
+// UnaryServer is a grpc.UnaryServerInterceptor.
+func (i *Interceptor) UnaryServer(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := i.startServerSpan(ctx, info.FullMethod)
+	defer span.End()
+
+	span.AddMessageReceiveEvent(1, size(req), size(req))
+	rsp, err := handler(ctx, req)
+	if err == nil {
+		span.AddMessageSendEvent(1, size(rsp), size(rsp))
+	}
+	span.SetStatus(spanStatus(err))
+	return rsp, err
+}
+
+// StreamServer is a grpc.StreamServerInterceptor.
+func (i *Interceptor) StreamServer(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := i.startServerSpan(ss.Context(), info.FullMethod)
+	defer span.End()
+
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx, span: span})
+	span.SetStatus(spanStatus(err))
+	return err
+}
+
+// UnaryClient is a grpc.UnaryClientInterceptor.
+func (i *Interceptor) UnaryClient(ctx context.Context, method string, req, rsp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := i.startClientSpan(ctx, method)
+	defer span.End()
+
+	span.AddMessageSendEvent(1, size(req), size(req))
+	err := invoker(ctx, method, req, rsp, cc, opts...)
+	if err == nil {
+		span.AddMessageReceiveEvent(1, size(rsp), size(rsp))
+	}
+	span.SetStatus(spanStatus(err))
+	return err
+}
+
+// StreamClient is a grpc.StreamClientInterceptor.
+func (i *Interceptor) StreamClient(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, span := i.startClientSpan(ctx, method)
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		span.SetStatus(spanStatus(err))
+		span.End()
+		return nil, err
+	}
+
+	s := &clientStream{ClientStream: cs, span: span, desc: desc}
+	go func() {
+		// The stream's context is done when the stream finishes. This ends
+		// the span of streams that are abandoned rather than read until
+		// io.EOF. The error is nil unless the caller's context is done.
+		<-cs.Context().Done()
+		s.finish(ctx.Err())
+	}()
+	return s, nil
+}
+
+// serverStream is a grpc.ServerStream that records message events, and whose
+// context contains the span representing the stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx        context.Context
+	span       *trace.Span
+	sent, recv int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.span.AddMessageSendEvent(atomic.AddInt64(&s.sent, 1), size(m), size(m))
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.span.AddMessageReceiveEvent(atomic.AddInt64(&s.recv, 1), size(m), size(m))
+	}
+	return err
+}
+
+// clientStream is a grpc.ClientStream that records message events, and ends
+// the span representing the stream when the stream finishes.
+type clientStream struct {
+	grpc.ClientStream
+	span       *trace.Span
+	desc       *grpc.StreamDesc
+	sent, recv int64
+	once       sync.Once
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		s.span.SetStatus(spanStatus(err))
+		s.span.End()
+	})
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.finish(err)
+	}
+	return md, err
+}
+
+func (s *clientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	switch err {
+	case nil:
+		s.span.AddMessageSendEvent(atomic.AddInt64(&s.sent, 1), size(m), size(m))
+	case io.EOF:
+		// The stream's status will be returned by RecvMsg.
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch err {
+	case nil:
+		s.span.AddMessageReceiveEvent(atomic.AddInt64(&s.recv, 1), size(m), size(m))
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+	case io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+// spanName returns the span name of the supplied full gRPC method name, e.g.
+// /grpc.health.v1.Health/Check becomes grpc.health.v1.Health.Check.
+func spanName(method string) string {
+	return strings.Replace(strings.TrimPrefix(method, "/"), "/", ".", -1)
+}
+
+// spanStatus returns the trace status of the supplied gRPC or context error.
+// OpenCensus status codes are identical to gRPC status codes.
+func spanStatus(err error) trace.Status {
+	if err == nil {
+		return trace.Status{}
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		s = status.FromContextError(err)
+	}
+	return trace.Status{Code: int32(s.Code()), Message: s.Message()}
+}
+
+func size(m interface{}) int64 {
+	if pm, ok := m.(proto.Message); ok {
+		return int64(proto.Size(pm))
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	hpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *recordingExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *recordingExporter) Spans() map[string]*trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := map[string]*trace.SpanData{}
+	for _, s := range e.spans {
+		spans[s.Name] = s
+	}
+	return spans
+}
+
+func dial(t *testing.T, i *Interceptor) (hpb.HealthClient, func()) {
+	l := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(i.UnaryServer), grpc.StreamInterceptor(i.StreamServer))
+	hpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(l)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithUnaryInterceptor(i.UnaryClient),
+		grpc.WithStreamInterceptor(i.StreamClient))
+	if err != nil {
+		t.Fatalf("grpc.Dial(): %v", err)
+	}
+	return hpb.NewHealthClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func TestUnary(t *testing.T) {
+	cases := []struct {
+		name string
+		f    propagation.HTTPFormat
+	}{
+		{name: "Linkerd"},
+		{name: "B3", f: &linkin.B3Format{}},
+		{name: "TraceContext", f: &linkin.TraceContextFormat{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			client, stop := dial(t, &Interceptor{Propagation: tc.f, StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}})
+			defer stop()
+
+			if _, err := client.Check(context.Background(), &hpb.HealthCheckRequest{}); err != nil {
+				t.Fatalf("client.Check(): %v", err)
+			}
+
+			spans := e.Spans()
+			sent, recv := spans["Sent.grpc.health.v1.Health.Check"], spans["Recv.grpc.health.v1.Health.Check"]
+			if sent == nil || recv == nil {
+				t.Fatalf("client.Check(): want client and server spans, got %v", spans)
+			}
+			if recv.TraceID != sent.TraceID || recv.ParentSpanID != sent.SpanID {
+				t.Errorf("client.Check(): want server span to be child of client span %v, got %v", sent.SpanContext, recv.ParentSpanID)
+			}
+			if len(recv.MessageEvents) != 2 {
+				t.Errorf("client.Check(): want 2 server message events, got %d", len(recv.MessageEvents))
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	client, stop := dial(t, &Interceptor{StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &hpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("client.Watch(): %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("stream.Recv(): %v", err)
+	}
+	cancel()
+
+	// Stopping the server waits for the stream handler to return.
+	stop()
+
+	// The client span ends asynchronously when the stream is cancelled.
+	var sent, recv *trace.SpanData
+	deadline := time.Now().Add(time.Second)
+	for (sent == nil || recv == nil) && time.Now().Before(deadline) {
+		spans := e.Spans()
+		sent, recv = spans["Sent.grpc.health.v1.Health.Watch"], spans["Recv.grpc.health.v1.Health.Watch"]
+		time.Sleep(time.Millisecond)
+	}
+	if sent == nil || recv == nil {
+		t.Fatalf("client.Watch(): want client and server spans, got %v", e.Spans())
+	}
+	if recv.TraceID != sent.TraceID || recv.ParentSpanID != sent.SpanID {
+		t.Errorf("client.Watch(): want server span to be child of client span %v, got %v", sent.SpanContext, recv.ParentSpanID)
+	}
+	if sent.Code != int32(codes.Canceled) {
+		t.Errorf("client.Watch(): want client span status %d, got %d", codes.Canceled, sent.Code)
+	}
+	if len(sent.MessageEvents) != 2 {
+		t.Errorf("client.Watch(): want 2 client message events, got %d", len(sent.MessageEvents))
+	}
+	if len(recv.MessageEvents) != 2 {
+		t.Errorf("client.Watch(): want 2 server message events, got %d", len(recv.MessageEvents))
+	}
+}
+
+func TestSpanStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "OK", want: codes.OK},
+		{name: "Status", err: status.Error(codes.NotFound, "nope"), want: codes.NotFound},
+		{name: "Canceled", err: context.Canceled, want: codes.Canceled},
+		{name: "DeadlineExceeded", err: context.DeadlineExceeded, want: codes.DeadlineExceeded},
+		{name: "Other", err: errors.New("boom"), want: codes.Unknown},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := spanStatus(tc.err); got.Code != int32(tc.want) {
+				t.Errorf("spanStatus(%v): want code %d, got %d", tc.err, tc.want, got.Code)
+			}
+		})
+	}
+}
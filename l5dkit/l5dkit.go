@@ -0,0 +1,174 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5dkit propagates linkerd span context through go-kit services. It
+// provides Before and After functions for go-kit's HTTP and gRPC transports,
+// which extract and inject span context, and endpoint middleware, which starts
+// and ends spans:
+//
+//  t := &l5dkit.Tracer{}
+//  handler := httptransport.NewServer(
+//    t.ServerEndpoint("users.get")(endpoint), decode, encode,
+//    httptransport.ServerBefore(t.HTTPServerBefore),
+//  )
+//  client := httptransport.NewClient("GET", u, encode, decode,
+//    httptransport.ClientBefore(t.HTTPClientBefore),
+//    httptransport.ClientAfter(t.HTTPClientAfter),
+//  ).Endpoint()
+//  client = t.ClientEndpoint("users.get")(client)
+package l5dkit
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc/metadata"
+)
+
+type remoteParentKey struct{}
+
+// A Tracer traces go-kit endpoints, propagating their span context via go-kit
+// transports.
+type Tracer struct {
+	// Propagation defines how traces are propagated. Propagation formats that
+	// work with HTTP headers, such as linkin.HTTPFormat and linkin.B3Format,
+	// work with gRPC metadata too; they see its lowercase keys canonicalized
+	// as HTTP header keys. &linkin.HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to each span started by endpoint middleware.
+	StartOptions trace.StartOptions
+}
+
+func (t *Tracer) propagation() propagation.HTTPFormat {
+	if t.Propagation == nil {
+		return &linkin.HTTPFormat{}
+	}
+	return t.Propagation
+}
+
+// withRemoteParent returns a copy of the supplied context carrying the span
+// context extracted from the supplied request, if any.
+func (t *Tracer) withRemoteParent(ctx context.Context, r *http.Request) context.Context {
+	if sc, ok := t.propagation().SpanContextFromRequest(r); ok {
+		return context.WithValue(ctx, remoteParentKey{}, sc)
+	}
+	return ctx
+}
+
+// HTTPServerBefore is an httptransport.RequestFunc that extracts span context
+// from an incoming request, for use as the parent of the span started by
+// ServerEndpoint.
+func (t *Tracer) HTTPServerBefore(ctx context.Context, r *http.Request) context.Context {
+	return t.withRemoteParent(ctx, r)
+}
+
+// HTTPClientBefore is an httptransport.RequestFunc that injects the span
+// context of the span in the supplied context, typically started by
+// ClientEndpoint, into an outgoing request.
+func (t *Tracer) HTTPClientBefore(ctx context.Context, r *http.Request) context.Context {
+	if span := trace.FromContext(ctx); span != nil {
+		t.propagation().SpanContextToRequest(span.SpanContext(), r)
+	}
+	return ctx
+}
+
+// HTTPClientAfter is an httptransport.ClientResponseFunc that records the
+// status of a response on the span in the supplied context.
+func (t *Tracer) HTTPClientAfter(ctx context.Context, rsp *http.Response) context.Context {
+	if span := trace.FromContext(ctx); span != nil {
+		span.AddAttributes(trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(rsp.StatusCode)))
+		span.SetStatus(ochttp.TraceStatus(rsp.StatusCode, rsp.Status))
+	}
+	return ctx
+}
+
+// GRPCServerBefore is a grpctransport.ServerRequestFunc that extracts span
+// context from incoming metadata, for use as the parent of the span started by
+// ServerEndpoint.
+func (t *Tracer) GRPCServerBefore(ctx context.Context, md metadata.MD) context.Context {
+	if sc, ok := linkin.SpanContextFromCarrier(ctx, t.Propagation, linkin.MetadataCarrier(md)); ok {
+		return context.WithValue(ctx, remoteParentKey{}, sc)
+	}
+	return ctx
+}
+
+// GRPCClientBefore is a grpctransport.ClientRequestFunc that injects the span
+// context of the span in the supplied context, typically started by
+// ClientEndpoint, into outgoing metadata.
+func (t *Tracer) GRPCClientBefore(ctx context.Context, md *metadata.MD) context.Context {
+	if span := trace.FromContext(ctx); span != nil {
+		if *md == nil {
+			*md = metadata.MD{}
+		}
+		linkin.SpanContextToCarrier(ctx, t.Propagation, span.SpanContext(), linkin.MetadataCarrier(*md))
+	}
+	return ctx
+}
+
+// ServerEndpoint returns endpoint middleware that starts a server span with the
+// supplied name for each request, as a child of the span context extracted by
+// HTTPServerBefore or GRPCServerBefore, if any. The span ends when the
+// endpoint returns, recording any error it returns.
+func (t *Tracer) ServerEndpoint(name string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var span *trace.Span
+			if sc, ok := ctx.Value(remoteParentKey{}).(trace.SpanContext); ok {
+				ctx, span = trace.StartSpanWithRemoteParent(ctx, name, sc,
+					trace.WithSampler(t.StartOptions.Sampler),
+					trace.WithSpanKind(trace.SpanKindServer))
+			} else {
+				ctx, span = trace.StartSpan(ctx, name,
+					trace.WithSampler(t.StartOptions.Sampler),
+					trace.WithSpanKind(trace.SpanKindServer))
+			}
+			defer span.End()
+			return end(span, next, ctx, request)
+		}
+	}
+}
+
+// ClientEndpoint returns endpoint middleware that starts a client span with the
+// supplied name for each request, as a child of the span in the request's
+// context, if any. Its span context is injected by HTTPClientBefore or
+// GRPCClientBefore. The span ends when the endpoint returns, recording any
+// error it returns.
+func (t *Tracer) ClientEndpoint(name string) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, span := trace.StartSpan(ctx, name,
+				trace.WithSampler(t.StartOptions.Sampler),
+				trace.WithSpanKind(trace.SpanKindClient))
+			defer span.End()
+			return end(span, next, ctx, request)
+		}
+	}
+}
+
+// end calls the supplied endpoint, recording any error on the supplied span.
+func end(span *trace.Span, next endpoint.Endpoint, ctx context.Context, request interface{}) (interface{}, error) {
+	rsp, err := next(ctx, request)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	return rsp, err
+}
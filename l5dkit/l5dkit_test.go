@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/planetlabs/linkin"
+	"github.com/planetlabs/linkin/tracetest"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"google.golang.org/grpc/metadata"
+)
+
+// httpTransport and grpcTransport simulate go-kit client and server transport
+// pairs, passing the client's outgoing request or metadata to the server.
+func httpTransport(t *Tracer, ctx context.Context) context.Context {
+	r := httptest.NewRequest("GET", "http://example.org", nil)
+	t.HTTPClientBefore(ctx, r)
+	t.HTTPClientAfter(ctx, &http.Response{StatusCode: http.StatusOK, Status: "200 OK"})
+	return t.HTTPServerBefore(context.Background(), r)
+}
+
+func grpcTransport(t *Tracer, ctx context.Context) context.Context {
+	md := metadata.MD{}
+	t.GRPCClientBefore(ctx, &md)
+	return t.GRPCServerBefore(context.Background(), md)
+}
+
+func TestTracer(t *testing.T) {
+	cases := []struct {
+		name        string
+		propagation propagation.HTTPFormat
+		transport   func(t *Tracer, ctx context.Context) context.Context
+		err         error
+	}{
+		{name: "HTTP", transport: httpTransport},
+		{name: "GRPC", transport: grpcTransport},
+		{name: "GRPCB3", propagation: &linkin.B3Format{}, transport: grpcTransport},
+		{name: "Error", transport: httpTransport, err: errors.New("boom")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &tracetest.Exporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			tr := &Tracer{Propagation: tc.propagation, StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}}
+
+			var server trace.SpanContext
+			svc := tr.ServerEndpoint("Recv.users.get")(func(ctx context.Context, _ interface{}) (interface{}, error) {
+				server = trace.FromContext(ctx).SpanContext()
+				return nil, tc.err
+			})
+			var client trace.SpanContext
+			var e2e endpoint.Endpoint = func(ctx context.Context, request interface{}) (interface{}, error) {
+				client = trace.FromContext(ctx).SpanContext()
+				return svc(tc.transport(tr, ctx), request)
+			}
+			e2e = tr.ClientEndpoint("Sent.users.get")(e2e)
+
+			_, err := e2e(context.Background(), nil)
+			if err != tc.err {
+				t.Errorf("e2e(): want error %v, got %v", tc.err, err)
+			}
+			if server.TraceID != client.TraceID {
+				t.Errorf("ServerEndpoint(): want trace ID %v, got %v", client.TraceID, server.TraceID)
+			}
+
+			s, ok := e.Span("Recv.users.get")
+			if !ok {
+				t.Fatalf("ServerEndpoint(): want span, got %v", e.Names())
+			}
+			c, ok := e.Parent(s)
+			if !ok || c.Name != "Sent.users.get" {
+				t.Fatalf("ServerEndpoint(): want parent Sent.users.get, got %v", e.Names())
+			}
+			if !s.HasRemoteParent {
+				t.Errorf("ServerEndpoint(): want remote parent")
+			}
+			if s.SpanKind != trace.SpanKindServer || c.SpanKind != trace.SpanKindClient {
+				t.Errorf("want server and client span kinds, got %d and %d", s.SpanKind, c.SpanKind)
+			}
+			wantCode := int32(trace.StatusCodeOK)
+			if tc.err != nil {
+				wantCode = trace.StatusCodeUnknown
+			}
+			for _, sd := range e.Spans() {
+				if sd.Code != wantCode {
+					t.Errorf("%s: want status code %d, got %d", sd.Name, wantCode, sd.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestServerEndpointWithoutParent(t *testing.T) {
+	tr := &Tracer{}
+	var got *trace.Span
+	ep := tr.ServerEndpoint("Recv.users.get")(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		got = trace.FromContext(ctx)
+		return nil, nil
+	})
+	ctx := tr.HTTPServerBefore(context.Background(), httptest.NewRequest("GET", "http://example.org", nil))
+	if _, err := ep(ctx, nil); err != nil {
+		t.Fatalf("ep(): %v", err)
+	}
+	if got == nil {
+		t.Errorf("ServerEndpoint(): want span, got nil")
+	}
+}
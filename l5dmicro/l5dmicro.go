@@ -0,0 +1,179 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5dmicro propagates linkerd span context over go-micro, for teams
+// building services on that framework. Span context is carried in go-micro's
+// request metadata, which its transports send as headers, so linkerd forwards
+// it just as it does l5d-ctx-* HTTP headers:
+//
+//  w := &l5dmicro.Wrapper{}
+//  service := micro.NewService(
+//    micro.WrapClient(w.Client),
+//    micro.WrapHandler(w.Handler),
+//    micro.WrapSubscriber(w.Subscriber),
+//  )
+package l5dmicro
+
+import (
+	"context"
+
+	"github.com/planetlabs/linkin"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/server"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// Wrapper provides go-micro client, handler, and subscriber wrappers that start
+// a span for each request or message and propagate its span context in
+// go-micro metadata.
+type Wrapper struct {
+	// Propagation defines how traces are propagated. Propagation formats that
+	// work with HTTP headers, such as linkin.HTTPFormat, work with go-micro
+	// metadata too. &linkin.HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each request or
+	// message.
+	StartOptions trace.StartOptions
+}
+
+// A carrier is a linkin.Carrier backed by go-micro metadata.
+type carrier metadata.Metadata
+
+func (c carrier) Get(key string) string { return c[key] }
+func (c carrier) Set(key, value string) { c[key] = value }
+
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// extract extracts span context from the supplied context's metadata.
+func (w *Wrapper) extract(ctx context.Context) (trace.SpanContext, bool) {
+	md, _ := metadata.FromContext(ctx)
+	return linkin.SpanContextFromCarrier(ctx, w.Propagation, carrier(md))
+}
+
+// inject returns a copy of the supplied context whose metadata includes the
+// supplied span context.
+func (w *Wrapper) inject(ctx context.Context, sc trace.SpanContext) context.Context {
+	md := metadata.Metadata{}
+	if existing, ok := metadata.FromContext(ctx); ok {
+		for k, v := range existing {
+			md[k] = v
+		}
+	}
+	linkin.SpanContextToCarrier(ctx, w.Propagation, sc, carrier(md))
+	return metadata.NewContext(ctx, md)
+}
+
+// startServerSpan starts a span named name that is a child of the span context
+// in the supplied context's metadata, if any.
+func (w *Wrapper) startServerSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	if sc, ok := w.extract(ctx); ok {
+		return trace.StartSpanWithRemoteParent(ctx, name, sc,
+			trace.WithSampler(w.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	}
+	return trace.StartSpan(ctx, name,
+		trace.WithSampler(w.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// startClientSpan starts a span named name, and injects its span context into
+// the returned context's metadata.
+func (w *Wrapper) startClientSpan(ctx context.Context, name string) (context.Context, *trace.Span) {
+	ctx, span := trace.StartSpan(ctx, name,
+		trace.WithSampler(w.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindClient))
+	return w.inject(ctx, span.SpanContext()), span
+}
+
+// Client is a client.Wrapper.
+func (w *Wrapper) Client(c client.Client) client.Client {
+	return &tracedClient{Client: c, w: w}
+}
+
+// Handler is a server.HandlerWrapper.
+func (w *Wrapper) Handler(fn server.HandlerFunc) server.HandlerFunc {
+	return func(ctx context.Context, req server.Request, rsp interface{}) error {
+		ctx, span := w.startServerSpan(ctx, "Recv."+req.Service()+"."+req.Endpoint())
+		defer span.End()
+
+		err := fn(ctx, req, rsp)
+		span.SetStatus(spanStatus(err))
+		return err
+	}
+}
+
+// Subscriber is a server.SubscriberWrapper. The span started for each message
+// is a child of the span that published it.
+func (w *Wrapper) Subscriber(fn server.SubscriberFunc) server.SubscriberFunc {
+	return func(ctx context.Context, m server.Message) error {
+		ctx, span := w.startServerSpan(ctx, "Sub."+m.Topic())
+		defer span.End()
+
+		err := fn(ctx, m)
+		span.SetStatus(spanStatus(err))
+		return err
+	}
+}
+
+type tracedClient struct {
+	client.Client
+	w *Wrapper
+}
+
+func (c *tracedClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	ctx, span := c.w.startClientSpan(ctx, "Sent."+req.Service()+"."+req.Endpoint())
+	defer span.End()
+
+	err := c.Client.Call(ctx, req, rsp, opts...)
+	span.SetStatus(spanStatus(err))
+	return err
+}
+
+// Stream starts a stream. Its span represents only the establishment of the
+// stream.
+func (c *tracedClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	ctx, span := c.w.startClientSpan(ctx, "Sent."+req.Service()+"."+req.Endpoint())
+	defer span.End()
+
+	s, err := c.Client.Stream(ctx, req, opts...)
+	span.SetStatus(spanStatus(err))
+	return s, err
+}
+
+func (c *tracedClient) Publish(ctx context.Context, m client.Message, opts ...client.PublishOption) error {
+	ctx, span := c.w.startClientSpan(ctx, "Pub."+m.Topic())
+	defer span.End()
+
+	err := c.Client.Publish(ctx, m, opts...)
+	span.SetStatus(spanStatus(err))
+	return err
+}
+
+func spanStatus(err error) trace.Status {
+	if err == nil {
+		return trace.Status{}
+	}
+	return trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dmicro
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/planetlabs/linkin/tracetest"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/metadata"
+	"go-micro.dev/v4/server"
+	"go.opencensus.io/trace"
+)
+
+// A loopback is a client.Client that calls a server.HandlerFunc or
+// server.SubscriberFunc in process, passing along the caller's metadata as a
+// go-micro transport would.
+type loopback struct {
+	client.Client
+	handler    server.HandlerFunc
+	subscriber server.SubscriberFunc
+}
+
+func received(ctx context.Context) context.Context {
+	md, _ := metadata.FromContext(ctx)
+	return metadata.NewContext(context.Background(), md)
+}
+
+func (c *loopback) Call(ctx context.Context, req client.Request, rsp interface{}, _ ...client.CallOption) error {
+	return c.handler(received(ctx), &request{service: req.Service(), endpoint: req.Endpoint()}, rsp)
+}
+
+func (c *loopback) Publish(ctx context.Context, m client.Message, _ ...client.PublishOption) error {
+	return c.subscriber(received(ctx), &message{topic: m.Topic()})
+}
+
+type request struct {
+	server.Request
+	service, endpoint string
+}
+
+func (r *request) Service() string  { return r.service }
+func (r *request) Endpoint() string { return r.endpoint }
+
+type message struct {
+	server.Message
+	topic string
+}
+
+func (m *message) Topic() string { return m.topic }
+
+type clientRequest struct {
+	client.Request
+	service, endpoint string
+}
+
+func (r *clientRequest) Service() string  { return r.service }
+func (r *clientRequest) Endpoint() string { return r.endpoint }
+
+type clientMessage struct {
+	client.Message
+	topic string
+}
+
+func (m *clientMessage) Topic() string { return m.topic }
+
+func TestSatisfiesWrappers(t *testing.T) {
+	w := &Wrapper{}
+	var _ client.Wrapper = w.Client
+	var _ server.HandlerWrapper = w.Handler
+	var _ server.SubscriberWrapper = w.Subscriber
+}
+
+func TestWrapper(t *testing.T) {
+	cases := []struct {
+		name       string
+		send       func(ctx context.Context, c client.Client) error
+		err        error
+		wantClient string
+		wantServer string
+	}{
+		{
+			name: "Call",
+			send: func(ctx context.Context, c client.Client) error {
+				return c.Call(ctx, &clientRequest{service: "greeter", endpoint: "Greeter.Hello"}, nil)
+			},
+			wantClient: "Sent.greeter.Greeter.Hello",
+			wantServer: "Recv.greeter.Greeter.Hello",
+		},
+		{
+			name: "CallError",
+			send: func(ctx context.Context, c client.Client) error {
+				return c.Call(ctx, &clientRequest{service: "greeter", endpoint: "Greeter.Hello"}, nil)
+			},
+			err:        errors.New("boom"),
+			wantClient: "Sent.greeter.Greeter.Hello",
+			wantServer: "Recv.greeter.Greeter.Hello",
+		},
+		{
+			name: "Publish",
+			send: func(ctx context.Context, c client.Client) error {
+				return c.Publish(ctx, &clientMessage{topic: "events"})
+			},
+			wantClient: "Pub.events",
+			wantServer: "Sub.events",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &tracetest.Exporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			w := &Wrapper{StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}}
+			var got trace.SpanContext
+			c := w.Client(&loopback{
+				handler: w.Handler(func(ctx context.Context, _ server.Request, _ interface{}) error {
+					got = trace.FromContext(ctx).SpanContext()
+					return tc.err
+				}),
+				subscriber: w.Subscriber(func(ctx context.Context, _ server.Message) error {
+					got = trace.FromContext(ctx).SpanContext()
+					return tc.err
+				}),
+			})
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+			if err := tc.send(ctx, c); err != tc.err {
+				t.Errorf("c.Send(): want error %v, got %v", tc.err, err)
+			}
+			parent.End()
+
+			if got.TraceID != parent.SpanContext().TraceID {
+				t.Errorf("w.Handler(): want trace ID %v, got %v", parent.SpanContext().TraceID, got.TraceID)
+			}
+			cs, cok := e.Span(tc.wantClient)
+			ss, sok := e.Span(tc.wantServer)
+			if !cok || !sok {
+				t.Fatalf("w.Client(): want spans %s and %s, got %v", tc.wantClient, tc.wantServer, e.Names())
+			}
+			if p, ok := e.Parent(ss); !ok || p.SpanID != cs.SpanID {
+				t.Errorf("w.Client(): want server span %s child of client span %s, got %+v", tc.wantServer, tc.wantClient, e.Spans())
+			}
+			if p, ok := e.Parent(cs); !ok || p.Name != "parent" {
+				t.Errorf("w.Client(): want client span %s child of parent, got %+v", tc.wantClient, e.Spans())
+			}
+			if (ss.Status.Code != trace.StatusCodeOK) != (tc.err != nil) {
+				t.Errorf("w.Handler(): want error status %t, got %+v", tc.err != nil, ss.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5dretryablehttp traces the attempts made by a go-retryablehttp
+// client. go-retryablehttp retries requests internally, so an ochttp.Transport
+// wrapped around the client's StandardClient sees only one request however
+// many attempts are made, and every attempt carries the same span context.
+// Instrument instead starts a client span for each attempt, annotated with its
+// attempt number, and injects that span's context into the attempt:
+//
+//  c := retryablehttp.NewClient()
+//  (&l5dretryablehttp.Tracer{}).Instrument(c)
+//  req, _ := retryablehttp.NewRequest("GET", u, nil)
+//  rsp, err := c.Do(req.WithContext(ctx))
+package l5dretryablehttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+type attemptKey struct{}
+
+// A Tracer traces the attempts made by go-retryablehttp clients.
+type Tracer struct {
+	// Propagation defines how traces are propagated. &linkin.HTTPFormat{} is
+	// used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each attempt.
+	StartOptions trace.StartOptions
+}
+
+// Instrument configures the supplied client to start a client span for each
+// attempt, as a child of the span in the request's context, if any. Any
+// existing RequestLogHook is preserved. The client's HTTPClient transport is
+// wrapped, and thus should not be an ochttp.Transport.
+func (t *Tracer) Instrument(c *retryablehttp.Client) {
+	hook := c.RequestLogHook
+	c.RequestLogHook = func(l retryablehttp.Logger, r *http.Request, n int) {
+		// go-retryablehttp sends the request it supplies to this hook, so the
+		// attempt number is recorded by replacing its context in place.
+		*r = *r.WithContext(context.WithValue(r.Context(), attemptKey{}, n))
+		if hook != nil {
+			hook(l, r, n)
+		}
+	}
+
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{}
+	}
+	hc := *c.HTTPClient
+	hc.Transport = &attemptTransport{Base: hc.Transport, Tracer: t}
+	c.HTTPClient = &hc
+}
+
+// An attemptTransport starts a client span for each request it sends.
+type attemptTransport struct {
+	Base   http.RoundTripper
+	Tracer *Tracer
+}
+
+func (t *attemptTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	n, _ := r.Context().Value(attemptKey{}).(int)
+	ctx, span := trace.StartSpan(r.Context(), r.URL.Path,
+		trace.WithSampler(t.Tracer.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindClient))
+	span.AddAttributes(
+		trace.StringAttribute(ochttp.MethodAttribute, r.Method),
+		trace.StringAttribute(ochttp.URLAttribute, r.URL.String()),
+		trace.Int64Attribute(linkin.AttemptAttribute, int64(n)),
+	)
+
+	out := r.Clone(ctx)
+	t.Tracer.propagation().SpanContextToRequest(span.SpanContext(), out)
+
+	rsp, err := t.base().RoundTrip(out)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		span.End()
+		return nil, err
+	}
+	span.AddAttributes(trace.Int64Attribute(ochttp.StatusCodeAttribute, int64(rsp.StatusCode)))
+	span.SetStatus(ochttp.TraceStatus(rsp.StatusCode, rsp.Status))
+	rsp.Body = &spanBody{ReadCloser: rsp.Body, span: span}
+	return rsp, nil
+}
+
+func (t *attemptTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Tracer) propagation() propagation.HTTPFormat {
+	if t.Propagation == nil {
+		return &linkin.HTTPFormat{}
+	}
+	return t.Propagation
+}
+
+// spanBody is a response body that ends a span when it is closed.
+// go-retryablehttp closes the bodies of responses it retries.
+type spanBody struct {
+	io.ReadCloser
+	once sync.Once
+	span *trace.Span
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.span.End)
+	return err
+}
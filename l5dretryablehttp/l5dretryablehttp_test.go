@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dretryablehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/planetlabs/linkin"
+	"github.com/planetlabs/linkin/tracetest"
+	"go.opencensus.io/trace"
+)
+
+func TestInstrument(t *testing.T) {
+	cases := []struct {
+		name     string
+		failures int
+		wantCode int
+	}{
+		{name: "FirstAttempt", wantCode: http.StatusOK},
+		{name: "Retried", failures: 2, wantCode: http.StatusOK},
+		{name: "Exhausted", failures: 5, wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &tracetest.Exporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			var mu sync.Mutex
+			var received []trace.SpanContext
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sc, _ := (&linkin.HTTPFormat{}).SpanContextFromRequest(r)
+				mu.Lock()
+				defer mu.Unlock()
+				received = append(received, sc)
+				if len(received) <= tc.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer s.Close()
+
+			var hooked []int
+			c := retryablehttp.NewClient()
+			c.RetryMax = 2
+			c.RetryWaitMin, c.RetryWaitMax = time.Millisecond, time.Millisecond
+			c.ErrorHandler = retryablehttp.PassthroughErrorHandler
+			c.RequestLogHook = func(_ retryablehttp.Logger, _ *http.Request, n int) { hooked = append(hooked, n) }
+			(&Tracer{StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}}).Instrument(c)
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+			req, err := retryablehttp.NewRequest("GET", s.URL+"/users", nil)
+			if err != nil {
+				t.Fatalf("retryablehttp.NewRequest(): %v", err)
+			}
+			req.Request = req.Request.WithContext(ctx)
+			rsp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("c.Do(): %v", err)
+			}
+			rsp.Body.Close()
+			parent.End()
+
+			if rsp.StatusCode != tc.wantCode {
+				t.Errorf("c.Do(): want status %d, got %d", tc.wantCode, rsp.StatusCode)
+			}
+			if len(hooked) != len(received) {
+				t.Errorf("c.RequestLogHook: want %d calls, got %v", len(received), hooked)
+			}
+
+			attempts := map[trace.SpanID]*trace.SpanData{}
+			for _, sd := range e.Named("/users") {
+				attempts[sd.SpanID] = sd
+			}
+			if len(attempts) != len(received) {
+				t.Fatalf("want %d attempt spans, got %v", len(received), e.Names())
+			}
+			for i, sc := range received {
+				sd, ok := attempts[sc.SpanID]
+				if !ok {
+					t.Errorf("attempt %d: want span %v to be recorded", i, sc.SpanID)
+					continue
+				}
+				if p, ok := e.Parent(sd); !ok || p.SpanID != parent.SpanContext().SpanID {
+					t.Errorf("attempt %d: want parent span %v, got parent span ID %v", i, parent.SpanContext().SpanID, sd.ParentSpanID)
+				}
+				if got := sd.Attributes[linkin.AttemptAttribute]; got != int64(i) {
+					t.Errorf("attempt %d: want %s %d, got %v", i, linkin.AttemptAttribute, i, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5drpc
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"net/rpc"
+)
+
+// A gobCodec reads and writes gob encoded RPCs, exactly as do the unexported
+// codecs used by rpc.NewClient and rpc.ServeConn.
+type gobCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobCodec(conn io.ReadWriteCloser) *gobCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf}
+}
+
+// write encodes and flushes the supplied header and body, closing the
+// connection if either cannot be encoded.
+func (c *gobCodec) write(header, body interface{}) error {
+	if err := c.enc.Encode(header); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
+
+// NewGobClientCodec returns a gob encoding client codec, as used by
+// rpc.NewClient, suitable for wrapping with Codec.Client:
+//
+//  client := rpc.NewClientWithCodec(c.Client(l5drpc.NewGobClientCodec(conn)))
+func NewGobClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &gobClientCodec{newGobCodec(conn)}
+}
+
+type gobClientCodec struct{ *gobCodec }
+
+func (c *gobClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	return c.write(r, body)
+}
+
+func (c *gobClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobClientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+// NewGobServerCodec returns a gob encoding server codec, as used by
+// rpc.ServeConn, suitable for wrapping with Codec.Server:
+//
+//  server.ServeCodec(c.Server(l5drpc.NewGobServerCodec(conn)))
+func NewGobServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &gobServerCodec{newGobCodec(conn)}
+}
+
+type gobServerCodec struct{ *gobCodec }
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	return c.write(r, body)
+}
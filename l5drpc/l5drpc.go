@@ -0,0 +1,201 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5drpc propagates linkerd span context over the standard library's
+// net/rpc package, for legacy services that have yet to move to HTTP or gRPC.
+//
+// net/rpc request headers carry only a service method name and a sequence
+// number, so span context is carried in the service method name, following a
+// "?" and encoded as a URL query, e.g.:
+//
+//  Arith.Multiply?l5d-ctx-trace=9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA%3D%3D
+//
+// The span context is removed from the service method name by the server codec
+// before the rpc.Server sees it. Both clients and servers must therefore wrap
+// their codecs using this package. Note that rpc.NewClient and rpc.ServeConn
+// use an unexported gob codec; use NewGobClientCodec and NewGobServerCodec
+// instead.
+package l5drpc
+
+import (
+	"context"
+	"net/rpc"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A ContextSetter receives the context of the server span started for an RPC.
+// RPC argument types may implement ContextSetter, typically by storing the
+// context in an unexported field, to make the span available to the service
+// method. The server codec calls SetContext after decoding the arguments.
+type ContextSetter interface {
+	SetContext(ctx context.Context)
+}
+
+// Codec wraps net/rpc client and server codecs to start a span for each RPC and
+// propagate its span context in the RPC's request header.
+type Codec struct {
+	// Propagation defines how traces are propagated. Propagation formats that
+	// work with HTTP headers, such as linkin.HTTPFormat, work with RPC
+	// headers too. &linkin.HTTPFormat{} is used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// StartOptions are applied to the span started for each RPC.
+	StartOptions trace.StartOptions
+}
+
+func (c *Codec) propagation() propagation.HTTPFormat {
+	if c.Propagation == nil {
+		return &linkin.HTTPFormat{}
+	}
+	return c.Propagation
+}
+
+// A call is the arguments of an RPC sent by Call, along with the span context
+// to propagate.
+type call struct {
+	sc   trace.SpanContext
+	args interface{}
+}
+
+// Call starts a client span, then calls the named function using the supplied
+// client, which must use a codec returned by Client. The span context of the
+// client span is propagated to the server. Call returns when the supplied
+// context is done, but net/rpc offers no way to cancel the call itself.
+func (c *Codec) Call(ctx context.Context, client *rpc.Client, serviceMethod string, args interface{}, reply interface{}) error {
+	ctx, span := trace.StartSpan(ctx, serviceMethod,
+		trace.WithSampler(c.StartOptions.Sampler),
+		trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	select {
+	case rsp := <-client.Go(serviceMethod, &call{sc: span.SpanContext(), args: args}, reply, make(chan *rpc.Call, 1)).Done:
+		if rsp.Error != nil {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: rsp.Error.Error()})
+		}
+		return rsp.Error
+	case <-ctx.Done():
+		span.SetStatus(trace.Status{Code: trace.StatusCodeCancelled, Message: ctx.Err().Error()})
+		return ctx.Err()
+	}
+}
+
+// Client wraps the supplied client codec to propagate the span context of RPCs
+// sent using Call. Other RPCs are sent unmodified.
+func (c *Codec) Client(cc rpc.ClientCodec) rpc.ClientCodec {
+	return &clientCodec{ClientCodec: cc, c: c}
+}
+
+type clientCodec struct {
+	rpc.ClientCodec
+	c *Codec
+}
+
+func (cc *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	call, ok := body.(*call)
+	if !ok {
+		return cc.ClientCodec.WriteRequest(r, body)
+	}
+	carrier := linkin.MapCarrier{}
+	linkin.SpanContextToCarrier(context.Background(), cc.c.propagation(), call.sc, carrier)
+	q := url.Values{}
+	for k, v := range carrier {
+		q.Set(k, v)
+	}
+	out := *r
+	out.ServiceMethod = r.ServiceMethod + "?" + q.Encode()
+	return cc.ClientCodec.WriteRequest(&out, call.args)
+}
+
+// Server wraps the supplied server codec to start a server span for each RPC
+// it reads, a child of any propagated span context. The span ends when the
+// RPC's response is written.
+func (c *Codec) Server(sc rpc.ServerCodec) rpc.ServerCodec {
+	return &serverCodec{ServerCodec: sc, c: c, spans: map[uint64]*trace.Span{}}
+}
+
+type serverCodec struct {
+	rpc.ServerCodec
+	c *Codec
+
+	// ctx is the context of the most recently read request. net/rpc always
+	// reads a request's body immediately after its header.
+	ctx context.Context
+
+	mu    sync.Mutex
+	spans map[uint64]*trace.Span
+}
+
+func (sc *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := sc.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	carrier := linkin.MapCarrier{}
+	if i := strings.Index(r.ServiceMethod, "?"); i >= 0 {
+		if q, err := url.ParseQuery(r.ServiceMethod[i+1:]); err == nil {
+			for k := range q {
+				carrier.Set(k, q.Get(k))
+			}
+		}
+		r.ServiceMethod = r.ServiceMethod[:i]
+	}
+
+	ctx, span := context.Background(), (*trace.Span)(nil)
+	if parent, ok := linkin.SpanContextFromCarrier(ctx, sc.c.propagation(), carrier); ok {
+		ctx, span = trace.StartSpanWithRemoteParent(ctx, r.ServiceMethod, parent,
+			trace.WithSampler(sc.c.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	} else {
+		ctx, span = trace.StartSpan(ctx, r.ServiceMethod,
+			trace.WithSampler(sc.c.StartOptions.Sampler),
+			trace.WithSpanKind(trace.SpanKindServer))
+	}
+	sc.ctx = ctx
+
+	sc.mu.Lock()
+	sc.spans[r.Seq] = span
+	sc.mu.Unlock()
+	return nil
+}
+
+func (sc *serverCodec) ReadRequestBody(body interface{}) error {
+	if err := sc.ServerCodec.ReadRequestBody(body); err != nil {
+		return err
+	}
+	if s, ok := body.(ContextSetter); ok && sc.ctx != nil {
+		s.SetContext(sc.ctx)
+	}
+	return nil
+}
+
+func (sc *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	sc.mu.Lock()
+	span, ok := sc.spans[r.Seq]
+	delete(sc.spans, r.Seq)
+	sc.mu.Unlock()
+	if ok {
+		if r.Error != "" {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: r.Error})
+		}
+		span.End()
+	}
+	return sc.ServerCodec.WriteResponse(r, body)
+}
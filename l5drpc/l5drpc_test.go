@@ -0,0 +1,186 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5drpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *recordingExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *recordingExporter) Spans() map[int]*trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := map[int]*trace.SpanData{}
+	for _, s := range e.spans {
+		spans[s.SpanKind] = s
+	}
+	return spans
+}
+
+type Args struct {
+	A, B int
+
+	ctx context.Context
+}
+
+func (a *Args) SetContext(ctx context.Context) {
+	a.ctx = ctx
+}
+
+type Arith struct {
+	mu   sync.Mutex
+	span trace.SpanContext
+}
+
+func (a *Arith) Divide(args *Args, reply *int) error {
+	a.mu.Lock()
+	a.span = trace.FromContext(args.ctx).SpanContext()
+	a.mu.Unlock()
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.A / args.B
+	return nil
+}
+
+func (a *Arith) Span() trace.SpanContext {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.span
+}
+
+type codecs struct {
+	name   string
+	client func(net.Conn) rpc.ClientCodec
+	server func(net.Conn) rpc.ServerCodec
+}
+
+var allCodecs = []codecs{
+	{
+		name:   "Gob",
+		client: func(c net.Conn) rpc.ClientCodec { return NewGobClientCodec(c) },
+		server: func(c net.Conn) rpc.ServerCodec { return NewGobServerCodec(c) },
+	},
+	{
+		name:   "JSON",
+		client: func(c net.Conn) rpc.ClientCodec { return jsonrpc.NewClientCodec(c) },
+		server: func(c net.Conn) rpc.ServerCodec { return jsonrpc.NewServerCodec(c) },
+	},
+}
+
+func dial(t *testing.T, c *Codec, cs codecs) (*rpc.Client, *Arith) {
+	a := &Arith{}
+	srv := rpc.NewServer()
+	if err := srv.Register(a); err != nil {
+		t.Fatalf("srv.Register(): %v", err)
+	}
+	cli, svr := net.Pipe()
+	go srv.ServeCodec(c.Server(cs.server(svr)))
+	return rpc.NewClientWithCodec(c.Client(cs.client(cli))), a
+}
+
+func TestCall(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    Args
+		want    int
+		wantErr bool
+	}{
+		{name: "Success", args: Args{A: 6, B: 3}, want: 2},
+		{name: "Error", args: Args{A: 6}, wantErr: true},
+	}
+
+	for _, cs := range allCodecs {
+		for _, tc := range cases {
+			t.Run(cs.name+tc.name, func(t *testing.T) {
+				e := &recordingExporter{}
+				trace.RegisterExporter(e)
+				defer trace.UnregisterExporter(e)
+
+				c := &Codec{StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()}}
+				client, a := dial(t, c, cs)
+				defer client.Close()
+
+				var got int
+				err := c.Call(context.Background(), client, "Arith.Divide", &tc.args, &got)
+				if (err != nil) != tc.wantErr {
+					t.Fatalf("c.Call(): want error %t, got %v", tc.wantErr, err)
+				}
+				if got != tc.want {
+					t.Errorf("c.Call(): want %d, got %d", tc.want, got)
+				}
+
+				// The server span ends before its response is written.
+				spans := e.Spans()
+				clientSpan, serverSpan := spans[trace.SpanKindClient], spans[trace.SpanKindServer]
+				if clientSpan == nil || serverSpan == nil {
+					t.Fatalf("want client and server spans, got %v", spans)
+				}
+				if serverSpan.Name != "Arith.Divide" {
+					t.Errorf("want server span named %q, got %q", "Arith.Divide", serverSpan.Name)
+				}
+				if serverSpan.TraceID != clientSpan.TraceID || serverSpan.ParentSpanID != clientSpan.SpanID {
+					t.Errorf("want server span to be a child of client span %v, got %v", clientSpan.SpanContext, serverSpan.SpanContext)
+				}
+				if a.Span() != serverSpan.SpanContext {
+					t.Errorf("want service method context span %v, got %v", serverSpan.SpanContext, a.Span())
+				}
+				if got := serverSpan.Status.Code != trace.StatusCodeOK; got != tc.wantErr {
+					t.Errorf("want server span error status %t, got %v", tc.wantErr, serverSpan.Status)
+				}
+			})
+		}
+	}
+}
+
+func TestCallWithoutPropagation(t *testing.T) {
+	for _, cs := range allCodecs {
+		t.Run(cs.name, func(t *testing.T) {
+			client, a := dial(t, &Codec{}, cs)
+			defer client.Close()
+
+			var got int
+			if err := client.Call("Arith.Divide", &Args{A: 6, B: 3}, &got); err != nil {
+				t.Fatalf("client.Call(): %v", err)
+			}
+			if got != 2 {
+				t.Errorf("client.Call(): want 2, got %d", got)
+			}
+			if a.Span().TraceID == (trace.TraceID{}) {
+				t.Errorf("want service method context span, got none")
+			}
+		})
+	}
+}
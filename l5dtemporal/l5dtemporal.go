@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package l5dtemporal propagates linkerd span context through Temporal
+// workflows and activities. Span context is carried from the service that
+// starts a workflow, through the workflow, to its activities, and out of those
+// activities via their HTTP calls:
+//
+//  c, err := client.Dial(client.Options{
+//    ContextPropagators: []workflow.ContextPropagator{&l5dtemporal.Propagator{}},
+//  })
+//
+// Workflows must be deterministic, so no spans are started within them; they
+// carry the span context of their caller to their activities unchanged.
+// Activities call StartSpan to start a span that is a child of the span that
+// started the workflow, then make HTTP calls with its context.
+package l5dtemporal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// HeaderKey is the Temporal header that carries span context. Its payload is a
+// map of the headers written by the propagation format.
+const HeaderKey = "l5d-ctx"
+
+type spanContextKey struct{}
+
+// A Propagator is a workflow.ContextPropagator that propagates span context via
+// Temporal headers.
+type Propagator struct {
+	// Propagation defines how traces are propagated. &linkin.HTTPFormat{} is
+	// used if Propagation is nil.
+	Propagation propagation.HTTPFormat
+}
+
+// Inject injects the span context of the span in the supplied context, or the
+// span context propagated to it, into the supplied headers.
+func (p *Propagator) Inject(ctx context.Context, w workflow.HeaderWriter) error {
+	if span := trace.FromContext(ctx); span != nil {
+		return p.inject(ctx, span.SpanContext(), w)
+	}
+	if sc, ok := ctx.Value(spanContextKey{}).(trace.SpanContext); ok {
+		return p.inject(ctx, sc, w)
+	}
+	return nil
+}
+
+// Extract returns a copy of the supplied context carrying the span context
+// extracted from the supplied headers, if any.
+func (p *Propagator) Extract(ctx context.Context, r workflow.HeaderReader) (context.Context, error) {
+	sc, ok, err := p.extract(ctx, r)
+	if err != nil || !ok {
+		return ctx, err
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc), nil
+}
+
+// InjectFromWorkflow injects the span context propagated to the supplied
+// workflow context, if any, into the supplied headers.
+func (p *Propagator) InjectFromWorkflow(ctx workflow.Context, w workflow.HeaderWriter) error {
+	if sc, ok := ctx.Value(spanContextKey{}).(trace.SpanContext); ok {
+		return p.inject(context.Background(), sc, w)
+	}
+	return nil
+}
+
+// ExtractToWorkflow returns a copy of the supplied workflow context carrying the
+// span context extracted from the supplied headers, if any.
+func (p *Propagator) ExtractToWorkflow(ctx workflow.Context, r workflow.HeaderReader) (workflow.Context, error) {
+	sc, ok, err := p.extract(context.Background(), r)
+	if err != nil || !ok {
+		return ctx, err
+	}
+	return workflow.WithValue(ctx, spanContextKey{}, sc), nil
+}
+
+func (p *Propagator) inject(ctx context.Context, sc trace.SpanContext, w workflow.HeaderWriter) error {
+	c := linkin.MapCarrier{}
+	linkin.SpanContextToCarrier(ctx, p.Propagation, sc, c)
+	payload, err := converter.GetDefaultDataConverter().ToPayload(map[string]string(c))
+	if err != nil {
+		return fmt.Errorf("cannot encode span context: %v", err)
+	}
+	w.Set(HeaderKey, payload)
+	return nil
+}
+
+func (p *Propagator) extract(ctx context.Context, r workflow.HeaderReader) (trace.SpanContext, bool, error) {
+	payload, ok := r.Get(HeaderKey)
+	if !ok {
+		return trace.SpanContext{}, false, nil
+	}
+	c := linkin.MapCarrier{}
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, (*map[string]string)(&c)); err != nil {
+		return trace.SpanContext{}, false, fmt.Errorf("cannot decode span context: %v", err)
+	}
+	sc, ok := linkin.SpanContextFromCarrier(ctx, p.Propagation, c)
+	return sc, ok, nil
+}
+
+// SpanContextFromContext returns the span context propagated to the supplied
+// activity context, if any.
+func SpanContextFromContext(ctx context.Context) (trace.SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(trace.SpanContext)
+	return sc, ok
+}
+
+// StartSpan starts a span with the supplied name, typically in an activity. The
+// span is a child of the span in the supplied context, if any, or of the span
+// context propagated to it, if any, or a new root span otherwise. HTTP calls
+// made with the returned context via an ochttp.Transport are children of the
+// returned span.
+func StartSpan(ctx context.Context, name string, o ...trace.StartOption) (context.Context, *trace.Span) {
+	if trace.FromContext(ctx) != nil {
+		return trace.StartSpan(ctx, name, o...)
+	}
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		return trace.StartSpanWithRemoteParent(ctx, name, sc, o...)
+	}
+	return trace.StartSpan(ctx, name, o...)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package l5dtemporal
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/trace"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/workflow"
+)
+
+// header is a workflow.HeaderReader and workflow.HeaderWriter backed by a map.
+type header map[string]*commonpb.Payload
+
+func (h header) Set(k string, p *commonpb.Payload) { h[k] = p }
+
+func (h header) Get(k string) (*commonpb.Payload, bool) {
+	p, ok := h[k]
+	return p, ok
+}
+
+func (h header) ForEachKey(fn func(string, *commonpb.Payload) error) error {
+	for k, p := range h {
+		if err := fn(k, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workflowContext is a workflow.Context that carries no values.
+type workflowContext struct {
+	workflow.Context
+}
+
+func (workflowContext) Value(interface{}) interface{} { return nil }
+
+func TestPropagator(t *testing.T) {
+	p := &Propagator{}
+	parentCtx, parent := trace.StartSpan(context.Background(), "api", trace.WithSampler(trace.AlwaysSample()))
+	defer parent.End()
+
+	cases := []struct {
+		name       string
+		ctx        context.Context
+		wantParent bool
+	}{
+		{name: "Span", ctx: parentCtx, wantParent: true},
+		{name: "NoSpan", ctx: context.Background()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// A service starts a workflow.
+			started := header{}
+			if err := p.Inject(tc.ctx, started); err != nil {
+				t.Fatalf("p.Inject(): %v", err)
+			}
+			if _, ok := started[HeaderKey]; ok != tc.wantParent {
+				t.Errorf("p.Inject(): want header %t, got %v", tc.wantParent, started)
+			}
+
+			// The workflow schedules an activity.
+			wctx, err := p.ExtractToWorkflow(workflowContext{}, started)
+			if err != nil {
+				t.Fatalf("p.ExtractToWorkflow(): %v", err)
+			}
+			scheduled := header{}
+			if err := p.InjectFromWorkflow(wctx, scheduled); err != nil {
+				t.Fatalf("p.InjectFromWorkflow(): %v", err)
+			}
+
+			// The activity starts a span.
+			actx, err := p.Extract(context.Background(), scheduled)
+			if err != nil {
+				t.Fatalf("p.Extract(): %v", err)
+			}
+			_, span := StartSpan(actx, "activity")
+			defer span.End()
+
+			got := span.SpanContext()
+			want := parent.SpanContext()
+			if sameTrace := got.TraceID == want.TraceID; sameTrace != tc.wantParent {
+				t.Errorf("StartSpan(): want trace ID %v (%t), got %v", want.TraceID, tc.wantParent, got.TraceID)
+			}
+			if tc.wantParent && !got.IsSampled() {
+				t.Errorf("StartSpan(): want sampled span")
+			}
+		})
+	}
+}
+
+func TestExtractInvalidPayload(t *testing.T) {
+	h := header{HeaderKey: &commonpb.Payload{Data: []byte("not json")}}
+	if _, err := (&Propagator{}).Extract(context.Background(), h); err == nil {
+		t.Errorf("p.Extract(): want error, got nil")
+	}
+	if _, err := (&Propagator{}).ExtractToWorkflow(workflowContext{}, h); err == nil {
+		t.Errorf("p.ExtractToWorkflow(): want error, got nil")
+	}
+}
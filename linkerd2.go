@@ -0,0 +1,86 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+// Headers used by linkerd2.
+const (
+	l5d2HeaderDstOverride = "l5d-dst-override"
+	l5d2HeaderClientID    = "l5d-client-id"
+	l5d2HeaderProxyError  = "l5d-proxy-error"
+)
+
+// Linkerd2Format implements propagation.HTTPFormat to propagate traces through
+// linkerd2 deployments. Unlike linkerd, linkerd2 proxies do not use the
+// l5d-ctx-trace header; they participate in traces propagated via B3 or W3C
+// trace context headers. Linkerd2Format extracts span context from W3C trace
+// context headers if present and from B3 headers if not. It injects span
+// context in both formats.
+type Linkerd2Format struct {
+	w3c TraceContextFormat
+	b3  B3Format
+}
+
+// SpanContextFromRequest extracts span context from incoming requests in W3C
+// trace context format if possible, and in B3 format if not.
+func (f *Linkerd2Format) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	if sc, ok := f.w3c.SpanContextFromRequest(r); ok {
+		return sc, true
+	}
+	return f.b3.SpanContextFromRequest(r)
+}
+
+// SpanContextToRequest modifies the given request to include both W3C trace
+// context and B3 headers derived from the given SpanContext.
+func (f *Linkerd2Format) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	f.w3c.SpanContextToRequest(sc, r)
+	f.b3.SpanContextToRequest(sc, r)
+}
+
+// SetDstOverride modifies the given request to include an l5d-dst-override
+// header, instructing linkerd2 to route the request to the supplied authority
+// (e.g. web.default.svc.cluster.local:8080) regardless of its Host header.
+func SetDstOverride(r *http.Request, authority string) {
+	r.Header.Set(l5d2HeaderDstOverride, authority)
+}
+
+// DstOverride returns the authority in the given request's l5d-dst-override
+// header, if any.
+func DstOverride(r *http.Request) string {
+	return r.Header.Get(l5d2HeaderDstOverride)
+}
+
+// ClientID returns the TLS identity of the client that sent the given request,
+// as asserted by the linkerd2 proxy via the l5d-client-id header. ClientID
+// returns an empty string for requests that were not sent over a meshed TLS
+// connection.
+func ClientID(r *http.Request) string {
+	return r.Header.Get(l5d2HeaderClientID)
+}
+
+// ProxyError returns the error reported by a linkerd2 proxy via the given
+// response's l5d-proxy-error header, if any. A non-empty ProxyError indicates
+// the response was generated by a proxy rather than by the service that was
+// sent the request.
+func ProxyError(rsp *http.Response) string {
+	return rsp.Header.Get(l5d2HeaderProxyError)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestLinkerd2FormatSatisfiesHTTPFormat(t *testing.T) {
+	var _ propagation.HTTPFormat = (*Linkerd2Format)(nil)
+}
+
+func TestLinkerd2Format(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 50, 164, 219, 32, 245, 213, 146, 231},
+		SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+		TraceOptions: ocShouldSample,
+	}
+
+	cases := []struct {
+		name   string
+		remove []string
+	}{
+		{name: "BothFormats"},
+		{name: "W3COnly", remove: []string{b3.TraceIDHeader, b3.SpanIDHeader, b3.SampledHeader}},
+		{name: "B3Only", remove: []string{"traceparent", "tracestate"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &Linkerd2Format{}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(sc, r)
+			for _, h := range tc.remove {
+				r.Header.Del(h)
+			}
+
+			got, ok := f.SpanContextFromRequest(r)
+			if !ok {
+				t.Fatalf("f.SpanContextFromRequest(): want ok true, got false")
+			}
+			got.Tracestate = nil
+			if got != sc {
+				t.Errorf("f.SpanContextFromRequest():\ngot:  %+v\nwant: %+v\n", got, sc)
+			}
+		})
+	}
+}
+
+func TestLinkerd2Headers(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	SetDstOverride(r, "web.default.svc.cluster.local:8080")
+	if got, want := DstOverride(r), "web.default.svc.cluster.local:8080"; got != want {
+		t.Errorf("DstOverride(): want %q, got %q", want, got)
+	}
+
+	r.Header.Set(l5d2HeaderClientID, "web.default.serviceaccount.identity.linkerd.cluster.local")
+	if got, want := ClientID(r), "web.default.serviceaccount.identity.linkerd.cluster.local"; got != want {
+		t.Errorf("ClientID(): want %q, got %q", want, got)
+	}
+
+	rsp := &http.Response{Header: http.Header{}}
+	rsp.Header.Set(l5d2HeaderProxyError, "connection refused")
+	if got, want := ProxyError(rsp), "connection refused"; got != want {
+		t.Errorf("ProxyError(): want %q, got %q", want, got)
+	}
+}
@@ -46,17 +46,30 @@ and limitations under the License.
 package linkin
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"net/http"
+	"strconv"
 
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 )
 
 const (
-	l5dHeaderTrace = "l5d-ctx-trace"
+	l5dHeaderPrefix = "l5d-ctx-"
+	l5dHeaderTrace  = "l5d-ctx-trace"
+	l5dHeaderSample = "l5d-sample"
 
-	l5dFlagShouldSample byte               = 6
-	ocShouldSample      trace.TraceOptions = 1
+	ocShouldSample trace.TraceOptions = 1
+
+	// OpenCensus uses only the lowest bit of its trace options. We use the
+	// next bit to represent Finagle's debug flag. OpenCensus copies trace
+	// options from parent to child spans, so the debug flag survives from
+	// incoming to outgoing requests.
+	ocDebug trace.TraceOptions = 2
 )
 
 // HTTPFormat implements propagation.HTTPFormat to propagate traces in HTTP
@@ -65,50 +78,225 @@ const (
 // from the incoming header will be the direct children of the client-side span.
 // Similarly, the receiver of the outgoing spans should use client-side span
 // created by OpenCensus as the parent.
-type HTTPFormat struct{}
+type HTTPFormat struct {
+	// CookieName is the name of an HTTP cookie from which span context will be
+	// extracted when an incoming request has no l5d-ctx-trace header. This
+	// allows a series of browser requests, for example a redirect heavy
+	// authentication flow, to be stitched into one trace at the edge of the
+	// mesh. Span context is never extracted from a cookie if CookieName is
+	// empty. Use SetCookie to set the cookie.
+	CookieName string
 
-func shouldSample(f byte) bool {
-	// If the debug bit is set, we should sample.
-	if f&1 != 0 {
+	// Linkerd detects the capabilities of the linkerd to which requests are
+	// sent. Outgoing l5d-ctx-trace headers omit the high 64 bits of the trace
+	// ID unless linkerd supports 128 bit trace IDs. Incoming requests are
+	// observed by Linkerd. 40 byte headers are always emitted if Linkerd is nil.
+	Linkerd *Detector
+
+	// Encoding is the base64 encoding of outgoing l5d-ctx-trace headers, for
+	// forked meshes and intermediaries that expect a specific variant, e.g.
+	// base64.RawURLEncoding. base64.StdEncoding is used if Encoding is nil.
+	// Incoming headers may use any standard or URL safe variant.
+	Encoding *base64.Encoding
+
+	// Strict causes SpanContextToRequest to refuse to inject invalid span
+	// contexts, i.e. those with a zero trace or span ID, rather than emit
+	// headers that downstream services would propagate. Each refusal is
+	// recorded as an InvalidInjections measurement.
+	Strict bool
+
+	// Preserve causes SpanContextToRequest to leave any l5d-ctx-trace header
+	// already present on an outgoing request untouched, rather than overwrite
+	// it with the supplied span context. This suits pure proxies, which should
+	// forward the span context they received. See also the Preserve fields of
+	// TagTransport and baggage.Transport, which inject the other l5d-ctx-*
+	// headers.
+	Preserve bool
+}
+
+// A SamplingReason explains the sampling decision made for a span context
+// extracted from an incoming request.
+type SamplingReason string
+
+// Sampling reasons.
+const (
+	// SampledInherited span contexts were sampled upstream.
+	SampledInherited SamplingReason = "inherited"
+
+	// SampledL5dSample span contexts were sampled upstream at the rate
+	// specified by the l5d-sample header of the incoming request.
+	SampledL5dSample SamplingReason = "l5d-sample"
+
+	// SampledDebug span contexts were sampled because the debug flag was set
+	// upstream.
+	SampledDebug SamplingReason = "debug"
+
+	// SampledLocal spans were sampled by the local sampler, either because
+	// they are root spans or because the sampler overrode the upstream
+	// sampling decision.
+	SampledLocal SamplingReason = "local"
+
+	// SampledError spans were not sampled when they started, but were
+	// exported because their request failed. See ErrorSamplingHandler.
+	SampledError SamplingReason = "error"
+
+	// SampledSlow spans were not sampled when they started, but were exported
+	// because their request was slow. See LatencySamplingHandler.
+	SampledSlow SamplingReason = "slow"
+
+	// NotSampled span contexts were not sampled.
+	NotSampled SamplingReason = "unsampled"
+)
+
+// sampledAt deterministically decides whether the supplied trace ID should be
+// sampled at the supplied rate, between 0 and 1. Only the low 64 bits of the
+// trace ID are considered, because the high 64 bits are zero for the many
+// Finagle (and thus linkerd) traces that use 64 bit IDs.
+func sampledAt(id trace.TraceID, rate float64) bool {
+	if rate >= 1 {
 		return true
 	}
-	// If the sampling known and sampled bits are set, we should sample.
-	return f&(1<<1) != 0 && f&(1<<2) != 0
+	if !(rate > 0) {
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:16])>>1 < uint64(rate*(1<<63))
+}
+
+// IsDebug returns true if the supplied SpanContext has the debug flag set. The
+// debug flag forces a trace to be sampled at every hop. It is set on span
+// contexts extracted from incoming requests with Finagle's debug flag set, and
+// on those extracted by B3Format from requests with the X-B3-Flags: 1 header.
+// W3C trace context has no debug flag, so TraceContextFormat omits it.
+func IsDebug(sc trace.SpanContext) bool {
+	return sc.TraceOptions&ocDebug != 0
+}
+
+func shouldSample(f byte) bool {
+	return wire.TraceID{Flags: uint64(f)}.Sampled()
 }
 
 // SpanContextFromRequest extracts linkerd span context from incoming requests.
 func (f *HTTPFormat) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
-	sc := trace.SpanContext{}
-	b, err := base64.StdEncoding.DecodeString(r.Header.Get(l5dHeaderTrace))
-	if err != nil {
-		return sc, false
+	if f.Linkerd != nil {
+		f.Linkerd.Observe(r.Header)
+	}
+	return f.extract(r.Context(), f.header(r), r.Header)
+}
+
+// header returns the l5d-ctx-trace header of the supplied request, or the value
+// of the configured cookie if the request has no such header.
+func (f *HTTPFormat) header(r *http.Request) string {
+	h := headerValue(r.Header, l5dHeaderTrace)
+	if h == "" && f.CookieName != "" {
+		if c, err := r.Cookie(f.CookieName); err == nil {
+			h = c.Value
+		}
+	}
+	return h
+}
+
+// SpanContextFromResponse extracts linkerd span context from the supplied
+// response, for flows such as reverse callbacks and webhook verification in
+// which span context arrives on a response rather than a request.
+func (f *HTTPFormat) SpanContextFromResponse(rsp *http.Response) (trace.SpanContext, bool) {
+	ctx := context.Background()
+	if rsp.Request != nil {
+		ctx = rsp.Request.Context()
 	}
-	if len(b) != 32 && len(b) != 40 {
+	return f.extract(ctx, headerValue(rsp.Header, l5dHeaderTrace), rsp.Header)
+}
+
+// extract decodes the supplied l5d-ctx-trace header value and records the
+// reason for the resulting span context's sampling decision.
+func (f *HTTPFormat) extract(ctx context.Context, h string, hdr http.Header) (trace.SpanContext, bool) {
+	sc, ok := decode(ctx, h)
+	if !ok {
 		return sc, false
 	}
+	reason := extractedReason(hdr, sc)
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(KeySamplingReason, string(reason))}, SamplingDecisions.M(1))
+	return sc, true
+}
 
-	if len(b) == 40 {
-		copy(sc.TraceID[0:8], b[32:])
+// extractedReason explains the upstream sampling decision of a span context
+// extracted from the supplied headers. The decision itself is never changed.
+func extractedReason(h http.Header, sc trace.SpanContext) SamplingReason {
+	if IsDebug(sc) {
+		return SampledDebug
+	}
+	if !sc.IsSampled() {
+		return NotSampled
 	}
-	copy(sc.TraceID[8:16], b[16:24])
-	copy(sc.SpanID[:], b[0:8])
+	if rate, err := strconv.ParseFloat(headerValue(h, l5dHeaderSample), 64); err == nil && sampledAt(sc.TraceID, rate) {
+		return SampledL5dSample
+	}
+	return SampledInherited
+}
 
-	if shouldSample(b[31]) {
-		sc.TraceOptions = ocShouldSample
+// StartOptions returns start options for the server span of the supplied
+// request. The span is sampled at the rate specified by the request's
+// l5d-sample header, if any, rather than by the default sampler. StartOptions
+// is intended for use as the GetStartOptions function of an ochttp.Handler, so
+// that root spans honor the caller specified sampling rate just as extracted
+// span contexts do.
+func StartOptions(r *http.Request) trace.StartOptions {
+	rate, err := strconv.ParseFloat(headerValue(r.Header, l5dHeaderSample), 64)
+	if err != nil {
+		return trace.StartOptions{}
 	}
+	return trace.StartOptions{Sampler: probabilitySampler(rate)}
+}
 
-	return sc, true
+func decode(ctx context.Context, h string) (trace.SpanContext, bool) {
+	id, ok := parse(ctx, h)
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	return id.SpanContext(), true
 }
 
 // SpanContextToRequest modifies the given request to include an l5d-ctx-trace
-// HTTP header derived from the given SpanContext.
+// HTTP header derived from the given SpanContext. The header's parent ID is
+// zero unless a parent was recorded in the request's context by ParentTransport.
 func (f *HTTPFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
-	b := [40]byte{}
-	copy(b[0:8], sc.SpanID[:])
-	copy(b[16:24], sc.TraceID[8:16])
-	copy(b[32:], sc.TraceID[0:8])
-	if sc.IsSampled() {
-		b[31] = l5dFlagShouldSample
-	}
-	r.Header.Set(l5dHeaderTrace, base64.StdEncoding.EncodeToString(b[:]))
+	if f.Preserve && headerValue(r.Header, l5dHeaderTrace) != "" {
+		return
+	}
+	if f.Strict && !validSpanContext(sc) {
+		stats.Record(r.Context(), InvalidInjections.M(1))
+		return
+	}
+	id := traceIDFromSpanContext(sc)
+	if p, ok := parentFromContext(r.Context(), sc); ok {
+		id.Parent = p
+	}
+	enc := f.Encoding
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	if f.Linkerd != nil && !f.Linkerd.Capabilities().TraceID128 {
+		r.Header.Set(l5dHeaderTrace, wire.TraceID(id).Encode32(enc))
+		return
+	}
+	r.Header.Set(l5dHeaderTrace, wire.TraceID(id).Encode(enc))
+}
+
+// SetCookie sets a cookie containing the given SpanContext, encoded as per the
+// l5d-ctx-trace header, on the given response. The cookie is named CookieName.
+// SetCookie does nothing if CookieName is empty.
+func (f *HTTPFormat) SetCookie(w http.ResponseWriter, sc trace.SpanContext) {
+	if f.CookieName == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: f.CookieName, Value: encode(sc), Path: "/", HttpOnly: true})
+}
+
+// validSpanContext returns true if the supplied span context has non-zero trace
+// and span IDs.
+func validSpanContext(sc trace.SpanContext) bool {
+	return sc.TraceID != trace.TraceID{} && sc.SpanID != trace.SpanID{}
+}
+
+func encode(sc trace.SpanContext) string {
+	return traceIDFromSpanContext(sc).String()
 }
@@ -17,9 +17,14 @@ and limitations under the License.
 package linkin
 
 import (
+	"encoding/base64"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 	"go.opencensus.io/trace/propagation"
 )
@@ -129,6 +134,16 @@ func TestSpanContextFromRequest(t *testing.T) {
 				SpanID:  trace.SpanID{149, 161, 0, 109, 39, 5, 71, 248},
 			},
 		},
+		{
+			name: "ValidHeaderWithDebugEnabled",
+			r:    requestWithHeader("9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAA=="),
+			ok:   true,
+			sc: trace.SpanContext{
+				TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+				TraceOptions: ocShouldSample | ocDebug,
+			},
+		},
 		{
 			name: "InvalidHeaderEncoding",
 			r:    requestWithHeader("PROBABLYNOTBASE64"),
@@ -157,9 +172,10 @@ func TestSpanContextFromRequest(t *testing.T) {
 
 func TestSpanContextToRequest(t *testing.T) {
 	cases := []struct {
-		name   string
-		header string
-		sc     trace.SpanContext
+		name     string
+		encoding *base64.Encoding
+		header   string
+		sc       trace.SpanContext
 	}{
 		{
 			name:   "ValidHeaderWithSamplingEnabled",
@@ -178,6 +194,15 @@ func TestSpanContextToRequest(t *testing.T) {
 				SpanID:  trace.SpanID{149, 161, 0, 109, 39, 5, 71, 248},
 			},
 		},
+		{
+			name:   "ValidHeaderWithDebugEnabled",
+			header: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAA==",
+			sc: trace.SpanContext{
+				TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+				TraceOptions: ocShouldSample | ocDebug,
+			},
+		},
 		{
 			name:   "ValidHeaderWith128BitTraceID",
 			header: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAQ==",
@@ -187,10 +212,19 @@ func TestSpanContextToRequest(t *testing.T) {
 				TraceOptions: ocShouldSample,
 			},
 		},
+		{
+			name:     "RawURLEncoding",
+			encoding: base64.RawURLEncoding,
+			header:   "laEAbScFR_gAAAAAAAAAAP8jOugI0dtmAAAAAAAAAAAAAAAAAAAAAA",
+			sc: trace.SpanContext{
+				TraceID: trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 255, 35, 58, 232, 8, 209, 219, 102},
+				SpanID:  trace.SpanID{149, 161, 0, 109, 39, 5, 71, 248},
+			},
+		},
 	}
 
 	for _, tc := range cases {
-		f := &HTTPFormat{}
+		f := &HTTPFormat{Encoding: tc.encoding}
 		t.Run(tc.name, func(t *testing.T) {
 			r, _ := http.NewRequest("GET", "http://example.org", nil)
 			f.SpanContextToRequest(tc.sc, r)
@@ -201,3 +235,418 @@ func TestSpanContextToRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestSpanContextToRequestStrict(t *testing.T) {
+	cases := []struct {
+		name    string
+		strict  bool
+		sc      trace.SpanContext
+		want    bool
+		refused int64
+	}{
+		{
+			name:   "Valid",
+			strict: true,
+			sc:     trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}},
+			want:   true,
+		},
+		{
+			name:    "ZeroTraceID",
+			strict:  true,
+			sc:      trace.SpanContext{SpanID: trace.SpanID{1}},
+			refused: 1,
+		},
+		{
+			name:    "ZeroSpanID",
+			strict:  true,
+			sc:      trace.SpanContext{TraceID: trace.TraceID{1}},
+			refused: 1,
+		},
+		{
+			name: "NotStrict",
+			sc:   trace.SpanContext{},
+			want: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := view.Register(InvalidInjectionsView); err != nil {
+				t.Fatalf("view.Register(): %v", err)
+			}
+			defer view.Unregister(InvalidInjectionsView)
+
+			f := &HTTPFormat{Strict: tc.strict}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(tc.sc, r)
+			if got := r.Header.Get(l5dHeaderTrace) != ""; got != tc.want {
+				t.Errorf("f.SpanContextToRequest(): want header %t, got %v", tc.want, r.Header)
+			}
+
+			rows, err := view.RetrieveData(InvalidInjectionsView.Name)
+			if err != nil {
+				t.Fatalf("view.RetrieveData(): %v", err)
+			}
+			var refused int64
+			for _, row := range rows {
+				refused += row.Data.(*view.CountData).Value
+			}
+			if refused != tc.refused {
+				t.Errorf("view.RetrieveData(): want %d refused injections, got %d", tc.refused, refused)
+			}
+		})
+	}
+}
+
+func TestSpanContextToRequestPreserve(t *testing.T) {
+	existing := "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+	sc := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}}
+
+	cases := []struct {
+		name      string
+		preserve  bool
+		existing  string
+		lowercase bool
+		want      string
+	}{
+		{
+			name:     "Preserved",
+			preserve: true,
+			existing: existing,
+			want:     existing,
+		},
+		{
+			name:      "PreservedLowercase",
+			preserve:  true,
+			existing:  existing,
+			lowercase: true,
+			want:      existing,
+		},
+		{
+			name:     "NothingToPreserve",
+			preserve: true,
+			want:     encode(sc),
+		},
+		{
+			name:     "Overwritten",
+			existing: existing,
+			want:     encode(sc),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			switch {
+			case tc.lowercase:
+				r.Header[l5dHeaderTrace] = []string{tc.existing}
+			case tc.existing != "":
+				r.Header.Set(l5dHeaderTrace, tc.existing)
+			}
+			(&HTTPFormat{Preserve: tc.preserve}).SpanContextToRequest(sc, r)
+			if got := headerValue(r.Header, l5dHeaderTrace); got != tc.want || len(r.Header) != 1 {
+				t.Errorf("f.SpanContextToRequest(): want header %s, got %v", tc.want, r.Header)
+			}
+		})
+	}
+}
+
+func TestSpanContextFromCookie(t *testing.T) {
+	sampled := trace.SpanContext{
+		TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+		SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+		TraceOptions: ocShouldSample,
+	}
+	unsampled := trace.SpanContext{
+		TraceID: trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 255, 35, 58, 232, 8, 209, 219, 102},
+		SpanID:  trace.SpanID{149, 161, 0, 109, 39, 5, 71, 248},
+	}
+
+	cases := []struct {
+		name   string
+		f      *HTTPFormat
+		header string
+		cookie string
+		ok     bool
+		sc     trace.SpanContext
+	}{
+		{
+			name:   "CookieOnly",
+			f:      &HTTPFormat{CookieName: "l5d"},
+			cookie: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			ok:     true,
+			sc:     sampled,
+		},
+		{
+			name:   "HeaderTakesPrecedence",
+			f:      &HTTPFormat{CookieName: "l5d"},
+			header: "laEAbScFR/gDfE/j8FV/8P8jOugI0dtmAAAAAAAAAAA=",
+			cookie: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			ok:     true,
+			sc:     unsampled,
+		},
+		{
+			name:   "CookieDisabled",
+			f:      &HTTPFormat{},
+			cookie: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			ok:     false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			r.AddCookie(&http.Cookie{Name: "l5d", Value: tc.cookie})
+			got, ok := tc.f.SpanContextFromRequest(r)
+			if ok != tc.ok {
+				t.Errorf("f.SpanContextFromRequest(): want ok %v, got %v", tc.ok, ok)
+			}
+			if got != tc.sc {
+				t.Errorf("f.SpanContextFromRequest():\ngot:  %+v\nwant: %+v\n", got, tc.sc)
+			}
+		})
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+		SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+		TraceOptions: ocShouldSample,
+	}
+	f := &HTTPFormat{CookieName: "l5d"}
+	w := httptest.NewRecorder()
+	f.SetCookie(w, sc)
+
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	got, ok := f.SpanContextFromRequest(r)
+	if !ok {
+		t.Fatalf("f.SpanContextFromRequest(): want ok true, got false")
+	}
+	if got != sc {
+		t.Errorf("f.SpanContextFromRequest():\ngot:  %+v\nwant: %+v\n", got, sc)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	const (
+		sampled   = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+		unsampled = "laEAbScFR/gDfE/j8FV/8P8jOugI0dtmAAAAAAAAAAA="
+	)
+
+	cases := []struct {
+		name    string
+		f       *HTTPFormat
+		header  string
+		sample  string
+		sampled bool
+		reason  SamplingReason
+	}{
+		{
+			name:    "Inherited",
+			f:       &HTTPFormat{},
+			header:  sampled,
+			sampled: true,
+			reason:  SampledInherited,
+		},
+		{
+			name:    "NotSampled",
+			f:       &HTTPFormat{},
+			header:  unsampled,
+			sampled: false,
+			reason:  NotSampled,
+		},
+		{
+			name:    "L5dSample",
+			f:       &HTTPFormat{},
+			header:  sampled,
+			sample:  "1.0",
+			sampled: true,
+			reason:  SampledL5dSample,
+		},
+		{
+			name:    "L5dSampleNotResampled",
+			f:       &HTTPFormat{},
+			header:  unsampled,
+			sample:  "1.0",
+			sampled: false,
+			reason:  NotSampled,
+		},
+		{
+			name:    "L5dSampleNotUnsampled",
+			f:       &HTTPFormat{},
+			header:  sampled,
+			sample:  "0",
+			sampled: true,
+			reason:  SampledInherited,
+		},
+		{
+			name:    "L5dSampleInvalid",
+			f:       &HTTPFormat{},
+			header:  sampled,
+			sample:  "often",
+			sampled: true,
+			reason:  SampledInherited,
+		},
+		{
+			name:    "Debug",
+			f:       &HTTPFormat{},
+			header:  "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAEAAAAAAAAAAA==",
+			sample:  "0",
+			sampled: true,
+			reason:  SampledDebug,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := view.Register(SamplingDecisionsView); err != nil {
+				t.Fatalf("view.Register(): %v", err)
+			}
+			defer view.Unregister(SamplingDecisionsView)
+
+			r := requestWithHeader(tc.header)
+			if tc.sample != "" {
+				r.Header.Set(l5dHeaderSample, tc.sample)
+			}
+			sc, ok := tc.f.SpanContextFromRequest(r)
+			if !ok {
+				t.Fatalf("f.SpanContextFromRequest(): want ok true, got false")
+			}
+			if sc.IsSampled() != tc.sampled {
+				t.Errorf("f.SpanContextFromRequest(): want sampled %v, got %v", tc.sampled, sc.IsSampled())
+			}
+
+			rows, err := view.RetrieveData(SamplingDecisionsView.Name)
+			if err != nil {
+				t.Fatalf("view.RetrieveData(): %v", err)
+			}
+			want := []tag.Tag{{Key: KeySamplingReason, Value: string(tc.reason)}}
+			if len(rows) != 1 || !reflect.DeepEqual(rows[0].Tags, want) {
+				t.Errorf("view.RetrieveData(): want one row tagged %v, got %v", want, rows)
+			}
+		})
+	}
+}
+
+func TestStartOptions(t *testing.T) {
+	// The low 64 bits of this trace ID, shifted right by one, are 0.375 of the
+	// maximum value.
+	id := trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 0x60}
+
+	cases := []struct {
+		name      string
+		sample    string
+		parent    trace.SpanContext
+		byDefault bool
+		want      bool
+	}{
+		{
+			name:      "NoSampleHeader",
+			byDefault: true,
+		},
+		{
+			name:      "InvalidSampleHeader",
+			sample:    "lots",
+			byDefault: true,
+		},
+		{
+			name:   "SampledAtRate",
+			sample: "0.4",
+			want:   true,
+		},
+		{
+			name:   "NotSampledAtRate",
+			sample: "0.3",
+			want:   false,
+		},
+		{
+			name:   "SampledParent",
+			sample: "0.3",
+			parent: trace.SpanContext{TraceID: id, TraceOptions: ocShouldSample},
+			want:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			if tc.sample != "" {
+				r.Header.Set(l5dHeaderSample, tc.sample)
+			}
+			o := StartOptions(r)
+			if tc.byDefault {
+				if o.Sampler != nil {
+					t.Errorf("StartOptions(): want default sampler, got custom sampler")
+				}
+				return
+			}
+			if o.Sampler == nil {
+				t.Fatalf("StartOptions(): want custom sampler, got default sampler")
+			}
+			if got := o.Sampler(trace.SamplingParameters{ParentContext: tc.parent, TraceID: id}).Sample; got != tc.want {
+				t.Errorf("o.Sampler(): want sampled %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSpanContextFromResponse(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		ok     bool
+		sc     trace.SpanContext
+	}{
+		{
+			name:   "ValidHeader",
+			header: http.Header{"L5d-Ctx-Trace": []string{"9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="}},
+			ok:     true,
+			sc: trace.SpanContext{
+				TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+				TraceOptions: ocShouldSample,
+			},
+		},
+		{
+			name: "ValidHeaderWithSampleHeader",
+			header: http.Header{
+				"L5d-Ctx-Trace": []string{"9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="},
+				"L5d-Sample":    []string{"0"},
+			},
+			ok: true,
+			sc: trace.SpanContext{
+				TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+				TraceOptions: ocShouldSample,
+			},
+		},
+		{
+			name:   "InvalidHeader",
+			header: http.Header{"L5d-Ctx-Trace": []string{"PROBABLYNOTBASE64"}},
+		},
+		{
+			name:   "NoHeader",
+			header: http.Header{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &HTTPFormat{}
+			sc, ok := f.SpanContextFromResponse(&http.Response{Header: tc.header})
+			if ok != tc.ok {
+				t.Fatalf("f.SpanContextFromResponse(): want ok %t, got %t", tc.ok, ok)
+			}
+			if sc != tc.sc {
+				t.Errorf("f.SpanContextFromResponse():\ngot:  %+v\nwant: %+v\n", sc, tc.sc)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type memoKey struct{}
+
+// A memo caches the most recently decoded l5d-ctx-trace header of a request.
+type memo struct {
+	mu sync.Mutex
+	h  string
+	id TraceID
+	ok bool
+}
+
+// Memoize wraps the supplied handler, caching the decoded l5d-ctx-trace header
+// of each request in its context. Stacked middleware and handlers that each
+// extract span context from a request (e.g. via HTTPFormat, TraceIDFromRequest,
+// or AnnotateRoot) will then decode and validate the header only once. The
+// header is decoded again if it changes. Memoize should wrap all other
+// middleware, including any ochttp.Handler.
+func Memoize(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(memoKey{}).(*memo); ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), memoKey{}, &memo{})))
+	})
+}
+
+// parse decodes the supplied l5d-ctx-trace header, using the memo in the
+// supplied context (if any) to avoid repeatedly decoding the same header.
+func parse(ctx context.Context, h string) (TraceID, bool) {
+	m, ok := ctx.Value(memoKey{}).(*memo)
+	if !ok {
+		id, err := ParseTraceID(h)
+		return id, err == nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.h == h && h != "" {
+		return m.id, m.ok
+	}
+	id, err := ParseTraceID(h)
+	m.h, m.id, m.ok = h, id, err == nil
+	return m.id, m.ok
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMemoize(t *testing.T) {
+	const (
+		sampled   = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+		unsampled = "laEAbScFR/gDfE/j8FV/8P8jOugI0dtmAAAAAAAAAAA="
+	)
+
+	h := Memoize(Memoize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m, ok := r.Context().Value(memoKey{}).(*memo)
+		if !ok {
+			t.Fatalf("Memoize(): want memo in request context")
+		}
+
+		want, _ := ParseTraceID(sampled)
+		if got, ok := TraceIDFromRequest(r); !ok || got != want {
+			t.Errorf("TraceIDFromRequest(): want %+v, got %+v", want, got)
+		}
+		if m.h != sampled || m.id != want {
+			t.Errorf("TraceIDFromRequest(): want memoized %+v, got %+v", want, m.id)
+		}
+
+		// Memoized span contexts are subject to sampling as usual.
+		f := &HTTPFormat{}
+		if sc, ok := f.SpanContextFromRequest(r); !ok || sc != want.SpanContext() {
+			t.Errorf("f.SpanContextFromRequest(): want %+v, got %+v", want.SpanContext(), sc)
+		}
+
+		// Changing the header invalidates the memo.
+		r.Header.Set(l5dHeaderTrace, unsampled)
+		want, _ = ParseTraceID(unsampled)
+		if got, ok := TraceIDFromRequest(r); !ok || got != want {
+			t.Errorf("TraceIDFromRequest(): want %+v, got %+v", want, got)
+		}
+
+		r.Header.Set(l5dHeaderTrace, "PROBABLYNOTBASE64")
+		if _, ok := TraceIDFromRequest(r); ok {
+			t.Errorf("TraceIDFromRequest(): want invalid header")
+		}
+	})))
+
+	r := httptest.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set(l5dHeaderTrace, sampled)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// EnvMesh is an environment variable that may be used to explicitly declare
+// whether a process is running behind linkerd. Its value should be parseable
+// by strconv.ParseBool.
+const EnvMesh = "LINKIN_MESH"
+
+// linkerd's outgoing router conventionally listens on this port, and is used
+// as the HTTP proxy of meshed processes.
+const l5dOutgoingPort = "4140"
+
+// InMesh returns true if this process appears to be running behind linkerd.
+// The LINKIN_MESH environment variable is honored if set. Otherwise a process
+// is assumed to be in the mesh if its HTTP proxy listens on linkerd's
+// conventional outgoing router port.
+func InMesh() bool {
+	if v, err := strconv.ParseBool(os.Getenv(EnvMesh)); err == nil {
+		return v
+	}
+	for _, k := range []string{"http_proxy", "HTTP_PROXY"} {
+		if strings.HasSuffix(strings.TrimRight(os.Getenv(k), "/"), ":"+l5dOutgoingPort) {
+			return true
+		}
+	}
+	return false
+}
+
+// FallbackFormat implements propagation.HTTPFormat to propagate traces in
+// linkerd propagation format when running behind linkerd, and in a fallback
+// format otherwise. This allows the same binary to run in and out of the mesh.
+//
+// Span context is extracted from the linkerd format when an incoming request
+// contains a valid l5d-ctx-trace header, and from the fallback format
+// otherwise. Span context is injected in linkerd format if InMesh returns true
+// the first time it is called, and in the fallback format otherwise.
+type FallbackFormat struct {
+	// Linkerd is the linkerd propagation format. &HTTPFormat{} is used if
+	// Linkerd is nil.
+	Linkerd propagation.HTTPFormat
+
+	// Fallback is the propagation format used outside the mesh. B3
+	// propagation is used if Fallback is nil.
+	Fallback propagation.HTTPFormat
+
+	// InMesh reports whether this process is running behind linkerd. The
+	// package level InMesh function is used if InMesh is nil.
+	InMesh func() bool
+
+	once   sync.Once
+	inMesh bool
+}
+
+func (f *FallbackFormat) linkerd() propagation.HTTPFormat {
+	if f.Linkerd == nil {
+		return &HTTPFormat{}
+	}
+	return f.Linkerd
+}
+
+func (f *FallbackFormat) fallback() propagation.HTTPFormat {
+	if f.Fallback == nil {
+		return &B3Format{}
+	}
+	return f.Fallback
+}
+
+func (f *FallbackFormat) meshed() bool {
+	f.once.Do(func() {
+		if f.InMesh == nil {
+			f.inMesh = InMesh()
+			return
+		}
+		f.inMesh = f.InMesh()
+	})
+	return f.inMesh
+}
+
+// SpanContextFromRequest extracts span context from incoming requests in
+// linkerd propagation format if possible, and in the fallback format if not.
+func (f *FallbackFormat) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	if sc, ok := f.linkerd().SpanContextFromRequest(r); ok {
+		return sc, true
+	}
+	return f.fallback().SpanContextFromRequest(r)
+}
+
+// SpanContextToRequest modifies the given request to include headers derived
+// from the given SpanContext, in linkerd propagation format when running
+// behind linkerd and in the fallback format otherwise.
+func (f *FallbackFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	if f.meshed() {
+		f.linkerd().SpanContextToRequest(sc, r)
+		return
+	}
+	f.fallback().SpanContextToRequest(sc, r)
+}
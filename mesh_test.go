@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestFallbackFormatSatisfiesHTTPFormat(t *testing.T) {
+	var _ propagation.HTTPFormat = (*FallbackFormat)(nil)
+}
+
+func setenv(t *testing.T, env map[string]string) func() {
+	old := map[string]string{}
+	for k, v := range env {
+		old[k] = os.Getenv(k)
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("os.Setenv(%q): %v", k, err)
+		}
+	}
+	return func() {
+		for k, v := range old {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+func TestInMesh(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{
+			name: "ExplicitlyInMesh",
+			env:  map[string]string{EnvMesh: "true", "http_proxy": "", "HTTP_PROXY": ""},
+			want: true,
+		},
+		{
+			name: "ExplicitlyNotInMesh",
+			env:  map[string]string{EnvMesh: "false", "http_proxy": "http://node:4140", "HTTP_PROXY": ""},
+			want: false,
+		},
+		{
+			name: "LinkerdProxy",
+			env:  map[string]string{EnvMesh: "", "http_proxy": "", "HTTP_PROXY": "node:4140"},
+			want: true,
+		},
+		{
+			name: "OtherProxy",
+			env:  map[string]string{EnvMesh: "", "http_proxy": "http://squid:3128/", "HTTP_PROXY": ""},
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer setenv(t, tc.env)()
+			if got := InMesh(); got != tc.want {
+				t.Errorf("InMesh(): want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFallbackFormat(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+		SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+		TraceOptions: ocShouldSample,
+	}
+
+	cases := []struct {
+		name    string
+		inMesh  bool
+		present string
+		absent  string
+	}{
+		{
+			name:    "InMesh",
+			inMesh:  true,
+			present: l5dHeaderTrace,
+			absent:  b3.TraceIDHeader,
+		},
+		{
+			name:    "NotInMesh",
+			inMesh:  false,
+			present: b3.TraceIDHeader,
+			absent:  l5dHeaderTrace,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &FallbackFormat{InMesh: func() bool { return tc.inMesh }}
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			f.SpanContextToRequest(sc, r)
+			if r.Header.Get(tc.present) == "" {
+				t.Errorf("f.SpanContextToRequest(): want %s header", tc.present)
+			}
+			if r.Header.Get(tc.absent) != "" {
+				t.Errorf("f.SpanContextToRequest(): want no %s header", tc.absent)
+			}
+
+			got, ok := f.SpanContextFromRequest(r)
+			if !ok {
+				t.Fatalf("f.SpanContextFromRequest(): want ok true, got false")
+			}
+			if got != sc {
+				t.Errorf("f.SpanContextFromRequest():\ngot:  %+v\nwant: %+v\n", got, sc)
+			}
+		})
+	}
+}
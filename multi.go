@@ -0,0 +1,62 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// MultiFormat implements propagation.HTTPFormat to propagate traces in several
+// formats at once. This is useful when migrating between propagation formats;
+// for example a service may inject both linkerd and B3 headers until all of its
+// downstreams understand B3.
+type MultiFormat struct {
+	// Extract are the formats used to extract span context, in priority order.
+	// Span context is extracted by the first format that finds a valid span
+	// context.
+	Extract []propagation.HTTPFormat
+
+	// Inject are the formats used to inject span context. Span context is
+	// injected using all Extract formats if Inject is nil.
+	Inject []propagation.HTTPFormat
+}
+
+// SpanContextFromRequest extracts span context from the supplied request using
+// the first Extract format that finds a valid span context.
+func (f *MultiFormat) SpanContextFromRequest(r *http.Request) (trace.SpanContext, bool) {
+	for _, e := range f.Extract {
+		if sc, ok := e.SpanContextFromRequest(r); ok {
+			return sc, true
+		}
+	}
+	return trace.SpanContext{}, false
+}
+
+// SpanContextToRequest injects the supplied span context into the supplied
+// request using every Inject format.
+func (f *MultiFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	inject := f.Inject
+	if inject == nil {
+		inject = f.Extract
+	}
+	for _, i := range inject {
+		i.SpanContextToRequest(sc, r)
+	}
+}
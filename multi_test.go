@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestMultiFormatSatisfiesHTTPFormat(t *testing.T) {
+	var _ propagation.HTTPFormat = (*MultiFormat)(nil)
+}
+
+func TestMultiFormat(t *testing.T) {
+	l5d := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+	b3 := trace.SpanContext{TraceID: trace.TraceID{2}, SpanID: trace.SpanID{2}, TraceOptions: ocShouldSample}
+
+	cases := []struct {
+		name    string
+		f       *MultiFormat
+		inject  map[propagation.HTTPFormat]trace.SpanContext
+		want    trace.SpanContext
+		wantHdr []string
+	}{
+		{
+			name:    "PreferFirst",
+			f:       &MultiFormat{Extract: []propagation.HTTPFormat{&HTTPFormat{}, &B3Format{}}},
+			inject:  map[propagation.HTTPFormat]trace.SpanContext{&HTTPFormat{}: l5d, &B3Format{}: b3},
+			want:    l5d,
+			wantHdr: []string{"L5d-Ctx-Trace", "X-B3-Traceid"},
+		},
+		{
+			name:    "FallBack",
+			f:       &MultiFormat{Extract: []propagation.HTTPFormat{&HTTPFormat{}, &B3Format{}}},
+			inject:  map[propagation.HTTPFormat]trace.SpanContext{&B3Format{}: b3},
+			want:    b3,
+			wantHdr: []string{"L5d-Ctx-Trace", "X-B3-Traceid"},
+		},
+		{
+			name: "InjectOnly",
+			f: &MultiFormat{
+				Extract: []propagation.HTTPFormat{&HTTPFormat{}, &B3Format{}},
+				Inject:  []propagation.HTTPFormat{&B3Format{}},
+			},
+			inject:  map[propagation.HTTPFormat]trace.SpanContext{&HTTPFormat{}: l5d},
+			want:    l5d,
+			wantHdr: []string{"X-B3-Traceid"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			in, _ := http.NewRequest("GET", "http://example.org", nil)
+			for f, sc := range tc.inject {
+				f.SpanContextToRequest(sc, in)
+			}
+			got, ok := tc.f.SpanContextFromRequest(in)
+			if !ok {
+				t.Fatalf("f.SpanContextFromRequest(): want ok, got invalid span context")
+			}
+			if got != tc.want {
+				t.Errorf("f.SpanContextFromRequest():\ngot:  %+v\nwant: %+v\n", got, tc.want)
+			}
+
+			out, _ := http.NewRequest("GET", "http://example.org", nil)
+			tc.f.SpanContextToRequest(got, out)
+			for _, k := range tc.wantHdr {
+				if out.Header.Get(k) == "" {
+					t.Errorf("f.SpanContextToRequest(): want header %s, got %v", k, out.Header)
+				}
+			}
+			if len(tc.wantHdr) == 1 && out.Header.Get(l5dHeaderTrace) != "" {
+				t.Errorf("f.SpanContextToRequest(): want no %s header, got %v", l5dHeaderTrace, out.Header)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package namerd provides a client for namerd's HTTP API, allowing routing
+// policy (i.e. dtabs) stored in namerd to be applied to individual requests
+// via linkin.DtabTransport. This enables progressive delivery use cases, such
+// as routing a subset of requests to a canary, without reconfiguring linkerd.
+// https://linkerd.io/1/advanced/namerd/
+package namerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/planetlabs/linkin"
+)
+
+// DefaultInterval is the default interval at which a Poller fetches dtabs.
+const DefaultInterval = 10 * time.Second
+
+// A Client of namerd's HTTP API.
+type Client struct {
+	// URL is the base URL of namerd's HTTP API, e.g. http://namerd:4180.
+	URL string
+
+	// HTTPClient is used to send requests to namerd. http.DefaultClient is
+	// used if HTTPClient is nil.
+	HTTPClient *http.Client
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// Dtab returns the dtab stored in the supplied namerd namespace.
+func (c *Client) Dtab(ctx context.Context, namespace string) (linkin.Dtab, error) {
+	u := strings.TrimRight(c.URL, "/") + "/api/1/dtabs/" + url.PathEscape(namespace)
+	r, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create dtab request: %v", err)
+	}
+	r.Header.Set("Accept", "application/json")
+
+	rsp, err := c.client().Do(r.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get dtab %s: %v", namespace, err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot get dtab %s: namerd returned %s", namespace, rsp.Status)
+	}
+
+	d := linkin.Dtab{}
+	if err := json.NewDecoder(rsp.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("cannot decode dtab %s: %v", namespace, err)
+	}
+	return d, nil
+}
+
+// A Poller periodically fetches a dtab from namerd. Its Dtab method may be
+// used as the Dtab function of a linkin.DtabTransport, applying the dtab to
+// every request sent by the transport.
+type Poller struct {
+	// Client is used to fetch dtabs.
+	Client *Client
+
+	// Namespace is the namerd namespace from which to fetch dtabs.
+	Namespace string
+
+	// Interval at which dtabs are fetched. DefaultInterval is used if Interval
+	// is zero.
+	Interval time.Duration
+
+	// ErrorHandler is called with any error encountered while fetching a dtab.
+	// The most recently fetched dtab continues to be applied until a dtab is
+	// fetched successfully. Errors are ignored if ErrorHandler is nil.
+	ErrorHandler func(error)
+
+	mu   sync.RWMutex
+	dtab linkin.Dtab
+}
+
+// Run fetches dtabs until the supplied context is done.
+func (p *Poller) Run(ctx context.Context) error {
+	i := p.Interval
+	if i == 0 {
+		i = DefaultInterval
+	}
+	t := time.NewTicker(i)
+	defer t.Stop()
+
+	for {
+		p.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	d, err := p.Client.Dtab(ctx, p.Namespace)
+	if err != nil {
+		if p.ErrorHandler != nil {
+			p.ErrorHandler(err)
+		}
+		return
+	}
+	p.mu.Lock()
+	p.dtab = d
+	p.mu.Unlock()
+}
+
+// Dtab returns the most recently fetched dtab. The supplied request is
+// ignored.
+func (p *Poller) Dtab(_ *http.Request) linkin.Dtab {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.dtab
+}
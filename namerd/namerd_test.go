@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package namerd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/planetlabs/linkin"
+)
+
+func namerd() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/1/dtabs/canary":
+			w.Write([]byte(`[{"prefix":"/svc/web","dst":"/svc/web-canary"}]`))
+		case "/api/1/dtabs/broken":
+			w.Write([]byte(`nope`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestDtab(t *testing.T) {
+	s := namerd()
+	defer s.Close()
+
+	cases := []struct {
+		name      string
+		namespace string
+		want      linkin.Dtab
+		wantErr   bool
+	}{
+		{
+			name:      "Found",
+			namespace: "canary",
+			want:      linkin.Dtab{{Prefix: "/svc/web", Dst: "/svc/web-canary"}},
+		},
+		{
+			name:      "NotFound",
+			namespace: "missing",
+			wantErr:   true,
+		},
+		{
+			name:      "Undecodable",
+			namespace: "broken",
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{URL: s.URL}
+			got, err := c.Dtab(context.Background(), tc.namespace)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("c.Dtab(%q): want error %v, got %v", tc.namespace, tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("c.Dtab(%q):\ngot:  %v\nwant: %v", tc.namespace, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoller(t *testing.T) {
+	s := namerd()
+	defer s.Close()
+
+	p := &Poller{Client: &Client{URL: s.URL}, Namespace: "canary", Interval: time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+
+	want := linkin.Dtab{{Prefix: "/svc/web", Dst: "/svc/web-canary"}}
+	for i := 0; i < 100 && p.Dtab(nil) == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("p.Run(): want %v, got %v", context.Canceled, err)
+	}
+	if got := p.Dtab(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("p.Dtab():\ngot:  %v\nwant: %v", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+type parentKey struct{}
+
+// withParent returns a copy of the supplied context that records sc as the
+// parent of any span context injected using that context.
+func withParent(ctx context.Context, sc trace.SpanContext) context.Context {
+	return context.WithValue(ctx, parentKey{}, sc)
+}
+
+// parentFromContext returns the ID of the recorded parent of the supplied span
+// context, if any. The recorded parent must belong to the same trace as sc.
+func parentFromContext(ctx context.Context, sc trace.SpanContext) ([8]byte, bool) {
+	p, ok := ctx.Value(parentKey{}).(trace.SpanContext)
+	if !ok || p.TraceID != sc.TraceID || p.SpanID == sc.SpanID {
+		return [8]byte{}, false
+	}
+	return p.SpanID, true
+}
+
+// ParentTransport is an http.RoundTripper that records the ID of the span in
+// each request's context, typically the server span of the request currently
+// being handled, as the parent of the client span injected into the outgoing
+// request. HTTPFormat writes the recorded parent ID into the parent ID field of
+// the l5d-ctx-trace header, which is otherwise always zero. This improves the
+// fidelity of traces assembled by Finagle based consumers such as linkerd.
+//
+// ParentTransport must wrap an ochttp.Transport, i.e. the ochttp.Transport
+// should be its Base, so that it runs before the client span is started.
+type ParentTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip records the ID of the span in the request's context, if any, then
+// sends the request.
+func (t *ParentTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if span := trace.FromContext(r.Context()); span != nil {
+		r = r.WithContext(withParent(r.Context(), span.SpanContext()))
+	}
+	return t.base().RoundTrip(r)
+}
+
+func (t *ParentTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *ParentTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
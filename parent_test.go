@@ -0,0 +1,72 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestParentTransport(t *testing.T) {
+	cases := []struct {
+		name   string
+		server bool
+	}{
+		{name: "WithServerSpan", server: true},
+		{name: "WithoutServerSpan"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &recordingTransport{}
+			pt := &ParentTransport{Base: &ochttp.Transport{
+				Base:         rt,
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+			}}
+
+			ctx := context.Background()
+			want := [8]byte{}
+			if tc.server {
+				var span *trace.Span
+				ctx, span = trace.StartSpan(ctx, "server", trace.WithSampler(trace.AlwaysSample()))
+				defer span.End()
+				want = span.SpanContext().SpanID
+			}
+
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			if _, err := pt.RoundTrip(r.WithContext(ctx)); err != nil {
+				t.Fatalf("pt.RoundTrip(): %v", err)
+			}
+
+			id, ok := TraceIDFromRequest(rt.r)
+			if !ok {
+				t.Fatalf("pt.RoundTrip(): want valid %s header, got %q", l5dHeaderTrace, rt.r.Header.Get(l5dHeaderTrace))
+			}
+			if id.Parent != want {
+				t.Errorf("pt.RoundTrip(): want parent %x, got %x", want, id.Parent)
+			}
+			if tc.server && id.Span == want {
+				t.Errorf("pt.RoundTrip(): want client span ID, got server span ID %x", id.Span)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/trace"
+)
+
+// A PathRule decides whether requests for a URL path are sampled.
+type PathRule struct {
+	// Path is the URL path to which the rule applies. Paths ending in "/"
+	// match all paths beneath them, e.g. /checkout/ matches /checkout/cart.
+	// Other paths match only themselves.
+	Path string
+
+	// Sampler decides whether matching requests are sampled. It overrides any
+	// upstream sampling decision. Matching requests are never sampled if
+	// Sampler is nil.
+	Sampler trace.Sampler
+}
+
+func (r PathRule) matches(path string) bool {
+	if strings.HasSuffix(r.Path, "/") {
+		return strings.HasPrefix(path, r.Path)
+	}
+	return path == r.Path
+}
+
+// PathSampling applies sampling rules to requests by URL path, before their
+// server span is created. It cuts noisy traces, e.g. of health checks and
+// metrics scrapes, without changes to application handlers. Use its
+// StartOptions method as the GetStartOptions function of an ochttp.Handler:
+//
+//  p := &linkin.PathSampling{Rules: []linkin.PathRule{
+//  	{Path: "/healthz"},
+//  	{Path: "/metrics"},
+//  	{Path: "/checkout/", Sampler: trace.AlwaysSample()},
+//  }}
+//  h := &ochttp.Handler{Handler: h, Propagation: &linkin.HTTPFormat{}, GetStartOptions: p.StartOptions}
+type PathSampling struct {
+	// Rules are the sampling rules. The rule with the longest matching path
+	// applies to each request.
+	Rules []PathRule
+
+	// Default returns start options for requests that match no rule.
+	// StartOptions is used if Default is nil.
+	Default func(*http.Request) trace.StartOptions
+}
+
+// StartOptions returns start options for the server span of the supplied
+// request, per the rule matching its path.
+func (p *PathSampling) StartOptions(r *http.Request) trace.StartOptions {
+	var match *PathRule
+	for i := range p.Rules {
+		if !p.Rules[i].matches(r.URL.Path) {
+			continue
+		}
+		if match == nil || len(p.Rules[i].Path) > len(match.Path) {
+			match = &p.Rules[i]
+		}
+	}
+	if match == nil {
+		if p.Default == nil {
+			return StartOptions(r)
+		}
+		return p.Default(r)
+	}
+	if match.Sampler == nil {
+		return trace.StartOptions{Sampler: trace.NeverSample()}
+	}
+	return trace.StartOptions{Sampler: match.Sampler}
+}
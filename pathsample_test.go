@@ -0,0 +1,74 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestPathSampling(t *testing.T) {
+	p := &PathSampling{
+		Rules: []PathRule{
+			{Path: "/healthz"},
+			{Path: "/checkout/", Sampler: trace.AlwaysSample()},
+			{Path: "/checkout/ping"},
+		},
+		Default: func(_ *http.Request) trace.StartOptions {
+			return trace.StartOptions{Sampler: trace.AlwaysSample()}
+		},
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		header  map[string]string
+		sampled bool
+	}{
+		{name: "Never", path: "/healthz", sampled: false},
+		{name: "NeverWithSampledParent", path: "/healthz", header: map[string]string{l5dHeaderTrace: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="}, sampled: false},
+		{name: "NotPrefix", path: "/healthz/deep", sampled: true},
+		{name: "Always", path: "/checkout/cart", sampled: true},
+		{name: "LongestMatch", path: "/checkout/ping", sampled: false},
+		{name: "Default", path: "/", sampled: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sampled bool
+			h := &ochttp.Handler{
+				Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+					sampled = trace.FromContext(r.Context()).SpanContext().IsSampled()
+				}),
+				Propagation:     &HTTPFormat{},
+				GetStartOptions: p.StartOptions,
+			}
+			r := httptest.NewRequest("GET", "http://example.org"+tc.path, nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if sampled != tc.sampled {
+				t.Errorf("p.StartOptions(): want sampled %t, got %t", tc.sampled, sampled)
+			}
+		})
+	}
+}
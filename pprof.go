@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// pprof labels set by ProfileLabels.
+const (
+	labelTraceID  = "trace_id"
+	labelSpanID   = "span_id"
+	labelEndpoint = "endpoint"
+)
+
+// endpoint returns the endpoint served by the supplied request; the route
+// tagged by ochttp.WithRouteTag if any, or the URL path.
+func endpoint(r *http.Request) string {
+	if m := tag.FromContext(r.Context()); m != nil {
+		if route, ok := m.Value(ochttp.KeyServerRoute); ok {
+			return route
+		}
+	}
+	return r.URL.Path
+}
+
+// profileLabels returns labels identifying the supplied request and the span
+// in its context, if any, as alternating keys and values. Only the supplied
+// label keys are returned, or all labels if keys is nil.
+func profileLabels(r *http.Request, keys []string) []string {
+	labels := []string{labelEndpoint, endpoint(r)}
+	if s := trace.FromContext(r.Context()); s != nil {
+		sc := s.SpanContext()
+		labels = append(labels, labelTraceID, traceIDHex(sc.TraceID), labelSpanID, sc.SpanID.String())
+	}
+	if keys == nil {
+		return labels
+	}
+	filtered := make([]string, 0, len(labels))
+	for i := 0; i < len(labels); i += 2 {
+		if contains(keys, labels[i]) {
+			filtered = append(filtered, labels[i], labels[i+1])
+		}
+	}
+	return filtered
+}
+
+// A Profiler tags the profiling samples taken while a function runs. Profilers
+// allow ProfileHandler to integrate with continuous profilers. For example a
+// Pyroscope Profiler may be implemented as:
+//
+//  linkin.ProfilerFunc(func(ctx context.Context, labels []string, fn func(context.Context)) {
+//  	pyroscope.TagWrapper(ctx, pyroscope.Labels(labels...), fn)
+//  })
+type Profiler interface {
+	// Do calls fn with a copy of the supplied context that tags samples with
+	// the supplied labels; alternating keys and values.
+	Do(ctx context.Context, labels []string, fn func(context.Context))
+}
+
+// ProfilerFunc is a function that implements Profiler.
+type ProfilerFunc func(ctx context.Context, labels []string, fn func(context.Context))
+
+// Do calls f(ctx, labels, fn).
+func (f ProfilerFunc) Do(ctx context.Context, labels []string, fn func(context.Context)) {
+	f(ctx, labels, fn)
+}
+
+// Pprof is a Profiler that sets runtime/pprof labels. Profilers that consume
+// pprof labels, such as Parca, need no other integration.
+var Pprof Profiler = ProfilerFunc(func(ctx context.Context, labels []string, fn func(context.Context)) {
+	pprof.Do(ctx, pprof.Labels(labels...), fn)
+})
+
+// ProfileHandler is an http.Handler that tags the profiling samples taken while
+// serving each request with the request's trace_id, span_id, and endpoint,
+// allowing profiles and traces to be cross-referenced. The trace_id is hex
+// encoded as it is reported to Zipkin. The endpoint is the route tagged by
+// ochttp.WithRouteTag, if any, or else the URL path.
+// ProfileHandler must be wrapped by an ochttp.Handler.
+type ProfileHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Profiler tags profiling samples. Pprof is used if Profiler is nil.
+	Profiler Profiler
+
+	// Labels are the keys of the labels to set, e.g. only endpoint for
+	// profilers that store a series per distinct label value. All labels are
+	// set if Labels is nil.
+	Labels []string
+}
+
+// ServeHTTP serves the request with profiling samples tagged.
+func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := h.Profiler
+	if p == nil {
+		p = Pprof
+	}
+	p.Do(r.Context(), profileLabels(r, h.Labels), func(ctx context.Context) {
+		h.Handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ProfileLabels wraps the supplied handler, setting the trace_id, span_id, and
+// endpoint pprof labels for the duration of each request. CPU profiles may
+// then be sliced by trace, e.g. using go tool pprof -tagfocus. Goroutines
+// started while serving the request inherit its labels. See ProfileHandler.
+func ProfileLabels(h http.Handler) http.Handler {
+	return &ProfileHandler{Handler: h}
+}
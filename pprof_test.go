@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime/pprof"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestProfileLabels(t *testing.T) {
+	cases := []struct {
+		name        string
+		handler     func(http.Handler) http.Handler
+		header      string
+		endpoint    string
+		wantTraceID string
+	}{
+		{
+			name:     "Path",
+			handler:  func(h http.Handler) http.Handler { return h },
+			endpoint: "/users/42",
+		},
+		{
+			name:        "TraceID64",
+			handler:     func(h http.Handler) http.Handler { return h },
+			header:      "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			endpoint:    "/users/42",
+			wantTraceID: "32a4db20f5d592e7",
+		},
+		{
+			name:     "Route",
+			handler:  func(h http.Handler) http.Handler { return ochttp.WithRouteTag(h, "/users/:id") },
+			endpoint: "/users/:id",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sc trace.SpanContext
+			got := map[string]string{}
+			inner := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				sc = trace.FromContext(r.Context()).SpanContext()
+				pprof.ForLabels(r.Context(), func(k, v string) bool {
+					got[k] = v
+					return true
+				})
+			})
+			h := &ochttp.Handler{Handler: tc.handler(ProfileLabels(inner)), Propagation: &HTTPFormat{}}
+			r := httptest.NewRequest("GET", "http://example.org/users/42", nil)
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			want := map[string]string{
+				labelTraceID:  traceIDHex(sc.TraceID),
+				labelSpanID:   sc.SpanID.String(),
+				labelEndpoint: tc.endpoint,
+			}
+			if tc.wantTraceID != "" {
+				want[labelTraceID] = tc.wantTraceID
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("ProfileLabels(): want label %s=%s, got %s", k, v, got[k])
+				}
+			}
+		})
+	}
+}
+
+func TestProfileHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		labels []string
+		want   []string
+	}{
+		{
+			name:   "EndpointOnly",
+			labels: []string{labelEndpoint},
+			want:   []string{labelEndpoint, "/users/42"},
+		},
+		{
+			name:   "None",
+			labels: []string{},
+			want:   []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			p := ProfilerFunc(func(ctx context.Context, labels []string, fn func(context.Context)) {
+				got = labels
+				fn(ctx)
+			})
+			called := false
+			h := &ProfileHandler{
+				Handler:  http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) { called = true }),
+				Profiler: p,
+				Labels:   tc.labels,
+			}
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.org/users/42", nil))
+
+			if !called {
+				t.Errorf("h.ServeHTTP(): handler was not called")
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("h.ServeHTTP(): want labels %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// renamePrefix returns a copy of the supplied request in which all headers
+// whose names begin with from have been renamed to begin with to instead. Any
+// existing headers that begin with to are removed.
+func renamePrefix(r *http.Request, from, to string) *http.Request {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	out := withHeaderCopy(r)
+	for k := range out.Header {
+		if strings.HasPrefix(strings.ToLower(k), to) {
+			delete(out.Header, k)
+		}
+	}
+	for k, vs := range r.Header {
+		if !strings.HasPrefix(strings.ToLower(k), from) {
+			continue
+		}
+		delete(out.Header, k)
+		out.Header[http.CanonicalHeaderKey(to+k[len(from):])] = vs
+	}
+	return out
+}
+
+// PrefixHandler is an http.Handler that maps tenant specific context headers
+// to the canonical linkerd context (l5d-ctx-*) headers. It allows services to
+// participate in a mesh or namespaced header scheme that does not use the l5d
+// prefix. PrefixHandler must wrap the ochttp.Handler in order for its
+// propagation format to see the mapped headers.
+//
+// Any canonical linkerd context headers sent with the incoming request are
+// discarded; only the tenant's context is propagated.
+type PrefixHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Prefix is the tenant specific header prefix, e.g. "acme-ctx-". Headers
+	// are not mapped if Prefix is empty.
+	Prefix string
+}
+
+// ServeHTTP maps the incoming request's tenant specific context headers to
+// the canonical linkerd context headers, then serves the request.
+func (h *PrefixHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Prefix != "" {
+		r = renamePrefix(r, h.Prefix, l5dHeaderPrefix)
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// PrefixTransport is an http.RoundTripper that maps the canonical linkerd
+// context (l5d-ctx-*) headers to tenant specific context headers. It should
+// be the innermost transport, i.e. the Base of any HeaderLimitTransport or
+// ochttp.Transport, so that it sees headers injected by all other middleware.
+type PrefixTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Prefix is the tenant specific header prefix, e.g. "acme-ctx-". Headers
+	// are not mapped if Prefix is empty.
+	Prefix string
+}
+
+// RoundTrip maps the supplied request's linkerd context headers to tenant
+// specific context headers, then sends the request.
+func (t *PrefixTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.Prefix != "" {
+		r = renamePrefix(r, l5dHeaderPrefix, t.Prefix)
+	}
+	return t.base().RoundTrip(r)
+}
+
+func (t *PrefixTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *PrefixTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPrefixHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		prefix string
+		in     http.Header
+		want   http.Header
+	}{
+		{
+			name:   "MapsTenantHeaders",
+			prefix: "acme-ctx-",
+			in:     http.Header{"Acme-Ctx-Trace": {"a"}, "X-Unrelated": {"b"}},
+			want:   http.Header{"L5d-Ctx-Trace": {"a"}, "X-Unrelated": {"b"}},
+		},
+		{
+			name:   "DiscardsCanonicalHeaders",
+			prefix: "acme-ctx-",
+			in:     http.Header{"L5d-Ctx-Trace": {"a"}, "L5d-Ctx-Dtab": {"b"}, "Acme-Ctx-Trace": {"c"}},
+			want:   http.Header{"L5d-Ctx-Trace": {"c"}},
+		},
+		{
+			name: "NoPrefix",
+			in:   http.Header{"L5d-Ctx-Trace": {"a"}, "Acme-Ctx-Trace": {"c"}},
+			want: http.Header{"L5d-Ctx-Trace": {"a"}, "Acme-Ctx-Trace": {"c"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got http.Header
+			h := &PrefixHandler{
+				Prefix:  tc.prefix,
+				Handler: http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = r.Header }),
+			}
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			r.Header = tc.in
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("h.ServeHTTP():\ngot headers:  %v\nwant headers: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrefixTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	pt := &PrefixTransport{Base: rt, Prefix: "acme-ctx-"}
+
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set(l5dHeaderTrace, "a")
+	r.Header.Set("X-Unrelated", "b")
+	if _, err := pt.RoundTrip(r); err != nil {
+		t.Fatalf("pt.RoundTrip(): %v", err)
+	}
+
+	want := http.Header{"Acme-Ctx-Trace": {"a"}, "X-Unrelated": {"b"}}
+	if !reflect.DeepEqual(rt.r.Header, want) {
+		t.Errorf("pt.RoundTrip():\ngot headers:  %v\nwant headers: %v", rt.r.Header, want)
+	}
+	if r.Header.Get(l5dHeaderTrace) != "a" {
+		t.Errorf("pt.RoundTrip(): modified the original request headers: %v", r.Header)
+	}
+}
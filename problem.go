@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+// ProblemContentType is the content type of RFC 7807 problem responses.
+const ProblemContentType = "application/problem+json"
+
+// A Problem is an RFC 7807 problem details object, extended with the trace ID
+// of the request that caused it, so that API consumers can quote a trace when
+// reporting errors. https://tools.ietf.org/html/rfc7807
+type Problem struct {
+	// Type is a URI reference that identifies the problem type. It is omitted,
+	// implying about:blank, if empty.
+	Type string `json:"type,omitempty"`
+
+	// Title is a short summary of the problem type. The text of the HTTP
+	// status is used if Title is empty.
+	Title string `json:"title,omitempty"`
+
+	// Status is the HTTP status code of the response. 500 is used if Status is
+	// zero.
+	Status int `json:"status,omitempty"`
+
+	// Detail explains this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is a URI reference that identifies this occurrence of the
+	// problem.
+	Instance string `json:"instance,omitempty"`
+
+	// TraceID is the hex encoded trace ID of the request. It is set by
+	// ProblemWriter.
+	TraceID string `json:"trace_id,omitempty"`
+
+	// TraceURL links to the trace of the request, if it was sampled and a
+	// trace UI is configured. It is set by ProblemWriter.
+	TraceURL string `json:"trace_url,omitempty"`
+}
+
+// A ProblemWriter writes RFC 7807 problem responses that include the trace ID
+// of the span in the request's context, and a link to the trace if it was
+// sampled and a trace UI is configured.
+type ProblemWriter struct {
+	// UI is the trace UI to which problems link. The TraceUI of the stack
+	// seeded into the request's context, if any, is used if UI is nil. See
+	// Seed.
+	UI *TraceUI
+}
+
+// Write writes the supplied problem as the response to the supplied request.
+func (pw *ProblemWriter) Write(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if span := trace.FromContext(r.Context()); span != nil {
+		sc := span.SpanContext()
+		p.TraceID = traceIDHex(sc.TraceID)
+		if ui := pw.ui(r); ui != nil && sc.IsSampled() {
+			p.TraceURL = ui.TraceURL(sc)
+		}
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+func (pw *ProblemWriter) ui(r *http.Request) *TraceUI {
+	if pw.UI != nil {
+		return pw.UI
+	}
+	if s := StackFromContext(r.Context()); s != nil {
+		return s.Config().TraceUI
+	}
+	return nil
+}
+
+// WriteProblem writes the supplied problem as the response to the supplied
+// request using a zero ProblemWriter, i.e. linking to the trace UI of the
+// seeded stack, if any.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	(&ProblemWriter{}).Write(w, r, p)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestProblemWriter(t *testing.T) {
+	sampled := trace.SpanContext{TraceID: trace.TraceID{8: 1}, SpanID: trace.SpanID{1}, TraceOptions: ocShouldSample}
+	unsampled := trace.SpanContext{TraceID: trace.TraceID{8: 1}, SpanID: trace.SpanID{1}}
+	zipkin := &TraceUI{URL: "http://zipkin:9411/zipkin"}
+	seeded, err := Config{TraceUI: &TraceUI{URL: "http://zipkin:9411"}}.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		pw      *ProblemWriter
+		ctx     func(ctx context.Context) context.Context
+		problem Problem
+		want    Problem
+	}{
+		{
+			name:    "NoSpan",
+			pw:      &ProblemWriter{UI: zipkin},
+			ctx:     func(ctx context.Context) context.Context { return ctx },
+			problem: Problem{Detail: "boom"},
+			want:    Problem{Title: "Internal Server Error", Status: 500, Detail: "boom"},
+		},
+		{
+			name:    "Sampled",
+			pw:      &ProblemWriter{UI: zipkin},
+			ctx:     withSpan(sampled),
+			problem: Problem{Type: "https://example.org/out-of-credit", Title: "Out of credit", Status: 403},
+			want: Problem{
+				Type:     "https://example.org/out-of-credit",
+				Title:    "Out of credit",
+				Status:   403,
+				TraceID:  "0100000000000000",
+				TraceURL: "http://zipkin:9411/zipkin/traces/0100000000000000",
+			},
+		},
+		{
+			name:    "Unsampled",
+			pw:      &ProblemWriter{UI: zipkin},
+			ctx:     withSpan(unsampled),
+			problem: Problem{Status: 404},
+			want:    Problem{Title: "Not Found", Status: 404, TraceID: "0100000000000000"},
+		},
+		{
+			name: "Seeded",
+			pw:   &ProblemWriter{},
+			ctx: func(ctx context.Context) context.Context {
+				return withSpan(sampled)((&Seed{Context: ctx, Stack: seeded}).BaseContext(nil))
+			},
+			problem: Problem{Status: 502},
+			want: Problem{
+				Title:    "Bad Gateway",
+				Status:   502,
+				TraceID:  "0100000000000000",
+				TraceURL: "http://zipkin:9411/traces/0100000000000000",
+			},
+		},
+		{
+			name:    "NoUI",
+			pw:      &ProblemWriter{},
+			ctx:     withSpan(sampled),
+			problem: Problem{Status: 503},
+			want:    Problem{Title: "Service Unavailable", Status: 503, TraceID: "0100000000000000"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			w := httptest.NewRecorder()
+			tc.pw.Write(w, r.WithContext(tc.ctx(context.Background())), tc.problem)
+
+			if w.Code != tc.want.Status {
+				t.Errorf("pw.Write(): want status %d, got %d", tc.want.Status, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("pw.Write(): want content type %s, got %s", ProblemContentType, got)
+			}
+			got := Problem{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("json.Decode(): %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("pw.Write():\ngot:  %+v\nwant: %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// withSpan returns a function that starts a span with the supplied remote
+// parent in the supplied context, without ending it. The span is sampled if
+// its parent is.
+func withSpan(parent trace.SpanContext) func(ctx context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		s := trace.NeverSample()
+		if parent.IsSampled() {
+			s = trace.AlwaysSample()
+		}
+		ctx, _ = trace.StartSpanWithRemoteParent(ctx, "test", parent, trace.WithSampler(s))
+		return ctx
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteProblem(w, httptest.NewRequest("GET", "http://example.org", nil), Problem{Status: http.StatusTeapot})
+	if w.Code != http.StatusTeapot {
+		t.Errorf("WriteProblem(): want status %d, got %d", http.StatusTeapot, w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"strings"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A Propagator propagates span context via carriers of type C, for example:
+//
+//  var p linkin.Propagator[linkin.MetadataCarrier]
+//  p.Inject(ctx, span.SpanContext(), linkin.MetadataCarrier(md))
+//
+// Unlike SpanContextToCarrier and SpanContextFromCarrier a Propagator is bound
+// to a single carrier type, so an integration cannot accidentally inject into
+// or extract from a carrier of another type. The zero value is ready to use.
+type Propagator[C Carrier] struct {
+	// Format is the HTTP propagation format used to inject and extract span
+	// context. The format sees each carrier's key value pairs as HTTP headers.
+	// &HTTPFormat{} is used if Format is nil.
+	Format propagation.HTTPFormat
+}
+
+// Inject injects the supplied span context into the supplied carrier.
+func (p Propagator[C]) Inject(ctx context.Context, sc trace.SpanContext, c C) {
+	SpanContextToCarrier(ctx, p.Format, sc, c)
+}
+
+// InjectContext injects the span context of the span in the supplied context,
+// if any, into the supplied carrier.
+func (p Propagator[C]) InjectContext(ctx context.Context, c C) {
+	if span := trace.FromContext(ctx); span != nil {
+		p.Inject(ctx, span.SpanContext(), c)
+	}
+}
+
+// Extract extracts span context from the supplied carrier.
+func (p Propagator[C]) Extract(ctx context.Context, c C) (trace.SpanContext, bool) {
+	return SpanContextFromCarrier(ctx, p.Format, c)
+}
+
+// StartSpan starts a span that is a child of the span context extracted from
+// the supplied carrier, if any, or a new root span otherwise.
+func (p Propagator[C]) StartSpan(ctx context.Context, name string, c C, o ...trace.StartOption) (context.Context, *trace.Span) {
+	if sc, ok := p.Extract(ctx, c); ok {
+		return trace.StartSpanWithRemoteParent(ctx, name, sc, o...)
+	}
+	return trace.StartSpan(ctx, name, o...)
+}
+
+// A MetadataCarrier is a Carrier backed by a map of lowercase keys to lists of
+// values, such as gRPC metadata.MD. Convert such maps to a MetadataCarrier to
+// use them with a Propagator.
+type MetadataCarrier map[string][]string
+
+// Get returns the first value of the supplied key, or the empty string.
+func (c MetadataCarrier) Get(key string) string {
+	if v := c[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Set sets the supplied key to the supplied value, replacing any existing
+// values.
+func (c MetadataCarrier) Set(key, value string) {
+	c[strings.ToLower(key)] = []string{value}
+}
+
+// Keys returns all keys in the carrier.
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestPropagator(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0, 0, 0, 0, 0, 0, 0, 1, 50, 164, 219, 32, 245, 213, 146, 231},
+		SpanID:       trace.SpanID{244, 20, 29, 93, 192, 201, 53, 208},
+		TraceOptions: ocShouldSample,
+	}
+
+	cases := []struct {
+		name    string
+		inject  func(p Propagator[MetadataCarrier], c MetadataCarrier)
+		wantKey string
+		want    bool
+	}{
+		{
+			name:    "Inject",
+			inject:  func(p Propagator[MetadataCarrier], c MetadataCarrier) { p.Inject(context.Background(), sc, c) },
+			wantKey: l5dHeaderTrace,
+			want:    true,
+		},
+		{
+			name: "InjectContext",
+			inject: func(p Propagator[MetadataCarrier], c MetadataCarrier) {
+				ctx, span := trace.StartSpanWithRemoteParent(context.Background(), "test", sc)
+				defer span.End()
+				p.InjectContext(ctx, c)
+			},
+			wantKey: l5dHeaderTrace,
+			want:    true,
+		},
+		{
+			name:   "InjectContextWithoutSpan",
+			inject: func(p Propagator[MetadataCarrier], c MetadataCarrier) { p.InjectContext(context.Background(), c) },
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Propagator[MetadataCarrier]{}
+			c := MetadataCarrier{"unrelated": {"value"}}
+			tc.inject(p, c)
+			if got := len(c[tc.wantKey]) == 1; tc.want && !got {
+				t.Errorf("p.Inject(): want key %q, got %v", tc.wantKey, c)
+			}
+
+			got, ok := p.Extract(context.Background(), c)
+			if ok != tc.want {
+				t.Fatalf("p.Extract(): want ok %t, got %t", tc.want, ok)
+			}
+			if ok && got.TraceID != sc.TraceID {
+				t.Errorf("p.Extract(): want trace ID %v, got %v", sc.TraceID, got.TraceID)
+			}
+
+			_, span := p.StartSpan(context.Background(), "test", c)
+			defer span.End()
+			if got := span.SpanContext().TraceID == sc.TraceID; got != tc.want {
+				t.Errorf("p.StartSpan(): want child of the extracted span context %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMetadataCarrier(t *testing.T) {
+	c := MetadataCarrier{"l5d-ctx-trace": {"a", "b"}}
+	if got := c.Get("L5d-Ctx-Trace"); got != "a" {
+		t.Errorf("c.Get(): want first value %q, got %q", "a", got)
+	}
+	c.Set("L5d-Ctx-Trace", "c")
+	if got := c["l5d-ctx-trace"]; len(got) != 1 || got[0] != "c" {
+		t.Errorf("c.Set(): want values [c], got %v", got)
+	}
+	if got := c.Get("missing"); got != "" {
+		t.Errorf("c.Get(): want empty string, got %q", got)
+	}
+	if got := c.Keys(); len(got) != 1 || got[0] != "l5d-ctx-trace" {
+		t.Errorf("c.Keys(): want [l5d-ctx-trace], got %v", got)
+	}
+}
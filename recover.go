@@ -0,0 +1,63 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"go.opencensus.io/trace"
+)
+
+const headerTraceID = "X-Trace-Id"
+
+// Recover wraps the supplied handler, recovering from any panic while serving
+// a request so that the panic is visible in Zipkin. The span in the request's
+// context is given an error attribute describing the panic, which Zipkin uses
+// to highlight failed spans, and annotated with the stack of the panicking
+// goroutine. The client receives a 500 response with an X-Trace-Id header
+// containing the hex encoded trace ID, as it appears in Zipkin, unless the
+// handler had already written a response. Panics with http.ErrAbortHandler are
+// not recovered. Recover must be wrapped by an ochttp.Handler.
+func Recover(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			span := trace.FromContext(r.Context())
+			span.AddAttributes(trace.StringAttribute(ErrorAttribute, fmt.Sprintf("panic: %v", p)))
+			span.Annotate([]trace.Attribute{trace.StringAttribute(StackAttribute, string(debug.Stack()))}, "panic")
+
+			if sw.code != 0 {
+				return
+			}
+			if span != nil {
+				w.Header().Set(headerTraceID, traceIDHex(span.SpanContext().TraceID))
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestRecover(t *testing.T) {
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		header  string
+		status  int
+		traceID bool
+		wantID  string
+		panic   bool
+	}{
+		{
+			name:    "Panic",
+			handler: func(_ http.ResponseWriter, _ *http.Request) { panic("boom") },
+			status:  http.StatusInternalServerError,
+			traceID: true,
+			panic:   true,
+		},
+		{
+			name:    "Panic64BitTrace",
+			handler: func(_ http.ResponseWriter, _ *http.Request) { panic("boom") },
+			header:  "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			status:  http.StatusInternalServerError,
+			traceID: true,
+			wantID:  "32a4db20f5d592e7",
+			panic:   true,
+		},
+		{
+			name: "PanicAfterWrite",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			status: http.StatusAccepted,
+			panic:  true,
+		},
+		{
+			name:    "NoPanic",
+			handler: func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) },
+			status:  http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			h := &ochttp.Handler{
+				Handler:      Recover(tc.handler),
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+			}
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tc.status {
+				t.Errorf("h.ServeHTTP(): want status %d, got %d", tc.status, w.Code)
+			}
+			if got := w.Header().Get(headerTraceID) != ""; got != tc.traceID {
+				t.Errorf("h.ServeHTTP(): want %s header %t, got %t", headerTraceID, tc.traceID, got)
+			}
+
+			if len(e.spans) != 1 {
+				t.Fatalf("h.ServeHTTP(): want one span, got %d", len(e.spans))
+			}
+			s := e.spans[0]
+			want := tc.wantID
+			if want == "" {
+				want = traceIDHex(s.TraceID)
+			}
+			if tc.traceID && w.Header().Get(headerTraceID) != want {
+				t.Errorf("h.ServeHTTP(): want %s %s, got %s", headerTraceID, want, w.Header().Get(headerTraceID))
+			}
+			if !tc.panic {
+				return
+			}
+			if got, _ := s.Attributes[ErrorAttribute].(string); got != "panic: boom" {
+				t.Errorf("h.ServeHTTP(): want span %s attribute %q, got %q", ErrorAttribute, "panic: boom", got)
+			}
+			if len(s.Annotations) != 1 || !strings.Contains(s.Annotations[0].Attributes[StackAttribute].(string), "TestRecover") {
+				t.Errorf("h.ServeHTTP(): want annotation with stack, got %v", s.Annotations)
+			}
+		})
+	}
+}
+
+func TestRecoverAbort(t *testing.T) {
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Recover(): want panic %v, got %v", http.ErrAbortHandler, p)
+		}
+	}()
+	h := Recover(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) { panic(http.ErrAbortHandler) }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.org", nil))
+}
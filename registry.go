@@ -0,0 +1,72 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"sort"
+	"sync"
+
+	"go.opencensus.io/trace/propagation"
+)
+
+// Names of the propagation formats registered by this package.
+const (
+	FormatLinkerd      = "l5d"
+	FormatLinkerd2     = "linkerd2"
+	FormatB3           = "b3"
+	FormatTraceContext = "tracecontext"
+)
+
+var registry = struct {
+	sync.RWMutex
+	formats map[string]propagation.HTTPFormat
+}{formats: map[string]propagation.HTTPFormat{
+	FormatLinkerd:      &HTTPFormat{},
+	FormatLinkerd2:     &Linkerd2Format{},
+	FormatB3:           &B3Format{},
+	FormatTraceContext: &TraceContextFormat{},
+}}
+
+// Register registers the supplied propagation format under the supplied name,
+// allowing applications to select propagation formats by name (e.g. from
+// configuration) at startup. Registering a name again replaces its format. The
+// l5d, linkerd2, b3, and tracecontext formats are registered by default.
+func Register(name string, f propagation.HTTPFormat) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.formats[name] = f
+}
+
+// Get returns the propagation format registered under the supplied name.
+func Get(name string) (propagation.HTTPFormat, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.formats[name]
+	return f, ok
+}
+
+// Registered returns the sorted names of all registered propagation formats.
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.formats))
+	for name := range registry.formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
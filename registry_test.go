@@ -0,0 +1,64 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestRegistry(t *testing.T) {
+	custom := &HTTPFormat{CookieName: "l5d"}
+	Register("custom", custom)
+	defer func() {
+		registry.Lock()
+		delete(registry.formats, "custom")
+		registry.Unlock()
+	}()
+
+	cases := []struct {
+		name string
+		want propagation.HTTPFormat
+		ok   bool
+	}{
+		{name: FormatLinkerd, want: &HTTPFormat{}, ok: true},
+		{name: FormatLinkerd2, want: &Linkerd2Format{}, ok: true},
+		{name: FormatB3, want: &B3Format{}, ok: true},
+		{name: FormatTraceContext, want: &TraceContextFormat{}, ok: true},
+		{name: "custom", want: custom, ok: true},
+		{name: "unregistered"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Get(tc.name)
+			if ok != tc.ok {
+				t.Fatalf("Get(%q): want ok %t, got %t", tc.name, tc.ok, ok)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Get(%q): want %#v, got %#v", tc.name, tc.want, got)
+			}
+		})
+	}
+
+	want := []string{FormatB3, "custom", FormatLinkerd, FormatLinkerd2, FormatTraceContext}
+	if got := Registered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Registered(): want %v, got %v", want, got)
+	}
+}
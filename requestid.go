@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+const headerRequestID = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID recorded in the supplied context
+// by RequestID, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// RequestID wraps the supplied handler, unifying the x-request-id and trace ID
+// correlation schemes. Requests without an x-request-id header are assigned a
+// request ID equal to the trace ID of the span in their context, hex encoded as
+// it is reported to Zipkin.
+// The request ID is recorded in the request's context, for propagation by
+// RequestIDTransport, and as an http.request_id attribute of the span in the
+// request's context. RequestID must be wrapped by an ochttp.Handler.
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.FromContext(r.Context())
+		id := r.Header.Get(headerRequestID)
+		if id == "" {
+			id = newRequestID(span)
+			r = withHeaderCopy(r)
+			r.Header.Set(headerRequestID, id)
+		}
+		span.AddAttributes(trace.StringAttribute(RequestIDAttribute, id))
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func newRequestID(span *trace.Span) string {
+	if span == nil {
+		id := defaultIDs.NewTraceID()
+		return hex.EncodeToString(id[:])
+	}
+	return traceIDHex(span.SpanContext().TraceID)
+}
+
+// RequestIDTransport is an http.RoundTripper that propagates the request ID
+// recorded in each request's context by RequestID in the x-request-id header.
+// Requests that already have an x-request-id header are sent unmodified.
+type RequestIDTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip adds the x-request-id header to the supplied request, then sends
+// it.
+func (t *RequestIDTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if id, ok := RequestIDFromContext(r.Context()); ok && r.Header.Get(headerRequestID) == "" {
+		// RoundTrippers must not modify the request they're given.
+		r = withHeaderCopy(r)
+		r.Header.Set(headerRequestID, id)
+	}
+	return t.base().RoundTrip(r)
+}
+
+func (t *RequestIDTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *RequestIDTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestRequestID(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		trace  bool
+		l5d    string
+		wantID string
+	}{
+		{
+			name:  "Generated",
+			trace: true,
+		},
+		{
+			name:   "Generated64BitTrace",
+			trace:  true,
+			l5d:    "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			wantID: "32a4db20f5d592e7",
+		},
+		{
+			name:   "Existing",
+			header: "f058ebd6-02f7-4d3f-942e-904344e8cde5",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			rt := &recordingTransport{}
+			client := &http.Client{Transport: &RequestIDTransport{Base: rt}}
+			h := &ochttp.Handler{
+				Propagation:  &HTTPFormat{},
+				StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+				Handler: RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					o, _ := http.NewRequest("GET", "http://example.net", nil)
+					if _, err := client.Do(o.WithContext(r.Context())); err != nil {
+						t.Fatalf("client.Do(): %v", err)
+					}
+				})),
+			}
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			if tc.header != "" {
+				r.Header.Set(headerRequestID, tc.header)
+			}
+			if tc.l5d != "" {
+				r.Header.Set(l5dHeaderTrace, tc.l5d)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := e.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("h.ServeHTTP(): want 1 exported span, got %d", len(spans))
+			}
+			want := tc.header
+			if tc.trace {
+				want = traceIDHex(spans[0].TraceID)
+			}
+			if tc.wantID != "" && want != tc.wantID {
+				t.Errorf("spans[0].TraceID: want %s, got %s", tc.wantID, want)
+			}
+			if got := rt.r.Header.Get(headerRequestID); got != want {
+				t.Errorf("client.Do(): want %s header %q, got %q", headerRequestID, want, got)
+			}
+			if got := spans[0].Attributes[RequestIDAttribute]; got != want {
+				t.Errorf("spans[0].Attributes[%q]: want %q, got %v", RequestIDAttribute, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// DefaultBackoff waits 25ms before the first retry, doubling the wait for each
+// subsequent retry up to a maximum of one second.
+func DefaultBackoff(retry int) time.Duration {
+	if retry > 5 {
+		return time.Second
+	}
+	return 25 * time.Millisecond << uint(retry)
+}
+
+// DefaultShouldRetry retries requests that fail to send, or that receive a
+// 502, 503, or 504 response.
+func DefaultShouldRetry(rsp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch rsp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RetryTransport is an http.RoundTripper that retries failed requests. Each
+// attempt is represented by a distinct client span, annotated with its attempt
+// number and the backoff that preceded it, so that retries appear distinctly in
+// Zipkin rather than as one long span. Each attempt's span context is injected
+// into its request.
+//
+// RetryTransport starts its own client spans, so its Base should not be an
+// ochttp.Transport. Requests with a body are only retried if their GetBody
+// function is set.
+type RetryTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Propagation defines how traces are propagated. &HTTPFormat{} is used if
+	// Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// MaxRetries is the maximum number of times a request is retried. Requests
+	// are not retried if MaxRetries is zero.
+	MaxRetries int
+
+	// Backoff returns how long to wait before the supplied retry, starting at
+	// zero. DefaultBackoff is used if Backoff is nil.
+	Backoff func(retry int) time.Duration
+
+	// ShouldRetry returns true if an attempt with the supplied outcome should
+	// be retried. DefaultShouldRetry is used if ShouldRetry is nil.
+	ShouldRetry func(rsp *http.Response, err error) bool
+}
+
+// RoundTrip sends the supplied request, retrying it if necessary.
+func (t *RetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	max := t.MaxRetries
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		max = 0
+	}
+
+	var backoff time.Duration
+	for n := 0; ; n++ {
+		req := r
+		if n > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = r.WithContext(r.Context())
+			req.Body = body
+		}
+		req, span := startAttempt(req, t.propagation(), n)
+		span.AddAttributes(trace.Int64Attribute(BackoffAttribute, int64(backoff/time.Millisecond)))
+
+		rsp, err := t.base().RoundTrip(req)
+		if n == max || !t.shouldRetry(rsp, err) {
+			if err != nil {
+				endAttempt(span, nil, err)
+				return nil, err
+			}
+			rsp.Body = &spanBody{ReadCloser: rsp.Body, end: func() { endAttempt(span, rsp, nil) }}
+			return rsp, nil
+		}
+		if err == nil {
+			// Drain the body so the connection may be reused.
+			io.Copy(ioutil.Discard, rsp.Body)
+			rsp.Body.Close()
+		}
+		endAttempt(span, rsp, err)
+
+		backoff = t.backoff(n)
+		select {
+		case <-time.After(backoff):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+func (t *RetryTransport) backoff(retry int) time.Duration {
+	if t.Backoff == nil {
+		return DefaultBackoff(retry)
+	}
+	return t.Backoff(retry)
+}
+
+func (t *RetryTransport) shouldRetry(rsp *http.Response, err error) bool {
+	if t.ShouldRetry == nil {
+		return DefaultShouldRetry(rsp, err)
+	}
+	return t.ShouldRetry(rsp, err)
+}
+
+func (t *RetryTransport) propagation() propagation.HTTPFormat {
+	if t.Propagation == nil {
+		return &HTTPFormat{}
+	}
+	return t.Propagation
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestDefaultBackoff(t *testing.T) {
+	cases := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{retry: 0, want: 25 * time.Millisecond},
+		{retry: 1, want: 50 * time.Millisecond},
+		{retry: 2, want: 100 * time.Millisecond},
+		{retry: 3, want: 200 * time.Millisecond},
+		{retry: 4, want: 400 * time.Millisecond},
+		{retry: 5, want: 800 * time.Millisecond},
+		{retry: 6, want: time.Second},
+		{retry: 7, want: time.Second},
+		{retry: 8, want: time.Second},
+	}
+
+	for _, tc := range cases {
+		if got := DefaultBackoff(tc.retry); got != tc.want {
+			t.Errorf("DefaultBackoff(%d): want %v, got %v", tc.retry, tc.want, got)
+		}
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		max      int
+		body     bool
+		script   []func(r *http.Request) (*http.Response, error)
+		wantErr  bool
+		wantCode int
+		attempts int
+	}{
+		{
+			name:     "Succeeded",
+			max:      2,
+			script:   []func(r *http.Request) (*http.Response, error){respond(http.StatusOK)},
+			wantCode: http.StatusOK,
+			attempts: 1,
+		},
+		{
+			name:     "Retried",
+			max:      2,
+			script:   []func(r *http.Request) (*http.Response, error){fail, respond(http.StatusServiceUnavailable), respond(http.StatusOK)},
+			wantCode: http.StatusOK,
+			attempts: 3,
+		},
+		{
+			name:     "RetriesExhausted",
+			max:      1,
+			script:   []func(r *http.Request) (*http.Response, error){fail, respond(http.StatusBadGateway)},
+			wantCode: http.StatusBadGateway,
+			attempts: 2,
+		},
+		{
+			name:     "RetriesExhaustedWithError",
+			max:      1,
+			script:   []func(r *http.Request) (*http.Response, error){fail, fail},
+			wantErr:  true,
+			attempts: 2,
+		},
+		{
+			name:     "NotRetryable",
+			max:      2,
+			script:   []func(r *http.Request) (*http.Response, error){respond(http.StatusNotFound)},
+			wantCode: http.StatusNotFound,
+			attempts: 1,
+		},
+		{
+			name:     "UnreplayableBody",
+			max:      2,
+			body:     true,
+			script:   []func(r *http.Request) (*http.Response, error){fail},
+			wantErr:  true,
+			attempts: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			st := &scriptedTransport{script: tc.script}
+			rt := &RetryTransport{
+				Base:       st,
+				MaxRetries: tc.max,
+				Backoff:    func(retry int) time.Duration { return time.Duration(retry+1) * time.Millisecond },
+			}
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+			r, _ := http.NewRequest("GET", "http://example.org/retry", nil)
+			if tc.body {
+				r.Body = &nopCloser{strings.NewReader("body")}
+			}
+			rsp, err := rt.RoundTrip(r.WithContext(ctx))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("rt.RoundTrip(): want error, got nil")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("rt.RoundTrip(): %v", err)
+				}
+				if rsp.StatusCode != tc.wantCode {
+					t.Errorf("rt.RoundTrip(): want status code %d, got %d", tc.wantCode, rsp.StatusCode)
+				}
+				rsp.Body.Close()
+			}
+			parent.End()
+
+			reqs := st.requests()
+			if len(reqs) != tc.attempts {
+				t.Fatalf("rt.RoundTrip(): want %d attempts, got %d", tc.attempts, len(reqs))
+			}
+			spans := map[trace.SpanID]*trace.SpanData{}
+			for _, s := range e.Spans() {
+				spans[s.SpanID] = s
+			}
+			for i, req := range reqs {
+				id, _ := TraceIDFromRequest(req)
+				s, ok := spans[id.Span]
+				if !ok {
+					t.Fatalf("attempt %d: want exported span %x", i, id.Span)
+				}
+				if s.ParentSpanID != parent.SpanContext().SpanID {
+					t.Errorf("attempt %d: want child of %v, got parent %v", i, parent.SpanContext().SpanID, s.ParentSpanID)
+				}
+				if got := s.Attributes[AttemptAttribute]; got != int64(i) {
+					t.Errorf("attempt %d: want %s %d, got %v", i, AttemptAttribute, i, got)
+				}
+				if got := s.Attributes[BackoffAttribute]; got != int64(i) {
+					t.Errorf("attempt %d: want %s %d, got %v", i, BackoffAttribute, i, got)
+				}
+			}
+		})
+	}
+}
+
+// nopCloser is an io.ReadCloser that cannot be replayed.
+type nopCloser struct {
+	*strings.Reader
+}
+
+func (nopCloser) Close() error { return nil }
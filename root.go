@@ -0,0 +1,139 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// probabilitySampler returns a trace.Sampler that samples the supplied
+// fraction of traces. Unlike trace.ProbabilitySampler it considers only the
+// low 64 bits of the trace ID; trace.ProbabilitySampler considers only the
+// high 64 bits, and thus samples every trace with a 64 bit trace ID.
+func probabilitySampler(fraction float64) trace.Sampler {
+	return func(p trace.SamplingParameters) trace.SamplingDecision {
+		if p.ParentContext.IsSampled() {
+			return trace.SamplingDecision{Sample: true}
+		}
+		return trace.SamplingDecision{Sample: sampledAt(p.TraceID, fraction)}
+	}
+}
+
+// TraceIDSampler returns a trace.Sampler that samples the supplied fraction of
+// traces, deciding purely by trace ID. Unlike the default OpenCensus sampler it
+// uses no per-process state, and unlike probabilitySampler it ignores upstream
+// sampling decisions, except that of Finagle's debug flag. Independent services
+// configured with the same fraction thus make identical sampling decisions for
+// the same trace, regardless of what (if anything) their callers propagate,
+// avoiding partial traces across the fleet. Only the low 64 bits of the trace
+// ID are considered, as for probabilitySampler.
+func TraceIDSampler(fraction float64) trace.Sampler {
+	return func(p trace.SamplingParameters) trace.SamplingDecision {
+		if IsDebug(p.ParentContext) {
+			return trace.SamplingDecision{Sample: true}
+		}
+		return trace.SamplingDecision{Sample: sampledAt(p.TraceID, fraction)}
+	}
+}
+
+// RootHandler is an http.Handler that starts a new root span for each incoming
+// request without a valid propagated span context. The root span's trace ID
+// follows Finagle's convention of using 64 bits, rather than the 128 bits used
+// by OpenCensus, ensuring the traces it starts may be joined by linkerd. The
+// root span is injected into the request's context before it is handled.
+//
+// Only the root span's trace ID follows Finagle's conventions. OpenCensus
+// assigns span IDs itself, so unlike a Finagle root span, the root span's ID is
+// not the low 64 bits of its trace ID, and TraceID.IsRoot would report false
+// were its span context propagated.
+//
+// RootHandler must wrap an ochttp.Handler, which will create its server span as
+// a child of the root span. Requests with a propagated span context, or with a
+// span already in their context, are handled unmodified.
+type RootHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Propagation defines how traces are propagated. &HTTPFormat{} is used if
+	// Propagation is nil.
+	Propagation propagation.HTTPFormat
+
+	// Sampler decides whether root spans are sampled. The default sampler
+	// configured via trace.ApplyConfig is used if Sampler is nil. Note that
+	// trace.ProbabilitySampler, the OpenCensus default, considers only the
+	// high 64 bits of the trace ID. These are zero for root spans, so it
+	// samples every root span; use TraceIDSampler to sample a fraction of
+	// them.
+	Sampler trace.Sampler
+
+	// IDGenerator generates root trace IDs, of which only the low 64 bits are
+	// used. Random IDs are generated if IDGenerator is nil.
+	IDGenerator IDGenerator
+
+	// FormatSpanName returns the name of the root span. The URL path is used
+	// if FormatSpanName is nil.
+	FormatSpanName func(*http.Request) string
+}
+
+// ServeHTTP starts a new root span if necessary, then serves the request.
+func (h *RootHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if trace.FromContext(r.Context()) != nil {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+	if _, ok := h.propagation().SpanContextFromRequest(r); ok {
+		h.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	g := h.IDGenerator
+	if g == nil {
+		g = defaultIDs
+	}
+	name := r.URL.Path
+	if h.FormatSpanName != nil {
+		name = h.FormatSpanName(r)
+	}
+
+	ctx, span := startRootSpan(r.Context(), name, g, h.Sampler, trace.SpanKindServer)
+	defer span.End()
+
+	h.Handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// startRootSpan starts a new root span with a 64 bit trace ID generated by the
+// supplied generator, per Finagle's convention. The span's ID is assigned by
+// OpenCensus. The default sampler is used if the supplied sampler is nil.
+func startRootSpan(ctx context.Context, name string, g IDGenerator, s trace.Sampler, kind int) (context.Context, *trace.Span) {
+	// A remote parent with a trace ID but no span ID causes OpenCensus to
+	// start a root span with the supplied trace ID.
+	parent := trace.SpanContext{TraceID: newRootTraceID(g)}
+	return trace.StartSpanWithRemoteParent(ctx, name, parent,
+		trace.WithSampler(s),
+		trace.WithSpanKind(kind))
+}
+
+func (h *RootHandler) propagation() propagation.HTTPFormat {
+	if h.Propagation == nil {
+		return &HTTPFormat{}
+	}
+	return h.Propagation
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestRootHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		spans  int
+	}{
+		{
+			name:  "NoHeader",
+			spans: 2,
+		},
+		{
+			name:   "InvalidHeader",
+			header: "PROBABLYNOTBASE64",
+			spans:  2,
+		},
+		{
+			name:   "ValidHeader",
+			header: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+			spans:  1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			h := &RootHandler{
+				Sampler: trace.AlwaysSample(),
+				Handler: &ochttp.Handler{
+					Propagation:  &HTTPFormat{},
+					StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+					Handler:      http.NotFoundHandler(),
+				},
+			}
+			r := httptest.NewRequest("GET", "http://example.org/root", nil)
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := e.Spans()
+			if len(spans) != tc.spans {
+				t.Fatalf("h.ServeHTTP(): want %d exported spans, got %d", tc.spans, len(spans))
+			}
+			if tc.spans == 1 {
+				return
+			}
+
+			// The ochttp server span ends, and is thus exported, first.
+			child, root := spans[0], spans[1]
+			if root.ParentSpanID != (trace.SpanID{}) {
+				t.Errorf("root.ParentSpanID: want none, got %v", root.ParentSpanID)
+			}
+			if hi, lo := root.TraceID[0:8], root.TraceID[8:16]; !bytes.Equal(hi, make([]byte, 8)) || bytes.Equal(lo, make([]byte, 8)) {
+				t.Errorf("root.TraceID: want 64 bit trace ID, got %v", root.TraceID)
+			}
+			if child.ParentSpanID != root.SpanID || child.TraceID != root.TraceID {
+				t.Errorf("child: want child of %v, got %v", root.SpanContext, child.SpanContext)
+			}
+		})
+	}
+}
+
+// wideIDs generates 128 bit trace IDs, of which root spans use the low 64 bits.
+type wideIDs struct{}
+
+func (g wideIDs) NewTraceID() [16]byte { return [16]byte{0: 0xff, 15: 1} }
+func (g wideIDs) NewSpanID() [8]byte   { return [8]byte{7: 2} }
+
+func TestRootHandlerIDGenerator(t *testing.T) {
+	var got trace.TraceID
+	h := &RootHandler{
+		Sampler:     trace.AlwaysSample(),
+		IDGenerator: wideIDs{},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = trace.FromContext(r.Context()).SpanContext().TraceID
+		}),
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.org", nil))
+	if want := (trace.TraceID{15: 1}); got != want {
+		t.Errorf("h.ServeHTTP(): want root trace ID %v, got %v", want, got)
+	}
+}
+
+func TestRootHandlerDefaultSampler(t *testing.T) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	defer trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+
+	var sampled bool
+	h := &RootHandler{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sampled = trace.FromContext(r.Context()).SpanContext().IsSampled()
+	})}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.org", nil))
+	if !sampled {
+		t.Errorf("h.ServeHTTP(): want root span sampled by the default sampler")
+	}
+}
+
+func TestTraceIDSampler(t *testing.T) {
+	// The low 64 bits of these trace IDs begin 0x00 and 0xff respectively.
+	low := trace.TraceID{8: 0x00, 15: 0x01}
+	high := trace.TraceID{8: 0xff, 15: 0x01}
+
+	cases := []struct {
+		name   string
+		id     trace.TraceID
+		parent trace.SpanContext
+		want   bool
+	}{
+		{name: "BelowRate", id: low, want: true},
+		{name: "AboveRate", id: high},
+		{name: "SampledParentAboveRate", id: high, parent: trace.SpanContext{TraceID: high, TraceOptions: ocShouldSample}},
+		{name: "UnsampledParentBelowRate", id: low, parent: trace.SpanContext{TraceID: low}, want: true},
+		{name: "DebugParentAboveRate", id: high, parent: trace.SpanContext{TraceID: high, TraceOptions: ocShouldSample | ocDebug}, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := TraceIDSampler(0.5)(trace.SamplingParameters{ParentContext: tc.parent, TraceID: tc.id})
+			if got.Sample != tc.want {
+				t.Errorf("TraceIDSampler(0.5): want sample %t, got %t", tc.want, got.Sample)
+			}
+		})
+	}
+}
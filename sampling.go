@@ -0,0 +1,81 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opencensus.io/trace"
+)
+
+// SamplingReason returns the reason for the upstream sampling decision of the
+// span context HTTPFormat extracts from the supplied request. It returns false if
+// the request carries no linkerd span context.
+func (f *HTTPFormat) SamplingReason(r *http.Request) (SamplingReason, bool) {
+	sc, ok := decode(r.Context(), f.header(r))
+	if !ok {
+		return "", false
+	}
+	return extractedReason(r.Header, sc), true
+}
+
+// SamplingHandler is an http.Handler that adds an l5d.sampling.reason attribute
+// to the span in each request's context, describing why the span was sampled,
+// to make sampling behavior auditable per trace. Spans that were not sampled
+// record no attributes. SamplingHandler must be wrapped by an ochttp.Handler.
+type SamplingHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Format is the format used by the wrapping ochttp.Handler to extract
+	// span context. &HTTPFormat{} is used if Format is nil.
+	Format *HTTPFormat
+}
+
+// ServeHTTP annotates the request's span, then serves the request.
+func (h *SamplingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if span := trace.FromContext(r.Context()); span != nil {
+		span.AddAttributes(trace.StringAttribute(SamplingReasonAttribute, string(h.reason(r, span.SpanContext()))))
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// reason explains the sampling decision for the supplied span context of the
+// supplied request. The decision made by HTTPFormat is reported unless the span
+// was started with a sampler that overrode it. Root spans sampled per their
+// l5d-sample header (see StartOptions) are reported as such, while other root
+// spans were necessarily sampled by the local sampler.
+func (h *SamplingHandler) reason(r *http.Request, sc trace.SpanContext) SamplingReason {
+	f := h.Format
+	if f == nil {
+		f = &HTTPFormat{}
+	}
+	if !sc.IsSampled() {
+		return NotSampled
+	}
+	if reason, ok := f.SamplingReason(r); ok {
+		if reason == NotSampled {
+			return SampledLocal
+		}
+		return reason
+	}
+	if rate, err := strconv.ParseFloat(headerValue(r.Header, l5dHeaderSample), 64); err == nil && sampledAt(sc.TraceID, rate) {
+		return SampledL5dSample
+	}
+	return SampledLocal
+}
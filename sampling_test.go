@@ -0,0 +1,124 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	const (
+		sampled   = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+		unsampled = "laEAbScFR/gDfE/j8FV/8P8jOugI0dtmAAAAAAAAAAA="
+		debug     = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAEAAAAAAAAAAA=="
+	)
+
+	cases := []struct {
+		name    string
+		f       *HTTPFormat
+		header  string
+		sample  string
+		sampler trace.Sampler
+		start   func(*http.Request) trace.StartOptions
+		want    SamplingReason
+	}{
+		{
+			name:   "Inherited",
+			header: sampled,
+			want:   SampledInherited,
+		},
+		{
+			name:   "L5dSample",
+			header: sampled,
+			sample: "1",
+			want:   SampledL5dSample,
+		},
+		{
+			name:   "Debug",
+			header: debug,
+			want:   SampledDebug,
+		},
+		{
+			name:    "LocalOverride",
+			header:  unsampled,
+			sampler: trace.AlwaysSample(),
+			want:    SampledLocal,
+		},
+		{
+			name:    "Root",
+			sampler: trace.AlwaysSample(),
+			want:    SampledLocal,
+		},
+		{
+			name:   "RootL5dSample",
+			sample: "1",
+			start:  StartOptions,
+			want:   SampledL5dSample,
+		},
+		{
+			name:    "NotSampled",
+			header:  sampled,
+			sampler: trace.NeverSample(),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			f := tc.f
+			if f == nil {
+				f = &HTTPFormat{}
+			}
+			h := &ochttp.Handler{
+				Handler:         &SamplingHandler{Handler: http.NotFoundHandler(), Format: tc.f},
+				Propagation:     f,
+				StartOptions:    trace.StartOptions{Sampler: tc.sampler},
+				GetStartOptions: tc.start,
+			}
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTrace, tc.header)
+			}
+			if tc.sample != "" {
+				r.Header.Set(l5dHeaderSample, tc.sample)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			spans := e.Spans()
+			if tc.want == "" {
+				if len(spans) != 0 {
+					t.Errorf("h.ServeHTTP(): want no exported spans, got %d", len(spans))
+				}
+				return
+			}
+			if len(spans) != 1 {
+				t.Fatalf("h.ServeHTTP(): want 1 exported span, got %d", len(spans))
+			}
+			if got := spans[0].Attributes[SamplingReasonAttribute]; got != string(tc.want) {
+				t.Errorf("h.ServeHTTP(): want %s attribute %q, got %v", SamplingReasonAttribute, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// A Mask determines how a Scrubber masks the values of a class of headers.
+type Mask int
+
+// Masks.
+const (
+	// MaskRedact replaces each value with a fixed placeholder.
+	MaskRedact Mask = iota
+
+	// MaskHash replaces each value with a truncated SHA-256 hash of itself,
+	// allowing log lines to be correlated without revealing the value.
+	MaskHash
+
+	// MaskDrop removes the header entirely.
+	MaskDrop
+
+	// MaskKeep leaves each value unmodified.
+	MaskKeep
+)
+
+// redacted replaces header values masked with MaskRedact.
+const redacted = "[REDACTED]"
+
+// DefaultSensitiveHeaders are masked by a Scrubber with no configured
+// sensitive headers.
+var DefaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func isBaggageHeader(k string) bool {
+	k = strings.ToLower(k)
+	return k == l5dHeaderTags || k == l5dHeaderPrefix+"baggage" || k == "baggage"
+}
+
+// A Scrubber produces copies of HTTP headers that are safe to log, masking
+// trace context, baggage, and other sensitive header values per its policy.
+// The zero value redacts trace context, baggage, and DefaultSensitiveHeaders.
+type Scrubber struct {
+	// Trace determines how trace context headers (l5d-*, X-B3-*, b3,
+	// traceparent, and tracestate) are masked.
+	Trace Mask
+
+	// Baggage determines how baggage headers (l5d-ctx-tags, l5d-ctx-baggage,
+	// and baggage) are masked. Baggage frequently carries user or tenant
+	// identifiers.
+	Baggage Mask
+
+	// Sensitive are the names of other headers whose values are always
+	// redacted. DefaultSensitiveHeaders are redacted if Sensitive is nil.
+	Sensitive []string
+}
+
+// Header returns a scrubbed copy of the supplied headers.
+func (s *Scrubber) Header(h http.Header) http.Header {
+	sensitive := s.Sensitive
+	if sensitive == nil {
+		sensitive = DefaultSensitiveHeaders
+	}
+
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		m := MaskKeep
+		switch {
+		case contains(sensitive, k):
+			m = MaskRedact
+		case isBaggageHeader(k):
+			m = s.Baggage
+		case isTraceHeader(k):
+			m = s.Trace
+		}
+		if m == MaskDrop {
+			continue
+		}
+		out[k] = mask(vs, m)
+	}
+	return out
+}
+
+// Request returns a shallow copy of the supplied request with scrubbed
+// headers, e.g. for use with httputil.DumpRequest.
+func (s *Scrubber) Request(r *http.Request) *http.Request {
+	out := r.WithContext(r.Context())
+	out.Header = s.Header(r.Header)
+	return out
+}
+
+func contains(names []string, k string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func mask(vs []string, m Mask) []string {
+	if m == MaskKeep {
+		return vs
+	}
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		switch m {
+		case MaskHash:
+			sum := sha256.Sum256([]byte(v))
+			out[i] = "sha256:" + hex.EncodeToString(sum[:8])
+		default:
+			out[i] = redacted
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	h := http.Header{
+		"L5d-Ctx-Trace": {"9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="},
+		"L5d-Ctx-Tags":  {"team=payments"},
+		"X-B3-Traceid":  {"463ac35c9f6413ad"},
+		"Authorization": {"Bearer secret"},
+		"Accept":        {"application/json"},
+	}
+
+	cases := []struct {
+		name     string
+		scrubber *Scrubber
+		want     http.Header
+	}{
+		{
+			name:     "Default",
+			scrubber: &Scrubber{},
+			want: http.Header{
+				"L5d-Ctx-Trace": {redacted},
+				"L5d-Ctx-Tags":  {redacted},
+				"X-B3-Traceid":  {redacted},
+				"Authorization": {redacted},
+				"Accept":        {"application/json"},
+			},
+		},
+		{
+			name:     "KeepTraceDropBaggage",
+			scrubber: &Scrubber{Trace: MaskKeep, Baggage: MaskDrop},
+			want: http.Header{
+				"L5d-Ctx-Trace": {"9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="},
+				"X-B3-Traceid":  {"463ac35c9f6413ad"},
+				"Authorization": {redacted},
+				"Accept":        {"application/json"},
+			},
+		},
+		{
+			name:     "HashTraceNoSensitive",
+			scrubber: &Scrubber{Trace: MaskHash, Baggage: MaskKeep, Sensitive: []string{}},
+			want: http.Header{
+				"L5d-Ctx-Trace": {"sha256:6b51dde051d6528f"},
+				"L5d-Ctx-Tags":  {"team=payments"},
+				"X-B3-Traceid":  {"sha256:e219ef530a2c74d5"},
+				"Authorization": {"Bearer secret"},
+				"Accept":        {"application/json"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.scrubber.Header(h)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("s.Header():\ngot:  %v\nwant: %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScrubberRequest(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://example.org", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+
+	b, err := httputil.DumpRequest((&Scrubber{}).Request(r), false)
+	if err != nil {
+		t.Fatalf("httputil.DumpRequest(): %v", err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Errorf("s.Request(): want scrubbed dump, got %q", b)
+	}
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("s.Request(): original request was modified")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"go.opencensus.io/trace"
+)
+
+type seedKey struct{}
+
+// A Seed seeds service level span attributes and propagation configuration
+// into the context of every request served by an http.Server, so that handlers
+// and transports deep in a service need not rely on global state, e.g.:
+//
+//  seed := &linkin.Seed{Stack: stack, Attributes: []trace.Attribute{
+//    trace.StringAttribute("service.version", version),
+//  }}
+//  srv := &http.Server{Handler: handler, BaseContext: seed.BaseContext}
+//
+// Use StackFromContext to retrieve the seeded stack, and AnnotateService to add
+// the seeded attributes to each request's span.
+type Seed struct {
+	// Context is the parent of the seeded contexts. context.Background() is
+	// used if Context is nil.
+	Context context.Context
+
+	// Stack is the propagation stack seeded into each context.
+	Stack *Stack
+
+	// Attributes are the service level span attributes seeded into each
+	// context.
+	Attributes []trace.Attribute
+}
+
+// BaseContext returns a seeded context, for use as an http.Server's
+// BaseContext.
+func (s *Seed) BaseContext(_ net.Listener) context.Context {
+	ctx := s.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, seedKey{}, s)
+}
+
+// ConnContext seeds the supplied context, unless it is already seeded, for use
+// as an http.Server's ConnContext. Use ConnContext when the server's
+// BaseContext is used for another purpose.
+func (s *Seed) ConnContext(ctx context.Context, _ net.Conn) context.Context {
+	if _, ok := ctx.Value(seedKey{}).(*Seed); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, seedKey{}, s)
+}
+
+// StackFromContext returns the propagation stack seeded into the supplied
+// context, or nil if no stack was seeded.
+func StackFromContext(ctx context.Context) *Stack {
+	if s, ok := ctx.Value(seedKey{}).(*Seed); ok {
+		return s.Stack
+	}
+	return nil
+}
+
+// ServiceAttributesFromContext returns the service level span attributes seeded
+// into the supplied context, if any.
+func ServiceAttributesFromContext(ctx context.Context) []trace.Attribute {
+	if s, ok := ctx.Value(seedKey{}).(*Seed); ok {
+		return s.Attributes
+	}
+	return nil
+}
+
+// AnnotateService wraps the supplied handler, adding the service level
+// attributes seeded into each request's context to the span in its context.
+// AnnotateService must be wrapped by an ochttp.Handler.
+func AnnotateService(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := trace.FromContext(r.Context()); span != nil {
+			if attrs := ServiceAttributesFromContext(r.Context()); len(attrs) > 0 {
+				span.AddAttributes(attrs...)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
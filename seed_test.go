@@ -0,0 +1,119 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestSeed(t *testing.T) {
+	stack, err := Config{}.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+	seed := &Seed{Stack: stack, Attributes: []trace.Attribute{trace.StringAttribute("service.version", "v1")}}
+	other := &Seed{}
+
+	cases := []struct {
+		name  string
+		seed  func(s *httptest.Server)
+		want  *Stack
+		attrs int
+	}{
+		{
+			name:  "BaseContext",
+			seed:  func(s *httptest.Server) { s.Config.BaseContext = seed.BaseContext },
+			want:  stack,
+			attrs: 1,
+		},
+		{
+			name:  "ConnContext",
+			seed:  func(s *httptest.Server) { s.Config.ConnContext = seed.ConnContext },
+			want:  stack,
+			attrs: 1,
+		},
+		{
+			name: "AlreadySeeded",
+			seed: func(s *httptest.Server) {
+				s.Config.BaseContext = other.BaseContext
+				s.Config.ConnContext = seed.ConnContext
+			},
+		},
+		{
+			name: "Unseeded",
+			seed: func(s *httptest.Server) {},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *Stack
+			var attrs int
+			s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = StackFromContext(r.Context())
+				attrs = len(ServiceAttributesFromContext(r.Context()))
+			}))
+			tc.seed(s)
+			s.Start()
+			defer s.Close()
+
+			rsp, err := http.Get(s.URL)
+			if err != nil {
+				t.Fatalf("http.Get(): %v", err)
+			}
+			rsp.Body.Close()
+
+			if got != tc.want {
+				t.Errorf("StackFromContext(): want %p, got %p", tc.want, got)
+			}
+			if attrs != tc.attrs {
+				t.Errorf("ServiceAttributesFromContext(): want %d attributes, got %d", tc.attrs, attrs)
+			}
+		})
+	}
+}
+
+func TestAnnotateService(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	seed := &Seed{Attributes: []trace.Attribute{trace.StringAttribute("service.version", "v1")}}
+	h := &ochttp.Handler{
+		Handler:      AnnotateService(http.NotFoundHandler()),
+		StartOptions: trace.StartOptions{Sampler: trace.AlwaysSample()},
+	}
+	r := httptest.NewRequest("GET", "http://example.org", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r.WithContext(seed.BaseContext(nil)))
+
+	spans := e.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("h.ServeHTTP(): want 1 exported span, got %d", len(spans))
+	}
+	if got := spans[0].Attributes["service.version"]; got != "v1" {
+		t.Errorf("AnnotateService(): want attribute service.version=v1, got %v", spans[0].Attributes)
+	}
+	if got := ServiceAttributesFromContext(context.Background()); got != nil {
+		t.Errorf("ServiceAttributesFromContext(): want nil, got %v", got)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// ShadowTransport is an http.RoundTripper that mirrors requests to a shadow
+// destination, e.g. a new version of a service under test, without affecting
+// the original request. Shadow requests are sent asynchronously and their
+// responses discarded.
+//
+// Each shadow request carries the trace ID of the span in the original
+// request's context, but a new span ID and an unsampled decision, so services
+// handling shadow traffic do not report spans that double count the original
+// request's in Zipkin. Its l5d-ctx-trace header, if any, has Finagle's sampling
+// known flag set, so that linkerd does not make its own sampling decision.
+// Rather than being its child, the shadow request is linked from the span in
+// the original request's context. Any l5d-sample header is removed from shadow
+// requests.
+//
+// Shadow should not be an ochttp.Transport, which would start sampled client
+// spans for shadow requests. Requests with a body are only mirrored if their
+// GetBody function is set.
+type ShadowTransport struct {
+	// Base is the RoundTripper used to send original requests.
+	// http.DefaultTransport is used if Base is nil.
+	Base http.RoundTripper
+
+	// Shadow is the RoundTripper used to send shadow requests.
+	// http.DefaultTransport is used if Shadow is nil.
+	Shadow http.RoundTripper
+
+	// Mirror returns the shadow request to send for the supplied clone of an
+	// original request, e.g. by rewriting its URL, or nil if the request
+	// should not be mirrored. Requests are not mirrored if Mirror is nil.
+	Mirror func(r *http.Request) *http.Request
+
+	// Propagation defines how traces are propagated. &HTTPFormat{} is used if
+	// Propagation is nil.
+	Propagation propagation.HTTPFormat
+}
+
+// RoundTrip mirrors the supplied request, if necessary, then sends it.
+func (t *ShadowTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if s := t.shadow(r); s != nil {
+		go t.send(s)
+	}
+	return t.base().RoundTrip(r)
+}
+
+// shadow returns the shadow request for the supplied request, or nil.
+func (t *ShadowTransport) shadow(r *http.Request) *http.Request {
+	if t.Mirror == nil || (r.Body != nil && r.Body != http.NoBody && r.GetBody == nil) {
+		return nil
+	}
+	s := r.Clone(Detach(r.Context()))
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil
+		}
+		s.Body = body
+	}
+	if s = t.Mirror(s); s == nil {
+		return nil
+	}
+	s.Header.Del(l5dHeaderSample)
+
+	if span := trace.FromContext(r.Context()); span != nil {
+		sc := shadowSpanContext(span.SpanContext())
+		span.AddLink(trace.Link{
+			TraceID:    sc.TraceID,
+			SpanID:     sc.SpanID,
+			Type:       trace.LinkTypeChild,
+			Attributes: map[string]interface{}{ShadowAttribute: true},
+		})
+		t.propagation().SpanContextToRequest(sc, s)
+		markUnsampled(s)
+	}
+	return s
+}
+
+// markUnsampled sets the Finagle flags of the supplied request's l5d-ctx-trace
+// header, if any, to record a decision not to sample. A trace.SpanContext
+// cannot distinguish that decision from no decision, so formats omit it. The
+// header's width and encoding are preserved.
+func markUnsampled(r *http.Request) {
+	v := headerValue(r.Header, l5dHeaderTrace)
+	id, err := wire.Parse(v)
+	if err != nil {
+		return
+	}
+	id.Flags = wire.FlagSamplingKnown
+
+	enc := base64.StdEncoding
+	if strings.ContainsAny(v, "-_") {
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(v, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	if base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(v, "="))) == 32 {
+		r.Header.Set(l5dHeaderTrace, id.Encode32(enc))
+		return
+	}
+	r.Header.Set(l5dHeaderTrace, id.Encode(enc))
+}
+
+// shadowSpanContext returns an unsampled span context in the same trace as the
+// supplied span context, with a new span ID.
+func shadowSpanContext(sc trace.SpanContext) trace.SpanContext {
+	return trace.SpanContext{TraceID: sc.TraceID, SpanID: defaultIDs.NewSpanID()}
+}
+
+func (t *ShadowTransport) send(s *http.Request) {
+	rt := t.Shadow
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	rsp, err := rt.RoundTrip(s)
+	if err != nil {
+		return
+	}
+	rsp.Body.Close()
+}
+
+func (t *ShadowTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *ShadowTransport) propagation() propagation.HTTPFormat {
+	if t.Propagation == nil {
+		return &HTTPFormat{}
+	}
+	return t.Propagation
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestShadowTransport(t *testing.T) {
+	cases := []struct {
+		name        string
+		mirror      func(r *http.Request) *http.Request
+		propagation propagation.HTTPFormat
+		body        bool
+		getBody     bool
+		wantSent    bool
+		wantHeader  int
+	}{
+		{
+			name: "Mirrored",
+			mirror: func(r *http.Request) *http.Request {
+				r.URL.Host = "shadow.example.org"
+				return r
+			},
+			wantSent:   true,
+			wantHeader: 56,
+		},
+		{
+			name: "MirroredRawURL64",
+			mirror: func(r *http.Request) *http.Request {
+				r.URL.Host = "shadow.example.org"
+				return r
+			},
+			propagation: &HTTPFormat{Encoding: base64.RawURLEncoding, Linkerd: NewDetector(Capabilities{})},
+			wantSent:    true,
+			wantHeader:  43,
+		},
+		{
+			name: "MirroredWithBody",
+			mirror: func(r *http.Request) *http.Request {
+				r.URL.Host = "shadow.example.org"
+				return r
+			},
+			body:       true,
+			getBody:    true,
+			wantSent:   true,
+			wantHeader: 56,
+		},
+		{
+			name:   "BodyWithoutGetBody",
+			mirror: func(r *http.Request) *http.Request { return r },
+			body:   true,
+		},
+		{
+			name:   "NotMirrored",
+			mirror: func(r *http.Request) *http.Request { return nil },
+		},
+		{
+			name: "NoMirror",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &recordingExporter{}
+			trace.RegisterExporter(e)
+			defer trace.UnregisterExporter(e)
+
+			base := &recordingTransport{}
+			shadowed := make(chan *http.Request, 1)
+			rt := &ShadowTransport{
+				Base: base,
+				Shadow: transportFunc(func(r *http.Request) (*http.Response, error) {
+					shadowed <- r
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+				}),
+				Mirror:      tc.mirror,
+				Propagation: tc.propagation,
+			}
+
+			ctx, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+			r, _ := http.NewRequest("POST", "http://example.org", nil)
+			if tc.body {
+				r, _ = http.NewRequest("POST", "http://example.org", strings.NewReader("body"))
+				if !tc.getBody {
+					r.GetBody = nil
+				}
+			}
+			r.Header.Set(l5dHeaderSample, "1.0")
+			if _, err := rt.RoundTrip(r.WithContext(ctx)); err != nil {
+				t.Fatalf("rt.RoundTrip(): %v", err)
+			}
+			span.End()
+
+			if base.r == nil || base.r.URL.Host != "example.org" {
+				t.Errorf("rt.RoundTrip(): want original request sent to example.org, got %v", base.r)
+			}
+			if base.r.Header.Get(l5dHeaderSample) == "" {
+				t.Errorf("rt.RoundTrip(): want original request to keep its %s header", l5dHeaderSample)
+			}
+
+			var s *http.Request
+			select {
+			case s = <-shadowed:
+			case <-time.After(100 * time.Millisecond):
+			}
+			if (s != nil) != tc.wantSent {
+				t.Fatalf("rt.RoundTrip(): want shadow request sent %t, got %v", tc.wantSent, s)
+			}
+			if s == nil {
+				return
+			}
+
+			if s.URL.Host != "shadow.example.org" {
+				t.Errorf("rt.RoundTrip(): want shadow request sent to shadow.example.org, got %s", s.URL.Host)
+			}
+			if s.Header.Get(l5dHeaderSample) != "" {
+				t.Errorf("rt.RoundTrip(): want no %s header on shadow request, got %v", l5dHeaderSample, s.Header)
+			}
+			sc, ok := (&HTTPFormat{}).SpanContextFromRequest(s)
+			if !ok {
+				t.Fatalf("rt.RoundTrip(): want shadow span context, got %v", s.Header)
+			}
+			parent := span.SpanContext()
+			// 32 byte l5d-ctx-trace headers carry only the low 64 bits of the
+			// trace ID.
+			if !bytes.Equal(sc.TraceID[8:], parent.TraceID[8:]) || sc.SpanID == parent.SpanID || sc.IsSampled() {
+				t.Errorf("rt.RoundTrip(): want unsampled span context in trace %v, got %+v", parent.TraceID, sc)
+			}
+
+			h := s.Header.Get(l5dHeaderTrace)
+			if len(h) != tc.wantHeader {
+				t.Errorf("rt.RoundTrip(): want %d character %s header, got %q", tc.wantHeader, l5dHeaderTrace, h)
+			}
+			if id, err := wire.Parse(h); err != nil || id.Flags != wire.FlagSamplingKnown {
+				t.Errorf("rt.RoundTrip(): want %s flags %d, got %d (%v)", l5dHeaderTrace, wire.FlagSamplingKnown, id.Flags, err)
+			}
+
+			spans := e.Spans()
+			if len(spans) != 1 || len(spans[0].Links) != 1 || spans[0].Links[0].SpanID != sc.SpanID {
+				t.Errorf("rt.RoundTrip(): want parent span linked to shadow span %v, got %+v", sc.SpanID, spans)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SpanNamer derives low cardinality span names from route templates, rather
+// than from raw URL paths that include IDs and thus explode Zipkin's indexes.
+// Use its FormatSpanName method as the FormatSpanName function of an
+// ochttp.Handler or ochttp.Transport:
+//
+//  n := &linkin.SpanNamer{Routes: []string{"/users/{id}", "/users/{id}/orders/{order}", "/static/{path...}"}}
+//  h := &ochttp.Handler{Handler: h, Propagation: &linkin.HTTPFormat{}, FormatSpanName: n.FormatSpanName}
+type SpanNamer struct {
+	// Routes are the route templates from which span names are derived. A
+	// segment of the form {name} matches any single path segment. A final
+	// segment of the form {name...} matches the remainder of the path. When
+	// several routes match a path the route with the most literal segments
+	// applies, or the first such route in the case of a tie.
+	Routes []string
+
+	// Normalize derives span names for paths that match no route.
+	// NormalizePath is used if Normalize is nil.
+	Normalize func(path string) string
+}
+
+// FormatSpanName returns the span name of the supplied request: the route
+// template matching its path, or its normalized path.
+func (n *SpanNamer) FormatSpanName(r *http.Request) string {
+	path := r.URL.Path
+	match, best := "", -1
+	for _, route := range n.Routes {
+		if literals, ok := matchRoute(route, path); ok && literals > best {
+			match, best = route, literals
+		}
+	}
+	if best >= 0 {
+		return match
+	}
+	if n.Normalize == nil {
+		return NormalizePath(path)
+	}
+	return n.Normalize(path)
+}
+
+// matchRoute returns true if the supplied route template matches the supplied
+// path, and the number of literal segments in the route.
+func matchRoute(route, path string) (int, bool) {
+	rs := strings.Split(strings.Trim(route, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	literals := 0
+	for i, r := range rs {
+		wildcard := strings.HasPrefix(r, "{") && strings.HasSuffix(r, "}")
+		if wildcard && strings.HasSuffix(r, "...}") && i == len(rs)-1 {
+			return literals, len(ps) >= i
+		}
+		if i >= len(ps) {
+			return 0, false
+		}
+		if wildcard {
+			if ps[i] == "" {
+				return 0, false
+			}
+			continue
+		}
+		if r != ps[i] {
+			return 0, false
+		}
+		literals++
+	}
+	return literals, len(rs) == len(ps)
+}
+
+// NormalizePath replaces path segments that look like identifiers with
+// placeholders: numeric segments with {id}, UUIDs with {uuid}, and hex strings
+// of 16 or more characters (e.g. trace IDs and hashes) with {hex}.
+func NormalizePath(path string) string {
+	ss := strings.Split(path, "/")
+	for i, s := range ss {
+		switch {
+		case s == "":
+		case isDigits(s):
+			ss[i] = "{id}"
+		case isUUID(s):
+			ss[i] = "{uuid}"
+		case len(s) >= 16 && isHex(s):
+			ss[i] = "{hex}"
+		}
+	}
+	if p := strings.Join(ss, "/"); p != "" {
+		return p
+	}
+	return "/"
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for _, i := range []int{8, 13, 18, 23} {
+		if s[i] != '-' {
+			return false
+		}
+	}
+	return isHex(strings.Replace(s, "-", "", -1))
+}
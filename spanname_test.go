@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpanNamer(t *testing.T) {
+	routes := []string{
+		"/",
+		"/users/{id}",
+		"/users/{id}/orders/{order}",
+		"/users/me",
+		"/static/{path...}",
+	}
+
+	cases := []struct {
+		name      string
+		normalize func(string) string
+		path      string
+		want      string
+	}{
+		{name: "Root", path: "/", want: "/"},
+		{name: "Wildcard", path: "/users/42", want: "/users/{id}"},
+		{name: "NestedWildcards", path: "/users/42/orders/abc", want: "/users/{id}/orders/{order}"},
+		{name: "MostLiteralSegmentsWin", path: "/users/me", want: "/users/me"},
+		{name: "Remainder", path: "/static/css/site.css", want: "/static/{path...}"},
+		{name: "EmptyRemainder", path: "/static/", want: "/static/{path...}"},
+		{name: "EmptySegment", path: "/users/", want: "/users/"},
+		{name: "TooLong", path: "/users/42/orders", want: "/users/{id}/orders"},
+		{name: "Normalized", path: "/accounts/7/a3bb189e-8bf9-3888-9912-ace4e6543002/32a4db20f5d592e7", want: "/accounts/{id}/{uuid}/{hex}"},
+		{name: "CustomNormalize", normalize: strings.ToUpper, path: "/accounts/7", want: "/ACCOUNTS/7"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := &SpanNamer{Routes: routes, Normalize: tc.normalize}
+			r := httptest.NewRequest("GET", "http://example.org"+tc.path, nil)
+			if got := n.FormatSpanName(r); got != tc.want {
+				t.Errorf("n.FormatSpanName(%q): want %q, got %q", tc.path, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{path: "", want: "/"},
+		{path: "/", want: "/"},
+		{path: "/users/42", want: "/users/{id}"},
+		{path: "/users/a3bb189e-8bf9-3888-9912-ace4e6543002", want: "/users/{uuid}"},
+		{path: "/traces/32a4db20f5d592e7", want: "/traces/{hex}"},
+		{path: "/users/deadbeef", want: "/users/deadbeef"},
+		{path: "/v2/users", want: "/v2/users"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			if got := NormalizePath(tc.path); got != tc.want {
+				t.Errorf("NormalizePath(%q): want %q, got %q", tc.path, tc.want, got)
+			}
+		})
+	}
+}
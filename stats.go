@@ -0,0 +1,129 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Measures recorded by this package.
+var (
+	DroppedHeaders    = stats.Int64("linkin/dropped_headers", "Number of linkerd context headers dropped to satisfy a size limit", stats.UnitDimensionless)
+	SamplingDecisions = stats.Int64("linkin/sampling_decisions", "Number of sampling decisions made for extracted span contexts", stats.UnitDimensionless)
+	CanaryResponses   = stats.Int64("linkin/canary_responses", "Number of responses to requests that carried a canary propagation format", stats.UnitDimensionless)
+	ServerLatency     = stats.Float64("linkin/server/latency", "End-to-end latency of requests served by an ExemplarHandler", stats.UnitMilliseconds)
+	Disagreements     = stats.Int64("linkin/disagreements", "Number of requests whose linkerd and B3 headers disagree", stats.UnitDimensionless)
+	WidthMismatches   = stats.Int64("linkin/width_mismatches", "Number of requests whose trace ID width differs from that emitted locally", stats.UnitDimensionless)
+	DroppedSpans      = stats.Int64("linkin/zipkin/dropped_spans", "Number of spans dropped because a Zipkin reporter's buffer was full", stats.UnitDimensionless)
+	ReportRetries     = stats.Int64("linkin/zipkin/report_retries", "Number of retried attempts to report spans to a Zipkin collector", stats.UnitDimensionless)
+	InvalidInjections = stats.Int64("linkin/invalid_injections", "Number of invalid span contexts a strict HTTPFormat refused to inject", stats.UnitDimensionless)
+)
+
+// Tag keys recorded by this package.
+var (
+	KeySamplingReason = tag.MustNewKey("linkin_sampling_reason")
+	KeyCanaryResult   = tag.MustNewKey("linkin_canary_result")
+	KeyDisagreement   = tag.MustNewKey("linkin_disagreement")
+	KeyWidthMismatch  = tag.MustNewKey("linkin_width_mismatch")
+)
+
+// Views of the measures recorded by this package.
+var (
+	DroppedHeadersView = &view.View{
+		Name:        "linkin/dropped_headers",
+		Description: "Count of linkerd context headers dropped to satisfy a size limit",
+		Measure:     DroppedHeaders,
+		Aggregation: view.Sum(),
+	}
+
+	SamplingDecisionsView = &view.View{
+		Name:        "linkin/sampling_decisions",
+		Description: "Count of sampling decisions made for extracted span contexts, by reason",
+		Measure:     SamplingDecisions,
+		TagKeys:     []tag.Key{KeySamplingReason},
+		Aggregation: view.Count(),
+	}
+
+	CanaryResponsesView = &view.View{
+		Name:        "linkin/canary_responses",
+		Description: "Count of responses to requests that carried a canary propagation format, by whether the canary was honored",
+		Measure:     CanaryResponses,
+		TagKeys:     []tag.Key{KeyCanaryResult},
+		Aggregation: view.Count(),
+	}
+
+	ServerLatencyView = &view.View{
+		Name:        "linkin/server/latency",
+		Description: "Latency distribution of HTTP requests, by method and status, with trace exemplars",
+		Measure:     ServerLatency,
+		TagKeys:     []tag.Key{ochttp.Method, ochttp.StatusCode},
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	}
+
+	DisagreementsView = &view.View{
+		Name:        "linkin/disagreements",
+		Description: "Count of requests whose linkerd and B3 headers disagree, by the field in disagreement",
+		Measure:     Disagreements,
+		TagKeys:     []tag.Key{KeyDisagreement},
+		Aggregation: view.Count(),
+	}
+
+	WidthMismatchesView = &view.View{
+		Name:        "linkin/width_mismatches",
+		Description: "Count of requests whose trace ID width differs from that emitted locally, by upstream and local width",
+		Measure:     WidthMismatches,
+		TagKeys:     []tag.Key{KeyWidthMismatch},
+		Aggregation: view.Count(),
+	}
+
+	DroppedSpansView = &view.View{
+		Name:        "linkin/zipkin/dropped_spans",
+		Description: "Count of spans dropped because a Zipkin reporter's buffer was full",
+		Measure:     DroppedSpans,
+		Aggregation: view.Sum(),
+	}
+
+	ReportRetriesView = &view.View{
+		Name:        "linkin/zipkin/report_retries",
+		Description: "Count of retried attempts to report spans to a Zipkin collector",
+		Measure:     ReportRetries,
+		Aggregation: view.Sum(),
+	}
+
+	InvalidInjectionsView = &view.View{
+		Name:        "linkin/invalid_injections",
+		Description: "Count of invalid span contexts a strict HTTPFormat refused to inject",
+		Measure:     InvalidInjections,
+		Aggregation: view.Count(),
+	}
+)
+
+// DefaultViews are the default views provided by this package.
+var DefaultViews = []*view.View{
+	DroppedHeadersView,
+	SamplingDecisionsView,
+	CanaryResponsesView,
+	ServerLatencyView,
+	DisagreementsView,
+	WidthMismatchesView,
+	DroppedSpansView,
+	ReportRetriesView,
+	InvalidInjectionsView,
+}
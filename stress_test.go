@@ -0,0 +1,166 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// These tests are most useful when run with the race detector, i.e. go test
+// -race, which flags any unsynchronized access to shared configuration.
+
+const (
+	stressGoroutines = 16
+	stressIterations = 500
+)
+
+// stress calls fn concurrently from several goroutines, each passing the index
+// of its goroutine and iteration.
+func stress(t *testing.T, fn func(g, i int)) {
+	iterations := stressIterations
+	if testing.Short() {
+		iterations = stressIterations / 10
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < stressGoroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				fn(g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// stressSpanContext returns a distinct, valid span context for the supplied
+// goroutine and iteration. The high 64 bits of its trace ID are zero, so that
+// it survives 32 byte l5d-ctx-trace headers.
+func stressSpanContext(g, i int) trace.SpanContext {
+	sc := trace.SpanContext{TraceOptions: ocShouldSample}
+	binary.BigEndian.PutUint64(sc.TraceID[8:], uint64(g)<<32|uint64(i)+1)
+	binary.BigEndian.PutUint64(sc.SpanID[:], uint64(i)<<32|uint64(g)+1)
+	return sc
+}
+
+type transportFunc func(r *http.Request) (*http.Response, error)
+
+func (fn transportFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+func TestConcurrentFormats(t *testing.T) {
+	multi, err := Config{Formats: []string{FormatLinkerd, FormatB3}, Inject: []string{FormatLinkerd, FormatB3}}.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	cases := []struct {
+		name string
+		f    propagation.HTTPFormat
+	}{
+		{
+			name: "Default",
+			f:    &HTTPFormat{},
+		},
+		{
+			name: "AllOptions",
+			f: &HTTPFormat{
+				CookieName: "l5d",
+				Linkerd:    NewDetector(Capabilities{}),
+				Encoding:   base64.RawURLEncoding,
+				Strict:     true,
+			},
+		},
+		{
+			name: "Multi",
+			f:    multi.Propagation,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			stress(t, func(g, i int) {
+				want := stressSpanContext(g, i)
+				r := httptest.NewRequest("GET", "http://example.org", nil)
+				tc.f.SpanContextToRequest(want, r)
+				got, ok := tc.f.SpanContextFromRequest(r)
+				if !ok {
+					t.Errorf("f.SpanContextFromRequest(): want ok, got %v", r.Header)
+					return
+				}
+				if got.TraceID != want.TraceID || got.SpanID != want.SpanID || got.IsSampled() != want.IsSampled() {
+					t.Errorf("f.SpanContextFromRequest(): want %+v, got %+v", want, got)
+				}
+			})
+		})
+	}
+}
+
+func TestConcurrentStack(t *testing.T) {
+	s, err := Config{
+		Formats:         []string{FormatLinkerd, FormatB3},
+		Inject:          []string{FormatLinkerd, FormatB3},
+		HeaderPrefix:    "acme-ctx-",
+		MaxContextBytes: 1024,
+		LinkerdVersion:  "1.2.1",
+		Encoding:        "raw",
+		Strict:          true,
+		Suppress:        []SuppressRule{{Host: ".example.com"}},
+		TraceUI:         &TraceUI{URL: "http://zipkin:9411"},
+	}.Build()
+	if err != nil {
+		t.Fatalf("c.Build(): %v", err)
+	}
+
+	client := &http.Client{Transport: s.Transport(transportFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	}))}
+	h := Memoize(s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, host := range []string{"http://example.net", "http://api.example.com"} {
+			out, _ := http.NewRequest("GET", host, nil)
+			rsp, err := client.Do(out.WithContext(r.Context()))
+			if err != nil {
+				t.Errorf("client.Do(): %v", err)
+				return
+			}
+			rsp.Body.Close()
+		}
+		w.Header().Set("X-Trace-ID", trace.FromContext(r.Context()).SpanContext().TraceID.String())
+	})))
+
+	stress(t, func(g, i int) {
+		parent := stressSpanContext(g, i)
+		r := httptest.NewRequest("GET", "http://example.org", nil)
+		r.Header.Set("acme-ctx-trace", encode(parent))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r.WithContext(context.Background()))
+		if got := w.Header().Get("X-Trace-ID"); got != parent.TraceID.String() {
+			t.Errorf("s.Handler(): want trace ID %s, got %s", parent.TraceID, got)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Trace headers emitted by the propagation formats supported by this package,
+// other than the linkerd headers.
+var traceHeaders = map[string]bool{
+	"b3":          true,
+	"traceparent": true,
+	"tracestate":  true,
+}
+
+// isTraceHeader returns true if the supplied header may carry trace or mesh
+// context, i.e. it is a linkerd (l5d-*), B3 (X-B3-* or b3), or W3C trace
+// context header.
+func isTraceHeader(k string) bool {
+	k = strings.ToLower(k)
+	return strings.HasPrefix(k, "l5d-") || strings.HasPrefix(k, "x-b3-") || traceHeaders[k]
+}
+
+// A SuppressRule matches requests that must not carry trace headers. A rule
+// matches a request only if all of its non-empty fields match.
+type SuppressRule struct {
+	// Host matches the request's URL host, excluding any port. Hosts that
+	// begin with "." or "*." match all subdomains, e.g. .example.com and
+	// *.example.com match api.example.com.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+
+	// Path matches the request's URL path. Paths ending in "/" match all paths
+	// beneath them. Other paths match only themselves.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// Method matches the request's method.
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+}
+
+func (s SuppressRule) matches(r *http.Request) bool {
+	if s.Method != "" && !strings.EqualFold(s.Method, r.Method) {
+		return false
+	}
+	if s.Path != "" && !(PathRule{Path: s.Path}).matches(r.URL.Path) {
+		return false
+	}
+	if s.Host == "" {
+		return true
+	}
+	host := strings.ToLower(r.URL.Hostname())
+	rule := strings.TrimPrefix(strings.ToLower(s.Host), "*")
+	if strings.HasPrefix(rule, ".") {
+		return strings.HasSuffix(host, rule)
+	}
+	return host == rule
+}
+
+// SuppressTransport is an http.RoundTripper that removes trace and baggage
+// headers from requests matching any of its rules, e.g. calls to third party
+// APIs, so that internal trace identifiers and baggage do not leak outside the
+// organization. Alternatively it may remove them from all requests except
+// those matching an allow list, e.g. requests to *.svc.cluster.local.
+// Suppressed requests are still traced; only propagation is suppressed.
+//
+// SuppressTransport should be the Base of an ochttp.Transport, so that it sees
+// headers injected by all other middleware. It must wrap any PrefixTransport.
+type SuppressTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Rules match requests from which trace headers are removed.
+	Rules []SuppressRule
+
+	// Allow, if non-empty, matches the only requests that may carry trace
+	// headers. Trace headers are removed from requests that match no Allow
+	// rule, as well as those that match any of Rules.
+	Allow []SuppressRule
+}
+
+// RoundTrip removes trace headers from the supplied request if it matches any
+// rule, or if it matches no allow rule, then sends it.
+func (t *SuppressTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.suppressed(r) {
+		r = suppress(r)
+	}
+	return t.base().RoundTrip(r)
+}
+
+func (t *SuppressTransport) suppressed(r *http.Request) bool {
+	for _, s := range t.Rules {
+		if s.matches(r) {
+			return true
+		}
+	}
+	if len(t.Allow) == 0 {
+		return false
+	}
+	for _, s := range t.Allow {
+		if s.matches(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func suppress(r *http.Request) *http.Request {
+	// RoundTrippers must not modify the request they're given.
+	out := withHeaderCopy(r)
+	for k := range out.Header {
+		if isTraceHeader(k) || isBaggageHeader(k) {
+			delete(out.Header, k)
+		}
+	}
+	return out
+}
+
+func (t *SuppressTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *SuppressTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
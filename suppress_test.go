@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSuppressTransport(t *testing.T) {
+	rules := []SuppressRule{
+		{Host: ".stripe.com"},
+		{Host: "api.example.org", Path: "/v1/webhooks/", Method: "POST"},
+	}
+	headers := map[string]string{
+		l5dHeaderTrace:  "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==",
+		l5dHeaderSample: "0.5",
+		"X-B3-TraceId":  "463ac35c9f6413ad",
+		"traceparent":   "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"Authorization": "Bearer secret",
+	}
+
+	cases := []struct {
+		name       string
+		method     string
+		url        string
+		suppressed bool
+	}{
+		{name: "Subdomain", method: "GET", url: "https://api.stripe.com/v1/charges", suppressed: true},
+		{name: "SubdomainWithPort", method: "GET", url: "https://api.stripe.com:443/v1/charges", suppressed: true},
+		{name: "AllFieldsMatch", method: "POST", url: "https://api.example.org/v1/webhooks/github", suppressed: true},
+		{name: "MethodMismatch", method: "GET", url: "https://api.example.org/v1/webhooks/github"},
+		{name: "PathMismatch", method: "POST", url: "https://api.example.org/v1/users"},
+		{name: "Internal", method: "GET", url: "http://web.default.svc/"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &recordingTransport{}
+			st := &SuppressTransport{Base: rt, Rules: rules}
+
+			r, _ := http.NewRequest(tc.method, tc.url, nil)
+			for k, v := range headers {
+				r.Header.Set(k, v)
+			}
+			if _, err := st.RoundTrip(r); err != nil {
+				t.Fatalf("st.RoundTrip(): %v", err)
+			}
+
+			for k := range headers {
+				if len(r.Header[http.CanonicalHeaderKey(k)]) == 0 {
+					t.Errorf("st.RoundTrip(): header %s was removed from the original request", k)
+				}
+				_, sent := rt.r.Header[http.CanonicalHeaderKey(k)]
+				want := !tc.suppressed || k == "Authorization"
+				if sent != want {
+					t.Errorf("st.RoundTrip(): want header %s sent %t, got %t", k, want, sent)
+				}
+			}
+		})
+	}
+}
+
+func TestSuppressTransportAllow(t *testing.T) {
+	allow := []SuppressRule{{Host: "*.svc.cluster.local"}, {Host: "localhost"}}
+	rules := []SuppressRule{{Host: "vault.default.svc.cluster.local"}}
+
+	cases := []struct {
+		name       string
+		url        string
+		suppressed bool
+	}{
+		{name: "Allowed", url: "http://web.default.svc.cluster.local/"},
+		{name: "AllowedWithPort", url: "http://localhost:4140/"},
+		{name: "External", url: "https://api.stripe.com/v1/charges", suppressed: true},
+		{name: "AllowedButSuppressed", url: "http://vault.default.svc.cluster.local/", suppressed: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rt := &recordingTransport{}
+			st := &SuppressTransport{Base: rt, Rules: rules, Allow: allow}
+
+			r, _ := http.NewRequest("GET", tc.url, nil)
+			r.Header.Set(l5dHeaderTrace, "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA==")
+			r.Header.Set("baggage", "userId=alice")
+			if _, err := st.RoundTrip(r); err != nil {
+				t.Fatalf("st.RoundTrip(): %v", err)
+			}
+
+			for _, k := range []string{l5dHeaderTrace, "Baggage"} {
+				_, sent := rt.r.Header[http.CanonicalHeaderKey(k)]
+				if sent == tc.suppressed {
+					t.Errorf("st.RoundTrip(): want header %s sent %t, got %t", k, !tc.suppressed, sent)
+				}
+			}
+		})
+	}
+}
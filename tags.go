@@ -0,0 +1,153 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opencensus.io/tag"
+)
+
+// l5dHeaderTags propagates OpenCensus tags. linkerd forwards all l5d-ctx-*
+// headers, so tags survive meshed hops just like the trace context.
+const l5dHeaderTags = l5dHeaderPrefix + "tags"
+
+// encodeTags encodes the values of the supplied keys in the supplied tag map as
+// a comma separated list of URL query escaped key=value pairs. Keys without a
+// value are omitted.
+func encodeTags(m *tag.Map, keys []tag.Key) string {
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v, ok := m.Value(k)
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, url.QueryEscape(k.Name())+"="+url.QueryEscape(v))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// decodeTags decodes the supplied header into tag mutators. Only the supplied
+// keys are decoded; any others are ignored.
+func decodeTags(h string, keys []tag.Key) []tag.Mutator {
+	allowed := make(map[string]tag.Key, len(keys))
+	for _, k := range keys {
+		allowed[k.Name()] = k
+	}
+	m := []tag.Mutator{}
+	for _, pair := range strings.Split(h, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			continue
+		}
+		k, ok := allowed[name]
+		if !ok {
+			continue
+		}
+		v, err := url.QueryUnescape(kv[1])
+		if err != nil {
+			continue
+		}
+		m = append(m, tag.Upsert(k, v))
+	}
+	return m
+}
+
+// TagHandler is an http.Handler that restores OpenCensus tags propagated by
+// TagTransport in the l5d-ctx-tags header to the request's context, allowing
+// metrics dimensions such as the originating product to survive across meshed
+// hops.
+type TagHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Keys are the tag keys to restore. Propagated tags with other keys are
+	// ignored.
+	Keys []tag.Key
+}
+
+// ServeHTTP restores propagated tags to the request's context, then serves the
+// request. Propagated tags are ignored if any of them are invalid.
+func (h *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if hdr := headerValue(r.Header, l5dHeaderTags); hdr != "" {
+		if ctx, err := tag.New(r.Context(), decodeTags(hdr, h.Keys)...); err == nil {
+			r = r.WithContext(ctx)
+		}
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// TagTransport is an http.RoundTripper that propagates selected OpenCensus
+// tags from each request's context in the l5d-ctx-tags header.
+type TagTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// Keys are the tag keys to propagate.
+	Keys []tag.Key
+
+	// Preserve causes any l5d-ctx-tags header already present on an outgoing
+	// request to be left untouched, as HTTPFormat's Preserve does for the
+	// l5d-ctx-trace header.
+	Preserve bool
+}
+
+// RoundTrip adds the l5d-ctx-tags header to the supplied request, then sends
+// it. Requests without any tags to propagate are sent unmodified.
+func (t *TagTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.Preserve && headerValue(r.Header, l5dHeaderTags) != "" {
+		return t.base().RoundTrip(r)
+	}
+	if hdr := tagsFromContext(r.Context(), t.Keys); hdr != "" {
+		// RoundTrippers must not modify the request they're given.
+		r = withHeaderCopy(r)
+		r.Header.Set(l5dHeaderTags, hdr)
+	}
+	return t.base().RoundTrip(r)
+}
+
+func tagsFromContext(ctx context.Context, keys []tag.Key) string {
+	m := tag.FromContext(ctx)
+	if m == nil {
+		return ""
+	}
+	return encodeTags(m, keys)
+}
+
+func (t *TagTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+// CancelRequest cancels an in-flight request by closing its connection.
+func (t *TagTransport) CancelRequest(r *http.Request) {
+	if cr, ok := t.base().(interface {
+		CancelRequest(*http.Request)
+	}); ok {
+		cr.CancelRequest(r)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/tag"
+)
+
+var (
+	keyProduct = tag.MustNewKey("product")
+	keyTeam    = tag.MustNewKey("team")
+	keySecret  = tag.MustNewKey("secret")
+)
+
+func TestTagTransport(t *testing.T) {
+	cases := []struct {
+		name     string
+		tags     []tag.Mutator
+		existing string
+		preserve bool
+		want     string
+	}{
+		{
+			name: "NoTags",
+		},
+		{
+			name: "SelectedTags",
+			tags: []tag.Mutator{tag.Upsert(keyProduct, "explorer"), tag.Upsert(keySecret, "hunter2")},
+			want: "product=explorer",
+		},
+		{
+			name: "EscapedTags",
+			tags: []tag.Mutator{tag.Upsert(keyProduct, "a=b,c"), tag.Upsert(keyTeam, "data platform")},
+			want: "product=a%3Db%2Cc,team=data+platform",
+		},
+		{
+			name:     "Preserved",
+			tags:     []tag.Mutator{tag.Upsert(keyProduct, "explorer")},
+			existing: "product=mobile",
+			preserve: true,
+			want:     "product=mobile",
+		},
+		{
+			name:     "Overwritten",
+			tags:     []tag.Mutator{tag.Upsert(keyProduct, "explorer")},
+			existing: "product=mobile",
+			want:     "product=explorer",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, err := tag.New(context.Background(), tc.tags...)
+			if err != nil {
+				t.Fatalf("tag.New(): %v", err)
+			}
+			rt := &recordingTransport{}
+			tt := &TagTransport{Base: rt, Keys: []tag.Key{keyProduct, keyTeam}, Preserve: tc.preserve}
+
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			if tc.existing != "" {
+				r.Header.Set(l5dHeaderTags, tc.existing)
+			}
+			if _, err := tt.RoundTrip(r.WithContext(ctx)); err != nil {
+				t.Fatalf("tt.RoundTrip(): %v", err)
+			}
+			if got := rt.r.Header.Get(l5dHeaderTags); got != tc.want {
+				t.Errorf("tt.RoundTrip(): want %s header %q, got %q", l5dHeaderTags, tc.want, got)
+			}
+			if got := r.Header.Get(l5dHeaderTags); got != tc.existing {
+				t.Errorf("tt.RoundTrip(): modified the original request headers: %v", r.Header)
+			}
+		})
+	}
+}
+
+func TestTagHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   map[tag.Key]string
+	}{
+		{
+			name: "NoHeader",
+			want: map[tag.Key]string{},
+		},
+		{
+			name:   "SelectedTags",
+			header: "product=explorer,secret=hunter2",
+			want:   map[tag.Key]string{keyProduct: "explorer"},
+		},
+		{
+			name:   "EscapedTags",
+			header: "product=a%3Db%2Cc, team=data+platform",
+			want:   map[tag.Key]string{keyProduct: "a=b,c", keyTeam: "data platform"},
+		},
+		{
+			name:   "MalformedTags",
+			header: "product,team=%zz,=",
+			want:   map[tag.Key]string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *tag.Map
+			h := &TagHandler{
+				Keys: []tag.Key{keyProduct, keyTeam},
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					got = tag.FromContext(r.Context())
+				}),
+			}
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			if tc.header != "" {
+				r.Header.Set(l5dHeaderTags, tc.header)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			for _, k := range []tag.Key{keyProduct, keyTeam, keySecret} {
+				v, ok := got.Value(k)
+				want, wantOK := tc.want[k]
+				if ok != wantOK || v != want {
+					t.Errorf("h.ServeHTTP(): want tag %s=%q, got %q", k.Name(), want, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+
+	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
+	"go.opencensus.io/trace"
+)
+
+// TraceContextFormat implements propagation.HTTPFormat to propagate traces in
+// W3C trace context headers. It is identical to tracecontext.HTTPFormat, except
+// that it never injects Finagle's debug flag (see IsDebug). The debug flag is
+// carried in a TraceOptions bit that W3C reserves, and that strict parsers
+// reject, so tracecontext.HTTPFormat must not be used to inject span contexts
+// extracted by this package.
+type TraceContextFormat struct {
+	tracecontext.HTTPFormat
+}
+
+// SpanContextToRequest modifies the given request to include W3C trace context
+// headers derived from the given SpanContext, omitting its debug flag.
+func (f *TraceContextFormat) SpanContextToRequest(sc trace.SpanContext, r *http.Request) {
+	sc.TraceOptions &= ocShouldSample
+	f.HTTPFormat.SpanContextToRequest(sc, r)
+}
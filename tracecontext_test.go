@@ -0,0 +1,55 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"testing"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+func TestTraceContextOmitsDebug(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{1},
+		SpanID:       trace.SpanID{2},
+		TraceOptions: ocShouldSample | ocDebug,
+	}
+
+	cases := []struct {
+		name string
+		f    propagation.HTTPFormat
+	}{
+		{name: "TraceContext", f: &TraceContextFormat{}},
+		{name: "Linkerd2", f: &Linkerd2Format{}},
+		{name: "Consistent", f: &ConsistentFormat{Fraction: 1}},
+		{name: "Multi", f: &MultiFormat{Inject: []propagation.HTTPFormat{&HTTPFormat{}, &TraceContextFormat{}}}},
+		{name: "Registered", f: func() propagation.HTTPFormat { f, _ := Get(FormatTraceContext); return f }()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("GET", "http://example.org", nil)
+			tc.f.SpanContextToRequest(sc, r)
+			want := "00-01000000000000000000000000000000-0200000000000000-01"
+			if got := r.Header.Get("traceparent"); got != want {
+				t.Errorf("f.SpanContextToRequest(): want traceparent %q, got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/trace"
+)
+
+// A TraceID is a decoded l5d-ctx-trace header, i.e. a serialized Finagle
+// TraceId. Unlike a trace.SpanContext it includes the parent span ID and all
+// of Finagle's flags. See the wire package, which implements the codec.
+type TraceID wire.TraceID
+
+// ParseTraceID decodes a base64 encoded l5d-ctx-trace header value.
+func ParseTraceID(h string) (TraceID, error) {
+	id, err := wire.Parse(h)
+	return TraceID(id), err
+}
+
+// TraceIDFromRequest decodes the supplied request's l5d-ctx-trace header.
+func TraceIDFromRequest(r *http.Request) (TraceID, bool) {
+	return parse(r.Context(), headerValue(r.Header, l5dHeaderTrace))
+}
+
+// traceIDFromSpanContext returns a TraceID representing the supplied span
+// context. Its parent span ID is zero.
+func traceIDFromSpanContext(sc trace.SpanContext) TraceID {
+	id := TraceID{Span: sc.SpanID, Trace: sc.TraceID}
+	if sc.IsSampled() {
+		id.Flags = wire.FlagSamplingKnown | wire.FlagSampled
+	}
+	if IsDebug(sc) {
+		id.Flags |= wire.FlagDebug
+	}
+	return id
+}
+
+// String returns the TraceID base64 encoded, as per the l5d-ctx-trace header.
+// The 40 byte serialization format (i.e. a 128 bit trace ID) is always used.
+func (id TraceID) String() string {
+	return wire.TraceID(id).String()
+}
+
+// String32 returns the TraceID base64 encoded in the 32 byte serialization
+// format, as understood by linkerds that predate 128 bit trace IDs. The high 64
+// bits of the trace ID are omitted.
+func (id TraceID) String32() string {
+	return wire.TraceID(id).String32()
+}
+
+// SpanContext returns the trace.SpanContext represented by the TraceID.
+func (id TraceID) SpanContext() trace.SpanContext {
+	sc := trace.SpanContext{TraceID: id.Trace, SpanID: id.Span}
+	if wire.TraceID(id).Sampled() {
+		sc.TraceOptions = ocShouldSample
+	}
+	if wire.TraceID(id).Debug() {
+		sc.TraceOptions |= ocDebug
+	}
+	return sc
+}
+
+// IsRoot returns true if the TraceID represents the root span of a trace.
+// Finagle root spans have the same span ID as the (low 64 bits of) their trace
+// ID, and either no parent span ID or a parent span ID equal to their span ID.
+// A request whose l5d-ctx-trace header represents a root span was sent by the
+// service at the edge of the trace.
+func (id TraceID) IsRoot() bool {
+	return wire.TraceID(id).IsRoot()
+}
+
+// AnnotateRoot wraps the supplied handler, adding an l5d.root attribute to the
+// span in each request's context that indicates whether the request's
+// l5d-ctx-trace header represented a root span. Requests without a valid
+// l5d-ctx-trace header are not annotated. This attribute helps surface broken
+// parentage; i.e. traces that unexpectedly begin deep within the mesh.
+// AnnotateRoot must be wrapped by an ochttp.Handler.
+func AnnotateRoot(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := TraceIDFromRequest(r); ok {
+			trace.FromContext(r.Context()).AddAttributes(trace.BoolAttribute(RootAttribute, id.IsRoot()))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
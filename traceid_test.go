@@ -0,0 +1,162 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *recordingExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func (e *recordingExporter) Spans() []*trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.spans
+}
+
+func TestParseTraceID(t *testing.T) {
+	cases := []struct {
+		name    string
+		h       string
+		want    TraceID
+		wantErr bool
+	}{
+		{
+			name: "64BitTraceID",
+			h:    "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+			want: TraceID{
+				Span:   [8]byte{244, 20, 29, 93, 192, 201, 53, 208},
+				Parent: [8]byte{253, 59, 66, 4, 201, 246, 66, 111},
+				Trace:  [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				Flags:  6,
+			},
+		},
+		{
+			name: "128BitTraceID",
+			h:    "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ==",
+			want: TraceID{
+				Span:  [8]byte{244, 20, 29, 93, 192, 201, 53, 208},
+				Trace: [16]byte{0, 0, 0, 0, 0, 0, 0, 1, 50, 164, 219, 32, 245, 213, 146, 231},
+				Flags: 7,
+			},
+		},
+		{
+			name:    "InvalidEncoding",
+			h:       "PROBABLYNOTBASE64",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidLength",
+			h:       "bmVlZWVyZA==",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseTraceID(tc.h)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseTraceID(%q): want error %v, got %v", tc.h, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseTraceID(%q):\ngot:  %+v\nwant: %+v", tc.h, got, tc.want)
+			}
+			if tc.wantErr {
+				return
+			}
+			if rt, _ := ParseTraceID(got.String()); rt != got {
+				t.Errorf("ParseTraceID(id.String()):\ngot:  %+v\nwant: %+v", rt, got)
+			}
+		})
+	}
+}
+
+func TestIsRoot(t *testing.T) {
+	span := [8]byte{50, 164, 219, 32, 245, 213, 146, 231}
+	tid := [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231}
+
+	cases := []struct {
+		name string
+		id   TraceID
+		want bool
+	}{
+		{
+			name: "RootWithoutParent",
+			id:   TraceID{Span: span, Trace: tid},
+			want: true,
+		},
+		{
+			name: "RootWithSelfParent",
+			id:   TraceID{Span: span, Parent: span, Trace: tid},
+			want: true,
+		},
+		{
+			name: "Child",
+			id:   TraceID{Span: [8]byte{1}, Parent: span, Trace: tid},
+			want: false,
+		},
+		{
+			name: "OrphanWithTraceSpanID",
+			id:   TraceID{Span: span, Parent: [8]byte{1}, Trace: tid},
+			want: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.id.IsRoot(); got != tc.want {
+				t.Errorf("id.IsRoot(): want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAnnotateRoot(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	root := TraceID{Span: [8]byte{1}, Trace: [16]byte{8: 1}}
+	r := httptest.NewRequest("GET", "http://example.org", nil).WithContext(ctx)
+	r.Header.Set(l5dHeaderTrace, root.String())
+	AnnotateRoot(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+	span.End()
+
+	spans := e.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("want 1 exported span, got %d", len(spans))
+	}
+	if got := spans[0].Attributes[RootAttribute]; got != true {
+		t.Errorf("AnnotateRoot(): want %s attribute true, got %v", RootAttribute, got)
+	}
+}
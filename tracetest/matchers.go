@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package tracetest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/propagation"
+)
+
+// A Matcher matches values that carry span context, for asserting propagation
+// in unit tests. Matchers satisfy gomock's Matcher interface, and may be used
+// with testify via mock.MatchedBy(m.Matches). For example:
+//
+//  client.EXPECT().Do(tracetest.HasL5dContext())
+//  client.On("Do", mock.MatchedBy(tracetest.DescendsFrom(sc).Matches))
+//
+// Matchers match *http.Request, context.Context, *trace.Span, *trace.SpanData,
+// and trace.SpanContext values. The span context of a request is extracted from
+// its l5d headers if it has any, or from its context otherwise.
+type Matcher struct {
+	desc  string
+	match func(x interface{}) bool
+}
+
+// Matches returns true if the supplied value matches.
+func (m Matcher) Matches(x interface{}) bool {
+	return m.match(x)
+}
+
+// String describes what the Matcher matches.
+func (m Matcher) String() string {
+	return m.desc
+}
+
+// HasSpanContext returns a Matcher that matches requests from which the
+// supplied propagation format extracts a span context.
+func HasSpanContext(name string, f propagation.HTTPFormat) Matcher {
+	return Matcher{
+		desc: fmt.Sprintf("is a request with valid %s span context", name),
+		match: func(x interface{}) bool {
+			r, ok := x.(*http.Request)
+			if !ok {
+				return false
+			}
+			_, ok = f.SpanContextFromRequest(r)
+			return ok
+		},
+	}
+}
+
+// HasL5dContext returns a Matcher that matches requests with a valid
+// l5d-ctx-trace header.
+func HasL5dContext() Matcher {
+	return HasSpanContext(linkin.FormatLinkerd, &linkin.HTTPFormat{})
+}
+
+// DescendsFrom returns a Matcher that matches values whose span context is a
+// descendant of the supplied span context, i.e. a distinct span in the same
+// trace. OpenCensus spans do not expose their parent, so values that descend
+// from the supplied span context cannot be distinguished from its siblings.
+func DescendsFrom(parent trace.SpanContext) Matcher {
+	return Matcher{
+		desc: fmt.Sprintf("descends from span %s of trace %s", parent.SpanID, parent.TraceID),
+		match: func(x interface{}) bool {
+			sc, ok := SpanContextOf(x)
+			return ok && sc.TraceID == parent.TraceID && sc.SpanID != parent.SpanID
+		},
+	}
+}
+
+// Sampled returns a Matcher that matches values whose span context is sampled.
+func Sampled() Matcher {
+	return Matcher{
+		desc: "has a sampled span context",
+		match: func(x interface{}) bool {
+			sc, ok := SpanContextOf(x)
+			return ok && sc.IsSampled()
+		},
+	}
+}
+
+// SpanContextOf returns the span context carried by the supplied value, which
+// may be any value a Matcher matches.
+func SpanContextOf(x interface{}) (trace.SpanContext, bool) {
+	switch v := x.(type) {
+	case trace.SpanContext:
+		return v, true
+	case *trace.SpanData:
+		if v == nil {
+			return trace.SpanContext{}, false
+		}
+		return v.SpanContext, true
+	case *trace.Span:
+		if v == nil {
+			return trace.SpanContext{}, false
+		}
+		return v.SpanContext(), true
+	case *http.Request:
+		if v == nil {
+			return trace.SpanContext{}, false
+		}
+		if sc, ok := (&linkin.HTTPFormat{}).SpanContextFromRequest(v); ok {
+			return sc, true
+		}
+		return SpanContextOf(v.Context())
+	case context.Context:
+		if span := trace.FromContext(v); span != nil {
+			return span.SpanContext(), true
+		}
+	}
+	return trace.SpanContext{}, false
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package tracetest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+)
+
+// gomockMatcher is gomock's Matcher interface.
+type gomockMatcher interface {
+	Matches(x interface{}) bool
+	String() string
+}
+
+func TestMatcherSatisfiesGomockMatcher(t *testing.T) {
+	var _ gomockMatcher = Matcher{}
+}
+
+func TestMatchers(t *testing.T) {
+	ctx, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	child, childSpan := trace.StartSpan(ctx, "child")
+	defer childSpan.End()
+	_, other := trace.StartSpan(context.Background(), "other", trace.WithSampler(trace.NeverSample()))
+	defer other.End()
+
+	propagated, _ := http.NewRequest("GET", "http://example.org", nil)
+	(&linkin.HTTPFormat{}).SpanContextToRequest(childSpan.SpanContext(), propagated)
+	local, _ := http.NewRequest("GET", "http://example.org", nil)
+	local = local.WithContext(child)
+	bare, _ := http.NewRequest("GET", "http://example.org", nil)
+
+	cases := []struct {
+		name string
+		m    Matcher
+		x    interface{}
+		want bool
+	}{
+		{name: "HasL5dContext", m: HasL5dContext(), x: propagated, want: true},
+		{name: "HasNoL5dContext", m: HasL5dContext(), x: local},
+		{name: "HasL5dContextNotARequest", m: HasL5dContext(), x: "GET /"},
+		{name: "DescendsFromPropagated", m: DescendsFrom(span.SpanContext()), x: propagated, want: true},
+		{name: "DescendsFromContext", m: DescendsFrom(span.SpanContext()), x: child, want: true},
+		{name: "DescendsFromRequestContext", m: DescendsFrom(span.SpanContext()), x: local, want: true},
+		{name: "DescendsFromSpan", m: DescendsFrom(span.SpanContext()), x: childSpan, want: true},
+		{name: "DoesNotDescendFromItself", m: DescendsFrom(span.SpanContext()), x: span},
+		{name: "DoesNotDescendFromOtherTrace", m: DescendsFrom(span.SpanContext()), x: other},
+		{name: "DoesNotDescendWithoutSpan", m: DescendsFrom(span.SpanContext()), x: bare},
+		{name: "Sampled", m: Sampled(), x: child, want: true},
+		{name: "NotSampled", m: Sampled(), x: other.SpanContext()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.m.Matches(tc.x); got != tc.want {
+				t.Errorf("%s: want Matches() %t, got %t", tc.m, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package tracetest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/trace"
+)
+
+// A Request is a request received by a Server.
+type Request struct {
+	// Method and URI are the request's method and URI.
+	Method string
+	URI    string
+
+	// Header is the request's header.
+	Header http.Header
+
+	// TraceID is the decoded l5d-ctx-trace header of the request, if Valid.
+	TraceID linkin.TraceID
+
+	// Valid is true if the request carried a valid l5d-ctx-trace header.
+	Valid bool
+}
+
+// Server is an httptest.Server that records the l5d headers of the requests it
+// receives, simplifying black-box tests of client instrumentation. For example:
+//
+//  s := tracetest.NewServer(nil)
+//  defer s.Close()
+//
+//  ctx, span := trace.StartSpan(ctx, "parent")
+//  client.Do(req.WithContext(ctx)) // Send a request to s.URL.
+//  span.End()
+//
+//  s.AssertPropagated(t, span.SpanContext())
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewServer starts and returns a new Server that serves requests using the
+// supplied handler, or responds 200 OK if the handler is nil. The caller
+// should call Close when finished, to shut it down.
+func NewServer(h http.Handler) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.record(r)
+		if h != nil {
+			h.ServeHTTP(w, r)
+		}
+	}))
+	return s
+}
+
+func (s *Server) record(r *http.Request) {
+	id, ok := linkin.TraceIDFromRequest(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, URI: r.RequestURI, Header: r.Header.Clone(), TraceID: id, Valid: ok})
+}
+
+// Requests returns the requests received by the Server, in the order they were
+// received.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Request, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+// Reset forgets all received requests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// CheckPropagated returns an error describing the first request received by the
+// Server that was not correctly parented by the supplied span context, or if
+// no requests were received. A request is correctly parented if it carries a
+// valid l5d-ctx-trace header in the parent's trace, whose span is distinct from
+// the parent (i.e. a client span), and whose parent span ID, if any, is that of
+// the parent.
+func (s *Server) CheckPropagated(parent trace.SpanContext) error {
+	requests := s.Requests()
+	if len(requests) == 0 {
+		return fmt.Errorf("no requests received")
+	}
+	for _, r := range requests {
+		desc := r.Method + " " + r.URI
+		switch {
+		case !r.Valid:
+			return fmt.Errorf("%s: no valid l5d-ctx-trace header", desc)
+		case r.TraceID.Trace != parent.TraceID:
+			return fmt.Errorf("%s: want trace ID %s, got %s", desc, parent.TraceID, trace.TraceID(r.TraceID.Trace))
+		case r.TraceID.Span == parent.SpanID:
+			return fmt.Errorf("%s: want a child span of %s, got the parent span itself", desc, parent.SpanID)
+		case r.TraceID.Parent != [8]byte{} && r.TraceID.Parent != parent.SpanID:
+			return fmt.Errorf("%s: want parent span ID %s, got %s", desc, parent.SpanID, trace.SpanID(r.TraceID.Parent))
+		}
+	}
+	return nil
+}
+
+// AssertPropagated reports a test error unless every request received by the
+// Server was correctly parented by the supplied span context. See
+// CheckPropagated.
+func (s *Server) AssertPropagated(t testing.TB, parent trace.SpanContext) {
+	t.Helper()
+	if err := s.CheckPropagated(parent); err != nil {
+		t.Errorf("incorrect l5d propagation: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package tracetest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer s.Close()
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent")
+	defer parent.End()
+	_, other := trace.StartSpan(context.Background(), "other")
+	defer other.End()
+
+	cases := []struct {
+		name      string
+		transport http.RoundTripper
+		ctx       context.Context
+		check     trace.SpanContext
+		wantErr   bool
+	}{
+		{
+			name:      "Propagated",
+			transport: &ochttp.Transport{Propagation: &linkin.HTTPFormat{}},
+			ctx:       ctx,
+			check:     parent.SpanContext(),
+		},
+		{
+			name:      "PropagatedWithParent",
+			transport: &linkin.ParentTransport{Base: &ochttp.Transport{Propagation: &linkin.HTTPFormat{}}},
+			ctx:       ctx,
+			check:     parent.SpanContext(),
+		},
+		{
+			name:      "WrongTrace",
+			transport: &ochttp.Transport{Propagation: &linkin.HTTPFormat{}},
+			ctx:       ctx,
+			check:     other.SpanContext(),
+			wantErr:   true,
+		},
+		{
+			name:      "NotPropagated",
+			transport: http.DefaultTransport,
+			ctx:       ctx,
+			check:     parent.SpanContext(),
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s.Reset()
+			if err := s.CheckPropagated(tc.check); err == nil {
+				t.Errorf("s.CheckPropagated(): want error when no requests were received")
+			}
+
+			r, _ := http.NewRequest("GET", s.URL+"/users", nil)
+			rsp, err := (&http.Client{Transport: tc.transport}).Do(r.WithContext(tc.ctx))
+			if err != nil {
+				t.Fatalf("client.Do(): %v", err)
+			}
+			rsp.Body.Close()
+			if rsp.StatusCode != http.StatusTeapot {
+				t.Errorf("client.Do(): want status %d from the wrapped handler, got %d", http.StatusTeapot, rsp.StatusCode)
+			}
+
+			if got := s.Requests(); len(got) != 1 || got[0].URI != "/users" {
+				t.Errorf("s.Requests(): want 1 request for /users, got %+v", got)
+			}
+			err = s.CheckPropagated(tc.check)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("s.CheckPropagated(): want error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
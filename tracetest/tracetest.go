@@ -0,0 +1,150 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package tracetest provides an in-memory OpenCensus trace exporter with query
+// helpers, and matchers usable with gomock and testify, making assertions about
+// propagation and parentage straightforward in unit tests. For example:
+//
+//  e := &tracetest.Exporter{}
+//  trace.RegisterExporter(e)
+//  defer trace.UnregisterExporter(e)
+//
+//  // Exercise code that starts spans...
+//
+//  if !e.HasChild("/users", "GET /db") {
+//      t.Errorf("want span GET /db with parent /users, got %v", e.Names())
+//  }
+package tracetest
+
+import (
+	"sync"
+
+	"go.opencensus.io/trace"
+)
+
+// Exporter is a trace.Exporter that records exported spans in memory. Spans
+// are only exported if they are sampled, so tests typically start spans with
+// trace.AlwaysSample. Exporter must not be copied after first use.
+type Exporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+// ExportSpan records the supplied span.
+func (e *Exporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+// Reset forgets all recorded spans.
+func (e *Exporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// Spans returns all recorded spans, in the order they were exported, i.e. the
+// order in which they ended.
+func (e *Exporter) Spans() []*trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]*trace.SpanData, len(e.spans))
+	copy(out, e.spans)
+	return out
+}
+
+// Names returns the names of all recorded spans, in the order they were
+// exported. It is useful in test failure messages.
+func (e *Exporter) Names() []string {
+	spans := e.Spans()
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// Filter returns the recorded spans for which the supplied function returns
+// true.
+func (e *Exporter) Filter(fn func(s *trace.SpanData) bool) []*trace.SpanData {
+	var out []*trace.SpanData
+	for _, s := range e.Spans() {
+		if fn(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Named returns the recorded spans with the supplied name.
+func (e *Exporter) Named(name string) []*trace.SpanData {
+	return e.Filter(func(s *trace.SpanData) bool { return s.Name == name })
+}
+
+// Span returns the first recorded span with the supplied name.
+func (e *Exporter) Span(name string) (*trace.SpanData, bool) {
+	spans := e.Named(name)
+	if len(spans) == 0 {
+		return nil, false
+	}
+	return spans[0], true
+}
+
+// Trace returns the recorded spans of the supplied trace.
+func (e *Exporter) Trace(id trace.TraceID) []*trace.SpanData {
+	return e.Filter(func(s *trace.SpanData) bool { return s.TraceID == id })
+}
+
+// Parent returns the recorded parent of the supplied span, if any.
+func (e *Exporter) Parent(s *trace.SpanData) (*trace.SpanData, bool) {
+	for _, p := range e.Trace(s.TraceID) {
+		if p.SpanID == s.ParentSpanID {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Children returns the recorded children of the supplied span.
+func (e *Exporter) Children(s *trace.SpanData) []*trace.SpanData {
+	return e.Filter(func(c *trace.SpanData) bool {
+		return c.TraceID == s.TraceID && c.ParentSpanID == s.SpanID && c.SpanID != s.SpanID
+	})
+}
+
+// HasChild returns true if a span with the supplied child name was recorded
+// whose parent is a recorded span with the supplied parent name.
+func (e *Exporter) HasChild(parent, child string) bool {
+	for _, c := range e.Named(child) {
+		if p, ok := e.Parent(c); ok && p.Name == parent {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRemoteChild returns true if a span with the supplied child name was
+// recorded whose parent is a recorded span with the supplied parent name, and
+// whose span context was propagated from its parent, e.g. via HTTP headers.
+func (e *Exporter) HasRemoteChild(parent, child string) bool {
+	for _, c := range e.Named(child) {
+		if p, ok := e.Parent(c); ok && p.Name == parent && c.HasRemoteParent {
+			return true
+		}
+	}
+	return false
+}
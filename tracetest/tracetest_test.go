@@ -0,0 +1,105 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package tracetest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestExporterSatisfiesExporter(t *testing.T) {
+	var _ trace.Exporter = (*Exporter)(nil)
+}
+
+func TestQueries(t *testing.T) {
+	e := &Exporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	// A client span propagated via l5d headers to a server, which starts a
+	// local child span.
+	srv := httptest.NewServer(&ochttp.Handler{
+		Propagation: &linkin.HTTPFormat{},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := trace.StartSpan(r.Context(), "SELECT")
+			span.End()
+		}),
+	})
+	defer srv.Close()
+
+	ctx, root := trace.StartSpan(context.Background(), "root", trace.WithSampler(trace.AlwaysSample()))
+	client := &http.Client{Transport: &ochttp.Transport{Propagation: &linkin.HTTPFormat{}}}
+	r, _ := http.NewRequest("GET", srv.URL+"/users", nil)
+	rsp, err := client.Do(r.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("client.Do(): %v", err)
+	}
+	rsp.Body.Close()
+	root.End()
+
+	cases := []struct {
+		name  string
+		query func() bool
+		want  bool
+	}{
+		{name: "HasChild", query: func() bool { return e.HasChild("root", "/users") }, want: true},
+		{name: "HasLocalChild", query: func() bool { return e.HasChild("/users", "SELECT") }, want: true},
+		{name: "HasGrandchild", query: func() bool { return e.HasChild("root", "SELECT") }},
+		{name: "HasRemoteChild", query: func() bool { return e.HasRemoteChild("/users", "/users") }, want: true},
+		{name: "HasLocalRemoteChild", query: func() bool { return e.HasRemoteChild("/users", "SELECT") }},
+		{name: "Missing", query: func() bool { _, ok := e.Span("DELETE"); return ok }},
+		{name: "TraceSpans", query: func() bool { return len(e.Trace(root.SpanContext().TraceID)) == 4 }, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.query(); got != tc.want {
+				t.Errorf("want %t, got %t for spans %v", tc.want, got, e.Names())
+			}
+		})
+	}
+}
+
+func TestChildren(t *testing.T) {
+	e := &Exporter{}
+	parent := &trace.SpanData{Name: "parent", SpanContext: trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}}}
+	a := &trace.SpanData{Name: "a", SpanContext: trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}}, ParentSpanID: trace.SpanID{1}}
+	b := &trace.SpanData{Name: "b", SpanContext: trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{3}}, ParentSpanID: trace.SpanID{1}}
+	other := &trace.SpanData{Name: "other", SpanContext: trace.SpanContext{TraceID: trace.TraceID{2}, SpanID: trace.SpanID{4}}, ParentSpanID: trace.SpanID{1}}
+	for _, s := range []*trace.SpanData{a, b, other, parent} {
+		e.ExportSpan(s)
+	}
+
+	if got, want := e.Children(parent), []*trace.SpanData{a, b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("e.Children(): want %v, got %v", want, got)
+	}
+	if got, want := e.Names(), []string{"a", "b", "other", "parent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("e.Names(): want %v, got %v", want, got)
+	}
+
+	e.Reset()
+	if got := e.Spans(); len(got) != 0 {
+		t.Errorf("e.Reset(): want no spans, got %v", got)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opencensus.io/trace"
+)
+
+// Trace UIs for which a TraceUI may build links.
+const (
+	UIZipkin = "zipkin"
+	UIJaeger = "jaeger"
+)
+
+// A TraceUI builds links to traces in a Zipkin or Jaeger UI, so that logs and
+// error reports may link directly to the trace of the request that produced
+// them.
+type TraceUI struct {
+	// URL is the base URL of the trace UI, e.g. http://zipkin:9411/zipkin for
+	// Zipkin or http://jaeger:16686 for Jaeger.
+	URL string `json:"url" yaml:"url"`
+
+	// Kind is the kind of trace UI, i.e. UIZipkin or UIJaeger. UIZipkin is
+	// assumed if Kind is empty.
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+}
+
+// TraceURL returns a link to the trace of the supplied span context. Links to
+// a Jaeger UI highlight the span within its trace.
+func (u *TraceUI) TraceURL(sc trace.SpanContext) string {
+	base := strings.TrimRight(u.URL, "/")
+	id := traceIDHex(sc.TraceID)
+	if u.Kind == UIJaeger {
+		return base + "/trace/" + id + "?" + url.Values{"uiFind": {hex.EncodeToString(sc.SpanID[:])}}.Encode()
+	}
+	return base + "/traces/" + id
+}
+
+// TraceURLFromContext returns a link to the trace of the span in the supplied
+// context, if any.
+func (u *TraceUI) TraceURLFromContext(ctx context.Context) (string, bool) {
+	span := trace.FromContext(ctx)
+	if span == nil {
+		return "", false
+	}
+	return u.TraceURL(span.SpanContext()), true
+}
+
+// traceIDHex returns the supplied trace ID hex encoded. 64 bit trace IDs, i.e.
+// those propagated by linkerds that predate 128 bit trace IDs, are encoded as
+// 16 characters because that is how they are reported to Zipkin and Jaeger.
+func traceIDHex(id trace.TraceID) string {
+	if binary.BigEndian.Uint64(id[:8]) == 0 {
+		return hex.EncodeToString(id[8:])
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// DefaultTraceUIHeader is the response header in which TraceUIHandler sets the
+// trace link when no header is configured.
+const DefaultTraceUIHeader = "X-Trace-Url"
+
+// TraceUIHandler is an http.Handler that sets a response header linking to the
+// trace of each sampled request, shortening the debug loop for engineers
+// calling a service directly. The link reveals internal trace identifiers and
+// infrastructure, so TraceUIHandler should be used only in non-production
+// environments. TraceUIHandler must be wrapped by an ochttp.Handler.
+type TraceUIHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// UI is the trace UI to which the response header links.
+	UI *TraceUI
+
+	// Header is the response header in which the trace link is set. The
+	// DefaultTraceUIHeader is used if Header is empty.
+	Header string
+}
+
+// ServeHTTP sets the trace link response header if the request is sampled,
+// then serves the request.
+func (h *TraceUIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if span := trace.FromContext(r.Context()); span != nil && span.SpanContext().IsSampled() {
+		header := h.Header
+		if header == "" {
+			header = DefaultTraceUIHeader
+		}
+		w.Header().Set(header, h.UI.TraceURL(span.SpanContext()))
+	}
+	h.Handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/trace"
+)
+
+func TestTraceURL(t *testing.T) {
+	sc128 := trace.SpanContext{
+		TraceID: trace.TraceID{0x46, 0x3a, 0xc3, 0x5c, 0x9f, 0x64, 0x13, 0xad, 0x48, 0x48, 0x5a, 0x3f, 0x43, 0x06, 0x92, 0x8f},
+		SpanID:  trace.SpanID{0xa2, 0xfb, 0x46, 0x4d, 0x6b, 0x0e, 0x49, 0xa1},
+	}
+	sc64 := trace.SpanContext{
+		TraceID: trace.TraceID{8: 0x46, 9: 0x3a, 10: 0xc3, 11: 0x5c, 12: 0x9f, 13: 0x64, 14: 0x13, 15: 0xad},
+		SpanID:  trace.SpanID{0xa2, 0xfb, 0x46, 0x4d, 0x6b, 0x0e, 0x49, 0xa1},
+	}
+
+	cases := []struct {
+		name string
+		ui   *TraceUI
+		sc   trace.SpanContext
+		want string
+	}{
+		{
+			name: "Zipkin",
+			ui:   &TraceUI{URL: "http://zipkin:9411/zipkin"},
+			sc:   sc128,
+			want: "http://zipkin:9411/zipkin/traces/463ac35c9f6413ad48485a3f4306928f",
+		},
+		{
+			name: "Zipkin64BitTraceID",
+			ui:   &TraceUI{URL: "http://zipkin:9411/zipkin/", Kind: UIZipkin},
+			sc:   sc64,
+			want: "http://zipkin:9411/zipkin/traces/463ac35c9f6413ad",
+		},
+		{
+			name: "Jaeger",
+			ui:   &TraceUI{URL: "https://jaeger.example.org", Kind: UIJaeger},
+			sc:   sc128,
+			want: "https://jaeger.example.org/trace/463ac35c9f6413ad48485a3f4306928f?uiFind=a2fb464d6b0e49a1",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.ui.TraceURL(tc.sc); got != tc.want {
+				t.Errorf("u.TraceURL(): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTraceURLFromContext(t *testing.T) {
+	ui := &TraceUI{URL: "http://zipkin:9411/zipkin"}
+	if _, ok := ui.TraceURLFromContext(context.Background()); ok {
+		t.Errorf("u.TraceURLFromContext(): want no URL for a context without a span")
+	}
+
+	ctx, span := trace.StartSpan(context.Background(), "test")
+	defer span.End()
+	got, ok := ui.TraceURLFromContext(ctx)
+	if !ok {
+		t.Fatalf("u.TraceURLFromContext(): want URL for a context with a span")
+	}
+	if want := ui.TraceURL(span.SpanContext()); got != want {
+		t.Errorf("u.TraceURLFromContext(): want %q, got %q", want, got)
+	}
+}
+
+func TestTraceUIHandler(t *testing.T) {
+	ui := &TraceUI{URL: "http://zipkin:9411/zipkin"}
+
+	cases := []struct {
+		name    string
+		header  string
+		sampler trace.Sampler
+		want    string
+	}{
+		{name: "Sampled", sampler: trace.AlwaysSample(), want: DefaultTraceUIHeader},
+		{name: "CustomHeader", header: "X-Zipkin", sampler: trace.AlwaysSample(), want: "X-Zipkin"},
+		{name: "NotSampled", sampler: trace.NeverSample()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sc trace.SpanContext
+			h := &ochttp.Handler{
+				Handler: &TraceUIHandler{
+					Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						sc = trace.FromContext(r.Context()).SpanContext()
+					}),
+					UI:     ui,
+					Header: tc.header,
+				},
+				StartOptions: trace.StartOptions{Sampler: tc.sampler},
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.org", nil))
+
+			if tc.want == "" {
+				for k := range w.Header() {
+					if k == DefaultTraceUIHeader {
+						t.Errorf("h.ServeHTTP(): want no %s header, got %v", k, w.Header())
+					}
+				}
+				return
+			}
+			if got, want := w.Header().Get(tc.want), ui.TraceURL(sc); got != want {
+				t.Errorf("h.ServeHTTP(): want %s header %q, got %q", tc.want, want, got)
+			}
+		})
+	}
+}
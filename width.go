@@ -0,0 +1,107 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/planetlabs/linkin/wire"
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// A WidthMismatch describes a request whose trace ID is of a different width,
+// in bits, than the trace IDs emitted locally.
+type WidthMismatch struct {
+	// Upstream is the width of the request's trace ID; 64 or 128.
+	Upstream int
+
+	// Local is the width of locally emitted trace IDs; 64 or 128.
+	Local int
+}
+
+// String returns the mismatch as recorded by the KeyWidthMismatch tag, e.g.
+// "64/128" for a 64 bit upstream trace ID and 128 bit local trace IDs.
+func (m WidthMismatch) String() string {
+	return fmt.Sprintf("%d/%d", m.Upstream, m.Local)
+}
+
+// TraceIDWidth returns the width, in bits, of the trace ID propagated by the
+// supplied request's l5d-ctx-trace header, or by its X-B3-TraceId header if it
+// has no l5d-ctx-trace header. It returns false if the request propagates no
+// trace ID. Note that a 128 bit wide trace ID may have zero high bits.
+func TraceIDWidth(r *http.Request) (int, bool) {
+	if v := strings.TrimRight(headerValue(r.Header, l5dHeaderTrace), "="); v != "" {
+		if _, err := wire.Parse(v); err != nil {
+			return 0, false
+		}
+		if base64.RawStdEncoding.DecodedLen(len(v)) == 40 {
+			return 128, true
+		}
+		return 64, true
+	}
+	switch len(r.Header.Get(b3.TraceIDHeader)) {
+	case 16:
+		return 64, true
+	case 32:
+		return 128, true
+	}
+	return 0, false
+}
+
+// WidthHandler is an http.Handler that detects requests whose trace IDs are
+// of a different width than those emitted locally; e.g. an upstream that sends
+// 64 bit trace IDs to a service that emits 128 bit trace IDs. Some tracing
+// systems treat the two widths as distinct traces, so such mismatches silently
+// fragment traces. Each mismatch is recorded by the WidthMismatches measure.
+type WidthHandler struct {
+	// Handler is the handler used to handle the incoming request.
+	Handler http.Handler
+
+	// Linkerd determines the width of locally emitted trace IDs, as it does
+	// for HTTPFormat. 128 bit trace IDs are emitted if Linkerd is nil. Note
+	// that the Detector of an HTTPFormat observes incoming requests, so an
+	// upstream that sends 128 bit trace IDs upgrades a Detector that was
+	// emitting 64 bit trace IDs.
+	Linkerd *Detector
+
+	// Report is called with each request whose trace ID width mismatches,
+	// before the request is served. Mismatches are not reported if Report is
+	// nil.
+	Report func(r *http.Request, m WidthMismatch)
+}
+
+// ServeHTTP records and reports any mismatch, then serves the request.
+func (h *WidthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if upstream, ok := TraceIDWidth(r); ok {
+		m := WidthMismatch{Upstream: upstream, Local: 128}
+		if h.Linkerd != nil && !h.Linkerd.Capabilities().TraceID128 {
+			m.Local = 64
+		}
+		if m.Upstream != m.Local {
+			stats.RecordWithTags(r.Context(), []tag.Mutator{tag.Upsert(KeyWidthMismatch, m.String())}, WidthMismatches.M(1))
+			if h.Report != nil {
+				h.Report(r, m)
+			}
+		}
+	}
+	h.Handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestWidthHandler(t *testing.T) {
+	const (
+		l5d64  = "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY="
+		l5d128 = "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA=="
+	)
+
+	cases := []struct {
+		name    string
+		header  map[string]string
+		linkerd *Detector
+		want    *WidthMismatch
+	}{
+		{
+			name:   "Linkerd64",
+			header: map[string]string{l5dHeaderTrace: l5d64},
+			want:   &WidthMismatch{Upstream: 64, Local: 128},
+		},
+		{
+			name:   "Linkerd128",
+			header: map[string]string{l5dHeaderTrace: l5d128},
+		},
+		{
+			name:   "Linkerd128Unpadded",
+			header: map[string]string{l5dHeaderTrace: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAYAAAAAAAAAAA"},
+		},
+		{
+			name:   "Linkerd64Unpadded",
+			header: map[string]string{l5dHeaderTrace: "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY"},
+			want:   &WidthMismatch{Upstream: 64, Local: 128},
+		},
+		{
+			name:    "Linkerd128ToLocal64",
+			header:  map[string]string{l5dHeaderTrace: l5d128},
+			linkerd: NewDetector(Capabilities{}),
+			want:    &WidthMismatch{Upstream: 128, Local: 64},
+		},
+		{
+			name:    "Linkerd64ToLocal64",
+			header:  map[string]string{l5dHeaderTrace: l5d64},
+			linkerd: NewDetector(Capabilities{}),
+		},
+		{
+			name:   "B364",
+			header: map[string]string{"X-B3-Traceid": "463ac35c9f6413ad", "X-B3-Spanid": "463ac35c9f6413ad"},
+			want:   &WidthMismatch{Upstream: 64, Local: 128},
+		},
+		{
+			name:   "B3128",
+			header: map[string]string{"X-B3-Traceid": "463ac35c9f6413ad48485a3953bb6124", "X-B3-Spanid": "463ac35c9f6413ad"},
+		},
+		{
+			name:   "InvalidLinkerd",
+			header: map[string]string{l5dHeaderTrace: "invalid"},
+		},
+		{
+			name: "NoTrace",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := view.Register(WidthMismatchesView); err != nil {
+				t.Fatalf("view.Register(): %v", err)
+			}
+			defer view.Unregister(WidthMismatchesView)
+
+			r := httptest.NewRequest("GET", "http://example.org", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+
+			var got *WidthMismatch
+			h := &WidthHandler{
+				Handler: http.NotFoundHandler(),
+				Linkerd: tc.linkerd,
+				Report:  func(_ *http.Request, m WidthMismatch) { got = &m },
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("h.ServeHTTP(): want mismatch %+v, got %+v", tc.want, got)
+			}
+
+			rows, err := view.RetrieveData(WidthMismatchesView.Name)
+			if err != nil {
+				t.Fatalf("view.RetrieveData(): %v", err)
+			}
+			if tc.want == nil {
+				if len(rows) != 0 {
+					t.Errorf("view.RetrieveData(): want no rows, got %v", rows)
+				}
+				return
+			}
+			want := []tag.Tag{{Key: KeyWidthMismatch, Value: tc.want.String()}}
+			if len(rows) != 1 || !reflect.DeepEqual(rows[0].Tags, want) {
+				t.Errorf("view.RetrieveData(): want one row tagged %v, got %v", want, rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package wire encodes and decodes linkerd's l5d-ctx-trace header, i.e. a
+// serialized Finagle TraceId, using only the standard library. It is the codec
+// upon which the linkin OpenCensus propagation formats are built, and may be
+// imported by proxies and tools that need the codec without the OpenCensus
+// dependency tree.
+//
+// The header is a base64 encoded 32 or 40 byte array (depending on whether the
+// trace ID is 64 or 128 bit) with the following Finagle serialization format:
+//
+//  spanID:8 parentID:8 traceIDLow:8 flags:8 traceIDHigh:8
+//
+// https://github.com/twitter/finagle/blob/345d7a2/finagle-core/src/main/scala/com/twitter/finagle/tracing/Id.scala#L113
+package wire
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Header is the name of the HTTP header that carries a serialized TraceID.
+const Header = "l5d-ctx-trace"
+
+// Finagle flags. Only the lowest three bits are defined.
+// https://github.com/twitter/finagle/blob/345d7a2/finagle-core/src/main/scala/com/twitter/finagle/tracing/Flags.scala
+const (
+	// FlagDebug forces a trace to be sampled at every hop.
+	FlagDebug uint64 = 1
+
+	// FlagSamplingKnown indicates that a sampling decision was made.
+	FlagSamplingKnown uint64 = 1 << 1
+
+	// FlagSampled indicates that the trace is sampled, if FlagSamplingKnown
+	// is also set.
+	FlagSampled uint64 = 1 << 2
+)
+
+// A TraceID is a decoded l5d-ctx-trace header, i.e. a serialized Finagle
+// TraceId.
+type TraceID struct {
+	// Span is the span ID.
+	Span [8]byte
+
+	// Parent is the parent span ID.
+	Parent [8]byte
+
+	// Trace is the trace ID. The high 64 bits are zero for 64 bit trace IDs.
+	Trace [16]byte
+
+	// Flags are the Finagle flags.
+	Flags uint64
+}
+
+// Parse decodes a base64 encoded l5d-ctx-trace header value. The standard and
+// URL safe base64 alphabets are accepted, with or without padding, because some
+// intermediaries normalize headers to a specific variant.
+func Parse(h string) (TraceID, error) {
+	id := TraceID{}
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(h, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	b, err := enc.DecodeString(strings.TrimRight(h, "="))
+	if err != nil {
+		return id, fmt.Errorf("cannot decode trace header: %v", err)
+	}
+	if len(b) != 32 && len(b) != 40 {
+		return id, fmt.Errorf("invalid trace header length: want 32 or 40 bytes, got %d", len(b))
+	}
+
+	copy(id.Span[:], b[0:8])
+	copy(id.Parent[:], b[8:16])
+	copy(id.Trace[8:16], b[16:24])
+	id.Flags = binary.BigEndian.Uint64(b[24:32])
+	if len(b) == 40 {
+		copy(id.Trace[0:8], b[32:])
+	}
+	return id, nil
+}
+
+// String returns the TraceID base64 encoded, as per the l5d-ctx-trace header.
+// The 40 byte serialization format (i.e. a 128 bit trace ID) is always used.
+func (id TraceID) String() string {
+	return id.Encode(base64.StdEncoding)
+}
+
+// String32 returns the TraceID base64 encoded in the 32 byte serialization
+// format, as understood by linkerds that predate 128 bit trace IDs. The high 64
+// bits of the trace ID are omitted.
+func (id TraceID) String32() string {
+	return id.Encode32(base64.StdEncoding)
+}
+
+// Encode returns the TraceID encoded in the 40 byte serialization format using
+// the supplied base64 encoding.
+func (id TraceID) Encode(enc *base64.Encoding) string {
+	b := [40]byte{}
+	id.put(b[:32])
+	copy(b[32:], id.Trace[0:8])
+	return enc.EncodeToString(b[:])
+}
+
+// Encode32 returns the TraceID encoded in the 32 byte serialization format
+// using the supplied base64 encoding.
+func (id TraceID) Encode32(enc *base64.Encoding) string {
+	b := [32]byte{}
+	id.put(b[:])
+	return enc.EncodeToString(b[:])
+}
+
+func (id TraceID) put(b []byte) {
+	copy(b[0:8], id.Span[:])
+	copy(b[8:16], id.Parent[:])
+	copy(b[16:24], id.Trace[8:16])
+	binary.BigEndian.PutUint64(b[24:32], id.Flags)
+}
+
+// IsRoot returns true if the TraceID represents the root span of a trace.
+// Finagle root spans have the same span ID as the (low 64 bits of) their trace
+// ID, and either no parent span ID or a parent span ID equal to their span ID.
+func (id TraceID) IsRoot() bool {
+	if !bytes.Equal(id.Span[:], id.Trace[8:16]) {
+		return false
+	}
+	return id.Parent == [8]byte{} || id.Parent == id.Span
+}
+
+// Sampled returns true if the TraceID should be sampled; i.e. if its debug
+// flag is set, or if a sampling decision was made in favor of sampling.
+func (id TraceID) Sampled() bool {
+	if id.Flags&FlagDebug != 0 {
+		return true
+	}
+	return id.Flags&FlagSamplingKnown != 0 && id.Flags&FlagSampled != 0
+}
+
+// Debug returns true if the TraceID's debug flag is set.
+func (id TraceID) Debug() bool {
+	return id.Flags&FlagDebug != 0
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package wire
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		h       string
+		want    TraceID
+		want32  string
+		wantErr bool
+	}{
+		{
+			name: "64BitTraceID",
+			h:    "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+			want: TraceID{
+				Span:   [8]byte{244, 20, 29, 93, 192, 201, 53, 208},
+				Parent: [8]byte{253, 59, 66, 4, 201, 246, 66, 111},
+				Trace:  [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				Flags:  6,
+			},
+			want32: "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+		},
+		{
+			name: "128BitTraceID",
+			h:    "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ==",
+			want: TraceID{
+				Span:  [8]byte{244, 20, 29, 93, 192, 201, 53, 208},
+				Trace: [16]byte{0, 0, 0, 0, 0, 0, 0, 1, 50, 164, 219, 32, 245, 213, 146, 231},
+				Flags: 7,
+			},
+			want32: "9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAc=",
+		},
+		{
+			name: "RawURLEncoding",
+			h:    "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY",
+			want: TraceID{
+				Span:   [8]byte{244, 20, 29, 93, 192, 201, 53, 208},
+				Parent: [8]byte{253, 59, 66, 4, 201, 246, 66, 111},
+				Trace:  [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				Flags:  6,
+			},
+			want32: "9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+		},
+		{
+			name: "URLEncoding",
+			h:    "_BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+			want: TraceID{
+				Span:   [8]byte{252, 20, 29, 93, 192, 201, 53, 208},
+				Parent: [8]byte{253, 59, 66, 4, 201, 246, 66, 111},
+				Trace:  [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231},
+				Flags:  6,
+			},
+			want32: "/BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=",
+		},
+		{
+			name:    "InvalidEncoding",
+			h:       "PROBABLYNOTBASE64",
+			wantErr: true,
+		},
+		{
+			name:    "InvalidLength",
+			h:       "bmVlZWVyZA==",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.h)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Parse(%q): want error %v, got %v", tc.h, tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("Parse(%q):\ngot:  %+v\nwant: %+v", tc.h, got, tc.want)
+			}
+			if tc.wantErr {
+				return
+			}
+			if rt, _ := Parse(got.String()); rt != got {
+				t.Errorf("Parse(id.String()):\ngot:  %+v\nwant: %+v", rt, got)
+			}
+			if s := got.String32(); s != tc.want32 {
+				t.Errorf("id.String32(): want %q, got %q", tc.want32, s)
+			}
+		})
+	}
+}
+
+func TestFlags(t *testing.T) {
+	cases := []struct {
+		name    string
+		flags   uint64
+		sampled bool
+		debug   bool
+	}{
+		{name: "NoDecision", flags: 0},
+		{name: "SampledUnknown", flags: FlagSampled},
+		{name: "NotSampled", flags: FlagSamplingKnown},
+		{name: "Sampled", flags: FlagSamplingKnown | FlagSampled, sampled: true},
+		{name: "Debug", flags: FlagDebug, sampled: true, debug: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			id := TraceID{Flags: tc.flags}
+			if got := id.Sampled(); got != tc.sampled {
+				t.Errorf("id.Sampled(): want %t, got %t", tc.sampled, got)
+			}
+			if got := id.Debug(); got != tc.debug {
+				t.Errorf("id.Debug(): want %t, got %t", tc.debug, got)
+			}
+		})
+	}
+}
+
+func TestIsRoot(t *testing.T) {
+	span := [8]byte{50, 164, 219, 32, 245, 213, 146, 231}
+	tid := [16]byte{0, 0, 0, 0, 0, 0, 0, 0, 50, 164, 219, 32, 245, 213, 146, 231}
+
+	cases := []struct {
+		name string
+		id   TraceID
+		want bool
+	}{
+		{name: "RootWithoutParent", id: TraceID{Span: span, Trace: tid}, want: true},
+		{name: "RootWithSelfParent", id: TraceID{Span: span, Parent: span, Trace: tid}, want: true},
+		{name: "Child", id: TraceID{Span: [8]byte{1}, Parent: span, Trace: tid}},
+		{name: "OrphanWithTraceSpanID", id: TraceID{Span: span, Parent: [8]byte{1}, Trace: tid}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.id.IsRoot(); got != tc.want {
+				t.Errorf("id.IsRoot(): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	id := TraceID{
+		Span:  [8]byte{252, 20, 29, 93, 192, 201, 53, 208},
+		Trace: [16]byte{0, 0, 0, 0, 0, 0, 0, 1, 50, 164, 219, 32, 245, 213, 146, 231},
+		Flags: 7,
+	}
+
+	cases := []struct {
+		name   string
+		enc    *base64.Encoding
+		want   string
+		want32 string
+	}{
+		{name: "Std", enc: base64.StdEncoding, want: "/BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ==", want32: "/BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAc="},
+		{name: "RawStd", enc: base64.RawStdEncoding, want: "/BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ", want32: "/BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAc"},
+		{name: "URL", enc: base64.URLEncoding, want: "_BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ==", want32: "_BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAc="},
+		{name: "RawURL", enc: base64.RawURLEncoding, want: "_BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ", want32: "_BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAc"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := id.Encode(tc.enc); got != tc.want {
+				t.Errorf("id.Encode(): want %q, got %q", tc.want, got)
+			}
+			if got := id.Encode32(tc.enc); got != tc.want32 {
+				t.Errorf("id.Encode32(): want %q, got %q", tc.want32, got)
+			}
+			if got, err := Parse(tc.want); err != nil || got != id {
+				t.Errorf("Parse(%q): want %+v, got %+v, %v", tc.want, id, got, err)
+			}
+		})
+	}
+}
+
+// FuzzParse checks that any header that parses survives a round trip. Captured
+// headers may be added to its corpus using cmd/linkin-corpus.
+func FuzzParse(f *testing.F) {
+	f.Add("9BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY=")
+	f.Add("9BQdXcDJNdAAAAAAAAAAADKk2yD11ZLnAAAAAAAAAAcAAAAAAAAAAQ==")
+	f.Add("_BQdXcDJNdD9O0IEyfZCbzKk2yD11ZLnAAAAAAAAAAY")
+	f.Add("bmVlZWVyZA==")
+	f.Fuzz(func(t *testing.T, h string) {
+		id, err := Parse(h)
+		if err != nil {
+			return
+		}
+		if rt, err := Parse(id.String()); err != nil || rt != id {
+			t.Errorf("Parse(id.String()): want %+v, got %+v, %v", id, rt, err)
+		}
+		low := id
+		low.Trace = [16]byte{}
+		copy(low.Trace[8:], id.Trace[8:])
+		if rt, err := Parse(id.String32()); err != nil || rt != low {
+			t.Errorf("Parse(id.String32()): want %+v, got %+v, %v", low, rt, err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// Captured is trace context captured from a context, for handing to a worker
+// pool. Work is commonly enqueued with a context that is cancelled before (or
+// shortly after) a worker dequeues it, for example when the handler that
+// enqueued it returns. Capturing only the trace context allows a worker to
+// attach it to a context of its own, retaining the parentage of any spans it
+// starts without inheriting the enqueuer's cancellation or deadline.
+type Captured struct {
+	span *trace.Span
+	tags *tag.Map
+}
+
+// Capture captures the span and tags of the supplied context.
+func Capture(ctx context.Context) Captured {
+	return Captured{span: trace.FromContext(ctx), tags: tag.FromContext(ctx)}
+}
+
+// Attach returns a copy of the supplied context with the captured span and
+// tags attached.
+func (c Captured) Attach(ctx context.Context) context.Context {
+	if c.span != nil {
+		ctx = trace.NewContext(ctx, c.span)
+	}
+	if c.tags != nil {
+		ctx = tag.NewContext(ctx, c.tags)
+	}
+	return ctx
+}
+
+// Bind captures the trace context of the supplied context at enqueue time, and
+// returns a function suitable for enqueuing to a worker pool. When a worker
+// invokes the returned function with its own context the captured trace context
+// is attached to the worker's context, which is then passed to fn.
+func Bind(ctx context.Context, fn func(ctx context.Context)) func(ctx context.Context) {
+	c := Capture(ctx)
+	return func(ctx context.Context) {
+		fn(c.Attach(ctx))
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package linkin
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+func TestBind(t *testing.T) {
+	e := &recordingExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	// A bounded worker pool, whose workers run with their own context.
+	queue := make(chan func(context.Context), 1)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for fn := range queue {
+			fn(context.Background())
+		}
+	}()
+
+	ctx, parent := trace.StartSpan(context.Background(), "enqueue", trace.WithSampler(trace.AlwaysSample()))
+	ctx, err := tag.New(ctx, tag.Upsert(keyProduct, "explorer"))
+	if err != nil {
+		t.Fatalf("tag.New(): %v", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	var product string
+	var cancelled bool
+	queue <- Bind(ctx, func(ctx context.Context) {
+		_, span := trace.StartSpan(ctx, "work")
+		span.End()
+		product, _ = tag.FromContext(ctx).Value(keyProduct)
+		cancelled = ctx.Err() != nil
+	})
+
+	// The enqueuer finishes before the work is done.
+	cancel()
+	parent.End()
+	close(queue)
+	wg.Wait()
+
+	spans := e.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Bind(): want 2 exported spans, got %d", len(spans))
+	}
+	work := spans[0]
+	if work.Name == "enqueue" {
+		work = spans[1]
+	}
+	if work.ParentSpanID != parent.SpanContext().SpanID {
+		t.Errorf("Bind(): want child of %v, got parent %v", parent.SpanContext().SpanID, work.ParentSpanID)
+	}
+	if product != "explorer" {
+		t.Errorf("Bind(): want tag %s=%q, got %q", keyProduct.Name(), "explorer", product)
+	}
+	if cancelled {
+		t.Errorf("Bind(): want worker context not to inherit enqueuer cancellation")
+	}
+}
+
+func TestCaptureEmpty(t *testing.T) {
+	ctx := Capture(context.Background()).Attach(context.Background())
+	if trace.FromContext(ctx) != nil || tag.FromContext(ctx) != nil {
+		t.Errorf("Attach(): want no span or tags attached")
+	}
+}
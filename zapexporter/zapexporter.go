@@ -0,0 +1,121 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package zapexporter provides an OpenCensus trace exporter that logs completed
+// spans via zap at debug level, giving developers visibility of their spans
+// locally without running Zipkin.
+package zapexporter
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultMessage is the message with which spans are logged when no message is
+// configured.
+const DefaultMessage = "span"
+
+// Exporter is a trace.Exporter that logs each exported span at debug level.
+// Trace IDs are hex encoded as they are reported to Zipkin, so that logged
+// spans may be looked up there.
+type Exporter struct {
+	// Logger is the logger to which spans are logged. Spans are not logged if
+	// Logger is nil.
+	Logger *zap.Logger
+
+	// Message is the message with which spans are logged. The DefaultMessage
+	// is used if Message is empty.
+	Message string
+}
+
+// ExportSpan logs the supplied span.
+func (e *Exporter) ExportSpan(s *trace.SpanData) {
+	if e.Logger == nil {
+		return
+	}
+	msg := e.Message
+	if msg == "" {
+		msg = DefaultMessage
+	}
+	// Avoid building fields for spans that would not be logged.
+	ce := e.Logger.Check(zapcore.DebugLevel, msg)
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("name", s.Name),
+		zap.String("trace_id", traceIDHex(s.TraceID)),
+		zap.String("span_id", s.SpanID.String()),
+		zap.Duration("duration", s.EndTime.Sub(s.StartTime)),
+		zap.Bool("sampled", s.IsSampled()),
+	}
+	if s.ParentSpanID != (trace.SpanID{}) {
+		fields = append(fields, zap.String("parent_span_id", s.ParentSpanID.String()))
+	}
+	if k := kind(s.SpanKind); k != "" {
+		fields = append(fields, zap.String("kind", k))
+	}
+	if s.Code != trace.StatusCodeOK {
+		fields = append(fields, zap.Int32("status_code", s.Code), zap.String("status_message", s.Message))
+	}
+	if len(s.Attributes) > 0 {
+		fields = append(fields, zap.Object("attributes", attributes(s.Attributes)))
+	}
+	ce.Write(fields...)
+}
+
+// traceIDHex returns the supplied trace ID hex encoded. 64 bit trace IDs, i.e.
+// those propagated by linkerds that predate 128 bit trace IDs, are encoded as
+// 16 characters because that is how they are reported to Zipkin and Jaeger.
+func traceIDHex(id trace.TraceID) string {
+	if binary.BigEndian.Uint64(id[:8]) == 0 {
+		return hex.EncodeToString(id[8:])
+	}
+	return hex.EncodeToString(id[:])
+}
+
+func kind(k int) string {
+	switch k {
+	case trace.SpanKindServer:
+		return "server"
+	case trace.SpanKindClient:
+		return "client"
+	default:
+		return ""
+	}
+}
+
+// attributes marshals span attributes as a zap object with sorted keys.
+type attributes map[string]interface{}
+
+func (a attributes) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := enc.AddReflected(k, a[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
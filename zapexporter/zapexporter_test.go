@@ -0,0 +1,146 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package zapexporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExporterSatisfiesExporter(t *testing.T) {
+	var _ trace.Exporter = (*Exporter)(nil)
+}
+
+func TestExportSpan(t *testing.T) {
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	span := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:      trace.TraceID{0x01},
+			SpanID:       trace.SpanID{0x02},
+			TraceOptions: trace.TraceOptions(1),
+		},
+		ParentSpanID: trace.SpanID{0x03},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "/users",
+		StartTime:    start,
+		EndTime:      start.Add(150 * time.Millisecond),
+		Attributes:   map[string]interface{}{"http.method": "GET", "http.status_code": int64(503)},
+		Status:       trace.Status{Code: trace.StatusCodeUnavailable, Message: "Unavailable"},
+	}
+
+	cases := []struct {
+		name    string
+		level   zapcore.Level
+		message string
+		want    []observer.LoggedEntry
+	}{
+		{
+			name:  "Debug",
+			level: zapcore.DebugLevel,
+			want: []observer.LoggedEntry{{
+				Entry: zapcore.Entry{Level: zapcore.DebugLevel, Message: DefaultMessage},
+				Context: []zapcore.Field{
+					zap.String("name", "/users"),
+					zap.String("trace_id", "01000000000000000000000000000000"),
+					zap.String("span_id", "0200000000000000"),
+					zap.Duration("duration", 150*time.Millisecond),
+					zap.Bool("sampled", true),
+					zap.String("parent_span_id", "0300000000000000"),
+					zap.String("kind", "server"),
+					zap.Int32("status_code", trace.StatusCodeUnavailable),
+					zap.String("status_message", "Unavailable"),
+					zap.Object("attributes", attributes(span.Attributes)),
+				},
+			}},
+		},
+		{
+			name:    "Message",
+			level:   zapcore.DebugLevel,
+			message: "completed span",
+		},
+		{
+			name:  "InfoLevel",
+			level: zapcore.InfoLevel,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			core, logs := observer.New(tc.level)
+			e := &Exporter{Logger: zap.New(core), Message: tc.message}
+			e.ExportSpan(span)
+
+			got := logs.AllUntimed()
+			if tc.level > zapcore.DebugLevel {
+				if len(got) != 0 {
+					t.Errorf("e.ExportSpan(): want no log entries, got %v", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("e.ExportSpan(): want 1 log entry, got %d", len(got))
+			}
+			if tc.message != "" && got[0].Message != tc.message {
+				t.Errorf("e.ExportSpan(): want message %q, got %q", tc.message, got[0].Message)
+			}
+			if tc.want != nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("e.ExportSpan(): want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExportSpanNilLogger(t *testing.T) {
+	(&Exporter{}).ExportSpan(&trace.SpanData{})
+}
+
+func TestMarshalAttributes(t *testing.T) {
+	enc := zapcore.NewMapObjectEncoder()
+	a := attributes{"b": "two", "a": int64(1), "c": true}
+	if err := a.MarshalLogObject(enc); err != nil {
+		t.Fatalf("a.MarshalLogObject(): %v", err)
+	}
+	want := map[string]interface{}{"a": int64(1), "b": "two", "c": true}
+	if !reflect.DeepEqual(enc.Fields, want) {
+		t.Errorf("a.MarshalLogObject(): want %v, got %v", want, enc.Fields)
+	}
+}
+
+func TestTraceIDHex(t *testing.T) {
+	cases := []struct {
+		name string
+		id   trace.TraceID
+		want string
+	}{
+		{name: "TraceID64", id: trace.TraceID{8: 0x32, 15: 0xe7}, want: "32000000000000e7"},
+		{name: "TraceID128", id: trace.TraceID{0x01}, want: "01000000000000000000000000000000"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := traceIDHex(tc.id); got != tc.want {
+				t.Errorf("traceIDHex(%v): want %s, got %s", tc.id, tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+// Package zipkinreporter decorates Zipkin reporters with bounded buffering, and
+// Zipkin's HTTP reporter with retries, so that transient collector outages
+// neither lose traces nor block the requests being traced.
+//
+//  r := zipkinreporter.NewHTTPReporter("http://zipkin:9411/api/v2/spans", zipkinreporter.Options{})
+//  defer r.Close()
+//  trace.RegisterExporter(zipkin.NewExporter(r, endpoint))
+package zipkinreporter
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/stats"
+)
+
+// DefaultBufferSize is the number of spans a Reporter buffers when no buffer
+// size is configured.
+const DefaultBufferSize = 10000
+
+// DefaultMaxRetries is the number of times a batch of spans is retried when no
+// maximum is configured.
+const DefaultMaxRetries = 5
+
+// DefaultTimeout bounds the time spent sending a batch of spans, including
+// retries, when no timeout is configured. It allows for DefaultMaxRetries
+// retries with DefaultBackoff.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultBackoff waits 500ms before the first retry, doubling the wait for each
+// subsequent retry up to a maximum of 30 seconds.
+func DefaultBackoff(retry int) time.Duration {
+	if retry > 5 {
+		return 30 * time.Second
+	}
+	return 500 * time.Millisecond << uint(retry)
+}
+
+// Reporter is a reporter.Reporter that buffers spans before sending them to
+// another reporter. Sending a span to a Reporter never blocks; spans are
+// dropped when its buffer is full, recording the DroppedSpans measure.
+type Reporter struct {
+	base   reporter.Reporter
+	spans  chan model.SpanModel
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewReporter returns a Reporter that buffers up to size spans before sending
+// them to the supplied reporter. The DefaultBufferSize is used if size is not
+// positive.
+func NewReporter(base reporter.Reporter, size int) *Reporter {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	r := &Reporter{base: base, spans: make(chan model.SpanModel, size), done: make(chan struct{})}
+	go r.loop()
+	return r
+}
+
+func (r *Reporter) loop() {
+	for s := range r.spans {
+		r.base.Send(s)
+	}
+	close(r.done)
+}
+
+// Send buffers the supplied span, or drops it if the buffer is full or the
+// Reporter is closed.
+func (r *Reporter) Send(s model.SpanModel) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		stats.Record(context.Background(), linkin.DroppedSpans.M(1))
+		return
+	}
+	select {
+	case r.spans <- s:
+	default:
+		stats.Record(context.Background(), linkin.DroppedSpans.M(1))
+	}
+}
+
+// Close sends all buffered spans to the underlying reporter, then closes it.
+func (r *Reporter) Close() error {
+	r.once.Do(func() {
+		r.mu.Lock()
+		r.closed = true
+		close(r.spans)
+		r.mu.Unlock()
+	})
+	<-r.done
+	return r.base.Close()
+}
+
+// Options configure a reporter returned by NewHTTPReporter.
+type Options struct {
+	// BufferSize is the number of spans buffered before spans are dropped.
+	// The DefaultBufferSize is used if BufferSize is zero.
+	BufferSize int
+
+	// MaxRetries is the maximum number of times a batch of spans is retried.
+	// The DefaultMaxRetries is used if MaxRetries is zero. Batches are not
+	// retried if MaxRetries is negative.
+	MaxRetries int
+
+	// Backoff returns how long to wait before the supplied retry, starting at
+	// zero. DefaultBackoff is used if Backoff is nil.
+	Backoff func(retry int) time.Duration
+
+	// Timeout bounds the time spent sending a batch of spans, including
+	// retries. The DefaultTimeout is used if Timeout is zero.
+	Timeout time.Duration
+
+	// Transport is used to send batches of spans to the Zipkin collector.
+	// http.DefaultTransport is used if Transport is nil. It should not be
+	// traced, lest reporting spans produce more spans.
+	Transport http.RoundTripper
+
+	// ReporterOptions are passed to Zipkin's HTTP reporter, e.g. to configure
+	// its batch size. Any Client or Timeout option is overridden.
+	ReporterOptions []zipkinhttp.ReporterOption
+}
+
+func (o Options) timeout() time.Duration {
+	if o.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
+}
+
+// NewHTTPReporter returns a Reporter that buffers spans before sending them to
+// a Zipkin HTTP reporter for the supplied collector URL, which retries failed
+// batches with backoff.
+func NewHTTPReporter(url string, o Options) *Reporter {
+	t := &RetryTransport{Base: o.Transport, MaxRetries: o.MaxRetries, Backoff: o.Backoff}
+	opts := make([]zipkinhttp.ReporterOption, 0, len(o.ReporterOptions)+2)
+	opts = append(opts, o.ReporterOptions...)
+	opts = append(opts,
+		zipkinhttp.Client(&http.Client{Transport: t, Timeout: o.timeout()}),
+		// Depending on its version the Zipkin reporter applies its timeout
+		// either to its client, or to the context of each batch's request.
+		zipkinhttp.Timeout(o.timeout()),
+	)
+	return NewReporter(zipkinhttp.NewReporter(url, opts...), o.BufferSize)
+}
+
+// RetryTransport is an http.RoundTripper that retries requests that fail to
+// send, or that receive a 5xx response, recording the ReportRetries measure.
+// Unlike linkin.RetryTransport it does not trace its attempts. Requests with a
+// body are only retried if their GetBody function is set.
+type RetryTransport struct {
+	// Base is the RoundTripper used to send requests. http.DefaultTransport is
+	// used if Base is nil.
+	Base http.RoundTripper
+
+	// MaxRetries is the maximum number of times a request is retried. The
+	// DefaultMaxRetries is used if MaxRetries is zero. Requests are not
+	// retried if MaxRetries is negative.
+	MaxRetries int
+
+	// Backoff returns how long to wait before the supplied retry, starting at
+	// zero. DefaultBackoff is used if Backoff is nil.
+	Backoff func(retry int) time.Duration
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *RetryTransport) maxRetries() int {
+	if t.MaxRetries == 0 {
+		return DefaultMaxRetries
+	}
+	return t.MaxRetries
+}
+
+func (t *RetryTransport) backoff(retry int) time.Duration {
+	if t.Backoff == nil {
+		return DefaultBackoff(retry)
+	}
+	return t.Backoff(retry)
+}
+
+// RoundTrip sends the supplied request, retrying it if it fails.
+func (t *RetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		rsp, err := t.base().RoundTrip(r)
+		if !failed(rsp, err) || retry >= t.maxRetries() || (r.Body != nil && r.GetBody == nil) {
+			return rsp, err
+		}
+		if rsp != nil {
+			io.Copy(ioutil.Discard, rsp.Body)
+			rsp.Body.Close()
+		}
+
+		tm := time.NewTimer(t.backoff(retry))
+		select {
+		case <-r.Context().Done():
+			tm.Stop()
+			return nil, r.Context().Err()
+		case <-tm.C:
+		}
+
+		stats.Record(r.Context(), linkin.ReportRetries.M(1))
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			out := r.WithContext(r.Context())
+			out.Body = body
+			r = out
+		}
+	}
+}
+
+func failed(rsp *http.Response, err error) bool {
+	return err != nil || rsp.StatusCode >= 500
+}
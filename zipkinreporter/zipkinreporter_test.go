@@ -0,0 +1,230 @@
+/*
+Copyright 2018 Planet Labs Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions
+and limitations under the License.
+*/
+
+package zipkinreporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/planetlabs/linkin"
+	"go.opencensus.io/stats/view"
+)
+
+func TestReporterSatisfiesReporter(t *testing.T) {
+	var _ reporter.Reporter = (*Reporter)(nil)
+}
+
+// blockingReporter records spans, blocking each Send until unblocked.
+type blockingReporter struct {
+	unblock chan struct{}
+	mu      sync.Mutex
+	spans   []model.SpanModel
+	closed  bool
+}
+
+func (r *blockingReporter) Send(s model.SpanModel) {
+	<-r.unblock
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *blockingReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func dropped(t *testing.T) int64 {
+	rows, err := view.RetrieveData(linkin.DroppedSpansView.Name)
+	if err != nil {
+		t.Fatalf("view.RetrieveData(): %v", err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return int64(rows[0].Data.(*view.SumData).Value)
+}
+
+func TestReporter(t *testing.T) {
+	if err := view.Register(linkin.DroppedSpansView); err != nil {
+		t.Fatalf("view.Register(): %v", err)
+	}
+	defer view.Unregister(linkin.DroppedSpansView)
+
+	base := &blockingReporter{unblock: make(chan struct{})}
+	r := NewReporter(base, 2)
+
+	// The first span is received by the blocked loop, and the next two fill
+	// the buffer. The remaining two are dropped.
+	for i := 0; i < 5; i++ {
+		r.Send(model.SpanModel{Name: "span"})
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := dropped(t); got != 2 {
+		t.Errorf("r.Send(): want 2 dropped spans, got %d", got)
+	}
+
+	close(base.unblock)
+	if err := r.Close(); err != nil {
+		t.Fatalf("r.Close(): %v", err)
+	}
+	if len(base.spans) != 3 {
+		t.Errorf("r.Close(): want 3 sent spans, got %d", len(base.spans))
+	}
+	if !base.closed {
+		t.Errorf("r.Close(): want underlying reporter closed")
+	}
+
+	r.Send(model.SpanModel{Name: "late"})
+	if got := dropped(t); got != 3 {
+		t.Errorf("r.Send(): want span sent after close to be dropped, got %d dropped", got)
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	cases := []struct {
+		name       string
+		failures   int
+		maxRetries int
+		want       int
+		wantCalls  int
+	}{
+		{name: "Success", want: http.StatusAccepted, wantCalls: 1},
+		{name: "Retried", failures: 2, maxRetries: 2, want: http.StatusAccepted, wantCalls: 3},
+		{name: "RetriesExhausted", failures: 3, maxRetries: 2, want: http.StatusServiceUnavailable, wantCalls: 3},
+		{name: "RetriesDisabled", failures: 1, maxRetries: -1, want: http.StatusServiceUnavailable, wantCalls: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if body, _ := ioutil.ReadAll(r.Body); string(body) != "[]" {
+					t.Errorf("want body [], got %q", body)
+				}
+				if calls <= tc.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: &RetryTransport{
+				MaxRetries: tc.maxRetries,
+				Backoff:    func(int) time.Duration { return 0 },
+			}}
+			rsp, err := client.Post(srv.URL, "application/json", strings.NewReader("[]"))
+			if err != nil {
+				t.Fatalf("client.Post(): %v", err)
+			}
+			rsp.Body.Close()
+			if rsp.StatusCode != tc.want {
+				t.Errorf("client.Post(): want status %d, got %d", tc.want, rsp.StatusCode)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("client.Post(): want %d attempts, got %d", tc.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestNewHTTPReporter(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+		spans []model.SpanModel
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []model.SpanModel
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("json.Decode(): %v", err)
+		}
+		spans = append(spans, batch...)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	r := NewHTTPReporter(srv.URL, Options{
+		Backoff:         func(int) time.Duration { return 0 },
+		ReporterOptions: []zipkinhttp.ReporterOption{zipkinhttp.BatchInterval(10 * time.Millisecond)},
+	})
+	r.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1}, Name: "span"})
+	if err := r.Close(); err != nil {
+		t.Fatalf("r.Close(): %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(spans) != 1 || spans[0].Name != "span" {
+		t.Errorf("r.Close(): want span reported after a retry, got %v", spans)
+	}
+}
+
+func TestNewHTTPReporterTimeout(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	opts := make([]zipkinhttp.ReporterOption, 1, 2)
+	opts[0] = zipkinhttp.BatchInterval(10 * time.Millisecond)
+	r := NewHTTPReporter(srv.URL, Options{
+		MaxRetries:      -1,
+		Timeout:         50 * time.Millisecond,
+		ReporterOptions: opts,
+	})
+	if opts[:2][1] != nil {
+		t.Errorf("NewHTTPReporter(): want the caller's ReporterOptions unmodified")
+	}
+
+	r.Send(model.SpanModel{SpanContext: model.SpanContext{TraceID: model.TraceID{Low: 1}, ID: 1}, Name: "span"})
+	closed := make(chan struct{})
+	go func() {
+		r.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("r.Close(): want batch abandoned after its timeout")
+	}
+}